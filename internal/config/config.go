@@ -1,21 +1,36 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
+
+	"gpx-self-host/internal/track"
 )
 
 type Config struct {
-	Port          string
-	StaticDir     string
-	DataDir       string
-	CacheDir      string
-	Providers     map[string]TileProviderConfig
-	ClientTimeout time.Duration
-	MaxRetries    int
-	Offline       bool
+	Port           string
+	StaticDir      string
+	DataDir        string
+	CacheDir       string
+	Providers      map[string]TileProviderConfig
+	ClientTimeout  time.Duration
+	MaxRetries     int
+	Offline        bool
+	WatchInterval  time.Duration
+	MaxUploadSize  int64 // bytes per uploaded file
+	MaxUploadTotal int64 // bytes per upload request, all files together
+
+	// Privacy zones are applied to track data served to clients that are
+	// neither on localhost (when TrustLocalhost is set) nor present
+	// AccessToken as a bearer token.
+	PrivacyZones    []track.PrivacyZone
+	PrivacyTruncate bool
+	TrustLocalhost  bool
+	AccessToken     string
 }
 
 type TileProviderConfig struct {
@@ -41,40 +56,93 @@ func Load() *Config {
 // hardcoded values.
 func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
 	defaultConfig := Config{
-		Port:          ":8080",
-		StaticDir:     "./static",
-		DataDir:       "./data",
-		CacheDir:      "./cache",
-		ClientTimeout: 10 * time.Second,
-		MaxRetries:    3,
-		Offline:       false,
-		Providers:     defaultProviders(),
+		Port:           ":8080",
+		StaticDir:      "./static",
+		DataDir:        "./data",
+		CacheDir:       "./cache",
+		ClientTimeout:  10 * time.Second,
+		MaxRetries:     3,
+		Offline:        false,
+		WatchInterval:  5 * time.Second,
+		MaxUploadSize:  50 << 20,
+		MaxUploadTotal: 200 << 20,
+		Providers:      defaultProviders(),
 	}
 
 	port := fs.String("port", defaultConfig.Port, "Port to listen on (e.g. :8080)")
 	staticDir := fs.String("static-dir", defaultConfig.StaticDir, "Directory to serve static assets from")
 	dataDir := fs.String("data-dir", defaultConfig.DataDir, "Directory containing GPX files")
-	cacheDir := fs.String("cache-dir", defaultConfig.CacheDir, "Directory to store cached map tiles")
+	cacheDir := fs.String("cache-dir", defaultConfig.CacheDir, "Directory to store cached map tiles and the library index")
 	clientTimeout := fs.Duration("client-timeout", defaultConfig.ClientTimeout, "HTTP client timeout for tile downloads")
 	maxRetries := fs.Int("max-retries", defaultConfig.MaxRetries, "Maximum retry attempts when downloading tiles")
 	offline := fs.Bool("offline", defaultConfig.Offline, "Serve tiles from cache only; do not download new tiles")
+	watchInterval := fs.Duration("watch-interval", defaultConfig.WatchInterval, "How often to poll the data dir for changes (0 disables live updates)")
+	maxUploadMB := fs.Int64("max-upload-mb", defaultConfig.MaxUploadSize>>20, "Maximum size of a single uploaded track file in MiB")
+	maxUploadTotalMB := fs.Int64("max-upload-total-mb", defaultConfig.MaxUploadTotal>>20, "Maximum total size of the files in one upload request in MiB")
+	privacyZones := fs.String("privacy-zones", "", "JSON file with privacy zones whose points are withheld from untrusted clients")
+	privacyMode := fs.String("privacy-mode", "strip", "How privacy zones cut tracks: strip (all points inside) or truncate (only start and end)")
+	trustLocalhost := fs.Bool("privacy-trust-localhost", true, "Serve unfiltered track data to clients connecting from localhost")
+	accessToken := fs.String("access-token", "", "Bearer token that unlocks unfiltered track data for remote clients")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
+	if *maxUploadMB <= 0 {
+		return nil, fmt.Errorf("max-upload-mb must be positive")
+	}
+	if *maxUploadTotalMB <= 0 {
+		return nil, fmt.Errorf("max-upload-total-mb must be positive")
+	}
+	if *privacyMode != "strip" && *privacyMode != "truncate" {
+		return nil, fmt.Errorf("privacy-mode must be strip or truncate")
+	}
+	var zones []track.PrivacyZone
+	if *privacyZones != "" {
+		var err error
+		if zones, err = loadPrivacyZones(*privacyZones); err != nil {
+			return nil, err
+		}
+	}
 
 	return &Config{
-		Port:          *port,
-		StaticDir:     *staticDir,
-		DataDir:       *dataDir,
-		CacheDir:      *cacheDir,
-		ClientTimeout: *clientTimeout,
-		MaxRetries:    *maxRetries,
-		Providers:     defaultProviders(),
-		Offline:       *offline,
+		Port:           *port,
+		StaticDir:      *staticDir,
+		DataDir:        *dataDir,
+		CacheDir:       *cacheDir,
+		ClientTimeout:  *clientTimeout,
+		MaxRetries:     *maxRetries,
+		Providers:      defaultProviders(),
+		Offline:        *offline,
+		WatchInterval:  *watchInterval,
+		MaxUploadSize:  *maxUploadMB << 20,
+		MaxUploadTotal: *maxUploadTotalMB << 20,
+
+		PrivacyZones:    zones,
+		PrivacyTruncate: *privacyMode == "truncate",
+		TrustLocalhost:  *trustLocalhost,
+		AccessToken:     *accessToken,
 	}, nil
 }
 
+// loadPrivacyZones reads a JSON array of zones, e.g.
+// [{"name": "Home", "lat": 59.43, "lon": 24.75, "radius": 300}].
+func loadPrivacyZones(path string) ([]track.PrivacyZone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("privacy-zones: %w", err)
+	}
+	var zones []track.PrivacyZone
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return nil, fmt.Errorf("privacy-zones: %w", err)
+	}
+	for _, z := range zones {
+		if err := z.Validate(); err != nil {
+			return nil, fmt.Errorf("privacy-zones: %w", err)
+		}
+	}
+	return zones, nil
+}
+
 func defaultProviders() map[string]TileProviderConfig {
 	return map[string]TileProviderConfig{
 		"openstreetmap": {
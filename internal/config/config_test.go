@@ -2,6 +2,8 @@ package config
 
 import (
 	"flag"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -25,6 +27,18 @@ func TestParse_Defaults(t *testing.T) {
 	if cfg.Offline != false {
 		t.Error("expected offline false")
 	}
+	if cfg.WatchInterval != 5*time.Second {
+		t.Errorf("expected watch interval 5s, got %v", cfg.WatchInterval)
+	}
+	if cfg.MaxUploadSize != 50<<20 {
+		t.Errorf("expected max upload size 50 MiB, got %d", cfg.MaxUploadSize)
+	}
+	if cfg.MaxUploadTotal != 200<<20 {
+		t.Errorf("expected max upload total 200 MiB, got %d", cfg.MaxUploadTotal)
+	}
+	if len(cfg.PrivacyZones) != 0 || cfg.PrivacyTruncate || !cfg.TrustLocalhost || cfg.AccessToken != "" {
+		t.Error("expected no privacy zones, strip mode and trusted localhost by default")
+	}
 	if len(cfg.Providers) == 0 {
 		t.Error("expected default providers to be loaded")
 	}
@@ -40,6 +54,9 @@ func TestParse_CustomFlags(t *testing.T) {
 		"-client-timeout", "5s",
 		"-max-retries", "5",
 		"-offline",
+		"-watch-interval", "0",
+		"-max-upload-mb", "5",
+		"-max-upload-total-mb", "20",
 	}
 
 	cfg, err := Parse(fs, args)
@@ -68,6 +85,15 @@ func TestParse_CustomFlags(t *testing.T) {
 	if cfg.Offline != true {
 		t.Error("expected offline true")
 	}
+	if cfg.WatchInterval != 0 {
+		t.Errorf("expected watch interval 0, got %v", cfg.WatchInterval)
+	}
+	if cfg.MaxUploadSize != 5<<20 {
+		t.Errorf("expected max upload size 5 MiB, got %d", cfg.MaxUploadSize)
+	}
+	if cfg.MaxUploadTotal != 20<<20 {
+		t.Errorf("expected max upload total 20 MiB, got %d", cfg.MaxUploadTotal)
+	}
 }
 
 func TestParse_Error(t *testing.T) {
@@ -77,3 +103,44 @@ func TestParse_Error(t *testing.T) {
 		t.Error("expected error for unknown flag")
 	}
 }
+
+func TestParse_InvalidUploadSize(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-max-upload-mb", "0"}); err == nil {
+		t.Error("expected error for non-positive upload size")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-max-upload-total-mb", "-1"}); err == nil {
+		t.Error("expected error for non-positive upload total")
+	}
+}
+
+func TestParse_PrivacyZones(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "zones.json")
+	if err := os.WriteFile(good, []byte(`[{"name":"Home","lat":59.43,"lon":24.75,"radius":300},{"polygon":[[59,24],[59,25],[60,25]]}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-privacy-zones", good, "-privacy-mode", "truncate", "-privacy-trust-localhost=false", "-access-token", "secret"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cfg.PrivacyZones) != 2 || cfg.PrivacyZones[0].Name != "Home" || !cfg.PrivacyTruncate || cfg.TrustLocalhost || cfg.AccessToken != "secret" {
+		t.Errorf("unexpected privacy config: %+v", cfg)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`[{"name":"Home","lat":59.43,"lon":24.75}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-privacy-zones", bad},
+		{"-privacy-zones", filepath.Join(dir, "missing.json")},
+		{"-privacy-mode", "blur"},
+	} {
+		if _, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
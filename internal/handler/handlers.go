@@ -2,28 +2,72 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"gpx-self-host/internal/config"
 	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
 )
 
 type GPXService interface {
 	ListFiles() ([]model.GPXFile, error)
+	GetTrack(relPath string) (model.GPXDetailResponse, error)
+	GetProfile(relPath string, points int) (model.ProfileResponse, error)
+	GetGeoJSON(relPath string, tolerance float64, algorithm string) ([]byte, error)
+	Export(relPath, format string) ([]byte, error)
+	Search(params url.Values) ([]model.GPXFile, int, error)
+	StatsSummary(params url.Values) (model.StatsSummaryResponse, error)
+	Records(activity string) (model.RecordsResponse, error)
+	Calendar(year int) (model.CalendarResponse, error)
+	ListSegments() ([]model.Segment, error)
+	CreateSegment(req model.SegmentRequest) (model.Segment, error)
+	GetSegment(id string) (model.SegmentResponse, error)
+	DeleteSegment(id string) error
+	Duplicates() (model.DuplicatesResponse, error)
+	ResolveDuplicates(req model.ResolveDuplicatesRequest) (model.ResolveDuplicatesResponse, error)
+	Compare(planPath, activityPath string) (model.CompareResponse, error)
+	PlanMatches(planPath string) ([]model.PlanMatch, error)
+	Upload(req model.UploadRequest) ([]model.GPXFile, error)
+	Move(relPath string, req model.MoveRequest) (model.GPXFile, error)
+	Delete(relPath string) (model.TrashItem, error)
+	ListTrash() ([]model.TrashItem, error)
+	RestoreTrash(id string) (model.GPXFile, error)
+	PurgeTrash(id string) error
+	Trim(relPath string, req model.TrimRequest) (model.TrimResponse, error)
+	Merge(req model.MergeRequest) (model.GPXFile, error)
+	Split(relPath string, req model.SplitRequest) ([]model.GPXFile, error)
+	HeatmapTile(z, x, y int, values url.Values) ([]byte, error)
+	Thumbnail(relPath, format string, size int, base *render.Basemap) ([]byte, error)
+	Snapshot(req model.SnapshotRequest, base *render.Basemap) ([]byte, error)
+	Subscribe() (<-chan model.LibraryEvent, func())
 }
 
 type TilesService interface {
 	GetTile(ctx context.Context, providerName, z, x, yPng string) (string, error)
 	PrewarmView(ctx context.Context, req model.PrewarmViewRequest) (model.PrewarmViewResponse, error)
 	GetStats() model.StatusResponse
+	TileImage(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error)
 }
 
 type Handlers struct {
 	cfg         *config.Config
 	gpxService  GPXService
 	tileService TilesService
+	redacted    GPXService // serves untrusted clients when privacy zones are set
 }
 
 func New(cfg *config.Config, gpxService GPXService, tileService TilesService) *Handlers {
@@ -34,8 +78,71 @@ func New(cfg *config.Config, gpxService GPXService, tileService TilesService) *H
 	}
 }
 
+// SetRedactedService makes track data for untrusted clients come from svc,
+// which is expected to withhold the points inside the privacy zones.
+func (h *Handlers) SetRedactedService(svc GPXService) {
+	h.redacted = svc
+}
+
+// readService picks the service that serves track data to r: the redacted
+// one unless no privacy zones are set or the client is trusted.
+func (h *Handlers) readService(r *http.Request) GPXService {
+	if h.redacted == nil || h.trusted(r) {
+		return h.gpxService
+	}
+	return h.redacted
+}
+
+// trusted reports whether r may see unfiltered track data: it carries the
+// configured access token, or comes straight from localhost. Requests passed
+// on by a proxy are never treated as local, since the proxy's own loopback
+// address says nothing about the real client.
+func (h *Handlers) trusted(r *http.Request) bool {
+	if h.cfg == nil {
+		return false
+	}
+	if h.cfg.AccessToken != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.AccessToken)) == 1 {
+			return true
+		}
+	}
+	if !h.cfg.TrustLocalhost {
+		return false
+	}
+	for _, header := range []string{"Forwarded", "X-Forwarded-For", "X-Real-Ip"} {
+		if r.Header.Get(header) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (h *Handlers) ListGPXFiles(w http.ResponseWriter, r *http.Request) {
-	files, err := h.gpxService.ListFiles()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		h.uploadGPXFiles(w, r)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	svc := h.readService(r)
+	params := r.URL.Query()
+	if len(params) > 0 {
+		h.searchGPXFiles(w, svc, params)
+		return
+	}
+
+	files, err := svc.ListFiles()
 	if err != nil {
 		http.Error(w, "Error scanning data folder: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -48,6 +155,950 @@ func (h *Handlers) ListGPXFiles(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// searchGPXFiles serves /api/gpx?q=...&sort=...&limit=...&offset=... The
+// response stays a plain array; the match count before pagination is sent in
+// X-Total-Count.
+func (h *Handlers) searchGPXFiles(w http.ResponseWriter, svc GPXService, params url.Values) {
+	files, total, err := svc.Search(params)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid query") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error scanning data folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+const (
+	maxUploadFiles     = 100
+	maxUploadField     = 1 << 10  // bytes of a destination or activity value
+	uploadFormOverhead = 64 << 10 // multipart headers and form fields on top of the files
+)
+
+// uploadGPXFiles serves POST /api/gpx: a multipart form with one or more
+// "files" parts plus optional "destination" (activities|plans) and
+// "activity" fields. It responds 201 with the new listing entries. Parts are
+// read one at a time: each file is checked for a supported type and the
+// per-file limit as it arrives, and the whole request is capped by
+// MaxUploadTotal.
+func (h *Handlers) uploadGPXFiles(w http.ResponseWriter, r *http.Request) {
+	maxSize := h.cfg.MaxUploadSize
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadTotal+uploadFormOverhead)
+	readError := func(err error) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Upload exceeds the %d MiB limit", h.cfg.MaxUploadTotal>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+		return
+	}
+
+	var req model.UploadRequest
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readError(err)
+			return
+		}
+		switch part.FormName() {
+		case "destination", "activity":
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadField+1))
+			if err != nil {
+				readError(err)
+				return
+			}
+			if len(value) > maxUploadField {
+				http.Error(w, part.FormName()+" is too long", http.StatusBadRequest)
+				return
+			}
+			if part.FormName() == "destination" {
+				req.Destination = string(value)
+			} else {
+				req.Activity = string(value)
+			}
+		case "files":
+			name := part.FileName()
+			if len(req.Files) == maxUploadFiles {
+				http.Error(w, fmt.Sprintf("At most %d files per upload", maxUploadFiles), http.StatusBadRequest)
+				return
+			}
+			if track.FormatOf(name) == "" {
+				http.Error(w, fmt.Sprintf("invalid upload: %s: unsupported file type", name), http.StatusUnprocessableEntity)
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+			if err != nil {
+				readError(err)
+				return
+			}
+			if int64(len(data)) > maxSize {
+				http.Error(w, fmt.Sprintf("%s exceeds the %d MiB limit", name, maxSize>>20), http.StatusRequestEntityTooLarge)
+				return
+			}
+			req.Files = append(req.Files, model.UploadFile{Name: name, Data: data})
+		}
+		part.Close()
+	}
+	if len(req.Files) == 0 {
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.gpxService.Upload(req)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid destination"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "invalid upload"):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+const (
+	defaultProfilePoints = 500
+	maxProfilePoints     = 10000
+
+	defaultGeoJSONTolerance = 5.0 // meters
+	maxGeoJSONTolerance     = 10000.0
+
+	defaultThumbnailSize = 128 // pixels
+	minThumbnailSize     = 32
+	maxThumbnailSize     = 512
+
+	defaultSnapshotWidth  = 1200 // pixels
+	defaultSnapshotHeight = 800
+	minSnapshotSize       = 64
+	maxSnapshotSize       = 2048
+)
+
+// GPXDetail serves /api/gpx/{relativePath} and its sub-resources, which are
+// addressed by a suffix after the file path (e.g. .../run.gpx/profile).
+// POST /api/gpx/merge cannot clash with a file, which always lives under a
+// scan root.
+func (h *Handlers) GPXDetail(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.TrimPrefix(r.URL.Path, "/api/gpx/")
+	if relPath == "merge" && r.Method == http.MethodPost {
+		h.gpxMerge(w, r)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/profile"); ok {
+		h.gpxProfile(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/geojson"); ok {
+		h.gpxGeoJSON(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/export"); ok {
+		h.gpxExport(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/thumbnail.png"); ok {
+		h.gpxThumbnail(w, r, rest, "png")
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/thumbnail.svg"); ok {
+		h.gpxThumbnail(w, r, rest, "svg")
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/compare"); ok {
+		h.gpxCompare(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/matches"); ok {
+		h.gpxMatches(w, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/move"); ok && r.Method == http.MethodPost {
+		h.gpxMove(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/trim"); ok && r.Method == http.MethodPost {
+		h.gpxTrim(w, r, rest)
+		return
+	}
+	if rest, ok := strings.CutSuffix(relPath, "/split"); ok && r.Method == http.MethodPost {
+		h.gpxSplit(w, r, rest)
+		return
+	}
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodDelete:
+		h.gpxDelete(w, relPath)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.readService(r).GetTrack(relPath)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// gpxMove serves POST /api/gpx/{relativePath}/move with a model.MoveRequest
+// body and responds with the file's new listing entry.
+func (h *Handlers) gpxMove(w http.ResponseWriter, r *http.Request, relPath string) {
+	var req model.MoveRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" && req.Folder == "" {
+		http.Error(w, "Missing name or folder", http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.gpxService.Move(relPath, req)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+	writeJSON(w, file)
+}
+
+// gpxTrim serves POST /api/gpx/{relativePath}/trim with a model.TrimRequest
+// body and responds 201 with the written file.
+func (h *Handlers) gpxTrim(w http.ResponseWriter, r *http.Request, relPath string) {
+	var req model.TrimRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.gpxService.Trim(relPath, req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid trim") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// gpxMerge serves POST /api/gpx/merge with a model.MergeRequest body and
+// responds 201 with the merged file.
+func (h *Handlers) gpxMerge(w http.ResponseWriter, r *http.Request) {
+	var req model.MergeRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.gpxService.Merge(req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid merge") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(file); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// gpxSplit serves POST /api/gpx/{relativePath}/split with a
+// model.SplitRequest body and responds 201 with the written parts in order.
+func (h *Handlers) gpxSplit(w http.ResponseWriter, r *http.Request, relPath string) {
+	var req model.SplitRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.gpxService.Split(relPath, req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid split") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// gpxDelete serves DELETE /api/gpx/{relativePath}; the file goes to the trash
+// and the new trash item is returned.
+func (h *Handlers) gpxDelete(w http.ResponseWriter, relPath string) {
+	item, err := h.gpxService.Delete(relPath)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+	writeJSON(w, item)
+}
+
+// StatsSummary serves GET /api/stats/summary?groupBy=activity,year with the
+// /api/gpx search parameters as filters.
+func (h *Handlers) StatsSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp, err := h.readService(r).StatsSummary(r.URL.Query())
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid query") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error scanning data folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// Records serves GET /api/records with the personal bests of every activity,
+// or of one with ?activity=Running.
+func (h *Handlers) Records(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp, err := h.gpxService.Records(r.URL.Query().Get("activity"))
+	if err != nil {
+		http.Error(w, "Error scanning data folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// Calendar serves GET /api/calendar?year=2025 with the activities of each
+// day of the year; the year defaults to the current one.
+func (h *Handlers) Calendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	year := time.Now().Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 9999 {
+			http.Error(w, "year must be between 1 and 9999", http.StatusBadRequest)
+			return
+		}
+		year = n
+	}
+	resp, err := h.gpxService.Calendar(year)
+	if err != nil {
+		http.Error(w, "Error scanning data folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// Segments serves segments: GET /api/segments lists them, POST
+// /api/segments creates one from a model.SegmentRequest body (201), GET
+// /api/segments/{id} returns it with its ranked efforts and DELETE
+// /api/segments/{id} removes it.
+func (h *Handlers) Segments(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/segments"), "/")
+
+	switch {
+	case id == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		segments, err := h.readService(r).ListSegments()
+		if err != nil {
+			http.Error(w, "Failed to read segments", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, segments)
+	case id == "" && r.Method == http.MethodPost:
+		var req model.SegmentRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		seg, err := h.readService(r).CreateSegment(req)
+		if err != nil {
+			writeSegmentError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(seg); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	case id != "" && strings.Contains(id, "/"):
+		http.NotFound(w, r)
+	case id != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		resp, err := h.readService(r).GetSegment(id)
+		if err != nil {
+			writeSegmentError(w, err)
+			return
+		}
+		writeJSON(w, resp)
+	case id != "" && r.Method == http.MethodDelete:
+		if err := h.gpxService.DeleteSegment(id); err != nil {
+			writeSegmentError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeSegmentError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid segment"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err.Error() == "segment not found":
+		http.Error(w, "Segment not found", http.StatusNotFound)
+	default:
+		writeGPXError(w, err)
+	}
+}
+
+// Duplicates serves duplicate detection: GET /api/duplicates lists the
+// groups of files holding the same activity and POST
+// /api/duplicates/resolve trashes or dismisses duplicates as described by a
+// model.ResolveDuplicatesRequest body.
+func (h *Handlers) Duplicates(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/duplicates"), "/")
+
+	switch {
+	case action == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		resp, err := h.gpxService.Duplicates()
+		if err != nil {
+			http.Error(w, "Failed to find duplicates", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, resp)
+	case action == "resolve" && r.Method == http.MethodPost:
+		var req model.ResolveDuplicatesRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		resp, err := h.gpxService.ResolveDuplicates(req)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "invalid resolve") {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeGPXError(w, err)
+			return
+		}
+		writeJSON(w, resp)
+	case action != "" && action != "resolve":
+		http.NotFound(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Trash serves the trash: GET /api/trash lists deleted files, DELETE
+// /api/trash empties it, POST /api/trash/{id}/restore puts a file back and
+// DELETE /api/trash/{id} removes it for good.
+func (h *Handlers) Trash(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/trash"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case id == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		items, err := h.gpxService.ListTrash()
+		if err != nil {
+			http.Error(w, "Failed to read trash", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, items)
+	case id == "" && r.Method == http.MethodDelete:
+		if err := h.gpxService.PurgeTrash(""); err != nil {
+			http.Error(w, "Failed to empty trash", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case id != "" && action == "restore" && r.Method == http.MethodPost:
+		file, err := h.gpxService.RestoreTrash(id)
+		if err != nil {
+			writeGPXError(w, err)
+			return
+		}
+		writeJSON(w, file)
+	case id != "" && action == "" && r.Method == http.MethodDelete:
+		if err := h.gpxService.PurgeTrash(id); err != nil {
+			writeGPXError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case id != "" && action != "" && action != "restore":
+		http.NotFound(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handlers) gpxProfile(w http.ResponseWriter, r *http.Request, relPath string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+
+	points := defaultProfilePoints
+	if v := r.URL.Query().Get("points"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > maxProfilePoints {
+			http.Error(w, fmt.Sprintf("points must be between 2 and %d", maxProfilePoints), http.StatusBadRequest)
+			return
+		}
+		points = n
+	}
+
+	resp, err := h.readService(r).GetProfile(relPath, points)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (h *Handlers) gpxGeoJSON(w http.ResponseWriter, r *http.Request, relPath string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	tolerance := defaultGeoJSONTolerance
+	if v := query.Get("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > maxGeoJSONTolerance {
+			http.Error(w, fmt.Sprintf("tolerance must be between 0 and %g meters", maxGeoJSONTolerance), http.StatusBadRequest)
+			return
+		}
+		tolerance = t
+	}
+	data, err := h.readService(r).GetGeoJSON(relPath, tolerance, query.Get("algorithm"))
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// gpxCompare serves GET /api/gpx/{plan}/compare?activity={relativePath}.
+// The sections carry track geometry, so privacy zones apply.
+func (h *Handlers) gpxCompare(w http.ResponseWriter, r *http.Request, relPath string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.readService(r).Compare(relPath, r.URL.Query().Get("activity"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid compare") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeGPXError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// gpxMatches serves GET /api/gpx/{plan}/matches: the activities that most
+// likely followed the plan, best first.
+func (h *Handlers) gpxMatches(w http.ResponseWriter, relPath string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+	matches, err := h.gpxService.PlanMatches(relPath)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+	writeJSON(w, matches)
+}
+
+// thumbnailContentTypes lists the formats /thumbnail.{ext} can produce.
+var thumbnailContentTypes = map[string]string{
+	"png": "image/png",
+	"svg": "image/svg+xml",
+}
+
+// gpxThumbnail serves GET /api/gpx/{relativePath}/thumbnail.{png,svg}. The
+// optional base parameter names a tile provider to draw the track over; only
+// tiles already in the cache are used, so a long file list never triggers a
+// burst of upstream downloads.
+func (h *Handlers) gpxThumbnail(w http.ResponseWriter, r *http.Request, relPath, format string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	size := defaultThumbnailSize
+	if v := query.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minThumbnailSize || n > maxThumbnailSize {
+			http.Error(w, fmt.Sprintf("size must be between %d and %d", minThumbnailSize, maxThumbnailSize), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+	var base *render.Basemap
+	if key := query.Get("base"); key != "" {
+		if base = h.basemap(r.Context(), key, true); base == nil {
+			http.Error(w, "Unknown base map", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := h.readService(r).Thumbnail(relPath, format, size, base)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", thumbnailContentTypes[format])
+	// Thumbnails follow the file contents, so browsers must revalidate.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// basemap returns the tiles of a configured provider as a render.Basemap,
+// or nil for unknown providers.
+func (h *Handlers) basemap(ctx context.Context, key string, cachedOnly bool) *render.Basemap {
+	provider, ok := h.cfg.Providers[key]
+	if !ok {
+		return nil
+	}
+	return &render.Basemap{
+		Key:         key,
+		MaxZoom:     provider.ZoomRange[1],
+		Attribution: provider.Attribution,
+		Tile: func(z, x, y int) (image.Image, error) {
+			return h.tileService.TileImage(ctx, key, z, x, y, cachedOnly)
+		},
+	}
+}
+
+// Snapshot serves GET /api/snapshot?tracks=&provider=&width=&height=, a PNG
+// map of the given files for trip reports. tracks is repeatable or
+// comma-separated; provider defaults to the initial map of the SPA. Tiles
+// come from the cache and are downloaded when missing unless the server is
+// offline, so a warm cache is enough to take snapshots without a network.
+func (h *Handlers) Snapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var req model.SnapshotRequest
+	for _, v := range query["tracks"] {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				req.Paths = append(req.Paths, p)
+			}
+		}
+	}
+	if len(req.Paths) == 0 {
+		http.Error(w, "Missing tracks", http.StatusBadRequest)
+		return
+	}
+	dims := []struct {
+		name string
+		def  int
+		dst  *int
+	}{
+		{"width", defaultSnapshotWidth, &req.Width},
+		{"height", defaultSnapshotHeight, &req.Height},
+	}
+	for _, d := range dims {
+		*d.dst = d.def
+		if v := query.Get(d.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < minSnapshotSize || n > maxSnapshotSize {
+				http.Error(w, fmt.Sprintf("%s must be between %d and %d", d.name, minSnapshotSize, maxSnapshotSize), http.StatusBadRequest)
+				return
+			}
+			*d.dst = n
+		}
+	}
+	key := query.Get("provider")
+	if key == "" {
+		key = initialProvider
+	}
+	base := h.basemap(r.Context(), key, false)
+	if base == nil {
+		http.Error(w, "Unknown provider", http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.readService(r).Snapshot(req, base)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid snapshot") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeGPXError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "snapshot.png"}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// DataFiles wraps the raw /data/ file server. A request carrying ?format=
+// converts the file instead (e.g. /data/Activities/run.tcx?format=gpx), so
+// every supported format can be fetched as GPX from its own URL.
+//
+// Untrusted clients never reach the file server while privacy zones are set:
+// GPX and KML files are rewritten without the hidden points, other formats
+// are only available converted, and everything else is not found.
+func (h *Handlers) DataFiles(files http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relPath := strings.TrimPrefix(r.URL.Path, "/data/")
+		if r.URL.Query().Has("format") {
+			h.gpxExport(w, r, relPath)
+			return
+		}
+		if h.redacted == nil || h.trusted(r) {
+			files.ServeHTTP(w, r)
+			return
+		}
+		switch format := strings.ToLower(strings.TrimPrefix(path.Ext(relPath), ".")); format {
+		case "gpx", "kml":
+			h.writeExport(w, r, relPath, format)
+		case "fit", "tcx", "kmz":
+			http.Error(w, "Raw "+strings.ToUpper(format)+" files are not served to remote clients; add ?format=gpx", http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// exportContentTypes lists the formats /export can produce.
+var exportContentTypes = map[string]string{
+	"gpx": "application/gpx+xml",
+	"kml": "application/vnd.google-earth.kml+xml",
+}
+
+func (h *Handlers) gpxExport(w http.ResponseWriter, r *http.Request, relPath string) {
+	if relPath == "" {
+		http.Error(w, "Missing track path", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "gpx"
+	}
+	h.writeExport(w, r, relPath, format)
+}
+
+// writeExport responds with the file converted to format as a download.
+func (h *Handlers) writeExport(w http.ResponseWriter, r *http.Request, relPath, format string) {
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.readService(r).Export(relPath, format)
+	if err != nil {
+		writeGPXError(w, err)
+		return
+	}
+
+	name := path.Base(relPath)
+	name = strings.TrimSuffix(name, path.Ext(name)) + "." + format
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+const (
+	sseKeepAliveInterval = 25 * time.Second
+	sseWriteTimeout      = 10 * time.Second
+)
+
+// Events streams library changes as Server-Sent Events. Each event is named
+// after its type (file-added, file-changed, file-removed) and carries the
+// JSON-encoded model.LibraryEvent as data.
+func (h *Handlers) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	events, unsubscribe := h.readService(r).Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// The server's WriteTimeout would otherwise cut the stream after 30s, so
+	// push the deadline forward before every write. Recorders used in tests
+	// do not support deadlines; that error is safe to ignore.
+	write := func(format string, args ...any) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !write(": connected\n\n") {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if !write(": ping\n\n") {
+				return
+			}
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if !write("event: %s\ndata: %s\n\n", ev.Type, data) {
+				return
+			}
+		}
+	}
+}
+
+func writeGPXError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "invalid path":
+		http.Error(w, "Invalid track path", http.StatusBadRequest)
+	case err.Error() == "not found":
+		http.Error(w, "Track not found", http.StatusNotFound)
+	case err.Error() == "already exists":
+		http.Error(w, "A file with that name already exists", http.StatusConflict)
+	case strings.HasPrefix(err.Error(), "invalid destination"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err.Error() == "invalid format":
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+	case err.Error() == "invalid algorithm":
+		http.Error(w, "algorithm must be douglas-peucker (dp) or visvalingam (vw)", http.StatusBadRequest)
+	case isParseError(err):
+		http.Error(w, "Failed to parse track: "+err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "Failed to read track", http.StatusInternalServerError)
+	}
+}
+
+// parseErrorPrefixes are the prefixes the track readers put on errors about
+// malformed input, one per supported format.
+var parseErrorPrefixes = []string{"invalid gpx", "invalid fit", "invalid tcx", "invalid kml", "invalid kmz"}
+
+func isParseError(err error) bool {
+	for _, prefix := range parseErrorPrefixes {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Handlers) TileConfig(w http.ResponseWriter, r *http.Request) {
 	providers := make(map[string]model.ProviderDTO)
 	for key, p := range h.cfg.Providers {
@@ -62,8 +1113,11 @@ func (h *Handlers) TileConfig(w http.ResponseWriter, r *http.Request) {
 
 	resp := model.TileConfigResponse{
 		Providers: providers,
-		Initial:   "maaamet-kaart",
-		Offline:   h.cfg.Offline,
+		Overlays: map[string]model.ProviderDTO{
+			heatmapProvider: {Name: "Heatmap", MinZoom: 0, MaxZoom: heatmapMaxZoom},
+		},
+		Initial: initialProvider,
+		Offline: h.cfg.Offline,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -92,6 +1146,10 @@ func (h *Handlers) TileProxy(w http.ResponseWriter, r *http.Request) {
 
 	providerName := parts[2]
 	z, x, yPng := parts[3], parts[4], parts[5]
+	if providerName == heatmapProvider {
+		h.heatmapTile(w, r, z, x, yPng)
+		return
+	}
 
 	path, err := h.tileService.GetTile(r.Context(), providerName, z, x, yPng)
 	if err != nil {
@@ -110,6 +1168,43 @@ func (h *Handlers) TileProxy(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, path)
 }
 
+// initialProvider is the base map the SPA opens with.
+const initialProvider = "maaamet-kaart"
+
+// The heatmap is served like a tile provider but rendered from the library.
+const (
+	heatmapProvider = "heatmap"
+	heatmapMaxZoom  = 18
+)
+
+// heatmapTile serves /tiles/heatmap/{z}/{x}/{y}.png, optionally filtered by
+// ?activity= and ?year=.
+func (h *Handlers) heatmapTile(w http.ResponseWriter, r *http.Request, z, x, yPng string) {
+	zi, errZ := strconv.Atoi(z)
+	xi, errX := strconv.Atoi(x)
+	yi, errY := strconv.Atoi(strings.TrimSuffix(yPng, ".png"))
+	if errZ != nil || errX != nil || errY != nil || !strings.HasSuffix(yPng, ".png") {
+		http.Error(w, "Invalid tile request", http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.readService(r).HeatmapTile(zi, xi, yi, r.URL.Query())
+	if err != nil {
+		switch {
+		case err.Error() == "invalid tile", strings.HasPrefix(err.Error(), "invalid query"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to render heatmap tile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	// Tiles change whenever the library does, so browsers must revalidate.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
 func (h *Handlers) PrewarmView(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
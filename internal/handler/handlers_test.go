@@ -4,29 +4,184 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"image"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gpx-self-host/internal/config"
 	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/service/gpx"
+	"gpx-self-host/internal/track"
 )
 
 type mockGPXService struct {
 	listFilesFunc func() ([]model.GPXFile, error)
+	getTrackFunc  func(relPath string) (model.GPXDetailResponse, error)
+	searchFunc    func(params url.Values) ([]model.GPXFile, int, error)
+	statsFunc     func(params url.Values) (model.StatsSummaryResponse, error)
+	recordsFunc   func(activity string) (model.RecordsResponse, error)
+	calendarFunc  func(year int) (model.CalendarResponse, error)
+	segmentsFunc  func() ([]model.Segment, error)
+	createSegFunc func(req model.SegmentRequest) (model.Segment, error)
+	getSegFunc    func(id string) (model.SegmentResponse, error)
+	deleteSegFunc func(id string) error
+	dupesFunc     func() (model.DuplicatesResponse, error)
+	resolveFunc   func(req model.ResolveDuplicatesRequest) (model.ResolveDuplicatesResponse, error)
+	compareFunc   func(planPath, activityPath string) (model.CompareResponse, error)
+	matchesFunc   func(planPath string) ([]model.PlanMatch, error)
+	profileFunc   func(relPath string, points int) (model.ProfileResponse, error)
+	geoJSONFunc   func(relPath string, tolerance float64, algorithm string) ([]byte, error)
+	exportFunc    func(relPath, format string) ([]byte, error)
+	uploadFunc    func(req model.UploadRequest) ([]model.GPXFile, error)
+	moveFunc      func(relPath string, req model.MoveRequest) (model.GPXFile, error)
+	deleteFunc    func(relPath string) (model.TrashItem, error)
+	listTrashFunc func() ([]model.TrashItem, error)
+	restoreFunc   func(id string) (model.GPXFile, error)
+	purgeFunc     func(id string) error
+	trimFunc      func(relPath string, req model.TrimRequest) (model.TrimResponse, error)
+	mergeFunc     func(req model.MergeRequest) (model.GPXFile, error)
+	splitFunc     func(relPath string, req model.SplitRequest) ([]model.GPXFile, error)
+	heatmapFunc   func(z, x, y int, values url.Values) ([]byte, error)
+	thumbnailFunc func(relPath, format string, size int, base *render.Basemap) ([]byte, error)
+	snapshotFunc  func(req model.SnapshotRequest, base *render.Basemap) ([]byte, error)
+	subscribeFunc func() (<-chan model.LibraryEvent, func())
 }
 
 func (m *mockGPXService) ListFiles() ([]model.GPXFile, error) {
 	return m.listFilesFunc()
 }
 
+func (m *mockGPXService) GetTrack(relPath string) (model.GPXDetailResponse, error) {
+	return m.getTrackFunc(relPath)
+}
+
+func (m *mockGPXService) GetProfile(relPath string, points int) (model.ProfileResponse, error) {
+	return m.profileFunc(relPath, points)
+}
+
+func (m *mockGPXService) GetGeoJSON(relPath string, tolerance float64, algorithm string) ([]byte, error) {
+	return m.geoJSONFunc(relPath, tolerance, algorithm)
+}
+
+func (m *mockGPXService) Export(relPath, format string) ([]byte, error) {
+	return m.exportFunc(relPath, format)
+}
+
+func (m *mockGPXService) Upload(req model.UploadRequest) ([]model.GPXFile, error) {
+	return m.uploadFunc(req)
+}
+
+func (m *mockGPXService) Move(relPath string, req model.MoveRequest) (model.GPXFile, error) {
+	return m.moveFunc(relPath, req)
+}
+
+func (m *mockGPXService) Delete(relPath string) (model.TrashItem, error) {
+	return m.deleteFunc(relPath)
+}
+
+func (m *mockGPXService) ListTrash() ([]model.TrashItem, error) {
+	return m.listTrashFunc()
+}
+
+func (m *mockGPXService) RestoreTrash(id string) (model.GPXFile, error) {
+	return m.restoreFunc(id)
+}
+
+func (m *mockGPXService) PurgeTrash(id string) error {
+	return m.purgeFunc(id)
+}
+
+func (m *mockGPXService) Trim(relPath string, req model.TrimRequest) (model.TrimResponse, error) {
+	return m.trimFunc(relPath, req)
+}
+
+func (m *mockGPXService) Merge(req model.MergeRequest) (model.GPXFile, error) {
+	return m.mergeFunc(req)
+}
+
+func (m *mockGPXService) Split(relPath string, req model.SplitRequest) ([]model.GPXFile, error) {
+	return m.splitFunc(relPath, req)
+}
+
+func (m *mockGPXService) HeatmapTile(z, x, y int, values url.Values) ([]byte, error) {
+	return m.heatmapFunc(z, x, y, values)
+}
+
+func (m *mockGPXService) Thumbnail(relPath, format string, size int, base *render.Basemap) ([]byte, error) {
+	return m.thumbnailFunc(relPath, format, size, base)
+}
+
+func (m *mockGPXService) Snapshot(req model.SnapshotRequest, base *render.Basemap) ([]byte, error) {
+	return m.snapshotFunc(req, base)
+}
+
+func (m *mockGPXService) Search(params url.Values) ([]model.GPXFile, int, error) {
+	return m.searchFunc(params)
+}
+
+func (m *mockGPXService) StatsSummary(params url.Values) (model.StatsSummaryResponse, error) {
+	return m.statsFunc(params)
+}
+
+func (m *mockGPXService) Records(activity string) (model.RecordsResponse, error) {
+	return m.recordsFunc(activity)
+}
+
+func (m *mockGPXService) Calendar(year int) (model.CalendarResponse, error) {
+	return m.calendarFunc(year)
+}
+
+func (m *mockGPXService) ListSegments() ([]model.Segment, error) {
+	return m.segmentsFunc()
+}
+
+func (m *mockGPXService) CreateSegment(req model.SegmentRequest) (model.Segment, error) {
+	return m.createSegFunc(req)
+}
+
+func (m *mockGPXService) GetSegment(id string) (model.SegmentResponse, error) {
+	return m.getSegFunc(id)
+}
+
+func (m *mockGPXService) DeleteSegment(id string) error {
+	return m.deleteSegFunc(id)
+}
+
+func (m *mockGPXService) Duplicates() (model.DuplicatesResponse, error) {
+	return m.dupesFunc()
+}
+
+func (m *mockGPXService) ResolveDuplicates(req model.ResolveDuplicatesRequest) (model.ResolveDuplicatesResponse, error) {
+	return m.resolveFunc(req)
+}
+
+func (m *mockGPXService) Compare(planPath, activityPath string) (model.CompareResponse, error) {
+	return m.compareFunc(planPath, activityPath)
+}
+
+func (m *mockGPXService) PlanMatches(planPath string) ([]model.PlanMatch, error) {
+	return m.matchesFunc(planPath)
+}
+
+func (m *mockGPXService) Subscribe() (<-chan model.LibraryEvent, func()) {
+	return m.subscribeFunc()
+}
+
 type mockTilesService struct {
 	getTileFunc     func(ctx context.Context, providerName, z, x, yPng string) (string, error)
 	prewarmViewFunc func(ctx context.Context, req model.PrewarmViewRequest) (model.PrewarmViewResponse, error)
 	getStatsFunc    func() model.StatusResponse
+	tileImageFunc   func(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error)
 }
 
 func (m *mockTilesService) GetTile(ctx context.Context, providerName, z, x, yPng string) (string, error) {
@@ -41,6 +196,10 @@ func (m *mockTilesService) GetStats() model.StatusResponse {
 	return m.getStatsFunc()
 }
 
+func (m *mockTilesService) TileImage(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error) {
+	return m.tileImageFunc(ctx, providerName, z, x, y, cachedOnly)
+}
+
 func TestTileConfigHandler(t *testing.T) {
 	cfg := &config.Config{
 		Providers: map[string]config.TileProviderConfig{
@@ -76,6 +235,9 @@ func TestTileConfigHandler(t *testing.T) {
 	if !ok || p.Name != "Test Provider" || p.MinZoom != 1 || p.MaxZoom != 10 {
 		t.Errorf("unexpected provider config: %+v", p)
 	}
+	if o, ok := resp.Overlays["heatmap"]; !ok || o.Name != "Heatmap" || o.MaxZoom != 18 {
+		t.Errorf("expected the heatmap overlay, got %+v", resp.Overlays)
+	}
 }
 
 func TestTileProxyHandler(t *testing.T) {
@@ -166,6 +328,201 @@ func TestTileProxyHandler_SpecificErrors(t *testing.T) {
 	}
 }
 
+func TestHeatmapTileHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"Success", "/tiles/heatmap/12/2329/1202.png?activity=running&year=2025", nil, http.StatusOK},
+		{"Bad coordinates", "/tiles/heatmap/12/x/1202.png", nil, http.StatusBadRequest},
+		{"Missing extension", "/tiles/heatmap/12/2329/1202", nil, http.StatusBadRequest},
+		{"Out of range", "/tiles/heatmap/30/0/0.png", &customError{"invalid tile"}, http.StatusBadRequest},
+		{"Bad filter", "/tiles/heatmap/1/0/0.png?year=soon", &customError{"invalid query: year \"soon\""}, http.StatusBadRequest},
+		{"Render failure", "/tiles/heatmap/1/0/0.png", &customError{"disk full"}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotZ, gotX, gotY int
+			var gotValues url.Values
+			h := New(nil, &mockGPXService{
+				heatmapFunc: func(z, x, y int, values url.Values) ([]byte, error) {
+					gotZ, gotX, gotY, gotValues = z, x, y, values
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []byte("png"), nil
+				},
+			}, &mockTilesService{
+				getTileFunc: func(ctx context.Context, providerName, z, x, yPng string) (string, error) {
+					t.Error("the heatmap must not be fetched from upstream")
+					return "", nil
+				},
+			})
+
+			rr := httptest.NewRecorder()
+			h.TileProxy(rr, httptest.NewRequest("GET", tt.path, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.name == "Success" {
+				if gotZ != 12 || gotX != 2329 || gotY != 1202 || gotValues.Get("activity") != "running" {
+					t.Errorf("unexpected tile request %d/%d/%d %v", gotZ, gotX, gotY, gotValues)
+				}
+				if rr.Header().Get("Content-Type") != "image/png" || rr.Body.String() != "png" {
+					t.Errorf("unexpected response %q %q", rr.Header().Get("Content-Type"), rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestGPXThumbnailHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockError      error
+		expectedStatus int
+		expectedFormat string
+		expectedSize   int
+		expectedBase   string
+	}{
+		{"PNG", "/api/gpx/Activities/run.gpx/thumbnail.png", nil, http.StatusOK, "png", 128, ""},
+		{"SVG over a base map", "/api/gpx/Activities/run.gpx/thumbnail.svg?size=64&base=osm", nil, http.StatusOK, "svg", 64, "osm"},
+		{"Size too small", "/api/gpx/Activities/run.gpx/thumbnail.png?size=8", nil, http.StatusBadRequest, "", 0, ""},
+		{"Unknown base map", "/api/gpx/Activities/run.gpx/thumbnail.png?base=nope", nil, http.StatusBadRequest, "", 0, ""},
+		{"Missing path", "/api/gpx//thumbnail.png", nil, http.StatusBadRequest, "", 0, ""},
+		{"Not found", "/api/gpx/Activities/missing.gpx/thumbnail.png", &customError{"not found"}, http.StatusNotFound, "png", 128, ""},
+		{"Parse error", "/api/gpx/Activities/bad.gpx/thumbnail.png", &customError{"invalid gpx: EOF"}, http.StatusUnprocessableEntity, "png", 128, ""},
+	}
+
+	cfg := &config.Config{Providers: map[string]config.TileProviderConfig{
+		"osm": {Name: "OSM", ZoomRange: [2]int{0, 19}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := New(cfg, &mockGPXService{
+				thumbnailFunc: func(relPath, format string, size int, base *render.Basemap) ([]byte, error) {
+					called = true
+					if format != tt.expectedFormat || size != tt.expectedSize {
+						t.Errorf("unexpected thumbnail request %s %d", format, size)
+					}
+					if (base == nil) != (tt.expectedBase == "") {
+						t.Fatalf("unexpected base map %+v", base)
+					}
+					if base != nil {
+						if base.Key != tt.expectedBase || base.MaxZoom != 19 {
+							t.Errorf("unexpected base map %+v", base)
+						}
+						base.Tile(3, 1, 2)
+					}
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []byte("image"), nil
+				},
+			}, &mockTilesService{
+				tileImageFunc: func(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error) {
+					if providerName != "osm" || z != 3 || x != 1 || y != 2 || !cachedOnly {
+						t.Errorf("unexpected tile request %s %d/%d/%d cachedOnly=%v", providerName, z, x, y, cachedOnly)
+					}
+					return nil, &customError{"tile not cached"}
+				},
+			})
+
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, httptest.NewRequest("GET", tt.path, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if called != (tt.expectedFormat != "") {
+				t.Errorf("service called = %v", called)
+			}
+			if rr.Code == http.StatusOK {
+				want := map[string]string{"png": "image/png", "svg": "image/svg+xml"}[tt.expectedFormat]
+				if rr.Header().Get("Content-Type") != want || rr.Body.String() != "image" {
+					t.Errorf("unexpected response %q %q", rr.Header().Get("Content-Type"), rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		mockError      error
+		expectedStatus int
+		expectedReq    *model.SnapshotRequest
+		expectedBase   string
+	}{
+		{"Defaults", "GET", "/api/snapshot?tracks=Activities/a.gpx,Activities/b.gpx", nil, http.StatusOK,
+			&model.SnapshotRequest{Paths: []string{"Activities/a.gpx", "Activities/b.gpx"}, Width: 1200, Height: 800}, "maaamet-kaart"},
+		{"Repeated tracks and size", "GET", "/api/snapshot?tracks=a.gpx&tracks=b.gpx&provider=osm&width=640&height=480", nil, http.StatusOK,
+			&model.SnapshotRequest{Paths: []string{"a.gpx", "b.gpx"}, Width: 640, Height: 480}, "osm"},
+		{"Missing tracks", "GET", "/api/snapshot?tracks=,", nil, http.StatusBadRequest, nil, ""},
+		{"Too wide", "GET", "/api/snapshot?tracks=a.gpx&width=5000", nil, http.StatusBadRequest, nil, ""},
+		{"Unknown provider", "GET", "/api/snapshot?tracks=a.gpx&provider=nope", nil, http.StatusBadRequest, nil, ""},
+		{"Too many tracks", "GET", "/api/snapshot?tracks=a.gpx", &customError{"invalid snapshot: at most 50 tracks"}, http.StatusBadRequest,
+			&model.SnapshotRequest{Paths: []string{"a.gpx"}, Width: 1200, Height: 800}, "maaamet-kaart"},
+		{"Not found", "GET", "/api/snapshot?tracks=missing.gpx", &customError{"not found"}, http.StatusNotFound,
+			&model.SnapshotRequest{Paths: []string{"missing.gpx"}, Width: 1200, Height: 800}, "maaamet-kaart"},
+		{"Wrong method", "POST", "/api/snapshot?tracks=a.gpx", nil, http.StatusMethodNotAllowed, nil, ""},
+	}
+
+	cfg := &config.Config{Providers: map[string]config.TileProviderConfig{
+		"osm":           {Name: "OSM", Attribution: "© OSM", ZoomRange: [2]int{0, 19}},
+		"maaamet-kaart": {Name: "Kaart", Attribution: "Maa-amet", ZoomRange: [2]int{0, 18}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *model.SnapshotRequest
+			h := New(cfg, &mockGPXService{
+				snapshotFunc: func(req model.SnapshotRequest, base *render.Basemap) ([]byte, error) {
+					got = &req
+					if base == nil || base.Key != tt.expectedBase || base.Attribution != cfg.Providers[tt.expectedBase].Attribution {
+						t.Errorf("unexpected base map %+v", base)
+					} else {
+						base.Tile(1, 0, 1)
+					}
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []byte("png"), nil
+				},
+			}, &mockTilesService{
+				tileImageFunc: func(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error) {
+					if cachedOnly {
+						t.Error("snapshots should download missing tiles")
+					}
+					return image.NewNRGBA(image.Rect(0, 0, 256, 256)), nil
+				},
+			})
+
+			rr := httptest.NewRecorder()
+			h.Snapshot(rr, httptest.NewRequest(tt.method, tt.path, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if (got == nil) != (tt.expectedReq == nil) {
+				t.Fatalf("unexpected service call %+v", got)
+			}
+			if got != nil && (strings.Join(got.Paths, "|") != strings.Join(tt.expectedReq.Paths, "|") ||
+				got.Width != tt.expectedReq.Width || got.Height != tt.expectedReq.Height) {
+				t.Errorf("expected %+v, got %+v", tt.expectedReq, got)
+			}
+			if rr.Code == http.StatusOK && (rr.Header().Get("Content-Type") != "image/png" || rr.Body.String() != "png") {
+				t.Errorf("unexpected response %q %q", rr.Header().Get("Content-Type"), rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestListGPXHandler(t *testing.T) {
 	mockGPX := &mockGPXService{
 		listFilesFunc: func() ([]model.GPXFile, error) {
@@ -214,111 +571,1267 @@ func TestListGPXHandler_Error(t *testing.T) {
 	}
 }
 
-func TestStatusHandler(t *testing.T) {
-	mockTiles := &mockTilesService{
-		getStatsFunc: func() model.StatusResponse {
-			return model.StatusResponse{CacheHits: 123}
-		},
+func TestListGPXHandler_Search(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		err            error
+		expectedStatus int
+	}{
+		{"Query", "/api/gpx?q=activity:Running&limit=1", nil, http.StatusOK},
+		{"Invalid query", "/api/gpx?sort=bogus", &customError{"invalid query: sort \"bogus\""}, http.StatusBadRequest},
+		{"Scan error", "/api/gpx?q=x", &customError{"scan error"}, http.StatusInternalServerError},
 	}
-	h := New(nil, nil, mockTiles)
-
-	req := httptest.NewRequest("GET", "/api/status", nil)
-	rr := httptest.NewRecorder()
 
-	h.Status(rr, req)
-
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected 200, got %d", rr.Code)
-	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got url.Values
+			mockGPX := &mockGPXService{
+				listFilesFunc: func() ([]model.GPXFile, error) {
+					t.Fatal("ListFiles should not be called for a query")
+					return nil, nil
+				},
+				searchFunc: func(params url.Values) ([]model.GPXFile, int, error) {
+					got = params
+					if tt.err != nil {
+						return nil, 0, tt.err
+					}
+					return []model.GPXFile{{Name: "run.gpx"}}, 7, nil
+				},
+			}
+			h := New(nil, mockGPX, nil)
 
-	var resp model.StatusResponse
-	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
-		t.Fatal(err)
-	}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			h.ListGPXFiles(rr, req)
 
-	if resp.CacheHits != 123 {
-		t.Errorf("expected 123 hits, got %d", resp.CacheHits)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.err != nil {
+				return
+			}
+			if got.Get("q") != "activity:Running" || got.Get("limit") != "1" {
+				t.Errorf("unexpected params passed to Search: %v", got)
+			}
+			if rr.Header().Get("X-Total-Count") != "7" {
+				t.Errorf("expected X-Total-Count 7, got %q", rr.Header().Get("X-Total-Count"))
+			}
+			var resp []model.GPXFile
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp) != 1 || resp[0].Name != "run.gpx" {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
 	}
 }
 
-type customError struct{ text string }
-
-func (e *customError) Error() string { return e.text }
-
-func TestPrewarmViewHandler(t *testing.T) {
+func TestStatsSummaryHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		method         string
-		body           interface{}
-		mockError      error
+		url            string
+		err            error
 		expectedStatus int
 	}{
-		{
-			name:           "Success",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{ProviderKey: "test"},
-			mockError:      nil,
-			expectedStatus: http.StatusOK,
-		},
-		{
-			name:           "Method Not Allowed",
-			method:         "GET",
-			body:           nil,
-			mockError:      nil,
-			expectedStatus: http.StatusMethodNotAllowed,
-		},
-		{
-			name:           "Invalid JSON",
-			method:         "POST",
-			body:           "invalid json",
-			mockError:      nil,
-			expectedStatus: http.StatusBadRequest,
-		},
-		{
-			name:           "Missing Provider Key",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{},
-			mockError:      nil,
-			expectedStatus: http.StatusBadRequest,
-		},
-		{
-			name:           "Unknown Provider",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{ProviderKey: "unknown"},
-			mockError:      &customError{"unknown provider"},
-			expectedStatus: http.StatusNotFound,
-		},
-		{
-			name:           "Offline Mode",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{ProviderKey: "test"},
-			mockError:      &customError{"offline mode"},
-			expectedStatus: http.StatusConflict,
-		},
-		{
-			name:           "Too Many Tiles",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{ProviderKey: "test"},
-			mockError:      &customError{"too many tiles"},
-			expectedStatus: http.StatusBadRequest,
-		},
-		{
-			name:           "Internal Error",
-			method:         "POST",
-			body:           model.PrewarmViewRequest{ProviderKey: "test"},
-			mockError:      &customError{"something went wrong"},
-			expectedStatus: http.StatusBadGateway,
-		},
+		{"Grouped", "GET", "/api/stats/summary?groupBy=activity,year&q=year:2025", nil, http.StatusOK},
+		{"Invalid groupBy", "GET", "/api/stats/summary?groupBy=day", &customError{"invalid query: groupBy \"day\""}, http.StatusBadRequest},
+		{"Scan error", "GET", "/api/stats/summary", &customError{"scan error"}, http.StatusInternalServerError},
+		{"Wrong method", "POST", "/api/stats/summary", nil, http.StatusMethodNotAllowed},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockTiles := &mockTilesService{
-				prewarmViewFunc: func(ctx context.Context, req model.PrewarmViewRequest) (model.PrewarmViewResponse, error) {
-					return model.PrewarmViewResponse{Total: 10}, tt.mockError
+			var got url.Values
+			h := New(nil, &mockGPXService{
+				statsFunc: func(params url.Values) (model.StatsSummaryResponse, error) {
+					got = params
+					if tt.err != nil {
+						return model.StatsSummaryResponse{}, tt.err
+					}
+					return model.StatsSummaryResponse{
+						GroupBy: []string{"activity", "year"},
+						Groups: []model.StatsGroup{{
+							Key:         map[string]string{"activity": "Running", "year": "2025"},
+							StatsTotals: model.StatsTotals{Count: 2, Distance: 21000},
+						}},
+						Total: model.StatsTotals{Count: 2, Distance: 21000},
+					}, nil
 				},
-			}
-			h := New(nil, nil, mockTiles)
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.StatsSummary(rr, httptest.NewRequest(tt.method, tt.url, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if got.Get("groupBy") != "activity,year" || got.Get("q") != "year:2025" {
+				t.Errorf("unexpected params passed to StatsSummary: %v", got)
+			}
+			var resp map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			group := resp["groups"].([]any)[0].(map[string]any)
+			if group["count"] != 2.0 || group["distance"] != 21000.0 || group["key"].(map[string]any)["year"] != "2025" {
+				t.Errorf("expected the totals inline with the key, got %v", group)
+			}
+		})
+	}
+}
+
+func TestRecordsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		err            error
+		expectedStatus int
+		activity       string
+	}{
+		{"All activities", "GET", "/api/records", nil, http.StatusOK, ""},
+		{"One activity", "GET", "/api/records?activity=Running", nil, http.StatusOK, "Running"},
+		{"Scan error", "GET", "/api/records", &customError{"scan error"}, http.StatusInternalServerError, ""},
+		{"Wrong method", "POST", "/api/records", nil, http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := New(nil, &mockGPXService{
+				recordsFunc: func(activity string) (model.RecordsResponse, error) {
+					got = activity
+					if tt.err != nil {
+						return model.RecordsResponse{}, tt.err
+					}
+					return model.RecordsResponse{Activities: []model.ActivityRecords{{
+						Activity: "Running",
+						Records: []model.Record{{
+							Type:  "fastest-5k",
+							Value: 1500,
+							File:  model.GPXFile{Name: "tempo.gpx", RelativePath: "Activities/Running/tempo.gpx"},
+						}},
+					}}}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.Records(rr, httptest.NewRequest(tt.method, tt.url, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if got != tt.activity {
+				t.Errorf("expected activity %q, got %q", tt.activity, got)
+			}
+			var resp model.RecordsResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if r := resp.Activities[0].Records[0]; r.Type != "fastest-5k" || r.File.RelativePath != "Activities/Running/tempo.gpx" {
+				t.Errorf("unexpected record %+v", r)
+			}
+		})
+	}
+}
+
+func TestCalendarHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		err            error
+		expectedStatus int
+		year           int
+	}{
+		{"Year", "GET", "/api/calendar?year=2025", nil, http.StatusOK, 2025},
+		{"Current year by default", "GET", "/api/calendar", nil, http.StatusOK, time.Now().Year()},
+		{"Invalid year", "GET", "/api/calendar?year=soon", nil, http.StatusBadRequest, 0},
+		{"Year out of range", "GET", "/api/calendar?year=0", nil, http.StatusBadRequest, 0},
+		{"Scan error", "GET", "/api/calendar?year=2025", &customError{"scan error"}, http.StatusInternalServerError, 2025},
+		{"Wrong method", "POST", "/api/calendar", nil, http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			h := New(nil, &mockGPXService{
+				calendarFunc: func(year int) (model.CalendarResponse, error) {
+					got = year
+					if tt.err != nil {
+						return model.CalendarResponse{}, tt.err
+					}
+					return model.CalendarResponse{Year: year, Days: []model.CalendarDay{{
+						Date:     "2025-06-30",
+						Count:    1,
+						Distance: 1200,
+						Activities: []model.CalendarEntry{{
+							File:     model.GPXFile{Name: "night.gpx"},
+							Distance: 1200,
+							Day:      1,
+							Days:     2,
+						}},
+					}}}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.Calendar(rr, httptest.NewRequest(tt.method, tt.url, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if got != tt.year {
+				t.Errorf("expected year %d passed to Calendar, got %d", tt.year, got)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			var resp model.CalendarResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Year != tt.year || resp.Days[0].Activities[0].Days != 2 {
+				t.Errorf("unexpected response %+v", resp)
+			}
+		})
+	}
+}
+
+// multipartUpload builds a POST /api/gpx request with the given form fields
+// and files (name -> content).
+func multipartUpload(t *testing.T, fields map[string]string, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/api/gpx", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadGPXHandler(t *testing.T) {
+	cfg := &config.Config{MaxUploadSize: 1 << 10, MaxUploadTotal: 2 << 10}
+	manyFiles := func(n, size int) map[string]string {
+		files := make(map[string]string, n)
+		for i := 0; i < n; i++ {
+			files[fmt.Sprintf("ride%d.gpx", i)] = strings.Repeat("x", size)
+		}
+		return files
+	}
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		files          map[string]string
+		mockError      error
+		expectedStatus int
+	}{
+		{"Success", map[string]string{"activity": "Gravel"}, map[string]string{"ride.gpx": "<gpx/>"}, nil, http.StatusCreated},
+		{"No files", map[string]string{"activity": "Gravel"}, nil, nil, http.StatusBadRequest},
+		{"Too large", nil, map[string]string{"big.gpx": strings.Repeat("x", 2<<10)}, nil, http.StatusRequestEntityTooLarge},
+		{"Request too large", nil, manyFiles(80, 1000), nil, http.StatusRequestEntityTooLarge},
+		{"Too many files", nil, manyFiles(maxUploadFiles+1, 10), nil, http.StatusBadRequest},
+		{"Unsupported type", nil, map[string]string{"notes.txt": "hello"}, nil, http.StatusUnprocessableEntity},
+		{"Bad destination", map[string]string{"destination": "tmp"}, map[string]string{"ride.gpx": "<gpx/>"}, &customError{"invalid destination: \"tmp\""}, http.StatusBadRequest},
+		{"Invalid file", nil, map[string]string{"ride.gpx": "nope"}, &customError{"invalid upload: ride.gpx: invalid gpx: EOF"}, http.StatusUnprocessableEntity},
+		{"Write error", nil, map[string]string{"ride.gpx": "<gpx/>"}, &customError{"disk full"}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.UploadRequest
+			called := false
+			h := New(cfg, &mockGPXService{
+				uploadFunc: func(req model.UploadRequest) ([]model.GPXFile, error) {
+					got, called = req, true
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []model.GPXFile{{Name: "ride.gpx", RelativePath: "Activities/Gravel/ride.gpx"}}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.ListGPXFiles(rr, multipartUpload(t, tt.fields, tt.files))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if called != (tt.mockError != nil || tt.expectedStatus == http.StatusCreated) {
+				t.Errorf("service called = %v, rejected requests must not reach it", called)
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			if got.Activity != "Gravel" || len(got.Files) != 1 || got.Files[0].Name != "ride.gpx" || string(got.Files[0].Data) != "<gpx/>" {
+				t.Errorf("unexpected upload request: %+v", got)
+			}
+			var files []model.GPXFile
+			if err := json.NewDecoder(rr.Body).Decode(&files); err != nil || len(files) != 1 {
+				t.Errorf("unexpected response: %v %+v", err, files)
+			}
+		})
+	}
+}
+
+func TestListGPXHandler_MethodNotAllowed(t *testing.T) {
+	h := New(nil, &mockGPXService{}, nil)
+	rr := httptest.NewRecorder()
+	h.ListGPXFiles(rr, httptest.NewRequest("DELETE", "/api/gpx", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestGPXDetailHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"Success", "/api/gpx/Activities/run.gpx", nil, http.StatusOK},
+		{"Missing Path", "/api/gpx/", nil, http.StatusBadRequest},
+		{"Invalid Path", "/api/gpx/../secret.gpx", &customError{"invalid path"}, http.StatusBadRequest},
+		{"Not Found", "/api/gpx/Activities/missing.gpx", &customError{"not found"}, http.StatusNotFound},
+		{"Parse Error", "/api/gpx/Activities/bad.gpx", &customError{"invalid gpx: EOF"}, http.StatusUnprocessableEntity},
+		{"Internal Error", "/api/gpx/Activities/run.gpx", &customError{"permission denied"}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			mockGPX := &mockGPXService{
+				getTrackFunc: func(relPath string) (model.GPXDetailResponse, error) {
+					gotPath = relPath
+					if tt.mockError != nil {
+						return model.GPXDetailResponse{}, tt.mockError
+					}
+					return model.GPXDetailResponse{File: model.GPXFile{RelativePath: relPath}}, nil
+				},
+			}
+			h := New(nil, mockGPX, nil)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if gotPath != "Activities/run.gpx" {
+					t.Errorf("expected relative path to be passed through, got %q", gotPath)
+				}
+				var resp model.GPXDetailResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.File.RelativePath != "Activities/run.gpx" {
+					t.Errorf("unexpected response: %+v", resp)
+				}
+			}
+		})
+	}
+}
+
+func TestGPXMoveHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"Rename", `{"name":"new.gpx"}`, nil, http.StatusOK},
+		{"Move", `{"folder":"Activities/Gravel"}`, nil, http.StatusOK},
+		{"Empty body", `{}`, nil, http.StatusBadRequest},
+		{"Unknown field", `{"path":"x"}`, nil, http.StatusBadRequest},
+		{"Bad destination", `{"folder":"tmp"}`, &customError{"invalid destination: folder must be under Activities or Plans"}, http.StatusBadRequest},
+		{"Conflict", `{"name":"other.gpx"}`, &customError{"already exists"}, http.StatusConflict},
+		{"Not Found", `{"name":"x.gpx"}`, &customError{"not found"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			h := New(nil, &mockGPXService{
+				moveFunc: func(relPath string, req model.MoveRequest) (model.GPXFile, error) {
+					gotPath = relPath
+					if tt.mockError != nil {
+						return model.GPXFile{}, tt.mockError
+					}
+					return model.GPXFile{RelativePath: "Activities/Gravel/new.gpx"}, nil
+				},
+			}, nil)
+
+			req := httptest.NewRequest("POST", "/api/gpx/Activities/run.gpx/move", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && gotPath != "Activities/run.gpx" {
+				t.Errorf("unexpected path %q", gotPath)
+			}
+		})
+	}
+}
+
+func TestGPXTrimHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"By time", `{"end":"2025-06-01T10:00:00Z"}`, nil, http.StatusCreated},
+		{"By index with replace", `{"from":10,"to":200,"replace":true}`, nil, http.StatusCreated},
+		{"Bad JSON", `{"end":"yesterday"}`, nil, http.StatusBadRequest},
+		{"Invalid range", `{}`, &customError{"invalid trim: missing range"}, http.StatusBadRequest},
+		{"Not Found", `{"from":1}`, &customError{"not found"}, http.StatusNotFound},
+		{"Name clash", `{"from":1,"name":"x/y"}`, &customError{"invalid destination: bad file name"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.TrimRequest
+			h := New(nil, &mockGPXService{
+				trimFunc: func(relPath string, req model.TrimRequest) (model.TrimResponse, error) {
+					got = req
+					if tt.mockError != nil {
+						return model.TrimResponse{}, tt.mockError
+					}
+					return model.TrimResponse{File: model.GPXFile{RelativePath: "Activities/run-trimmed.gpx"}}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, httptest.NewRequest("POST", "/api/gpx/Activities/run.gpx/trim", strings.NewReader(tt.body)))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.name == "By index with replace" && (got.From == nil || *got.From != 10 || *got.To != 200 || !got.Replace) {
+				t.Errorf("unexpected request: %+v", got)
+			}
+		})
+	}
+}
+
+func TestGPXMergeHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"Success", `{"paths":["Activities/a.gpx","Activities/b.fit"],"segmentPerSource":true}`, nil, http.StatusCreated},
+		{"Bad JSON", `{"paths":"Activities/a.gpx"}`, nil, http.StatusBadRequest},
+		{"Too few files", `{"paths":["Activities/a.gpx"]}`, &customError{"invalid merge: need at least two files"}, http.StatusBadRequest},
+		{"Not Found", `{"paths":["Activities/a.gpx","Activities/x.gpx"]}`, &customError{"not found"}, http.StatusNotFound},
+		{"Parse error", `{"paths":["Activities/a.gpx","Activities/bad.gpx"]}`, &customError{"invalid gpx: EOF"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.MergeRequest
+			h := New(nil, &mockGPXService{
+				mergeFunc: func(req model.MergeRequest) (model.GPXFile, error) {
+					got = req
+					if tt.mockError != nil {
+						return model.GPXFile{}, tt.mockError
+					}
+					return model.GPXFile{RelativePath: "Activities/a-merged.gpx"}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, httptest.NewRequest("POST", "/api/gpx/merge", strings.NewReader(tt.body)))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.name == "Success" && (len(got.Paths) != 2 || !got.SegmentPerSource) {
+				t.Errorf("unexpected request: %+v", got)
+			}
+		})
+	}
+}
+
+func TestGPXSplitHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"At times", `{"at":["2025-06-01T10:00:00Z"]}`, nil, http.StatusCreated},
+		{"At pauses", `{"pause":"30m"}`, nil, http.StatusCreated},
+		{"Bad JSON", `{"pause":30}`, nil, http.StatusBadRequest},
+		{"Nothing to split", `{"pause":"5h"}`, &customError{"invalid split: track would not be split"}, http.StatusBadRequest},
+		{"Not Found", `{"pause":"30m"}`, &customError{"not found"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			h := New(nil, &mockGPXService{
+				splitFunc: func(relPath string, req model.SplitRequest) ([]model.GPXFile, error) {
+					gotPath = relPath
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []model.GPXFile{{RelativePath: "Activities/run-1.gpx"}, {RelativePath: "Activities/run-2.gpx"}}, nil
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, httptest.NewRequest("POST", "/api/gpx/Activities/run.gpx/split", strings.NewReader(tt.body)))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			var files []model.GPXFile
+			if err := json.NewDecoder(rr.Body).Decode(&files); err != nil || len(files) != 2 || gotPath != "Activities/run.gpx" {
+				t.Errorf("unexpected response: %+v %v (path %q)", files, err, gotPath)
+			}
+		})
+	}
+}
+
+func TestGPXDeleteHandler(t *testing.T) {
+	var deleted string
+	h := New(nil, &mockGPXService{
+		deleteFunc: func(relPath string) (model.TrashItem, error) {
+			deleted = relPath
+			if relPath == "Activities/missing.gpx" {
+				return model.TrashItem{}, &customError{"not found"}
+			}
+			return model.TrashItem{ID: "abc", RelativePath: relPath}, nil
+		},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	h.GPXDetail(rr, httptest.NewRequest("DELETE", "/api/gpx/Activities/run.gpx", nil))
+	if rr.Code != http.StatusOK || deleted != "Activities/run.gpx" {
+		t.Fatalf("expected delete to succeed, got %d (%q)", rr.Code, deleted)
+	}
+	var item model.TrashItem
+	if err := json.NewDecoder(rr.Body).Decode(&item); err != nil || item.ID != "abc" {
+		t.Errorf("unexpected response: %+v %v", item, err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GPXDetail(rr, httptest.NewRequest("DELETE", "/api/gpx/Activities/missing.gpx", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GPXDetail(rr, httptest.NewRequest("PUT", "/api/gpx/Activities/run.gpx", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestTrashHandler(t *testing.T) {
+	var purged []string
+	h := New(nil, &mockGPXService{
+		listTrashFunc: func() ([]model.TrashItem, error) {
+			return []model.TrashItem{{ID: "abc", RelativePath: "Activities/run.gpx"}}, nil
+		},
+		restoreFunc: func(id string) (model.GPXFile, error) {
+			if id != "abc" {
+				return model.GPXFile{}, &customError{"not found"}
+			}
+			return model.GPXFile{RelativePath: "Activities/run.gpx"}, nil
+		},
+		purgeFunc: func(id string) error {
+			if id == ".." {
+				return &customError{"invalid path"}
+			}
+			purged = append(purged, id)
+			return nil
+		},
+	}, nil)
+
+	tests := []struct {
+		method, path   string
+		expectedStatus int
+	}{
+		{"GET", "/api/trash", http.StatusOK},
+		{"POST", "/api/trash/abc/restore", http.StatusOK},
+		{"POST", "/api/trash/zzz/restore", http.StatusNotFound},
+		{"DELETE", "/api/trash/abc", http.StatusNoContent},
+		{"DELETE", "/api/trash/..", http.StatusBadRequest},
+		{"DELETE", "/api/trash", http.StatusNoContent},
+		{"POST", "/api/trash", http.StatusMethodNotAllowed},
+		{"GET", "/api/trash/abc/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.Trash(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.expectedStatus, rr.Code)
+		}
+	}
+	if strings.Join(purged, ",") != "abc," {
+		t.Errorf("unexpected purges: %q", purged)
+	}
+}
+
+func TestSegmentsHandler(t *testing.T) {
+	var created model.SegmentRequest
+	var deleted []string
+	h := New(nil, &mockGPXService{
+		segmentsFunc: func() ([]model.Segment, error) {
+			return []model.Segment{{ID: "abc", Name: "The climb"}}, nil
+		},
+		createSegFunc: func(req model.SegmentRequest) (model.Segment, error) {
+			created = req
+			if req.Name == "" {
+				return model.Segment{}, &customError{"invalid segment: missing name"}
+			}
+			if req.Path == "Activities/missing.gpx" {
+				return model.Segment{}, &customError{"not found"}
+			}
+			return model.Segment{ID: "def", Name: req.Name}, nil
+		},
+		getSegFunc: func(id string) (model.SegmentResponse, error) {
+			if id != "abc" {
+				return model.SegmentResponse{}, &customError{"segment not found"}
+			}
+			return model.SegmentResponse{
+				Segment: model.Segment{ID: id},
+				Efforts: []model.SegmentEffort{{Rank: 1, File: model.GPXFile{Name: "fast.gpx"}, Elapsed: 180}},
+			}, nil
+		},
+		deleteSegFunc: func(id string) error {
+			if id != "abc" {
+				return &customError{"segment not found"}
+			}
+			deleted = append(deleted, id)
+			return nil
+		},
+	}, nil)
+
+	tests := []struct {
+		method, path, body string
+		expectedStatus     int
+	}{
+		{"GET", "/api/segments", "", http.StatusOK},
+		{"POST", "/api/segments", `{"name":"Drawn","points":[{"lat":59,"lon":24},{"lat":59.01,"lon":24}]}`, http.StatusCreated},
+		{"POST", "/api/segments", `{"points":[]}`, http.StatusBadRequest},
+		{"POST", "/api/segments", `{"name":"Slice","path":"Activities/missing.gpx","from":3}`, http.StatusNotFound},
+		{"POST", "/api/segments", `{"name":"x","unknown":1}`, http.StatusBadRequest},
+		{"GET", "/api/segments/abc", "", http.StatusOK},
+		{"GET", "/api/segments/zzz", "", http.StatusNotFound},
+		{"GET", "/api/segments/abc/efforts", "", http.StatusNotFound},
+		{"DELETE", "/api/segments/abc", "", http.StatusNoContent},
+		{"DELETE", "/api/segments/zzz", "", http.StatusNotFound},
+		{"PUT", "/api/segments/abc", "", http.StatusMethodNotAllowed},
+		{"DELETE", "/api/segments", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.Segments(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.expectedStatus, rr.Code, rr.Body.String())
+		}
+	}
+	if created.Path != "Activities/missing.gpx" || created.From == nil || *created.From != 3 {
+		t.Errorf("unexpected request passed to CreateSegment: %+v", created)
+	}
+	if strings.Join(deleted, ",") != "abc" {
+		t.Errorf("unexpected deletes: %q", deleted)
+	}
+}
+
+func TestDuplicatesHandler(t *testing.T) {
+	var resolved []model.ResolveDuplicatesRequest
+	h := New(nil, &mockGPXService{
+		dupesFunc: func() (model.DuplicatesResponse, error) {
+			return model.DuplicatesResponse{Groups: []model.DuplicateGroup{{Kind: "exact", Keep: "Activities/a.gpx"}}}, nil
+		},
+		resolveFunc: func(req model.ResolveDuplicatesRequest) (model.ResolveDuplicatesResponse, error) {
+			resolved = append(resolved, req)
+			switch {
+			case req.Keep == "":
+				return model.ResolveDuplicatesResponse{}, &customError{"invalid resolve: missing keep or remove"}
+			case req.Remove[0] == "Activities/gone.gpx":
+				return model.ResolveDuplicatesResponse{}, &customError{"not found"}
+			}
+			return model.ResolveDuplicatesResponse{Trashed: []model.TrashItem{{ID: "t1"}}}, nil
+		},
+	}, nil)
+
+	tests := []struct {
+		method, path, body string
+		expectedStatus     int
+	}{
+		{"GET", "/api/duplicates", "", http.StatusOK},
+		{"POST", "/api/duplicates/resolve", `{"keep":"Activities/a.gpx","remove":["Activities/a copy.gpx"]}`, http.StatusOK},
+		{"POST", "/api/duplicates/resolve", `{"keep":"Activities/a.gpx","remove":["Activities/b.gpx"],"dismiss":true}`, http.StatusOK},
+		{"POST", "/api/duplicates/resolve", `{"remove":["Activities/b.gpx"]}`, http.StatusBadRequest},
+		{"POST", "/api/duplicates/resolve", `{"keep":"Activities/a.gpx","remove":["Activities/gone.gpx"]}`, http.StatusNotFound},
+		{"POST", "/api/duplicates/resolve", `{"keep":"x","unknown":1}`, http.StatusBadRequest},
+		{"GET", "/api/duplicates/resolve", "", http.StatusMethodNotAllowed},
+		{"POST", "/api/duplicates", "", http.StatusMethodNotAllowed},
+		{"POST", "/api/duplicates/merge", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.Duplicates(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.expectedStatus, rr.Code, rr.Body.String())
+		}
+	}
+	if len(resolved) != 4 || !resolved[1].Dismiss || resolved[0].Dismiss {
+		t.Errorf("unexpected requests passed to ResolveDuplicates: %+v", resolved)
+	}
+}
+
+func TestGPXCompareHandler(t *testing.T) {
+	var compared, matched []string
+	h := New(nil, &mockGPXService{
+		compareFunc: func(planPath, activityPath string) (model.CompareResponse, error) {
+			compared = append(compared, planPath+"|"+activityPath)
+			switch activityPath {
+			case "":
+				return model.CompareResponse{}, &customError{"invalid compare: missing activity"}
+			case "Activities/missing.gpx":
+				return model.CompareResponse{}, &customError{"not found"}
+			}
+			return model.CompareResponse{Plan: model.GPXFile{RelativePath: planPath}}, nil
+		},
+		matchesFunc: func(planPath string) ([]model.PlanMatch, error) {
+			matched = append(matched, planPath)
+			if planPath == "Plans/missing.gpx" {
+				return nil, &customError{"not found"}
+			}
+			return []model.PlanMatch{{File: model.GPXFile{Name: "ride.gpx"}, Overlap: 1, Coverage: 0.9}}, nil
+		},
+	}, nil)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/api/gpx/Plans/loop.gpx/compare?activity=Activities/ride.gpx", http.StatusOK},
+		{"/api/gpx/Plans/loop.gpx/compare", http.StatusBadRequest},
+		{"/api/gpx/Plans/loop.gpx/compare?activity=Activities/missing.gpx", http.StatusNotFound},
+		{"/api/gpx//compare?activity=Activities/ride.gpx", http.StatusBadRequest},
+		{"/api/gpx/Plans/loop.gpx/matches", http.StatusOK},
+		{"/api/gpx/Plans/missing.gpx/matches", http.StatusNotFound},
+		{"/api/gpx//matches", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.GPXDetail(rr, httptest.NewRequest("GET", tt.path, nil))
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("GET %s: expected %d, got %d: %s", tt.path, tt.expectedStatus, rr.Code, rr.Body.String())
+		}
+	}
+	if len(compared) != 3 || compared[0] != "Plans/loop.gpx|Activities/ride.gpx" {
+		t.Errorf("unexpected compare calls: %q", compared)
+	}
+	if strings.Join(matched, ",") != "Plans/loop.gpx,Plans/missing.gpx" {
+		t.Errorf("unexpected matches calls: %q", matched)
+	}
+}
+
+func TestGPXProfileHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockError      error
+		expectedStatus int
+		expectedPoints int
+	}{
+		{"Default points", "/api/gpx/Activities/run.gpx/profile", nil, http.StatusOK, 500},
+		{"Custom points", "/api/gpx/Activities/run.gpx/profile?points=120", nil, http.StatusOK, 120},
+		{"Too few points", "/api/gpx/Activities/run.gpx/profile?points=1", nil, http.StatusBadRequest, 0},
+		{"Bad points", "/api/gpx/Activities/run.gpx/profile?points=lots", nil, http.StatusBadRequest, 0},
+		{"Missing Path", "/api/gpx//profile", nil, http.StatusBadRequest, 0},
+		{"Not Found", "/api/gpx/Activities/run.gpx/profile", &customError{"not found"}, http.StatusNotFound, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			var gotPoints int
+			mockGPX := &mockGPXService{
+				profileFunc: func(relPath string, points int) (model.ProfileResponse, error) {
+					gotPath, gotPoints = relPath, points
+					if tt.mockError != nil {
+						return model.ProfileResponse{}, tt.mockError
+					}
+					return model.ProfileResponse{File: model.GPXFile{RelativePath: relPath}, TotalPoints: 9000}, nil
+				},
+			}
+			h := New(nil, mockGPX, nil)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if gotPoints != tt.expectedPoints {
+				t.Errorf("expected %d points requested, got %d", tt.expectedPoints, gotPoints)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if gotPath != "Activities/run.gpx" {
+					t.Errorf("expected relative path without suffix, got %q", gotPath)
+				}
+				var resp model.ProfileResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.TotalPoints != 9000 {
+					t.Errorf("unexpected response: %+v", resp)
+				}
+			}
+		})
+	}
+}
+
+func TestGPXGeoJSONHandler(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		mockError         error
+		expectedStatus    int
+		expectedTolerance float64
+		expectedAlgorithm string
+	}{
+		{"Defaults", "/api/gpx/Activities/run.gpx/geojson", nil, http.StatusOK, 5, ""},
+		{"Custom", "/api/gpx/Activities/run.gpx/geojson?tolerance=20&algorithm=vw", nil, http.StatusOK, 20, "vw"},
+		{"Negative tolerance", "/api/gpx/Activities/run.gpx/geojson?tolerance=-1", nil, http.StatusBadRequest, 0, ""},
+		{"Bad algorithm", "/api/gpx/Activities/run.gpx/geojson?algorithm=x", &customError{"invalid algorithm"}, http.StatusBadRequest, 5, "x"},
+		{"Parse Error", "/api/gpx/Activities/run.gpx/geojson", &customError{"invalid gpx: EOF"}, http.StatusUnprocessableEntity, 5, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotAlgorithm string
+			var gotTolerance float64
+			mockGPX := &mockGPXService{
+				geoJSONFunc: func(relPath string, tolerance float64, algorithm string) ([]byte, error) {
+					gotPath, gotTolerance, gotAlgorithm = relPath, tolerance, algorithm
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []byte(`{"type":"FeatureCollection","features":[]}`), nil
+				},
+			}
+			h := New(nil, mockGPX, nil)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if gotTolerance != tt.expectedTolerance || gotAlgorithm != tt.expectedAlgorithm {
+				t.Errorf("expected tolerance %v/%q, got %v/%q", tt.expectedTolerance, tt.expectedAlgorithm, gotTolerance, gotAlgorithm)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if gotPath != "Activities/run.gpx" {
+					t.Errorf("expected relative path without suffix, got %q", gotPath)
+				}
+				if ct := rr.Header().Get("Content-Type"); ct != "application/geo+json" {
+					t.Errorf("expected GeoJSON content type, got %q", ct)
+				}
+			}
+		})
+	}
+}
+
+func TestGPXExportHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockError      error
+		expectedStatus int
+	}{
+		{"FIT to GPX", "/api/gpx/Activities/ride.fit/export?format=gpx", nil, http.StatusOK},
+		{"Default format", "/api/gpx/Activities/ride.fit/export", nil, http.StatusOK},
+		{"Unknown format", "/api/gpx/Activities/ride.fit/export?format=shp", nil, http.StatusBadRequest},
+		{"Corrupt KMZ", "/api/gpx/Plans/route.kmz/export", &customError{"invalid kmz: zip: not a valid zip file"}, http.StatusUnprocessableEntity},
+		{"Not Found", "/api/gpx/Activities/missing.fit/export", &customError{"not found"}, http.StatusNotFound},
+		{"Corrupt FIT", "/api/gpx/Activities/ride.fit/export", &customError{"invalid fit: bad header"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotFormat string
+			mockGPX := &mockGPXService{
+				exportFunc: func(relPath, format string) ([]byte, error) {
+					gotPath, gotFormat = relPath, format
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return []byte(`<gpx version="1.1"></gpx>`), nil
+				},
+			}
+			h := New(nil, mockGPX, nil)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			h.GPXDetail(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if gotPath != "Activities/ride.fit" || gotFormat != "gpx" {
+				t.Errorf("unexpected service call: %q %q", gotPath, gotFormat)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/gpx+xml" {
+				t.Errorf("expected GPX content type, got %q", ct)
+			}
+			if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=ride.gpx` {
+				t.Errorf("unexpected Content-Disposition %q", cd)
+			}
+		})
+	}
+}
+
+func TestGPXExportHandler_KML(t *testing.T) {
+	h := New(nil, &mockGPXService{
+		exportFunc: func(relPath, format string) ([]byte, error) {
+			if format != "kml" {
+				t.Errorf("expected kml, got %q", format)
+			}
+			return []byte("<kml/>"), nil
+		},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	h.GPXDetail(rr, httptest.NewRequest("GET", "/api/gpx/Activities/run.gpx/export?format=kml", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/vnd.google-earth.kml+xml" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != "attachment; filename=run.kml" {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+}
+
+func TestDataFilesHandler(t *testing.T) {
+	var exported string
+	h := New(nil, &mockGPXService{
+		exportFunc: func(relPath, format string) ([]byte, error) {
+			exported = relPath + ":" + format
+			return []byte("<gpx/>"), nil
+		},
+	}, nil)
+	raw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw"))
+	})
+	srv := h.DataFiles(raw)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/data/Activities/run.tcx", nil))
+	if rr.Body.String() != "raw" || exported != "" {
+		t.Errorf("expected raw file without format, got %q (export %q)", rr.Body.String(), exported)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/data/Activities/run.tcx?format=gpx", nil))
+	if rr.Code != http.StatusOK || exported != "Activities/run.tcx:gpx" || rr.Body.String() != "<gpx/>" {
+		t.Errorf("expected conversion, got %d %q (export %q)", rr.Code, rr.Body.String(), exported)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/data/Activities/run.tcx?format=doc", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", rr.Code)
+	}
+}
+
+func TestRedactedService(t *testing.T) {
+	full := &mockGPXService{
+		exportFunc:    func(relPath, format string) ([]byte, error) { return []byte("full"), nil },
+		listFilesFunc: func() ([]model.GPXFile, error) { return []model.GPXFile{{Name: "full"}}, nil },
+		searchFunc: func(params url.Values) ([]model.GPXFile, int, error) {
+			return []model.GPXFile{{Name: "full"}}, 1, nil
+		},
+		getTrackFunc: func(relPath string) (model.GPXDetailResponse, error) {
+			return model.GPXDetailResponse{File: model.GPXFile{Name: "full"}}, nil
+		},
+	}
+	redacted := &mockGPXService{
+		exportFunc:    func(relPath, format string) ([]byte, error) { return []byte("redacted"), nil },
+		listFilesFunc: func() ([]model.GPXFile, error) { return []model.GPXFile{{Name: "redacted"}}, nil },
+		searchFunc: func(params url.Values) ([]model.GPXFile, int, error) {
+			return []model.GPXFile{{Name: "redacted"}}, 1, nil
+		},
+		getTrackFunc: func(relPath string) (model.GPXDetailResponse, error) {
+			return model.GPXDetailResponse{File: model.GPXFile{Name: "redacted"}}, nil
+		},
+	}
+	h := New(&config.Config{TrustLocalhost: true, AccessToken: "secret"}, full, nil)
+	h.SetRedactedService(redacted)
+	raw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw"))
+	})
+	data := h.DataFiles(raw)
+
+	request := func(target, remote string, header http.Header) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remote
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		return req
+	}
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		body   string
+	}{
+		{"local raw file", request("/data/Activities/run.gpx", "127.0.0.1:5000", nil), http.StatusOK, "raw"},
+		{"local IPv6 raw file", request("/data/Activities/run.gpx", "[::1]:5000", nil), http.StatusOK, "raw"},
+		{"remote gpx", request("/data/Activities/run.gpx", "192.168.1.20:5000", nil), http.StatusOK, "redacted"},
+		{"remote kml", request("/data/Activities/run.KML", "192.168.1.20:5000", nil), http.StatusOK, "redacted"},
+		{"remote fit", request("/data/Activities/run.fit", "192.168.1.20:5000", nil), http.StatusForbidden, ""},
+		{"remote other file", request("/data/.trash/", "192.168.1.20:5000", nil), http.StatusNotFound, ""},
+		{"remote conversion", request("/data/Activities/run.fit?format=gpx", "192.168.1.20:5000", nil), http.StatusOK, "redacted"},
+		{"proxied local", request("/data/Activities/run.gpx", "127.0.0.1:5000", http.Header{"X-Forwarded-For": {"203.0.113.5"}}), http.StatusOK, "redacted"},
+		{"remote with token", request("/data/Activities/run.gpx", "192.168.1.20:5000", http.Header{"Authorization": {"Bearer secret"}}), http.StatusOK, "raw"},
+		{"remote with wrong token", request("/data/Activities/run.gpx", "192.168.1.20:5000", http.Header{"Authorization": {"Bearer guess"}}), http.StatusOK, "redacted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			data.ServeHTTP(rr, tt.req)
+			if rr.Code != tt.status || (tt.body != "" && rr.Body.String() != tt.body) {
+				t.Errorf("expected %d %q, got %d %q", tt.status, tt.body, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	h.GPXDetail(rr, request("/api/gpx/Activities/run.gpx", "192.168.1.20:5000", nil))
+	var resp model.GPXDetailResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.File.Name != "redacted" {
+		t.Errorf("expected the redacted track for a remote client, got %+v %v", resp.File, err)
+	}
+	for _, target := range []string{"/api/gpx", "/api/gpx?near=59,24"} {
+		rr = httptest.NewRecorder()
+		h.ListGPXFiles(rr, request(target, "192.168.1.20:5000", nil))
+		var files []model.GPXFile
+		if err := json.NewDecoder(rr.Body).Decode(&files); err != nil || len(files) != 1 || files[0].Name != "redacted" {
+			t.Errorf("%s: expected the redacted listing for a remote client, got %+v %v", target, files, err)
+		}
+	}
+
+	h = New(&config.Config{}, full, nil)
+	h.SetRedactedService(redacted)
+	rr = httptest.NewRecorder()
+	h.DataFiles(raw).ServeHTTP(rr, request("/data/Activities/run.gpx", "127.0.0.1:5000", nil))
+	if rr.Body.String() != "redacted" {
+		t.Errorf("localhost should not be trusted when disabled, got %q", rr.Body.String())
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	events := make(chan model.LibraryEvent, 2)
+	events <- model.LibraryEvent{
+		Type:         model.LibraryFileAdded,
+		RelativePath: "Activities/new.gpx",
+		File:         &model.GPXFile{Name: "new.gpx", RelativePath: "Activities/new.gpx"},
+	}
+	events <- model.LibraryEvent{Type: model.LibraryFileRemoved, RelativePath: "Activities/old.gpx"}
+	close(events)
+
+	unsubscribed := false
+	h := New(nil, &mockGPXService{
+		subscribeFunc: func() (<-chan model.LibraryEvent, func()) {
+			return events, func() { unsubscribed = true }
+		},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	rr := httptest.NewRecorder()
+	h.Events(rr, req)
+
+	if got := rr.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", got)
+	}
+	if !unsubscribed {
+		t.Error("expected subscription to be released")
+	}
+
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, ": connected\n\n") {
+		t.Errorf("expected initial comment, got %q", body)
+	}
+	if !strings.Contains(body, "event: file-added\ndata: {\"type\":\"file-added\",\"relativePath\":\"Activities/new.gpx\"") {
+		t.Errorf("missing file-added event in %q", body)
+	}
+	if !strings.Contains(body, "event: file-removed\ndata: {\"type\":\"file-removed\",\"relativePath\":\"Activities/old.gpx\"}\n\n") {
+		t.Errorf("missing file-removed event in %q", body)
+	}
+}
+
+func TestEventsHandler_ClientDisconnect(t *testing.T) {
+	events := make(chan model.LibraryEvent)
+	h := New(nil, &mockGPXService{
+		subscribeFunc: func() (<-chan model.LibraryEvent, func()) {
+			return events, func() {}
+		},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/events", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.Events(rr, req)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after client disconnect")
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	mockTiles := &mockTilesService{
+		getStatsFunc: func() model.StatusResponse {
+			return model.StatusResponse{CacheHits: 123}
+		},
+	}
+	h := New(nil, nil, mockTiles)
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	rr := httptest.NewRecorder()
+
+	h.Status(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rr.Code)
+	}
+
+	var resp model.StatusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.CacheHits != 123 {
+		t.Errorf("expected 123 hits, got %d", resp.CacheHits)
+	}
+}
+
+type customError struct{ text string }
+
+func (e *customError) Error() string { return e.text }
+
+func TestPrewarmViewHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           interface{}
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{ProviderKey: "test"},
+			mockError:      nil,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Method Not Allowed",
+			method:         "GET",
+			body:           nil,
+			mockError:      nil,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Invalid JSON",
+			method:         "POST",
+			body:           "invalid json",
+			mockError:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing Provider Key",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{},
+			mockError:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown Provider",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{ProviderKey: "unknown"},
+			mockError:      &customError{"unknown provider"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Offline Mode",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{ProviderKey: "test"},
+			mockError:      &customError{"offline mode"},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Too Many Tiles",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{ProviderKey: "test"},
+			mockError:      &customError{"too many tiles"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Internal Error",
+			method:         "POST",
+			body:           model.PrewarmViewRequest{ProviderKey: "test"},
+			mockError:      &customError{"something went wrong"},
+			expectedStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTiles := &mockTilesService{
+				prewarmViewFunc: func(ctx context.Context, req model.PrewarmViewRequest) (model.PrewarmViewResponse, error) {
+					return model.PrewarmViewResponse{Total: 10}, tt.mockError
+				},
+			}
+			h := New(nil, nil, mockTiles)
 
 			var reader io.Reader
 			if tt.body != nil {
@@ -341,3 +1854,79 @@ func TestPrewarmViewHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSegments_RedactedForUntrustedClients(t *testing.T) {
+	dataDir := t.TempDir()
+	var trk strings.Builder
+	trk.WriteString(`<gpx version="1.1"><trk><trkseg>`)
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i <= 20; i++ {
+		fmt.Fprintf(&trk, `<trkpt lat="%.4f" lon="24.0000"><time>%s</time></trkpt>`, 59+float64(i)*0.001, start.Add(time.Duration(i)*10*time.Second).Format(time.RFC3339))
+	}
+	trk.WriteString(`</trkseg></trk></gpx>`)
+	if err := os.MkdirAll(filepath.Join(dataDir, "Activities", "Running"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "Running", "run.gpx"), []byte(trk.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zone := track.PrivacyZone{Name: "home", Lat: 59.01, Lon: 24, Radius: 250}
+	service := gpx.NewService(dataDir, "")
+	h := New(&config.Config{TrustLocalhost: true}, service, nil)
+	h.SetRedactedService(service.Redacted([]track.PrivacyZone{zone}, false))
+
+	request := func(method, target, remote, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		h.Segments(rr, req)
+		return rr
+	}
+	checkPoints := func(what string, points []track.Point) {
+		t.Helper()
+		if len(points) == 0 {
+			t.Errorf("%s: expected the points outside the zone", what)
+		}
+		for _, p := range points {
+			if zone.Contains(p.Lat, p.Lon) {
+				t.Errorf("%s: point %v,%v inside the privacy zone was returned", what, p.Lat, p.Lon)
+			}
+		}
+	}
+
+	// A segment cut by an untrusted client only takes the points it may see.
+	rr := request("POST", "/api/segments", "192.168.1.20:5000", `{"name": "Whole run", "path": "Activities/Running/run.gpx"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created model.Segment
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	checkPoints("created", created.Points)
+
+	// A segment stored by a trusted client crosses the zone, but untrusted
+	// clients never see those points.
+	rr = request("POST", "/api/segments", "127.0.0.1:5000", `{"name": "Through", "path": "Activities/Running/run.gpx"}`)
+	var trusted model.Segment
+	if err := json.NewDecoder(rr.Body).Decode(&trusted); err != nil || len(trusted.Points) != 21 {
+		t.Fatalf("expected all points for a trusted client, got %d (%v)", len(trusted.Points), err)
+	}
+
+	rr = request("GET", "/api/segments", "192.168.1.20:5000", "")
+	var list []model.Segment
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil || len(list) != 2 {
+		t.Fatalf("expected two segments, got %+v (%v)", list, err)
+	}
+	for _, seg := range list {
+		checkPoints("listed "+seg.Name, seg.Points)
+	}
+
+	rr = request("GET", "/api/segments/"+trusted.ID, "192.168.1.20:5000", "")
+	var got model.SegmentResponse
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	checkPoints("fetched", got.Segment.Points)
+}
@@ -1,9 +1,287 @@
 package model
 
+import (
+	"time"
+
+	"gpx-self-host/internal/track"
+)
+
 type GPXFile struct {
-	Name         string `json:"name"`
-	Path         string `json:"path"`         // Relative path for fetching (with /data/ prefix)
-	RelativePath string `json:"relativePath"` // Path inside data dir, useful for displaying folders
+	Name         string      `json:"name"`
+	Path         string      `json:"path"`                  // Relative path for fetching (with /data/ prefix)
+	RelativePath string      `json:"relativePath"`          // Path inside data dir, useful for displaying folders
+	Format       string      `json:"format"`                // File format: gpx, fit, ...
+	Activity     string      `json:"activity"`              // First folder under Activities/, or "Plans"
+	Stats        *TrackStats `json:"stats,omitempty"`       // Nil when the file could not be parsed
+	DuplicateOf  string      `json:"duplicateOf,omitempty"` // RelativePath of the copy to keep when this file is a duplicate
+}
+
+// TrackStats are computed server-side with the same rules the info panel
+// uses (leaflet-gpx distance/moving time, smoothed elevation).
+type TrackStats struct {
+	StartTime     *time.Time `json:"startTime,omitempty"`
+	EndTime       *time.Time `json:"endTime,omitempty"`
+	Distance      float64    `json:"distance"`      // meters
+	TotalTime     float64    `json:"totalTime"`     // seconds
+	MovingTime    float64    `json:"movingTime"`    // seconds
+	ElevationGain float64    `json:"elevationGain"` // meters
+	ElevationLoss float64    `json:"elevationLoss"` // meters
+	Bounds        *BoundsDTO `json:"bounds,omitempty"`
+	PointCount    int        `json:"pointCount"`
+}
+
+const (
+	LibraryFileAdded   = "file-added"
+	LibraryFileChanged = "file-changed"
+	LibraryFileRemoved = "file-removed"
+)
+
+// UploadRequest carries files posted to POST /api/gpx. Destination is
+// "activities" (the default) or "plans"; Activity names the folder under
+// Activities/ and is only valid for activity uploads.
+type UploadRequest struct {
+	Destination string
+	Activity    string
+	Files       []UploadFile
+}
+
+type UploadFile struct {
+	Name string
+	Data []byte
+}
+
+// MoveRequest is the body of POST /api/gpx/{path}/move. Name renames the
+// file (the extension may be omitted but not changed); Folder moves it to
+// another folder under the data dir, e.g. "Activities/Gravel" or "Plans".
+// Either may be empty to keep the current value.
+type MoveRequest struct {
+	Name   string `json:"name,omitempty"`
+	Folder string `json:"folder,omitempty"`
+}
+
+// TrimRequest is the body of POST /api/gpx/{path}/trim. The kept range is
+// given either by time (Start/End, RFC 3339) or by track point index
+// (From/To, 0-based and inclusive); a missing bound leaves that side open.
+// By default a trimmed copy is written next to the original (named Name, or
+// "<name>-trimmed.gpx"); with Replace the original goes to the trash and the
+// trimmed track takes its place.
+type TrimRequest struct {
+	Start   *time.Time `json:"start,omitempty"`
+	End     *time.Time `json:"end,omitempty"`
+	From    *int       `json:"from,omitempty"`
+	To      *int       `json:"to,omitempty"`
+	Replace bool       `json:"replace,omitempty"`
+	Name    string     `json:"name,omitempty"`
+}
+
+// TrimResponse describes the file written by a trim. Backup is the trash item
+// holding the original when it was replaced.
+type TrimResponse struct {
+	File   GPXFile    `json:"file"`
+	Backup *TrashItem `json:"backup,omitempty"`
+}
+
+// MergeRequest is the body of POST /api/gpx/merge. The tracks of all Paths
+// are joined into one track, ordered by their start time, and written as GPX
+// to Folder (default: the folder of the first path) under Name (default:
+// "<first name>-merged.gpx"). With SegmentPerSource each source keeps its own
+// track segment; otherwise all points form one continuous segment.
+type MergeRequest struct {
+	Paths            []string `json:"paths"`
+	Name             string   `json:"name,omitempty"`
+	Folder           string   `json:"folder,omitempty"`
+	SegmentPerSource bool     `json:"segmentPerSource,omitempty"`
+}
+
+// SplitRequest is the body of POST /api/gpx/{path}/split. The track is cut at
+// each time in At (RFC 3339) and, when Pause is set (a Go duration such as
+// "30m"), at every gap between points at least that long. The parts are
+// written next to the original as "<name>-1.gpx", "<name>-2.gpx" and so on;
+// the original is kept.
+type SplitRequest struct {
+	At    []time.Time `json:"at,omitempty"`
+	Pause string      `json:"pause,omitempty"`
+}
+
+// SnapshotRequest describes GET /api/snapshot: a Width×Height pixel map of
+// the files in Paths, drawn in order over the base map.
+type SnapshotRequest struct {
+	Paths  []string
+	Width  int
+	Height int
+}
+
+// StatsTotals sums the listing stats of a set of activities. Count includes
+// files that could not be parsed; the sums only cover those that could.
+type StatsTotals struct {
+	Count         int     `json:"count"`
+	Distance      float64 `json:"distance"`      // meters
+	MovingTime    float64 `json:"movingTime"`    // seconds
+	ElevationGain float64 `json:"elevationGain"` // meters
+}
+
+// StatsGroup is one row of a stats summary. Key holds a value for each
+// groupBy dimension, e.g. {"activity": "Running", "year": "2025"}; date
+// dimensions are empty for files without a date.
+type StatsGroup struct {
+	Key map[string]string `json:"key"`
+	StatsTotals
+}
+
+// StatsSummaryResponse is the body of GET /api/stats/summary.
+type StatsSummaryResponse struct {
+	GroupBy []string     `json:"groupBy"`
+	Groups  []StatsGroup `json:"groups"`
+	Total   StatsTotals  `json:"total"`
+}
+
+// Record is one personal best. Value is in seconds for the fastest-*
+// records and in meters for the others; Start is when the effort began,
+// when the file has times.
+type Record struct {
+	Type  string     `json:"type"`
+	Value float64    `json:"value"`
+	Start *time.Time `json:"start,omitempty"`
+	File  GPXFile    `json:"file"`
+}
+
+// ActivityRecords are the personal bests of one activity folder.
+type ActivityRecords struct {
+	Activity string   `json:"activity"`
+	Records  []Record `json:"records"`
+}
+
+// RecordsResponse is the body of GET /api/records.
+type RecordsResponse struct {
+	Activities []ActivityRecords `json:"activities"`
+}
+
+// CalendarEntry is the part of one activity recorded on a calendar day. Day
+// counts from 1 over the Days the activity spans.
+type CalendarEntry struct {
+	File       GPXFile `json:"file"`
+	Distance   float64 `json:"distance"`   // meters
+	MovingTime float64 `json:"movingTime"` // seconds
+	Day        int     `json:"day"`
+	Days       int     `json:"days"`
+}
+
+// CalendarDay sums the activities recorded on one date (YYYY-MM-DD).
+type CalendarDay struct {
+	Date       string          `json:"date"`
+	Count      int             `json:"count"`
+	Distance   float64         `json:"distance"`   // meters
+	MovingTime float64         `json:"movingTime"` // seconds
+	Activities []CalendarEntry `json:"activities"`
+}
+
+// CalendarResponse is the body of GET /api/calendar.
+type CalendarResponse struct {
+	Year int           `json:"year"`
+	Days []CalendarDay `json:"days"`
+}
+
+// Segment is a stretch of road or trail whose traversals are timed across
+// the library. Points are stored as latitude/longitude only.
+type Segment struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Distance  float64       `json:"distance"` // meters
+	Points    []track.Point `json:"points"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// SegmentRequest is the body of POST /api/segments. The segment is either
+// drawn (Points) or cut from a library file (Path) by time (Start/End) or
+// track point index (From/To) as in TrimRequest.
+type SegmentRequest struct {
+	Name   string        `json:"name"`
+	Points []track.Point `json:"points,omitempty"`
+	Path   string        `json:"path,omitempty"`
+	Start  *time.Time    `json:"start,omitempty"`
+	End    *time.Time    `json:"end,omitempty"`
+	From   *int          `json:"from,omitempty"`
+	To     *int          `json:"to,omitempty"`
+}
+
+// SegmentEffort is one traversal of a segment. Rank is 1 for the fastest.
+type SegmentEffort struct {
+	Rank    int       `json:"rank"`
+	File    GPXFile   `json:"file"`
+	Start   time.Time `json:"start"`
+	Elapsed float64   `json:"elapsed"` // seconds
+	Speed   float64   `json:"speed"`   // average, m/s
+}
+
+// SegmentResponse is the body of GET /api/segments/{id}: the segment and its
+// efforts, fastest first.
+type SegmentResponse struct {
+	Segment Segment         `json:"segment"`
+	Efforts []SegmentEffort `json:"efforts"`
+}
+
+// DuplicateGroup is a set of files holding the same activity. Kind is
+// "exact" when they all have the same contents and "near" when they were
+// recorded over the same time along the same way. Keep is the suggested file
+// to keep, listed first in Files.
+type DuplicateGroup struct {
+	Kind  string    `json:"kind"`
+	Keep  string    `json:"keep"`
+	Files []GPXFile `json:"files"`
+}
+
+// DuplicatesResponse is the body of GET /api/duplicates.
+type DuplicatesResponse struct {
+	Groups []DuplicateGroup `json:"groups"`
+}
+
+// ResolveDuplicatesRequest is the body of POST /api/duplicates/resolve. The
+// files in Remove, which must be duplicates of Keep, go to the trash; with
+// Dismiss they stay and are no longer reported as duplicates of Keep.
+type ResolveDuplicatesRequest struct {
+	Keep    string   `json:"keep"`
+	Remove  []string `json:"remove"`
+	Dismiss bool     `json:"dismiss,omitempty"`
+}
+
+// ResolveDuplicatesResponse lists the trash items of the removed files.
+type ResolveDuplicatesResponse struct {
+	Trashed []TrashItem `json:"trashed"`
+}
+
+// CompareResponse is the body of GET /api/gpx/{plan}/compare: how the
+// activity deviated from the plan.
+type CompareResponse struct {
+	Plan       GPXFile          `json:"plan"`
+	Activity   GPXFile          `json:"activity"`
+	Comparison track.Comparison `json:"comparison"`
+}
+
+// PlanMatch is an activity suggested for a plan. Overlap is the share of the
+// plan the activity passes near and Coverage the share of the activity that
+// stays near the plan, both estimated from the indexed chunk bounds.
+type PlanMatch struct {
+	File     GPXFile `json:"file"`
+	Overlap  float64 `json:"overlap"`
+	Coverage float64 `json:"coverage"`
+}
+
+// TrashItem is a deleted file kept under data/.trash until it is restored or
+// purged.
+type TrashItem struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	RelativePath string    `json:"relativePath"` // Where the file was before deletion
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deletedAt"`
+}
+
+// LibraryEvent is pushed to /api/events subscribers when the watcher notices
+// a change under the data dir. File is omitted for removals.
+type LibraryEvent struct {
+	Type         string   `json:"type"`
+	RelativePath string   `json:"relativePath"`
+	File         *GPXFile `json:"file,omitempty"`
 }
 
 type ProviderDTO struct {
@@ -14,8 +292,11 @@ type ProviderDTO struct {
 	MaxZoom     int    `json:"maxZoom"`
 }
 
+// TileConfigResponse lists the base map providers and the overlays (tile
+// layers rendered by the server itself) under /tiles/{key}/{z}/{x}/{y}.png.
 type TileConfigResponse struct {
 	Providers map[string]ProviderDTO `json:"providers"`
+	Overlays  map[string]ProviderDTO `json:"overlays"`
 	Initial   string                 `json:"initial"`
 	Offline   bool                   `json:"offline"`
 }
@@ -48,3 +329,16 @@ type PrewarmViewResponse struct {
 	Ok          int    `json:"ok"`
 	Failed      int    `json:"failed"`
 }
+
+type GPXDetailResponse struct {
+	File  GPXFile         `json:"file"`
+	Track *track.Document `json:"track"`
+}
+
+// ProfileResponse is a downsampled distance/elevation/speed series for one
+// file. TotalPoints is the number of points before downsampling.
+type ProfileResponse struct {
+	File        GPXFile               `json:"file"`
+	TotalPoints int                   `json:"totalPoints"`
+	Points      []track.ProfileSample `json:"points"`
+}
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Viewport is a w×h window onto the world pixel plane at zoom Z, with its
+// north-west corner at world pixel (X, Y).
+type Viewport struct {
+	Z    int
+	X, Y float64
+	W, H int
+}
+
+// Fit returns the w×h viewport at the highest zoom up to maxZoom that shows
+// the given area with at least padding pixels to spare on every side,
+// centred on the area.
+func Fit(minLat, minLon, maxLat, maxLon float64, w, h, padding, maxZoom int) Viewport {
+	z := maxZoom
+	for ; z > 0; z-- {
+		x0, y0 := WorldPixel(maxLat, minLon, z)
+		x1, y1 := WorldPixel(minLat, maxLon, z)
+		if x1-x0 <= float64(w-2*padding) && y1-y0 <= float64(h-2*padding) {
+			break
+		}
+	}
+	x0, y0 := WorldPixel(maxLat, minLon, z)
+	x1, y1 := WorldPixel(minLat, maxLon, z)
+	return Viewport{
+		Z: z,
+		X: math.Round((x0+x1)/2 - float64(w)/2),
+		Y: math.Round((y0+y1)/2 - float64(h)/2),
+		W: w,
+		H: h,
+	}
+}
+
+// Project returns the position of a coordinate in the viewport.
+func (v Viewport) Project(lat, lon float64) (x, y float64) {
+	x, y = WorldPixel(lat, lon, v.Z)
+	return x - v.X, y - v.Y
+}
+
+// TileFunc returns base map tile z/x/y, numbered as in XYZ tile URLs.
+type TileFunc func(z, x, y int) (image.Image, error)
+
+// Basemap names a tile source for cache keys, bounds its zoom levels and
+// carries the attribution to print on images that show it.
+type Basemap struct {
+	Key         string
+	MaxZoom     int
+	Attribution string
+	Tile        TileFunc
+}
+
+// DrawTiles paints the base map tiles under the viewport onto img and
+// reports whether every one of them was available. Missing tiles are left
+// blank.
+func (v Viewport) DrawTiles(img draw.Image, tile TileFunc) bool {
+	n := 1 << v.Z
+	complete := true
+	for ty := int(math.Floor(v.Y / TileSize)); float64(ty*TileSize) < v.Y+float64(v.H); ty++ {
+		if ty < 0 || ty >= n {
+			continue
+		}
+		for tx := int(math.Floor(v.X / TileSize)); float64(tx*TileSize) < v.X+float64(v.W); tx++ {
+			src, err := tile(v.Z, ((tx%n)+n)%n, ty)
+			if err != nil {
+				complete = false
+				continue
+			}
+			at := image.Pt(tx*TileSize-int(v.X), ty*TileSize-int(v.Y))
+			draw.Draw(img, image.Rectangle{Min: at, Max: at.Add(image.Pt(TileSize, TileSize))}, src, src.Bounds().Min, draw.Src)
+		}
+	}
+	return complete
+}
+
+// Stroke draws a polyline, given in pixel coordinates, onto img with a
+// square pen of the given width.
+func Stroke(img draw.Image, points [][2]float64, width int, c color.Color) {
+	b := img.Bounds()
+	lo := -(width - 1) / 2
+	Polyline(points, b.Dx(), b.Dy(), func(x, y int) {
+		for py := y + lo; py < y+lo+width; py++ {
+			for px := x + lo; px < x+lo+width; px++ {
+				blend(img, b.Min.X+px, b.Min.Y+py, c)
+			}
+		}
+	})
+}
+
+// Disc fills the circle of radius r around (x, y).
+func Disc(img draw.Image, x, y, r float64, c color.Color) {
+	b := img.Bounds()
+	for py := int(math.Floor(y - r)); py <= int(math.Ceil(y+r)); py++ {
+		for px := int(math.Floor(x - r)); px <= int(math.Ceil(x+r)); px++ {
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if dx*dx+dy*dy <= r*r {
+				blend(img, b.Min.X+px, b.Min.Y+py, c)
+			}
+		}
+	}
+}
+
+// blend draws c over the pixel at (x, y), keeping what shows through a
+// translucent colour.
+func blend(img draw.Image, x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	draw.Draw(img, image.Rect(x, y, x+1, y+1), image.NewUniform(c), image.Point{}, draw.Over)
+}
@@ -0,0 +1,98 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Density counts, per pixel, how many tracks pass through it.
+type Density struct {
+	w, h   int
+	counts []uint32
+	marks  []uint32 // last track that touched each pixel
+	track  uint32
+}
+
+func NewDensity(w, h int) *Density {
+	return &Density{w: w, h: h, counts: make([]uint32, w*h), marks: make([]uint32, w*h)}
+}
+
+// AddTrack draws the lines of one track, given in pixel coordinates, with a
+// square pen of the given width. A pixel is counted once per track however
+// often the track crosses it, so a single loop ridden many times does not
+// outshine a popular road.
+func (d *Density) AddTrack(lines [][][2]float64, width int) {
+	d.track++
+	if width < 1 {
+		width = 1
+	}
+	lo := -(width - 1) / 2
+	plot := func(x, y int) {
+		for py := y + lo; py < y+lo+width; py++ {
+			for px := x + lo; px < x+lo+width; px++ {
+				if px < 0 || px >= d.w || py < 0 || py >= d.h {
+					continue
+				}
+				i := py*d.w + px
+				if d.marks[i] != d.track {
+					d.marks[i] = d.track
+					d.counts[i]++
+				}
+			}
+		}
+	}
+	for _, line := range lines {
+		Polyline(line, d.w, d.h, plot)
+	}
+}
+
+// Empty reports whether no pixel has been touched.
+func (d *Density) Empty() bool {
+	for _, c := range d.counts {
+		if c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// heatRamp runs from a translucent dark red for a single track to an opaque
+// pale yellow for busy pixels.
+var heatRamp = []color.NRGBA{
+	{178, 24, 43, 120},
+	{240, 59, 32, 190},
+	{254, 178, 76, 235},
+	{255, 255, 204, 255},
+}
+
+// Image colours the counts on a logarithmic scale that reaches the top of
+// the ramp at saturation tracks. The scale is fixed rather than relative to
+// the busiest pixel so that neighbouring tiles match at their seams.
+func (d *Density) Image(saturation float64) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, d.w, d.h))
+	scale := math.Log1p(math.Max(saturation, 1))
+	for i, c := range d.counts {
+		if c == 0 {
+			continue
+		}
+		t := 0.0
+		if scale > 0 {
+			t = math.Min(math.Log1p(float64(c-1))/scale, 1)
+		}
+		img.SetNRGBA(i%d.w, i/d.w, rampColor(t))
+	}
+	return img
+}
+
+func rampColor(t float64) color.NRGBA {
+	pos := t * float64(len(heatRamp)-1)
+	i := int(pos)
+	if i >= len(heatRamp)-1 {
+		return heatRamp[len(heatRamp)-1]
+	}
+	f := pos - float64(i)
+	a, b := heatRamp[i], heatRamp[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f)) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
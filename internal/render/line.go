@@ -0,0 +1,90 @@
+package render
+
+import "math"
+
+// Line calls plot for every pixel on the segment from (x0, y0) to (x1, y1)
+// that falls inside a w×h raster. The segment is clipped first, so very long
+// segments at high zoom levels cost no more than the visible part.
+func Line(x0, y0, x1, y1 float64, w, h int, plot func(x, y int)) {
+	var ok bool
+	if x0, y0, x1, y1, ok = clip(x0, y0, x1, y1, float64(w), float64(h)); !ok {
+		return
+	}
+	ix0, iy0 := int(math.Floor(x0)), int(math.Floor(y0))
+	ix1, iy1 := int(math.Floor(x1)), int(math.Floor(y1))
+	dx, dy := abs(ix1-ix0), -abs(iy1-iy0)
+	sx, sy := 1, 1
+	if ix0 > ix1 {
+		sx = -1
+	}
+	if iy0 > iy1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		if ix0 >= 0 && ix0 < w && iy0 >= 0 && iy0 < h {
+			plot(ix0, iy0)
+		}
+		if ix0 == ix1 && iy0 == iy1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			ix0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			iy0 += sy
+		}
+	}
+}
+
+// Polyline draws consecutive segments through the points, given as
+// (x, y) pairs; a single point is plotted on its own.
+func Polyline(points [][2]float64, w, h int, plot func(x, y int)) {
+	if len(points) == 1 {
+		Line(points[0][0], points[0][1], points[0][0], points[0][1], w, h, plot)
+	}
+	for i := 1; i < len(points); i++ {
+		Line(points[i-1][0], points[i-1][1], points[i][0], points[i][1], w, h, plot)
+	}
+}
+
+// clip cuts the segment to the box [0, w) × [0, h) (Liang–Barsky) and
+// reports whether anything is left.
+func clip(x0, y0, x1, y1, w, h float64) (float64, float64, float64, float64, bool) {
+	// Stay just inside the far edges so flooring never lands on w or h.
+	w, h = math.Nextafter(w, 0), math.Nextafter(h, 0)
+	t0, t1 := 0.0, 1.0
+	dx, dy := x1-x0, y1-y0
+	for _, edge := range [4][2]float64{{-dx, x0}, {dx, w - x0}, {-dy, y0}, {dy, h - y0}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, 0, 0, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return 0, 0, 0, 0, false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return 0, 0, 0, 0, false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	return x0 + t0*dx, y0 + t0*dy, x0 + t1*dx, y0 + t1*dy, true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
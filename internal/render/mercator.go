@@ -0,0 +1,61 @@
+// Package render rasterises tracks onto Web Mercator tiles and images.
+package render
+
+import "math"
+
+// TileSize is the edge length of a map tile in pixels.
+const TileSize = 256
+
+// maxLat is the latitude where the Web Mercator square ends.
+const maxLat = 85.05112878
+
+// WorldPixel projects a coordinate to global pixel coordinates at zoom z,
+// with (0, 0) at the north-west corner of tile 0/0/0.
+func WorldPixel(lat, lon float64, z int) (x, y float64) {
+	lat = math.Max(-maxLat, math.Min(maxLat, lat))
+	scale := float64(TileSize) * math.Exp2(float64(z))
+	x = (lon + 180) / 360 * scale
+	rad := lat * math.Pi / 180
+	y = (1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * scale
+	return x, y
+}
+
+// Unproject is the inverse of WorldPixel.
+func Unproject(x, y float64, z int) (lat, lon float64) {
+	scale := float64(TileSize) * math.Exp2(float64(z))
+	lon = x/scale*360 - 180
+	n := math.Pi * (1 - 2*y/scale)
+	lat = math.Atan(math.Sinh(n)) * 180 / math.Pi
+	return lat, lon
+}
+
+// TileBounds returns the area covered by tile z/x/y, grown by margin pixels
+// on every side.
+func TileBounds(z, x, y int, margin float64) (minLat, minLon, maxLat, maxLon float64) {
+	maxLat, minLon = Unproject(float64(x*TileSize)-margin, float64(y*TileSize)-margin, z)
+	minLat, maxLon = Unproject(float64((x+1)*TileSize)+margin, float64((y+1)*TileSize)+margin, z)
+	return minLat, minLon, maxLat, maxLon
+}
+
+// TileRange returns the tiles at zoom z whose bounds, grown by margin pixels
+// as in TileBounds, overlap the given area: columns x0..x1 and rows y0..y1.
+func TileRange(minLat, minLon, maxLat, maxLon float64, z int, margin float64) (x0, y0, x1, y1 int) {
+	left, top := WorldPixel(maxLat, minLon, z)
+	right, bottom := WorldPixel(minLat, maxLon, z)
+	last := 1<<z - 1
+	clamp := func(v int) int { return max(0, min(last, v)) }
+	// Tile t spans [t*TileSize-margin, (t+1)*TileSize+margin]; touching counts.
+	first := func(v float64) int { return clamp(int(math.Ceil((v-margin)/TileSize)) - 1) }
+	end := func(v float64) int { return clamp(int(math.Floor((v + margin) / TileSize))) }
+	return first(left), first(top), end(right), end(bottom)
+}
+
+// ValidTile reports whether z/x/y names a tile of the Web Mercator pyramid
+// with z in [0, maxZoom].
+func ValidTile(z, x, y, maxZoom int) bool {
+	if z < 0 || z > maxZoom {
+		return false
+	}
+	n := 1 << z
+	return x >= 0 && x < n && y >= 0 && y < n
+}
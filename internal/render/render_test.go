@@ -0,0 +1,135 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestWorldPixel(t *testing.T) {
+	x, y := WorldPixel(0, 0, 0)
+	if x != 128 || math.Abs(y-128) > 1e-9 {
+		t.Errorf("expected the center of tile 0/0/0, got %v,%v", x, y)
+	}
+	x, y = WorldPixel(59.437, 24.7536, 12)
+	lat, lon := Unproject(x, y, 12)
+	if math.Abs(lat-59.437) > 1e-9 || math.Abs(lon-24.7536) > 1e-9 {
+		t.Errorf("round trip drifted: %v,%v", lat, lon)
+	}
+	if tx, ty := int(x)/TileSize, int(y)/TileSize; tx != 2329 || ty != 1202 {
+		t.Errorf("expected tile 12/2329/1202, got %d/%d", tx, ty)
+	}
+
+	const maxLat85 = 85.0511287798
+	minLat, minLon, maxLat, maxLon := TileBounds(1, 1, 0, 0)
+	if minLat != 0 || minLon != 0 || math.Abs(maxLat-maxLat85) > 1e-6 || maxLon != 180 {
+		t.Errorf("unexpected bounds of tile 1/1/0: %v %v %v %v", minLat, minLon, maxLat, maxLon)
+	}
+	if x0, y0, x1, y1 := TileRange(59.437, 24.7536, 59.437, 24.7536, 12, 0); x0 != 2329 || y0 != 1202 || x1 != 2329 || y1 != 1202 {
+		t.Errorf("expected only tile 12/2329/1202, got %d..%d, %d..%d", x0, x1, y0, y1)
+	}
+	// Tile 1/1/0 ends at (0, 0); with a margin it also touches its neighbours.
+	if x0, y0, x1, y1 := TileRange(10, 10, 20, 20, 1, 0); x0 != 1 || y0 != 0 || x1 != 1 || y1 != 0 {
+		t.Errorf("expected only tile 1/1/0, got %d..%d, %d..%d", x0, x1, y0, y1)
+	}
+	if x0, y0, x1, y1 := TileRange(0.01, 0.01, 20, 20, 1, 2); x0 != 0 || y0 != 0 || x1 != 1 || y1 != 1 {
+		t.Errorf("expected the margin to reach every tile at zoom 1, got %d..%d, %d..%d", x0, x1, y0, y1)
+	}
+	if !ValidTile(3, 7, 0, 18) || ValidTile(3, 8, 0, 18) || ValidTile(19, 0, 0, 18) || ValidTile(0, 0, -1, 18) {
+		t.Error("ValidTile accepted or rejected the wrong tiles")
+	}
+}
+
+func TestLine(t *testing.T) {
+	var plotted [][2]int
+	plot := func(x, y int) { plotted = append(plotted, [2]int{x, y}) }
+
+	Line(0.5, 0.5, 3.5, 0.5, 10, 10, plot)
+	if len(plotted) != 4 || plotted[3] != [2]int{3, 0} {
+		t.Errorf("expected 4 pixels along the top row, got %v", plotted)
+	}
+
+	// A segment spanning millions of pixels is clipped to the raster.
+	plotted = nil
+	Line(-1e7, 5.5, 1e7, 5.5, 10, 10, plot)
+	if len(plotted) != 10 || plotted[0] != [2]int{0, 5} || plotted[9] != [2]int{9, 5} {
+		t.Errorf("expected the clipped row, got %v", plotted)
+	}
+
+	plotted = nil
+	Line(-5, -5, -1, 20, 10, 10, plot)
+	Line(0, 10, 10, 10, 10, 10, plot)
+	if len(plotted) != 0 {
+		t.Errorf("segments outside the raster should plot nothing, got %v", plotted)
+	}
+}
+
+func TestDensity(t *testing.T) {
+	d := NewDensity(8, 8)
+	if !d.Empty() {
+		t.Fatal("new density should be empty")
+	}
+	// The first track crosses pixel (2, 2) twice but counts once there.
+	d.AddTrack([][][2]float64{{{0.5, 2.5}, {5.5, 2.5}}, {{2.5, 0.5}, {2.5, 5.5}}}, 1)
+	d.AddTrack([][][2]float64{{{2.5, 2.5}}}, 1)
+	if d.counts[2*8+2] != 2 || d.counts[2*8+0] != 1 || d.counts[7*8+7] != 0 {
+		t.Errorf("unexpected counts: %v", d.counts)
+	}
+
+	img := d.Image(10)
+	if img.NRGBAAt(7, 7).A != 0 {
+		t.Error("untouched pixels should be transparent")
+	}
+	one, two := img.NRGBAAt(0, 2), img.NRGBAAt(2, 2)
+	if one != heatRamp[0] || two.A <= one.A {
+		t.Errorf("busier pixels should be hotter: %v vs %v", one, two)
+	}
+	if c := rampColor(1); c != heatRamp[len(heatRamp)-1] {
+		t.Errorf("expected the top of the ramp, got %v", c)
+	}
+
+	wide := NewDensity(8, 8)
+	wide.AddTrack([][][2]float64{{{4.5, 4.5}}}, 3)
+	n := 0
+	for _, c := range wide.counts {
+		n += int(c)
+	}
+	if n != 9 {
+		t.Errorf("a 3px pen should cover 9 pixels, got %d", n)
+	}
+}
+
+func TestFit(t *testing.T) {
+	// 0.01° of latitude near Tallinn spans 114 px at zoom 13, too many for
+	// 128 px less padding, and 57 px at zoom 12.
+	v := Fit(59.43, 24.75, 59.44, 24.75, 128, 128, 8, 18)
+	if v.Z != 12 {
+		t.Errorf("expected zoom 12, got %d", v.Z)
+	}
+	x0, y0 := v.Project(59.44, 24.75)
+	x1, y1 := v.Project(59.43, 24.75)
+	if math.Abs(x0-64) > 1 || y0 < 8 || y1 > 120 || math.Abs((y0+y1)/2-64) > 1 {
+		t.Errorf("expected the area centred inside the padding, got %v,%v %v,%v", x0, y0, x1, y1)
+	}
+	if v := Fit(59.43, 24.75, 59.43, 24.75, 128, 128, 8, 16); v.Z != 16 {
+		t.Errorf("a single point should use the maximum zoom, got %d", v.Z)
+	}
+}
+
+func TestText(t *testing.T) {
+	if w, h := TextSize("© OSM", 2); w != 2*(7*6-1) || h != 14 {
+		t.Errorf("unexpected size %dx%d", w, h)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	Text(img, 1, 1, "I", 1, color.Black)
+	// The I is a vertical bar in its middle column with serifs.
+	for y := 1; y <= 7; y++ {
+		if img.NRGBAAt(3, y).A != 255 {
+			t.Errorf("expected the stem at 3,%d", y)
+		}
+	}
+	if img.NRGBAAt(1, 4).A != 0 || img.NRGBAAt(2, 1).A != 255 || img.NRGBAAt(2, 4).A != 0 {
+		t.Error("unexpected glyph shape")
+	}
+}
@@ -13,29 +13,51 @@ import (
 )
 
 type Server struct {
-	cfg        *config.Config
-	httpServer *http.Server
+	cfg         *config.Config
+	gpxService  *gpx.Service
+	httpServer  *http.Server
+	watchCtx    context.Context
+	stopWatcher context.CancelFunc
 }
 
 func New(cfg *config.Config) *Server {
 	// Initialize Services
-	gpxService := gpx.NewService(cfg.DataDir)
+	gpxService := gpx.NewService(cfg.DataDir, cfg.CacheDir)
 	tileService := tiles.NewService(cfg)
 
 	// Initialize Handlers
 	h := handler.New(cfg, gpxService, tileService)
+	if len(cfg.PrivacyZones) > 0 {
+		h.SetRedactedService(gpxService.Redacted(cfg.PrivacyZones, cfg.PrivacyTruncate))
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/", http.FileServer(http.Dir(cfg.StaticDir)))
-	mux.Handle("/data/", http.StripPrefix("/data/", http.FileServer(http.Dir(cfg.DataDir))))
+	mux.Handle("/data/", h.DataFiles(http.StripPrefix("/data/", http.FileServer(http.Dir(cfg.DataDir)))))
 	mux.HandleFunc("/api/gpx", h.ListGPXFiles)
+	mux.HandleFunc("/api/gpx/", h.GPXDetail)
+	mux.HandleFunc("/api/trash", h.Trash)
+	mux.HandleFunc("/api/trash/", h.Trash)
 	mux.HandleFunc("/api/tile-config", h.TileConfig)
+	mux.HandleFunc("/api/events", h.Events)
 	mux.HandleFunc("/api/status", h.Status)
 	mux.HandleFunc("/api/prewarm-view", h.PrewarmView)
+	mux.HandleFunc("/api/snapshot", h.Snapshot)
+	mux.HandleFunc("/api/stats/summary", h.StatsSummary)
+	mux.HandleFunc("/api/records", h.Records)
+	mux.HandleFunc("/api/calendar", h.Calendar)
+	mux.HandleFunc("/api/segments", h.Segments)
+	mux.HandleFunc("/api/segments/", h.Segments)
+	mux.HandleFunc("/api/duplicates", h.Duplicates)
+	mux.HandleFunc("/api/duplicates/", h.Duplicates)
 	mux.HandleFunc("/tiles/", h.TileProxy)
 
+	watchCtx, stopWatcher := context.WithCancel(context.Background())
 	s := &Server{
-		cfg: cfg,
+		cfg:         cfg,
+		gpxService:  gpxService,
+		watchCtx:    watchCtx,
+		stopWatcher: stopWatcher,
 		httpServer: &http.Server{
 			Addr:              cfg.Port,
 			Handler:           mux,
@@ -54,6 +76,9 @@ func (s *Server) ListenAndServe() error {
 		size = 0
 	}
 	slog.Info("Current cache size", "size_readable", formatBytes(size))
+
+	go s.watchLibrary(s.watchCtx)
+
 	slog.Info("Starting server", "address", "http://localhost"+s.cfg.Port)
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
@@ -66,5 +91,26 @@ func (s *Server) Handler() http.Handler {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopWatcher()
+	// Event streams never go idle on their own; end them so Shutdown can drain.
+	s.gpxService.Close()
 	return s.httpServer.Shutdown(ctx)
 }
+
+// watchLibrary brings the library index up to date in the background, so the
+// first /api/gpx request does not pay for parsing new or changed files, and
+// then keeps polling for changes when a watch interval is configured.
+func (s *Server) watchLibrary(ctx context.Context) {
+	start := time.Now()
+	files, err := s.gpxService.ListFiles()
+	if err != nil {
+		slog.Warn("Failed to build library index", "error", err)
+	} else {
+		slog.Info("Library index ready", "files", len(files), "duration_ms", time.Since(start).Milliseconds())
+	}
+
+	if s.cfg.WatchInterval > 0 {
+		slog.Info("Watching data dir for changes", "interval", s.cfg.WatchInterval)
+		s.gpxService.Watch(ctx, s.cfg.WatchInterval)
+	}
+}
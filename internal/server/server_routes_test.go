@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gpx-self-host/internal/config"
@@ -29,6 +30,12 @@ func TestServerRegistersStaticAndDataRoutes(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(activitiesDir, "track.gpx"), gpxContent, 0644); err != nil {
 		t.Fatalf("failed to seed data file: %v", err)
 	}
+	tcxContent := []byte(`<TrainingCenterDatabase><Activities><Activity Sport="Running"><Lap><Track>
+<Trackpoint><Time>2025-06-01T08:00:00Z</Time><Position><LatitudeDegrees>59</LatitudeDegrees><LongitudeDegrees>24</LongitudeDegrees></Position></Trackpoint>
+</Track></Lap></Activity></Activities></TrainingCenterDatabase>`)
+	if err := os.WriteFile(filepath.Join(activitiesDir, "run.tcx"), tcxContent, 0644); err != nil {
+		t.Fatalf("failed to seed data file: %v", err)
+	}
 
 	cfg := &config.Config{
 		StaticDir: staticDir,
@@ -78,4 +85,27 @@ func TestServerRegistersStaticAndDataRoutes(t *testing.T) {
 			t.Fatalf("unexpected data body: %q", string(body))
 		}
 	})
+
+	t.Run("data files converted to gpx", func(t *testing.T) {
+		resp, err := ts.Client().Get(ts.URL + "/data/Activities/run.tcx?format=gpx")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for converted file, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/gpx+xml" {
+			t.Fatalf("unexpected content type: %q", ct)
+		}
+		if !strings.Contains(string(body), `<trkpt lat="59" lon="24">`) {
+			t.Fatalf("unexpected converted body: %q", string(body))
+		}
+	})
 }
@@ -0,0 +1,45 @@
+package gpx
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// derivedKinds lists the cache subdirectories holding files generated from
+// track contents. Entries are keyed by the content hash, so edits are picked
+// up naturally; pruneDerived removes what no indexed file refers to anymore.
+var derivedKinds = []string{"geojson", "heatlines", "thumbnails", "segments"}
+
+// derivedPath returns where a generated artifact for the given content hash
+// is cached, or "" when the service has no cache dir.
+func (s *Service) derivedPath(kind, hash, name string) string {
+	if s.cacheDir == "" || hash == "" {
+		return ""
+	}
+	return filepath.Join(s.cacheDir, kind, hash[:2], hash+"-"+name)
+}
+
+// pruneDerived deletes cached artifacts for hashes that no longer belong to
+// any indexed file. Callers must hold s.mu.
+func (s *Service) pruneDerived(hashes map[string]bool) {
+	if s.cacheDir == "" || len(hashes) == 0 {
+		return
+	}
+	for _, entry := range s.entries {
+		delete(hashes, entry.Hash)
+	}
+	for hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		for _, kind := range derivedKinds {
+			matches, _ := filepath.Glob(filepath.Join(s.cacheDir, kind, hash[:2], hash+"-*"))
+			for _, m := range matches {
+				if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+					slog.Warn("Failed to remove cached artifact", "path", m, "error", err)
+				}
+			}
+		}
+	}
+}
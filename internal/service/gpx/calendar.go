@@ -0,0 +1,88 @@
+package gpx
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// Calendar returns the days of year with activities on them. Days come from
+// the recorded timestamps in local time, so a track crossing midnight adds
+// its distance and moving time to each day it covers; files without times
+// fall back to the listing date (metadata time or filename prefix) and count
+// in full on that day. Undated files, files under Plans/ and duplicates of
+// other files are left out.
+// Days are in date order, activities within a day by start time.
+func (s *Service) Calendar(year int) (model.CalendarResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scanned, err := s.refresh()
+	if err != nil {
+		return model.CalendarResponse{}, err
+	}
+
+	prefix := strconv.Itoa(year) + "-"
+	type dated struct {
+		entry model.CalendarEntry
+		start int64
+	}
+	byDate := make(map[string][]dated)
+	for _, sf := range scanned {
+		f := s.fileFor(sf.relPath)
+		if f.Activity == "Plans" || f.DuplicateOf != "" {
+			continue
+		}
+		start, ok := fileDate(f)
+		if !ok {
+			continue
+		}
+		var days []track.DaySplit
+		if entry := s.entries[sf.relPath]; entry != nil {
+			days = track.DaySplits(entry.Splits, time.Local)
+		}
+		if len(days) == 0 {
+			split := track.DaySplit{Date: start.Format("2006-01-02")}
+			if f.Stats != nil {
+				split.Distance, split.MovingTime = f.Stats.Distance, f.Stats.MovingTime
+			}
+			days = []track.DaySplit{split}
+		}
+		for i, d := range days {
+			if !strings.HasPrefix(d.Date, prefix) {
+				continue
+			}
+			byDate[d.Date] = append(byDate[d.Date], dated{model.CalendarEntry{
+				File:       f,
+				Distance:   d.Distance,
+				MovingTime: d.MovingTime,
+				Day:        i + 1,
+				Days:       len(days),
+			}, start.UnixNano()})
+		}
+	}
+
+	resp := model.CalendarResponse{Year: year, Days: []model.CalendarDay{}}
+	for date, entries := range byDate {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].start != entries[j].start {
+				return entries[i].start < entries[j].start
+			}
+			return entries[i].entry.File.RelativePath < entries[j].entry.File.RelativePath
+		})
+		day := model.CalendarDay{Date: date, Activities: make([]model.CalendarEntry, 0, len(entries))}
+		for _, e := range entries {
+			day.Count++
+			day.Distance += e.entry.Distance
+			day.MovingTime += e.entry.MovingTime
+			day.Activities = append(day.Activities, e.entry)
+		}
+		resp.Days = append(resp.Days, day)
+	}
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].Date < resp.Days[j].Date })
+	return resp, nil
+}
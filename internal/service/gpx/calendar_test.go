@@ -0,0 +1,90 @@
+package gpx
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar(t *testing.T) {
+	dataDir := t.TempDir()
+	at := func(y, m, d, hh, mm, ss int) time.Time {
+		return time.Date(y, time.Month(m), d, hh, mm, ss, 0, time.Local)
+	}
+	untimed := `<gpx version="1.1"><rte><rtept lat="60" lon="25"/><rtept lat="60.01" lon="25"/></rte></gpx>`
+	for path, content := range map[string]string{
+		"Activities/Running/night.gpx":             lineTrack(at(2025, 6, 30, 23, 59, 40), 4), // crosses midnight
+		"Activities/Running/morning.gpx":           lineTrack(at(2025, 7, 1, 9, 0, 0), 3),
+		"Activities/Running/last year.gpx":         lineTrack(at(2024, 7, 1, 9, 0, 0), 3),
+		"Activities/Hiking/2025-07-03 untimed.gpx": untimed,
+		"Activities/Hiking/undated.gpx":            untimed,
+		"Plans/2025-07-02 plan.gpx":                strings.ReplaceAll(untimed, `lon="25"`, `lon="26"`),
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, "")
+	files, err := s.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := make(map[string]float64)
+	for _, f := range files {
+		stats[f.Name] = f.Stats.Distance
+	}
+
+	resp, err := s.Calendar(2025)
+	if err != nil {
+		t.Fatalf("Calendar failed: %v", err)
+	}
+	if resp.Year != 2025 || len(resp.Days) != 3 {
+		t.Fatalf("expected three days in 2025, got %+v", resp)
+	}
+	first, second, third := resp.Days[0], resp.Days[1], resp.Days[2]
+	if first.Date != "2025-06-30" || second.Date != "2025-07-01" || third.Date != "2025-07-03" {
+		t.Fatalf("unexpected dates %s, %s, %s", first.Date, second.Date, third.Date)
+	}
+
+	// The night run is split over both days, and its parts add up.
+	if first.Count != 1 || first.Activities[0].File.Name != "night.gpx" || first.Activities[0].Day != 1 || first.Activities[0].Days != 2 {
+		t.Errorf("unexpected first day %+v", first)
+	}
+	if second.Count != 2 || second.Activities[0].File.Name != "night.gpx" || second.Activities[0].Day != 2 || second.Activities[1].File.Name != "morning.gpx" {
+		t.Errorf("unexpected second day %+v", second)
+	}
+	if got := first.Activities[0].Distance + second.Activities[0].Distance; math.Abs(got-stats["night.gpx"]) > 1e-6 {
+		t.Errorf("night.gpx parts add up to %v, expected %v", got, stats["night.gpx"])
+	}
+	if math.Abs(second.Distance-second.Activities[0].Distance-second.Activities[1].Distance) > 1e-6 || second.MovingTime <= 0 {
+		t.Errorf("day totals do not match its activities: %+v", second)
+	}
+
+	// Files without times count in full on their filename date.
+	if third.Count != 1 || third.Activities[0].File.Name != "2025-07-03 untimed.gpx" || third.Distance != stats["2025-07-03 untimed.gpx"] {
+		t.Errorf("unexpected third day %+v", third)
+	}
+
+	resp, err = s.Calendar(2023)
+	if err != nil || len(resp.Days) != 0 {
+		t.Errorf("expected an empty year, got %+v %v", resp, err)
+	}
+}
+
+func TestCalendar_FollowsTimeZone(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+
+	dataDir, cacheDir := t.TempDir(), t.TempDir()
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Running", "late.gpx"), lineTrack(time.Date(2025, 6, 30, 22, 0, 0, 0, time.UTC), 3), time.Now())
+	if resp, err := NewService(dataDir, cacheDir).Calendar(2025); err != nil || len(resp.Days) != 1 || resp.Days[0].Date != "2025-06-30" {
+		t.Fatalf("expected the run on June 30 in UTC, got %+v %v", resp, err)
+	}
+
+	// The persisted index is reused, yet the days follow the new zone.
+	time.Local = time.FixedZone("EEST", 3*3600)
+	resp, err := NewService(dataDir, cacheDir).Calendar(2025)
+	if err != nil || len(resp.Days) != 1 || resp.Days[0].Date != "2025-07-01" {
+		t.Errorf("expected the run on July 1 in +03:00, got %+v %v", resp, err)
+	}
+}
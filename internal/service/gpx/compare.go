@@ -0,0 +1,118 @@
+package gpx
+
+import (
+	"fmt"
+	"sort"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/spatial"
+	"gpx-self-host/internal/track"
+)
+
+const (
+	planMatchMargin     = 0.0005 // degrees (~50 m, as track.OffRouteTolerance) chunks are grown by
+	planMatchMinOverlap = 0.5    // share of the plan's chunks a suggested activity passes near
+	maxPlanMatches      = 10
+)
+
+// Compare reports how an activity deviated from a plan: distances, off-route
+// distance and the sections added or skipped (see track.Compare).
+func (s *Service) Compare(planPath, activityPath string) (model.CompareResponse, error) {
+	return s.compare(planPath, activityPath, nil)
+}
+
+func (s *Service) compare(planPath, activityPath string, red *redaction) (model.CompareResponse, error) {
+	if activityPath == "" {
+		return model.CompareResponse{}, fmt.Errorf("invalid compare: missing activity")
+	}
+	var files [2]model.GPXFile
+	var lines [2][][]track.Point
+	for i, relPath := range []string{planPath, activityPath} {
+		fullPath, relPath, err := s.resolvePath(relPath)
+		if err != nil {
+			return model.CompareResponse{}, err
+		}
+		doc, err := parseFile(fullPath)
+		if err != nil {
+			return model.CompareResponse{}, err
+		}
+		doc = red.apply(doc)
+		if lines[i] = doc.Lines(); len(lines[i]) == 0 {
+			return model.CompareResponse{}, fmt.Errorf("invalid compare: %s has no track or route", relPath)
+		}
+		files[i] = newGPXFile(relPath)
+		files[i].Stats = statsDTO(doc.Stats())
+	}
+	return model.CompareResponse{
+		Plan:       files[0],
+		Activity:   files[1],
+		Comparison: track.Compare(lines[0], lines[1]),
+	}, nil
+}
+
+// PlanMatches suggests the activities that most likely followed a plan,
+// best first: those passing near at least planMatchMinOverlap of the plan's
+// indexed chunks, ranked by that share times the share of their own chunks
+// near the plan, so a ride that also went elsewhere ranks below one that
+// kept to the route. Only the index is used; Compare gives the exact
+// figures for a chosen pair. Plans, files without a track or route and
+// files marked as duplicates are not suggested.
+func (s *Service) PlanMatches(planPath string) ([]model.PlanMatch, error) {
+	_, planPath, err := s.resolvePath(planPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+	plan := s.entries[planPath]
+	if plan == nil {
+		return nil, fmt.Errorf("not found")
+	}
+	if s.tree == nil {
+		s.buildTree()
+	}
+	candidates := make(map[string]bool)
+	for _, c := range plan.Chunks {
+		grown := chunkBounds{c[0] - planMatchMargin, c[1] - planMatchMargin, c[2] + planMatchMargin, c[3] + planMatchMargin}
+		s.tree.Search(grown.rect(), func(it spatial.Item) bool {
+			candidates[s.treeRefs[it.ID].relPath] = true
+			return true
+		})
+	}
+
+	matches := []model.PlanMatch{}
+	for relPath := range candidates {
+		if relPath == planPath || deriveActivity(relPath) == "Plans" || s.duplicates().keepOf[relPath] != "" {
+			continue
+		}
+		entry := s.entries[relPath]
+		if entry.Stats == nil || entry.Stats.Distance == 0 {
+			continue // waypoints only
+		}
+		overlap := chunkCoverage(plan.Chunks, entry.Chunks, planMatchMargin)
+		if overlap < planMatchMinOverlap {
+			continue
+		}
+		matches = append(matches, model.PlanMatch{
+			File:     s.fileFor(relPath),
+			Overlap:  overlap,
+			Coverage: chunkCoverage(entry.Chunks, plan.Chunks, planMatchMargin),
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if sa, sb := a.Overlap*a.Coverage, b.Overlap*b.Coverage; sa != sb {
+			return sa > sb
+		}
+		return a.File.RelativePath < b.File.RelativePath
+	})
+	if len(matches) > maxPlanMatches {
+		matches = matches[:maxPlanMatches]
+	}
+	return matches, nil
+}
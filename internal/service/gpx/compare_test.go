@@ -0,0 +1,68 @@
+package gpx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareAndPlanMatches(t *testing.T) {
+	dataDir := t.TempDir()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 8, 0, 0, 0, time.UTC) }
+	const plan = `<gpx version="1.1"><rte><rtept lat="59.0000" lon="24.0000"/><rtept lat="59.0120" lon="24.0000"/></rte></gpx>`
+	for path, content := range map[string]string{
+		"Plans/2025-05-01 the climb.gpx":   plan,
+		"Plans/the climb again.gpx":        plan,
+		"Activities/Cycling/climb.gpx":     climbTrack(day(1), 4),
+		"Activities/Cycling/climb (1).gpx": climbTrack(day(1), 4),
+		"Activities/Running/long run.gpx":  runTrack(day(2), 40, 12, 0), // up the same road and 3 km on
+		"Activities/Running/elsewhere.gpx": strings.ReplaceAll(runTrack(day(3), 40, 12, 0), `lon="24.0"`, `lon="25.0"`),
+		"Activities/Walking/poi.gpx":       `<gpx version="1.1"><wpt lat="59.0" lon="24.0"/></gpx>`,
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, "")
+
+	matches, err := s.PlanMatches("Plans/2025-05-01 the climb.gpx")
+	if err != nil {
+		t.Fatalf("PlanMatches failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].File.Name != "climb.gpx" || matches[1].File.Name != "long run.gpx" {
+		t.Fatalf("expected climb.gpx then long run.gpx, got %+v", matches)
+	}
+	if matches[0].Overlap != 1 || matches[0].Coverage != 1 || matches[1].Overlap != 1 || matches[1].Coverage >= 0.5 {
+		t.Errorf("unexpected scores %+v", matches)
+	}
+	if _, err := s.PlanMatches("Plans/missing.gpx"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	resp, err := s.Compare("Plans/2025-05-01 the climb.gpx", "Activities/Cycling/climb.gpx")
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	c := resp.Comparison
+	if resp.Plan.Activity != "Plans" || resp.Activity.Stats == nil || len(c.Added) != 0 || len(c.Skipped) != 0 || c.MaxOffRoute > 1 || c.PlanDistance < 1330 || c.PlanDistance > 1340 {
+		t.Errorf("expected the climb to follow the plan, got %+v", resp)
+	}
+
+	resp, err = s.Compare("Plans/2025-05-01 the climb.gpx", "Activities/Running/long run.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := resp.Comparison; len(c.Added) != 1 || len(c.Skipped) != 0 || c.DistanceDiff < 2900 {
+		t.Errorf("expected the run past the plan's end to be added, got %+v", c)
+	}
+
+	for _, tt := range []struct{ plan, activity, err string }{
+		{"Plans/2025-05-01 the climb.gpx", "", "invalid compare"},
+		{"Plans/2025-05-01 the climb.gpx", "Activities/Walking/poi.gpx", "invalid compare"},
+		{"Plans/2025-05-01 the climb.gpx", "Activities/missing.gpx", "not found"},
+		{"../plan.gpx", "Activities/Cycling/climb.gpx", "invalid path"},
+	} {
+		if _, err := s.Compare(tt.plan, tt.activity); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("Compare(%q, %q): expected %s, got %v", tt.plan, tt.activity, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package gpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gpx-self-host/internal/model"
+)
+
+// dismissedFile remembers which files were confirmed not to be duplicates.
+// Pairs are stored by content hash, so they survive renames and moves.
+const dismissedFile = ".duplicates.json"
+
+// Near-duplicates are files recorded over mostly the same time along mostly
+// the same way, typically one activity synced from two devices.
+const (
+	duplicateMinOverlap  = 0.5    // share of the shorter time window both files cover
+	duplicateMinCoverage = 0.8    // share of each file's chunks lying near the other's
+	duplicateMargin      = 0.0005 // degrees (~50 m) chunks are grown by when compared
+)
+
+type duplicateGroup struct {
+	kind    string
+	members []string // relative paths, the one to keep first
+}
+
+type duplicateSet struct {
+	groups []duplicateGroup
+	keepOf map[string]string // duplicate → kept file; kept files are not listed
+}
+
+// Duplicates returns the groups of files that hold the same activity:
+// exact copies (same content hash) and near-duplicates (see
+// duplicateMinOverlap and duplicateMinCoverage). The file suggested to keep
+// is the most detailed recording (most points), then the one whose name
+// sorts first. Detection works on the library index alone, so no file is
+// read.
+func (s *Service) Duplicates() (model.DuplicatesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.refresh(); err != nil {
+		return model.DuplicatesResponse{}, err
+	}
+	resp := model.DuplicatesResponse{Groups: []model.DuplicateGroup{}}
+	for _, g := range s.duplicates().groups {
+		group := model.DuplicateGroup{Kind: g.kind, Keep: g.members[0]}
+		for _, relPath := range g.members {
+			group.Files = append(group.Files, s.fileFor(relPath))
+		}
+		resp.Groups = append(resp.Groups, group)
+	}
+	return resp, nil
+}
+
+// ResolveDuplicates moves the duplicates in req.Remove to the trash, or with
+// req.Dismiss records that they are not duplicates of req.Keep.
+func (s *Service) ResolveDuplicates(req model.ResolveDuplicatesRequest) (model.ResolveDuplicatesResponse, error) {
+	if req.Keep == "" || len(req.Remove) == 0 {
+		return model.ResolveDuplicatesResponse{}, fmt.Errorf("invalid resolve: missing keep or remove")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.refresh(); err != nil {
+		return model.ResolveDuplicatesResponse{}, err
+	}
+	dupes := s.duplicates()
+	var group *duplicateGroup
+	for i, g := range dupes.groups {
+		if containsString(g.members, req.Keep) {
+			group = &dupes.groups[i]
+		}
+	}
+	for _, relPath := range req.Remove {
+		if relPath == req.Keep || group == nil || !containsString(group.members, relPath) {
+			return model.ResolveDuplicatesResponse{}, fmt.Errorf("invalid resolve: %s is not a duplicate of %s", relPath, req.Keep)
+		}
+	}
+
+	resp := model.ResolveDuplicatesResponse{Trashed: []model.TrashItem{}}
+	if req.Dismiss {
+		if err := s.loadDismissed(); err != nil {
+			return resp, err
+		}
+		for _, relPath := range req.Remove {
+			s.dismissed[hashPair(s.entries[req.Keep].Hash, s.entries[relPath].Hash)] = true
+		}
+		if err := s.saveDismissed(); err != nil {
+			return resp, err
+		}
+		events := s.updateDuplicates(nil)
+		for i := range events {
+			file := s.fileFor(events[i].RelativePath)
+			events[i].File = &file
+		}
+		s.publish(events)
+		return resp, nil
+	}
+
+	var ids []string
+	for _, relPath := range req.Remove {
+		fullPath, relPath, err := s.resolvePath(relPath)
+		if err == nil {
+			err = s.checkConfined(fullPath)
+		}
+		if err == nil {
+			err = statFile(fullPath)
+		}
+		var id string
+		if err == nil {
+			id, err = s.moveToTrash(fullPath, relPath)
+		}
+		if err != nil {
+			s.refresh()
+			return resp, err
+		}
+		s.removeEmptyDirs(filepath.Dir(fullPath))
+		ids = append(ids, id)
+	}
+	if _, err := s.refresh(); err != nil {
+		return resp, err
+	}
+	for _, id := range ids {
+		item, err := s.trashItem(id)
+		if err != nil {
+			return resp, err
+		}
+		resp.Trashed = append(resp.Trashed, item)
+	}
+	return resp, nil
+}
+
+// duplicates returns the duplicate groups of the current index, computing
+// them when needed. Callers must hold s.mu.
+func (s *Service) duplicates() *duplicateSet {
+	if s.dupes != nil {
+		return s.dupes
+	}
+	if err := s.loadDismissed(); err != nil {
+		slog.Warn("Ignoring dismissed duplicates", "error", err)
+	}
+
+	paths := make([]string, 0, len(s.entries))
+	for relPath := range s.entries {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+
+	// Pairs are joined into groups with a union-find over relative paths;
+	// only paths in some pair are in parent. Edges keeps the pairs themselves
+	// for splitGroup.
+	parent := make(map[string]string)
+	edges := make(map[string][]string)
+	var find func(string) string
+	find = func(p string) string {
+		q, ok := parent[p]
+		if !ok {
+			parent[p] = p
+			return p
+		}
+		if q != p {
+			q = find(q)
+			parent[p] = q
+		}
+		return q
+	}
+	join := func(a, b string) {
+		if s.dismissed[hashPair(s.entries[a].Hash, s.entries[b].Hash)] {
+			return
+		}
+		edges[a] = append(edges[a], b)
+		edges[b] = append(edges[b], a)
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	// Exact copies share a hash.
+	byHash := make(map[string][]string)
+	for _, relPath := range paths {
+		if h := s.entries[relPath].Hash; h != "" {
+			byHash[h] = append(byHash[h], relPath)
+		}
+	}
+	for _, same := range byHash {
+		for _, other := range same[1:] {
+			join(same[0], other)
+		}
+	}
+
+	// Near-duplicates: sweep the timed files by start time, comparing each
+	// with the files that start before it ends.
+	var timed []string
+	for _, relPath := range paths {
+		if st := s.entries[relPath].Stats; st != nil && st.StartTime != nil && st.EndTime != nil && st.EndTime.After(*st.StartTime) {
+			timed = append(timed, relPath)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return s.entries[timed[i]].Stats.StartTime.Before(*s.entries[timed[j]].Stats.StartTime)
+	})
+	for i, a := range timed {
+		ea := s.entries[a]
+		for _, b := range timed[i+1:] {
+			eb := s.entries[b]
+			if !eb.Stats.StartTime.Before(*ea.Stats.EndTime) {
+				break
+			}
+			if ea.Hash != eb.Hash && nearDuplicates(ea, eb) {
+				join(a, b)
+			}
+		}
+	}
+
+	members := make(map[string][]string)
+	for relPath := range parent {
+		root := find(relPath)
+		members[root] = append(members[root], relPath)
+	}
+	set := &duplicateSet{keepOf: make(map[string]string)}
+	for _, component := range members {
+		sort.Slice(component, func(i, j int) bool { return s.keepBefore(component[i], component[j]) })
+		for _, group := range s.splitGroup(component, edges) {
+			kind := "exact"
+			for _, relPath := range group[1:] {
+				set.keepOf[relPath] = group[0]
+				if s.entries[relPath].Hash != s.entries[group[0]].Hash {
+					kind = "near"
+				}
+			}
+			set.groups = append(set.groups, duplicateGroup{kind: kind, members: group})
+		}
+	}
+	sort.Slice(set.groups, func(i, j int) bool { return set.groups[i].members[0] < set.groups[j].members[0] })
+	s.dupes = set
+	return set
+}
+
+// splitGroup breaks a connected set of files, sorted by keepBefore, into
+// groups that never put a file under one it was dismissed against: A and B
+// can both match C after A–B was dismissed. The best file keeps the files it
+// reaches through pairs without passing a file dismissed against it; the
+// rest are grouped again among themselves. Groups of one are dropped.
+func (s *Service) splitGroup(files []string, edges map[string][]string) [][]string {
+	var groups [][]string
+	for len(files) > 1 {
+		keep := files[0]
+		dismissed := func(relPath string) bool {
+			return s.dismissed[hashPair(s.entries[keep].Hash, s.entries[relPath].Hash)]
+		}
+		left := make(map[string]bool, len(files))
+		for _, relPath := range files {
+			left[relPath] = true
+		}
+		in := map[string]bool{keep: true}
+		queue := []string{keep}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			for _, q := range edges[p] {
+				if left[q] && !in[q] && !dismissed(q) {
+					in[q] = true
+					queue = append(queue, q)
+				}
+			}
+		}
+
+		var group, rest []string
+		for _, relPath := range files {
+			if in[relPath] {
+				group = append(group, relPath)
+			} else {
+				rest = append(rest, relPath)
+			}
+		}
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+		files = rest
+	}
+	return groups
+}
+
+// keepBefore orders the files of a group, the best one to keep first.
+func (s *Service) keepBefore(a, b string) bool {
+	pa, pb := 0, 0
+	if st := s.entries[a].Stats; st != nil {
+		pa = st.PointCount
+	}
+	if st := s.entries[b].Stats; st != nil {
+		pb = st.PointCount
+	}
+	if pa != pb {
+		return pa > pb
+	}
+	// Copies tend to get a suffix ("hike (1)", "hike copy"), so the name
+	// without extension that sorts first is usually the original.
+	stem := func(p string) string { return strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)) }
+	if sa, sb := stem(a), stem(b); sa != sb {
+		return sa < sb
+	}
+	return a < b
+}
+
+// nearDuplicates compares two entries whose time windows intersect.
+func nearDuplicates(a, b *indexEntry) bool {
+	start, end := *a.Stats.StartTime, *a.Stats.EndTime
+	if b.Stats.StartTime.After(start) {
+		start = *b.Stats.StartTime
+	}
+	if b.Stats.EndTime.Before(end) {
+		end = *b.Stats.EndTime
+	}
+	shorter := a.Stats.EndTime.Sub(*a.Stats.StartTime)
+	if d := b.Stats.EndTime.Sub(*b.Stats.StartTime); d < shorter {
+		shorter = d
+	}
+	if end.Sub(start).Seconds() < duplicateMinOverlap*shorter.Seconds() {
+		return false
+	}
+	return chunkCoverage(a.Chunks, b.Chunks, duplicateMargin) >= duplicateMinCoverage &&
+		chunkCoverage(b.Chunks, a.Chunks, duplicateMargin) >= duplicateMinCoverage
+}
+
+// chunkCoverage returns the share of chunks in a that come within margin
+// degrees of some chunk in b.
+func chunkCoverage(a, b []chunkBounds, margin float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	near := 0
+	for _, ca := range a {
+		grown := chunkBounds{ca[0] - margin, ca[1] - margin, ca[2] + margin, ca[3] + margin}.rect()
+		for _, cb := range b {
+			if grown.Intersects(cb.rect()) {
+				near++
+				break
+			}
+		}
+	}
+	return float64(near) / float64(len(a))
+}
+
+// updateDuplicates recomputes the duplicate groups after the index changed
+// and adds a file-changed event for every file whose duplicate status
+// changed without the file itself changing. Callers must hold s.mu.
+func (s *Service) updateDuplicates(events []model.LibraryEvent) []model.LibraryEvent {
+	old := s.dupes
+	s.dupes = nil
+	if old == nil {
+		return events
+	}
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		seen[e.RelativePath] = true
+	}
+	now := s.duplicates()
+	var changed []string
+	for _, m := range []map[string]string{old.keepOf, now.keepOf} {
+		for relPath := range m {
+			if !seen[relPath] && s.entries[relPath] != nil && old.keepOf[relPath] != now.keepOf[relPath] {
+				seen[relPath] = true
+				changed = append(changed, relPath)
+			}
+		}
+	}
+	sort.Strings(changed)
+	for _, relPath := range changed {
+		events = append(events, model.LibraryEvent{Type: model.LibraryFileChanged, RelativePath: relPath})
+	}
+	return events
+}
+
+func hashPair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// loadDismissed reads the dismissed pairs once. Callers must hold s.mu.
+func (s *Service) loadDismissed() error {
+	if s.dismissed != nil {
+		return nil
+	}
+	s.dismissed = make(map[[2]string]bool)
+	data, err := os.ReadFile(filepath.Join(s.DataDir, dismissedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var pairs [][2]string
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return fmt.Errorf("%s: %w", dismissedFile, err)
+	}
+	for _, p := range pairs {
+		s.dismissed[hashPair(p[0], p[1])] = true
+	}
+	return nil
+}
+
+// saveDismissed writes the dismissed pairs. Callers must hold s.mu.
+func (s *Service) saveDismissed() error {
+	pairs := make([][2]string, 0, len(s.dismissed))
+	for p := range s.dismissed {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return strings.Join(pairs[i][:], "") < strings.Join(pairs[j][:], "") })
+	data, err := json.MarshalIndent(pairs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.DataDir, dismissedFile), data)
+}
@@ -0,0 +1,179 @@
+package gpx
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+func TestDuplicates(t *testing.T) {
+	dataDir := t.TempDir()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 8, 0, 0, 0, time.UTC) }
+	ride := climbTrack(day(1), 4)
+	run := runTrack(day(2), 40, 12, 0)
+	for path, content := range map[string]string{
+		"Activities/Cycling/ride.gpx":       ride,
+		"Activities/Cycling/ride watch.gpx": strings.ReplaceAll(climbTrack(day(1).Add(10*time.Second), 4), `lon="24.0000"`, `lon="24.0001"`), // ~6 m east
+		"Activities/Cycling/next day.gpx":   climbTrack(day(3), 4),
+		"Activities/Cycling/parallel.gpx":   strings.ReplaceAll(ride, `lon="24.0000"`, `lon="24.0100"`), // same time, ~570 m east
+		"Activities/Running/run.gpx":        run,
+		"Activities/Running/run (1).gpx":    run,
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, "")
+
+	resp, err := s.Duplicates()
+	if err != nil {
+		t.Fatalf("Duplicates failed: %v", err)
+	}
+	if len(resp.Groups) != 2 {
+		t.Fatalf("expected two groups, got %+v", resp.Groups)
+	}
+	near, exact := resp.Groups[0], resp.Groups[1]
+	if near.Kind != "near" || near.Keep != "Activities/Cycling/ride.gpx" || len(near.Files) != 2 || near.Files[1].RelativePath != "Activities/Cycling/ride watch.gpx" {
+		t.Errorf("unexpected near group %+v", near)
+	}
+	if exact.Kind != "exact" || exact.Keep != "Activities/Running/run.gpx" || len(exact.Files) != 2 {
+		t.Errorf("unexpected exact group %+v", exact)
+	}
+
+	files, err := s.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dupes := map[string]string{}
+	for _, f := range files {
+		if f.DuplicateOf != "" {
+			dupes[f.RelativePath] = f.DuplicateOf
+		}
+	}
+	if len(dupes) != 2 || dupes["Activities/Cycling/ride watch.gpx"] != "Activities/Cycling/ride.gpx" || dupes["Activities/Running/run (1).gpx"] != "Activities/Running/run.gpx" {
+		t.Errorf("expected only the copies to be marked, got %v", dupes)
+	}
+
+	for _, req := range []model.ResolveDuplicatesRequest{
+		{Remove: []string{"Activities/Running/run (1).gpx"}},
+		{Keep: "Activities/Running/run.gpx"},
+		{Keep: "Activities/Running/run.gpx", Remove: []string{"Activities/Running/run.gpx"}},
+		{Keep: "Activities/Running/run.gpx", Remove: []string{"Activities/Cycling/ride watch.gpx"}},
+		{Keep: "Activities/Cycling/next day.gpx", Remove: []string{"Activities/Cycling/ride.gpx"}},
+	} {
+		if _, err := s.ResolveDuplicates(req); err == nil || !strings.HasPrefix(err.Error(), "invalid resolve") {
+			t.Errorf("expected an invalid resolve error for %+v, got %v", req, err)
+		}
+	}
+
+	// Dismissing a pair clears the mark, announces it and survives a restart.
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+	if _, err := s.ResolveDuplicates(model.ResolveDuplicatesRequest{
+		Keep: "Activities/Cycling/ride.gpx", Remove: []string{"Activities/Cycling/ride watch.gpx"}, Dismiss: true,
+	}); err != nil {
+		t.Fatalf("dismiss failed: %v", err)
+	}
+	ev := receiveEvent(t, events)
+	if ev.Type != model.LibraryFileChanged || ev.RelativePath != "Activities/Cycling/ride watch.gpx" || ev.File == nil || ev.File.DuplicateOf != "" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, dismissedFile)); err != nil {
+		t.Errorf("expected dismissed pairs in the data dir: %v", err)
+	}
+	if resp, err := NewService(dataDir, "").Duplicates(); err != nil || len(resp.Groups) != 1 || resp.Groups[0].Kind != "exact" {
+		t.Errorf("expected only the exact group after dismissing, got %+v %v", resp, err)
+	}
+
+	// Resolving moves the copy to the trash.
+	resolved, err := s.ResolveDuplicates(model.ResolveDuplicatesRequest{
+		Keep: "Activities/Running/run.gpx", Remove: []string{"Activities/Running/run (1).gpx"},
+	})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if len(resolved.Trashed) != 1 || resolved.Trashed[0].RelativePath != "Activities/Running/run (1).gpx" {
+		t.Errorf("unexpected trashed items %+v", resolved.Trashed)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "Activities", "Running", "run (1).gpx")); !os.IsNotExist(err) {
+		t.Errorf("expected the copy to be gone, got %v", err)
+	}
+	if resp, err := s.Duplicates(); err != nil || len(resp.Groups) != 0 {
+		t.Errorf("expected no duplicates left, got %+v %v", resp, err)
+	}
+}
+
+func TestDuplicates_DismissedPairAcrossThirdFile(t *testing.T) {
+	dataDir := t.TempDir()
+	start := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	shifted := func(offset time.Duration, lon string) string {
+		return strings.ReplaceAll(climbTrack(start.Add(offset), 4), `lon="24.0000"`, `lon="`+lon+`"`)
+	}
+	// All three files match each other; the watch one was dismissed against
+	// ride.gpx but still matches the phone recording.
+	for path, content := range map[string]string{
+		"Activities/Cycling/ride.gpx":       climbTrack(start, 4),
+		"Activities/Cycling/ride phone.gpx": shifted(5*time.Second, "24.00005"),
+		"Activities/Cycling/ride watch.gpx": shifted(10*time.Second, "24.0001"),
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, "")
+
+	if resp, err := s.Duplicates(); err != nil || len(resp.Groups) != 1 || len(resp.Groups[0].Files) != 3 {
+		t.Fatalf("expected one group of three, got %+v %v", resp, err)
+	}
+	if _, err := s.ResolveDuplicates(model.ResolveDuplicatesRequest{
+		Keep: "Activities/Cycling/ride.gpx", Remove: []string{"Activities/Cycling/ride watch.gpx"}, Dismiss: true,
+	}); err != nil {
+		t.Fatalf("dismiss failed: %v", err)
+	}
+
+	resp, err := s.Duplicates()
+	if err != nil || len(resp.Groups) != 1 {
+		t.Fatalf("expected one group left, got %+v %v", resp, err)
+	}
+	if g := resp.Groups[0]; g.Keep != "Activities/Cycling/ride.gpx" || len(g.Files) != 2 || g.Files[1].RelativePath != "Activities/Cycling/ride phone.gpx" {
+		t.Errorf("the dismissed file should leave the group, got %+v", g)
+	}
+	files, _, err := s.Search(url.Values{"duplicates": {"hide"}})
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected the kept and the dismissed file, got %+v %v", files, err)
+	}
+	for _, f := range files {
+		if f.RelativePath == "Activities/Cycling/ride phone.gpx" {
+			t.Errorf("the phone copy should still be hidden: %+v", files)
+		}
+	}
+}
+
+func TestDuplicates_LeftOutOfAggregates(t *testing.T) {
+	dataDir := t.TempDir()
+	run := runTrack(time.Date(2025, 5, 2, 8, 0, 0, 0, time.UTC), 40, 12, 0)
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Running", "run.gpx"), run, time.Now())
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Running", "run (1).gpx"), run, time.Now())
+	s := NewService(dataDir, "")
+
+	files, total, err := s.Search(url.Values{"duplicates": {"hide"}})
+	if err != nil || total != 1 || len(files) != 1 || files[0].RelativePath != "Activities/Running/run.gpx" {
+		t.Errorf("expected only the kept file, got %+v (total %d, %v)", files, total, err)
+	}
+	if _, total, err := s.Search(url.Values{"sort": {"name"}}); err != nil || total != 2 {
+		t.Errorf("expected duplicates to be listed by default, got total %d (%v)", total, err)
+	}
+	if _, _, err := s.Search(url.Values{"duplicates": {"only"}}); err == nil || !strings.HasPrefix(err.Error(), "invalid query") {
+		t.Errorf("expected invalid query error, got %v", err)
+	}
+
+	summary, err := s.StatsSummary(url.Values{})
+	if err != nil || summary.Total.Count != 1 {
+		t.Errorf("expected the activity to be counted once, got %+v (%v)", summary.Total, err)
+	}
+	calendar, err := s.Calendar(2025)
+	if err != nil || len(calendar.Days) != 1 || calendar.Days[0].Count != 1 {
+		t.Errorf("expected one calendar activity, got %+v (%v)", calendar.Days, err)
+	}
+}
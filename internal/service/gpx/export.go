@@ -0,0 +1,56 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"gpx-self-host/internal/track"
+)
+
+// writers maps the formats Export can produce to their encoders.
+var writers = map[string]func(io.Writer, *track.Document) error{
+	"gpx": track.WriteGPX,
+	"kml": track.WriteKML,
+}
+
+// Export returns a library file converted to format ("gpx" or "kml"). A file
+// already in the requested format is returned unchanged; anything else is
+// decoded and rewritten. Redacted.Export always rewrites.
+func (s *Service) Export(relPath, format string) ([]byte, error) {
+	return s.export(relPath, format, nil)
+}
+
+func (s *Service) export(relPath, format string, red *redaction) ([]byte, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	write, ok := writers[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid format")
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, err
+	}
+	source := track.FormatOf(relPath)
+	if source == format && red == nil {
+		return data, nil
+	}
+
+	doc, err := track.Parse(source, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, red.apply(doc)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
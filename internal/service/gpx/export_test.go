@@ -0,0 +1,192 @@
+package gpx
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/track"
+)
+
+// newFITLibrary copies testdata/ride.fit (a short road ride recorded on an
+// Edge 540) into a fresh data dir next to plain GPX and TCX files.
+func newFITLibrary(t *testing.T) string {
+	t.Helper()
+	fit, err := os.ReadFile(filepath.Join("testdata", "ride.fit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataDir := t.TempDir()
+	files := map[string][]byte{
+		"Activities/Road/ride.fit":   fit,
+		"Activities/Road/walk.gpx":   []byte(lineTrack(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), 3)),
+		"Activities/Road/broken.FIT": []byte("not a fit file"),
+		"Activities/Road/run.tcx":    []byte(testTCX),
+	}
+	for rel, data := range files {
+		full := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dataDir
+}
+
+func TestListFiles_TCX(t *testing.T) {
+	service := NewService(newFITLibrary(t), "")
+	files, _, err := service.Search(url.Values{"q": {"run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Format != "tcx" || files[0].Path != "/data/Activities/Road/run.tcx?format=gpx" {
+		t.Fatalf("unexpected TCX entry: %+v", files)
+	}
+	if st := files[0].Stats; st == nil || st.PointCount != 2 || st.MovingTime != 10 {
+		t.Errorf("unexpected TCX stats: %+v", st)
+	}
+
+	data, err := service.Export("Activities/Road/run.tcx", "gpx")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !bytes.Contains(data, []byte("<gpxtpx:hr>120</gpxtpx:hr>")) {
+		t.Errorf("heart rate missing from converted TCX: %s", data)
+	}
+}
+
+func TestListFiles_FIT(t *testing.T) {
+	service := NewService(newFITLibrary(t), t.TempDir())
+	files, err := service.ListFiles()
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+
+	byPath := make(map[string]int)
+	for i, f := range files {
+		byPath[f.RelativePath] = i
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %+v", files)
+	}
+
+	ride := files[byPath["Activities/Road/ride.fit"]]
+	if ride.Format != "fit" || ride.Activity != "Road" {
+		t.Errorf("unexpected FIT entry: %+v", ride)
+	}
+	if ride.Path != "/data/Activities/Road/ride.fit?format=gpx" {
+		t.Errorf("FIT files should be served through the converter, got %q", ride.Path)
+	}
+	if ride.Stats == nil || ride.Stats.PointCount != 4 || ride.Stats.Distance <= 0 {
+		t.Errorf("unexpected FIT stats: %+v", ride.Stats)
+	}
+
+	walk := files[byPath["Activities/Road/walk.gpx"]]
+	if walk.Format != "gpx" || walk.Path != "/data/Activities/Road/walk.gpx" {
+		t.Errorf("unexpected GPX entry: %+v", walk)
+	}
+	if broken := files[byPath["Activities/Road/broken.FIT"]]; broken.Stats != nil {
+		t.Errorf("corrupt FIT file should be listed without stats: %+v", broken)
+	}
+}
+
+func TestExport(t *testing.T) {
+	dataDir := newFITLibrary(t)
+	service := NewService(dataDir, "")
+
+	data, err := service.Export("Activities/Road/ride.fit", "gpx")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	doc, err := track.ParseGPX(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("exported GPX does not parse: %v", err)
+	}
+	if len(doc.Tracks) != 1 || len(doc.Tracks[0].Segments) != 2 || doc.Tracks[0].Type != "cycling" {
+		t.Errorf("unexpected exported track: %+v", doc.Tracks)
+	}
+	if p := doc.Tracks[0].Segments[0].Points[1]; p.Sensors().HeartRate == nil {
+		t.Errorf("heart rate was not carried over: %+v", p)
+	}
+
+	original, err := os.ReadFile(filepath.Join(dataDir, "Activities", "Road", "walk.gpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := service.Export("Activities/Road/walk.gpx", "gpx"); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("GPX sources should be returned unchanged, got %v", err)
+	}
+
+	if _, err := service.Export("Activities/Road/ride.fit", "shp"); err == nil || err.Error() != "invalid format" {
+		t.Errorf("expected invalid format, got %v", err)
+	}
+	if _, err := service.Export("Activities/Road/broken.FIT", "gpx"); err == nil || !strings.HasPrefix(err.Error(), "invalid fit") {
+		t.Errorf("expected FIT parse error, got %v", err)
+	}
+	if _, err := service.Export("Activities/Road/missing.fit", "gpx"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := service.Export("Activities/Road/notes.txt", "gpx"); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path, got %v", err)
+	}
+}
+
+const testTCX = `<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities><Activity Sport="Running"><Id>2025-06-03T07:00:00Z</Id><Lap StartTime="2025-06-03T07:00:00Z"><Track>
+    <Trackpoint><Time>2025-06-03T07:00:00Z</Time><Position><LatitudeDegrees>59.0</LatitudeDegrees><LongitudeDegrees>24.0</LongitudeDegrees></Position><HeartRateBpm><Value>120</Value></HeartRateBpm></Trackpoint>
+    <Trackpoint><Time>2025-06-03T07:00:10Z</Time><Position><LatitudeDegrees>59.0005</LatitudeDegrees><LongitudeDegrees>24.0</LongitudeDegrees></Position></Trackpoint>
+  </Track></Lap></Activity></Activities>
+</TrainingCenterDatabase>`
+
+func TestExport_KML(t *testing.T) {
+	dataDir := newFITLibrary(t)
+
+	var kmz bytes.Buffer
+	zw := zip.NewWriter(&kmz)
+	w, err := zw.Create("doc.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Placemark><name>Loop</name>
+<LineString><coordinates>24,59 24,59.01 24.01,59.01</coordinates></LineString></Placemark></Document></kml>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "Plans"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "Plans", "loop.kmz"), kmz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(dataDir, "")
+	files, _, err := service.Search(url.Values{"q": {"activity:Plans"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Format != "kmz" || files[0].Path != "/data/Plans/loop.kmz?format=gpx" {
+		t.Fatalf("unexpected KMZ entry: %+v", files)
+	}
+	if st := files[0].Stats; st == nil || st.PointCount != 3 || st.Distance < 1500 {
+		t.Errorf("unexpected KMZ stats: %+v", st)
+	}
+
+	data, err := service.Export("Activities/Road/ride.fit", "kml")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	doc, err := track.ParseKML(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("exported KML does not parse: %v", err)
+	}
+	if len(doc.Routes) != 2 {
+		t.Errorf("expected a line per FIT segment, got %+v", doc.Routes)
+	}
+}
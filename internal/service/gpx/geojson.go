@@ -0,0 +1,96 @@
+package gpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+
+	"gpx-self-host/internal/track"
+)
+
+// GetGeoJSON returns the file as an encoded GeoJSON FeatureCollection with
+// every line simplified to the given tolerance in meters (0 keeps all
+// points). algorithm is "douglas-peucker" (or "dp", the default), which drops
+// points within tolerance of the simplified line, or "visvalingam" ("vw"),
+// for which the tolerance is the side of the smallest triangle kept, i.e.
+// areas below tolerance² m² are dropped. The tolerance is snapped to the
+// nearest of toleranceSteps so the cache under the cache dir, keyed by file
+// contents, holds a bounded number of variants per file; variants of
+// contents no longer in the library are pruned when it changes.
+func (s *Service) GetGeoJSON(relPath string, tolerance float64, algorithm string) ([]byte, error) {
+	return s.getGeoJSON(relPath, tolerance, algorithm, nil)
+}
+
+func (s *Service) getGeoJSON(relPath string, tolerance float64, algorithm string, red *redaction) ([]byte, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerance = snapTolerance(tolerance)
+	var simplify func([]track.Point) []track.Point
+	switch algorithm {
+	case "", "dp", "douglas-peucker":
+		algorithm = "douglas-peucker"
+		simplify = func(p []track.Point) []track.Point { return track.SimplifyDouglasPeucker(p, tolerance) }
+	case "vw", "visvalingam":
+		algorithm = "visvalingam"
+		simplify = func(p []track.Point) []track.Point { return track.SimplifyVisvalingam(p, tolerance*tolerance) }
+	default:
+		return nil, fmt.Errorf("invalid algorithm")
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	cachePath := s.derivedPath("geojson", hex.EncodeToString(sum[:]),
+		red.cacheKey()+algorithm+"-"+strconv.FormatFloat(tolerance, 'f', -1, 64)+".json")
+
+	if cachePath != "" {
+		if cached, err := os.ReadFile(cachePath); err == nil {
+			return cached, nil
+		}
+	}
+
+	doc, err := track.Parse(track.FormatOf(relPath), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(red.apply(doc).GeoJSON(simplify))
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := writeFileAtomic(cachePath, out); err != nil {
+			slog.Warn("Failed to cache GeoJSON", "path", cachePath, "error", err)
+		}
+	}
+	return out, nil
+}
+
+// toleranceSteps are the simplification tolerances, in meters, that GeoJSON is
+// actually produced (and cached) for.
+var toleranceSteps = []float64{0, 0.5, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// snapTolerance returns the step in toleranceSteps closest to tolerance.
+func snapTolerance(tolerance float64) float64 {
+	best := toleranceSteps[0]
+	for _, step := range toleranceSteps[1:] {
+		if math.Abs(step-tolerance) < math.Abs(best-tolerance) {
+			best = step
+		}
+	}
+	return best
+}
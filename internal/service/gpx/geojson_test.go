@@ -0,0 +1,109 @@
+package gpx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func cachedGeoJSON(t *testing.T, cacheDir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(cacheDir, "geojson", "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestGetGeoJSON(t *testing.T) {
+	dataDir, cacheDir := t.TempDir(), t.TempDir()
+	trackPath := filepath.Join(dataDir, "Activities", "Running", "long.gpx")
+	writeTrack(t, trackPath, lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 40), time.Now())
+
+	s := NewService(dataDir, cacheDir)
+	if _, err := s.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.GetGeoJSON("Activities/Running/long.gpx", 5, "")
+	if err != nil {
+		t.Fatalf("GetGeoJSON failed: %v", err)
+	}
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string        `json:"type"`
+				Coordinates [][][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		t.Fatal(err)
+	}
+	// lineTrack is perfectly straight, so only its endpoints remain.
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 1 || len(fc.Features[0].Geometry.Coordinates[0]) != 2 {
+		t.Fatalf("unexpected GeoJSON: %s", data)
+	}
+
+	if _, err := s.GetGeoJSON("Activities/Running/long.gpx", 5, "vw"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedGeoJSON(t, cacheDir); len(got) != 2 {
+		t.Fatalf("expected 2 cached variants, got %v", got)
+	}
+	// Nearby tolerances snap to the same step and share its cache entry.
+	for _, tolerance := range []float64{4.2, 5.3, 6.9} {
+		if _, err := s.GetGeoJSON("Activities/Running/long.gpx", tolerance, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := cachedGeoJSON(t, cacheDir); len(got) != 2 {
+		t.Fatalf("expected snapped tolerances to reuse the cache, got %v", got)
+	}
+
+	// A cached copy is served even if it was written by an earlier run.
+	marker := []byte(`{"cached":true}`)
+	if err := os.WriteFile(cachedGeoJSON(t, cacheDir)[0], marker, 0644); err != nil {
+		t.Fatal(err)
+	}
+	hits := 0
+	for _, algo := range []string{"dp", "vw"} {
+		data, _ := s.GetGeoJSON("Activities/Running/long.gpx", 5, algo)
+		if string(data) == string(marker) {
+			hits++
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected one response from the cache, got %d", hits)
+	}
+
+	// Changing the track drops the stale cache entries.
+	writeTrack(t, trackPath, lineTrack(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), 41), time.Now().Add(time.Minute))
+	if _, err := s.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedGeoJSON(t, cacheDir); len(got) != 0 {
+		t.Errorf("expected stale GeoJSON to be pruned, got %v", got)
+	}
+
+	if _, err := s.GetGeoJSON("Activities/Running/long.gpx", 5, "bezier"); err == nil || err.Error() != "invalid algorithm" {
+		t.Errorf("expected invalid algorithm error, got %v", err)
+	}
+	if _, err := s.GetGeoJSON("Activities/Running/missing.gpx", 5, ""); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestSnapTolerance(t *testing.T) {
+	tests := []struct{ in, want float64 }{
+		{0, 0}, {0.2, 0}, {0.8, 1}, {3.4, 2}, {3.6, 5}, {5, 5}, {12, 10}, {9999, 10000}, {1e9, 10000},
+	}
+	for _, tt := range tests {
+		if got := snapTolerance(tt.in); got != tt.want {
+			t.Errorf("snapTolerance(%g) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package gpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/spatial"
+	"gpx-self-host/internal/track"
+)
+
+// Heatmap tiles are cached next to the downloaded ones, as if "heatmap" were
+// a provider: <cache>/tiles/heatmap/<variant>/<z>/<x>/<y>.png, where the
+// variant names the filter (and privacy zones) a tile was rendered with.
+const (
+	heatmapMaxZoom = 18
+
+	heatmapPen        = 2   // pixels
+	heatmapSaturation = 20  // tracks through a pixel for the hottest colour
+	heatlineTolerance = 1.0 // meters; Douglas-Peucker tolerance of stored lines
+	heatlinePrecision = 1e5 // stored coordinates are rounded to ~1 m
+	heatmapMargin     = 2.0 // pixels; lines just outside a tile still touch it
+)
+
+// heatlines is the cached geometry the heatmap draws for one file: its lines
+// simplified and reduced to [lat, lon] pairs.
+type heatlines [][][2]float64
+
+// HeatmapTile renders tile z/x/y of the density heatmap of all activities
+// (files under Plans/ are left out) as a PNG. values may restrict it with
+// activity and year parameters, each repeatable or comma-separated.
+func (s *Service) HeatmapTile(z, x, y int, values url.Values) ([]byte, error) {
+	return s.heatmapTile(z, x, y, values, nil)
+}
+
+func (s *Service) heatmapTile(z, x, y int, values url.Values, red *redaction) ([]byte, error) {
+	if !render.ValidTile(z, x, y, heatmapMaxZoom) {
+		return nil, fmt.Errorf("invalid tile")
+	}
+	q, variant, err := parseHeatmapFilter(values)
+	if err != nil {
+		return nil, err
+	}
+	variant = red.cacheKey() + variant
+
+	var cachePath string
+	if s.cacheDir != "" {
+		cachePath = filepath.Join(s.heatmapDir(), variant, strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".png")
+		if cached, err := os.ReadFile(cachePath); err == nil {
+			return cached, nil
+		}
+	}
+
+	minLat, minLon, maxLat, maxLon := render.TileBounds(z, x, y, heatmapMargin)
+	area := spatial.Rect{MinX: minLon, MinY: minLat, MaxX: maxLon, MaxY: maxLat}
+
+	s.mu.Lock()
+	if !s.indexed {
+		if _, err := s.refresh(); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	if s.tree == nil {
+		s.buildTree()
+	}
+	generation := s.heatmapGen
+	hashes := make(map[string]string)
+	s.tree.Search(area, func(it spatial.Item) bool {
+		relPath := s.treeRefs[it.ID].relPath
+		if _, ok := hashes[relPath]; ok || deriveActivity(relPath) == "Plans" {
+			return true
+		}
+		if q.matches(s.fileFor(relPath)) {
+			hashes[relPath] = s.entries[relPath].Hash
+		}
+		return true
+	})
+	s.mu.Unlock()
+
+	// Draw in a stable order so a tile renders the same way every time.
+	paths := make([]string, 0, len(hashes))
+	for relPath := range hashes {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+
+	density := render.NewDensity(render.TileSize, render.TileSize)
+	originX, originY := float64(x*render.TileSize), float64(y*render.TileSize)
+	for _, relPath := range paths {
+		lines, err := s.heatlines(relPath, hashes[relPath], red)
+		if err != nil {
+			continue
+		}
+		projected := make([][][2]float64, 0, len(lines))
+		for _, line := range lines {
+			pts := make([][2]float64, len(line))
+			for i, p := range line {
+				px, py := render.WorldPixel(p[0], p[1], z)
+				pts[i] = [2]float64{px - originX, py - originY}
+			}
+			projected = append(projected, pts)
+		}
+		density.AddTrack(projected, heatmapPen)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, density.Image(heatmapSaturation)); err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		// A file that changed while we were drawing has already invalidated
+		// this tile; caching the result now would bring the old state back.
+		s.mu.Lock()
+		if generation == s.heatmapGen {
+			if err := writeFileAtomic(cachePath, buf.Bytes()); err != nil {
+				slog.Warn("Failed to cache heatmap tile", "path", cachePath, "error", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return buf.Bytes(), nil
+}
+
+// parseHeatmapFilter reads the activity and year parameters into a Query
+// and names the combination for the tile cache: "all" without filters,
+// otherwise a hash of the normalised values.
+func parseHeatmapFilter(values url.Values) (Query, string, error) {
+	var q Query
+	split := func(key string) []string {
+		var out []string
+		for _, v := range values[key] {
+			for _, part := range strings.Split(v, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					out = append(out, part)
+				}
+			}
+		}
+		return out
+	}
+	for _, a := range split("activity") {
+		q.Activities = append(q.Activities, strings.ToLower(a))
+	}
+	for _, v := range split("year") {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			return Query{}, "", fmt.Errorf("invalid query: year %q", v)
+		}
+		q.Years = append(q.Years, year)
+	}
+	if len(q.Activities) == 0 && len(q.Years) == 0 {
+		return q, "all", nil
+	}
+	sort.Strings(q.Activities)
+	sort.Ints(q.Years)
+	key, _ := json.Marshal([]any{q.Activities, q.Years})
+	sum := sha256.Sum256(key)
+	return q, hex.EncodeToString(sum[:8]), nil
+}
+
+// heatlines returns the simplified lines of a file, from the derived cache
+// when possible.
+func (s *Service) heatlines(relPath, hash string, red *redaction) (heatlines, error) {
+	cachePath := s.derivedPath("heatlines", hash, red.cacheKey()+"lines.json")
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var lines heatlines
+			if json.Unmarshal(data, &lines) == nil {
+				return lines, nil
+			}
+		}
+	}
+
+	doc, err := parseFile(filepath.Join(s.DataDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil, err
+	}
+	var lines heatlines
+	for _, line := range red.apply(doc).Lines() {
+		simplified := track.SimplifyDouglasPeucker(line, heatlineTolerance)
+		pts := make([][2]float64, len(simplified))
+		for i, p := range simplified {
+			pts[i] = [2]float64{
+				math.Round(p.Lat*heatlinePrecision) / heatlinePrecision,
+				math.Round(p.Lon*heatlinePrecision) / heatlinePrecision,
+			}
+		}
+		lines = append(lines, pts)
+	}
+
+	if cachePath != "" {
+		if data, err := json.Marshal(lines); err == nil {
+			if err := writeFileAtomic(cachePath, data); err != nil {
+				slog.Warn("Failed to cache heatmap lines", "path", cachePath, "error", err)
+			}
+		}
+	}
+	return lines, nil
+}
+
+func (s *Service) heatmapDir() string {
+	return filepath.Join(s.cacheDir, "tiles", "heatmap")
+}
+
+// invalidateHeatmap deletes the cached heatmap tiles, in every variant, that
+// overlap the given chunks, i.e. the old and new geometry of changed files.
+// The tiles are addressed directly from each chunk's tile range per zoom, so
+// only columns that hold cached tiles are touched. Callers must hold s.mu.
+func (s *Service) invalidateHeatmap(changed []chunkBounds) {
+	s.heatmapGen++
+	if s.cacheDir == "" || len(changed) == 0 {
+		return
+	}
+	root := s.heatmapDir()
+	variants, err := os.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to invalidate heatmap tiles", "error", err)
+		}
+		return
+	}
+
+	removed := 0
+	for z := 0; z <= heatmapMaxZoom; z++ {
+		ranges := make([][4]int, len(changed))
+		for i, c := range changed {
+			r := c.rect()
+			x0, y0, x1, y1 := render.TileRange(r.MinY, r.MinX, r.MaxY, r.MaxX, z, heatmapMargin)
+			ranges[i] = [4]int{x0, y0, x1, y1}
+		}
+		for _, variant := range variants {
+			if !variant.IsDir() {
+				continue
+			}
+			// <variant>/<z>/<x>/<y>.png
+			zoomDir := filepath.Join(root, variant.Name(), strconv.Itoa(z))
+			columns, err := os.ReadDir(zoomDir)
+			if err != nil {
+				continue
+			}
+			cached := make(map[int]bool, len(columns))
+			for _, col := range columns {
+				if x, err := strconv.Atoi(col.Name()); err == nil {
+					cached[x] = true
+				}
+			}
+			seen := make(map[[2]int]bool)
+			for _, r := range ranges {
+				for x := r[0]; x <= r[2]; x++ {
+					if !cached[x] {
+						continue
+					}
+					for y := r[1]; y <= r[3]; y++ {
+						if seen[[2]int{x, y}] {
+							continue
+						}
+						seen[[2]int{x, y}] = true
+						if os.Remove(filepath.Join(zoomDir, strconv.Itoa(x), strconv.Itoa(y)+".png")) == nil {
+							removed++
+						}
+					}
+				}
+			}
+		}
+	}
+	if removed > 0 {
+		slog.Info("Heatmap tiles invalidated", "removed", removed)
+	}
+}
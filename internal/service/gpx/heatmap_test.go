@@ -0,0 +1,147 @@
+package gpx
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
+)
+
+func countOpaque(t *testing.T, data []byte) int {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestHeatmapTile(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	write := func(rel, content string) {
+		full := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// All tracks run north from 59°N 24°E.
+	write("Activities/Running/run.gpx", lineTrack(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC), 3))
+	write("Activities/Gravel/ride.gpx", lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 3))
+	write("Plans/plan.gpx", lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 3))
+	service := NewService(dataDir, cacheDir)
+
+	const z = 10
+	px, py := render.WorldPixel(59.005, 24.0, z)
+	x, y := int(px)/render.TileSize, int(py)/render.TileSize
+
+	all, err := service.HeatmapTile(z, x, y, nil)
+	if err != nil {
+		t.Fatalf("HeatmapTile failed: %v", err)
+	}
+	if img, _, err := image.DecodeConfig(bytes.NewReader(all)); err != nil || img.Width != 256 || img.Height != 256 {
+		t.Fatalf("expected a 256px PNG: %+v %v", img, err)
+	}
+	drawn := countOpaque(t, all)
+	if drawn == 0 {
+		t.Fatal("expected the tracks on the tile")
+	}
+	cached := filepath.Join(cacheDir, "tiles", "heatmap", "all", strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".png")
+	if _, err := os.Stat(cached); err != nil {
+		t.Errorf("expected the tile in the cache: %v", err)
+	}
+
+	// Both activities follow the same line; the overlap is hotter, not wider.
+	gravel, err := service.HeatmapTile(z, x, y, url.Values{"activity": {"Gravel"}})
+	if err != nil || countOpaque(t, gravel) != drawn || bytes.Equal(gravel, all) {
+		t.Errorf("expected the same pixels in a cooler colour for one activity: %v", err)
+	}
+	if none, _ := service.HeatmapTile(z, x, y, url.Values{"year": {"2023,2022"}}); countOpaque(t, none) != 0 {
+		t.Error("expected an empty tile for years without tracks")
+	}
+	if empty, _ := service.HeatmapTile(z, x+5, y, nil); countOpaque(t, empty) != 0 {
+		t.Error("expected an empty tile away from the tracks")
+	}
+	far := filepath.Join(cacheDir, "tiles", "heatmap", "all", strconv.Itoa(z), strconv.Itoa(x+5), strconv.Itoa(y)+".png")
+
+	for _, bad := range []struct {
+		z, x, y int
+		values  url.Values
+	}{
+		{19, 0, 0, nil},
+		{2, 4, 0, nil},
+		{2, 0, -1, nil},
+		{2, 0, 0, url.Values{"year": {"recent"}}},
+	} {
+		if _, err := service.HeatmapTile(bad.z, bad.x, bad.y, bad.values); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+
+	// Removing a track drops the tiles it touched, in every variant, and
+	// leaves the others alone.
+	if err := os.Remove(filepath.Join(dataDir, "Activities", "Gravel", "ride.gpx")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cached); !os.IsNotExist(err) {
+		t.Errorf("expected the cached tile to be invalidated, got %v", err)
+	}
+	if _, err := os.Stat(far); err != nil {
+		t.Errorf("unrelated tiles should stay cached: %v", err)
+	}
+	gravel, _ = service.HeatmapTile(z, x, y, url.Values{"activity": {"gravel"}})
+	if countOpaque(t, gravel) != 0 {
+		t.Error("the removed track should be gone from the filtered tile")
+	}
+}
+
+func TestRedactedHeatmapTile(t *testing.T) {
+	dataDir := t.TempDir()
+	full := filepath.Join(dataDir, "Activities", "run.gpx")
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 6)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(dataDir, t.TempDir())
+	redacted := service.Redacted([]track.PrivacyZone{{Lat: 59, Lon: 24, Radius: 1500}}, false)
+
+	const z = 12
+	px, py := render.WorldPixel(59.0, 24.0, z)
+	x, y := int(px)/render.TileSize, int(py)/render.TileSize
+	plain, err := service.HeatmapTile(z, x, y, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := redacted.HeatmapTile(z, x, y, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countOpaque(t, hidden) >= countOpaque(t, plain) {
+		t.Error("the redacted heatmap should not show the track inside the zone")
+	}
+}
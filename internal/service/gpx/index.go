@@ -0,0 +1,333 @@
+package gpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// indexVersion must be bumped whenever the derived data stored per entry
+// changes, so that indexes written by older binaries are rebuilt.
+const indexVersion = 5
+
+const indexFileName = "library-index.json"
+
+type indexEntry struct {
+	Size    int64             `json:"size"`
+	ModTime int64             `json:"modTime"` // UnixNano
+	Hash    string            `json:"hash"`    // sha256 of the file contents
+	Stats   *model.TrackStats `json:"stats,omitempty"`
+	Chunks  []chunkBounds     `json:"chunks,omitempty"`  // see spatial.go
+	Efforts *track.Efforts    `json:"efforts,omitempty"` // see records.go
+	Splits  []track.TimeSplit `json:"splits,omitempty"`  // see calendar.go
+	Error   string            `json:"error,omitempty"`
+}
+
+type indexFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*indexEntry `json:"entries"`
+}
+
+type scannedFile struct {
+	relPath  string
+	fullPath string
+	info     fs.FileInfo
+}
+
+// refresh walks the scan roots and brings the index up to date, reparsing
+// only files whose size or mtime changed (and files that could not be read
+// before), and publishes the resulting changes to subscribers. It returns
+// the scanned files in walk order. Callers must hold s.mu; it is released
+// while files are parsed, so state read before the call may be outdated
+// after it.
+func (s *Service) refresh() ([]scannedFile, error) {
+	if !s.refreshMu.TryLock() {
+		// Another refresh is parsing; wait for it so refreshes apply in order.
+		s.mu.Unlock()
+		s.refreshMu.Lock()
+		s.mu.Lock()
+	}
+	defer s.refreshMu.Unlock()
+
+	if s.entries == nil {
+		s.loadIndex()
+	}
+
+	var scanned []scannedFile
+	for _, root := range scanRoots {
+		rootPath := filepath.Join(s.DataDir, root)
+		info, err := os.Stat(rootPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.IsDir() {
+			continue
+		}
+
+		err = filepath.WalkDir(rootPath, func(fullPath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || track.FormatOf(d.Name()) == "" {
+				return nil
+			}
+			relPath, err := filepath.Rel(s.DataDir, fullPath)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			scanned = append(scanned, scannedFile{
+				relPath:  filepath.ToSlash(relPath),
+				fullPath: fullPath,
+				info:     info,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var stale []scannedFile
+	seen := make(map[string]bool, len(scanned))
+	for _, f := range scanned {
+		seen[f.relPath] = true
+		entry, ok := s.entries[f.relPath]
+		if !ok || entry.Hash == "" || entry.Size != f.info.Size() || entry.ModTime != f.info.ModTime().UnixNano() {
+			stale = append(stale, f)
+		}
+	}
+
+	var built []*indexEntry
+	start := time.Now()
+	if len(stale) > 0 {
+		// Parsing a large library takes a while; other requests keep using
+		// the current index meanwhile.
+		s.mu.Unlock()
+		built = buildEntries(stale)
+		s.mu.Lock()
+	}
+
+	var events []model.LibraryEvent
+	var changed []chunkBounds // old and new geometry, for the heatmap
+	staleHashes := make(map[string]bool)
+	for relPath, entry := range s.entries {
+		if !seen[relPath] {
+			staleHashes[entry.Hash] = true
+			changed = append(changed, entry.Chunks...)
+			delete(s.entries, relPath)
+			events = append(events, model.LibraryEvent{Type: model.LibraryFileRemoved, RelativePath: relPath})
+		}
+	}
+
+	if len(stale) > 0 {
+		reparsed := 0
+		for i, entry := range built {
+			relPath := stale[i].relPath
+			old, ok := s.entries[relPath]
+			if ok && old.Hash == "" && entry.Hash == "" && old.Size == entry.Size && old.ModTime == entry.ModTime {
+				// Still unreadable; nothing to announce.
+				s.entries[relPath] = entry
+				continue
+			}
+			eventType := model.LibraryFileChanged
+			if !ok {
+				eventType = model.LibraryFileAdded
+			} else {
+				if old.Hash != entry.Hash {
+					staleHashes[old.Hash] = true
+				}
+				changed = append(changed, old.Chunks...)
+			}
+			changed = append(changed, entry.Chunks...)
+			s.entries[relPath] = entry
+			events = append(events, model.LibraryEvent{Type: eventType, RelativePath: relPath})
+			reparsed++
+		}
+		if reparsed > 0 {
+			slog.Info("Library index updated", "reparsed", reparsed, "total", len(scanned), "duration_ms", time.Since(start).Milliseconds())
+		}
+	}
+
+	if len(events) > 0 {
+		s.tree = nil
+		events = s.updateDuplicates(events)
+		for i := range events {
+			if events[i].Type != model.LibraryFileRemoved {
+				file := s.fileFor(events[i].RelativePath)
+				events[i].File = &file
+			}
+		}
+		s.pruneDerived(staleHashes)
+		s.invalidateHeatmap(changed)
+		s.saveIndex()
+		// The first refresh after startup reports the whole library as new;
+		// clients fetch the full listing on connect, so only later diffs matter.
+		if s.indexed {
+			s.publish(events)
+		}
+	}
+	s.indexed = true
+	return scanned, nil
+}
+
+// buildEntries reads and parses files concurrently; a large library is only
+// fully parsed once, when the index is first created.
+func buildEntries(files []scannedFile) []*indexEntry {
+	entries := make([]*indexEntry, len(files))
+
+	workers := runtime.NumCPU()
+	if workers > len(files) {
+		workers = len(files)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				entries[i] = buildEntry(files[i])
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return entries
+}
+
+func buildEntry(f scannedFile) *indexEntry {
+	entry := &indexEntry{
+		Size:    f.info.Size(),
+		ModTime: f.info.ModTime().UnixNano(),
+	}
+
+	data, err := os.ReadFile(f.fullPath)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	sum := sha256.Sum256(data)
+	entry.Hash = hex.EncodeToString(sum[:])
+
+	doc, err := track.Parse(track.FormatOf(f.relPath), bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("Skipping stats for unreadable track", "path", f.relPath, "error", err)
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Stats = statsDTO(doc.Stats())
+	entry.Chunks = chunksFor(doc)
+	efforts := doc.BestEfforts()
+	entry.Efforts = &efforts
+	entry.Splits = doc.TimeSplits()
+	return entry
+}
+
+func (s *Service) loadIndex() {
+	s.entries = make(map[string]*indexEntry)
+	if s.indexPath == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.indexPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read library index; rebuilding", "path", s.indexPath, "error", err)
+		}
+		return
+	}
+
+	var idx indexFile
+	if err := json.Unmarshal(data, &idx); err != nil {
+		slog.Warn("Corrupt library index; rebuilding", "path", s.indexPath, "error", err)
+		return
+	}
+	if idx.Version != indexVersion {
+		slog.Info("Library index format changed; rebuilding", "found", idx.Version, "want", indexVersion)
+		return
+	}
+	for relPath, entry := range idx.Entries {
+		if entry != nil {
+			s.entries[relPath] = entry
+		}
+	}
+}
+
+// saveIndex writes the index atomically so a crash mid-write never leaves a
+// truncated file behind. Failures are logged; the in-memory index stays valid.
+// Files that could not be read or parsed are left out, so they are retried
+// after a restart.
+func (s *Service) saveIndex() {
+	if s.indexPath == "" {
+		return
+	}
+
+	entries := make(map[string]*indexEntry, len(s.entries))
+	for relPath, entry := range s.entries {
+		if entry.Error == "" {
+			entries[relPath] = entry
+		}
+	}
+	data, err := json.Marshal(indexFile{Version: indexVersion, Entries: entries})
+	if err != nil {
+		slog.Error("Failed to encode library index", "error", err)
+		return
+	}
+	if err := writeFileAtomic(s.indexPath, data); err != nil {
+		slog.Error("Failed to write library index", "path", s.indexPath, "error", err)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	// CreateTemp uses 0600; give the result the permissions os.WriteFile would.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
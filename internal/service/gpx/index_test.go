@@ -0,0 +1,201 @@
+package gpx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const indexTestTrack = `<gpx version="1.1"><trk><trkseg>
+	<trkpt lat="59.0" lon="24.0"><time>2025-06-01T08:00:00Z</time></trkpt>
+	<trkpt lat="59.01" lon="24.0"><time>2025-06-01T08:00:10Z</time></trkpt>
+</trkseg></trk></gpx>`
+
+// sameSizeTrack has the same byte length as indexTestTrack but different
+// coordinates, so a stale index entry is detectable.
+const sameSizeTrack = `<gpx version="1.1"><trk><trkseg>
+	<trkpt lat="59.0" lon="24.0"><time>2025-06-01T08:00:00Z</time></trkpt>
+	<trkpt lat="59.02" lon="24.0"><time>2025-06-01T08:00:10Z</time></trkpt>
+</trkseg></trk></gpx>`
+
+func writeTrack(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func distanceOf(t *testing.T, s *Service, relPath string) float64 {
+	t.Helper()
+	files, err := s.ListFiles()
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	for _, f := range files {
+		if f.RelativePath == relPath {
+			if f.Stats == nil {
+				t.Fatalf("no stats for %s", relPath)
+			}
+			return f.Stats.Distance
+		}
+	}
+	t.Fatalf("%s not listed", relPath)
+	return 0
+}
+
+func TestIndex_PersistsAcrossRestarts(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	trackPath := filepath.Join(dataDir, "Activities", "a.gpx")
+	mtime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	writeTrack(t, trackPath, indexTestTrack, mtime)
+
+	original := distanceOf(t, NewService(dataDir, cacheDir), "Activities/a.gpx")
+
+	if _, err := os.Stat(filepath.Join(cacheDir, indexFileName)); err != nil {
+		t.Fatalf("expected index file to be written: %v", err)
+	}
+
+	// Same size and mtime: a new service must trust the persisted entry.
+	writeTrack(t, trackPath, sameSizeTrack, mtime)
+	if got := distanceOf(t, NewService(dataDir, cacheDir), "Activities/a.gpx"); got != original {
+		t.Errorf("expected cached distance %v, got %v", original, got)
+	}
+
+	// A changed mtime invalidates the entry.
+	writeTrack(t, trackPath, sameSizeTrack, mtime.Add(time.Minute))
+	if got := distanceOf(t, NewService(dataDir, cacheDir), "Activities/a.gpx"); got <= original {
+		t.Errorf("expected reparsed distance larger than %v, got %v", original, got)
+	}
+}
+
+func TestIndex_DropsRemovedFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	mtime := time.Now()
+	writeTrack(t, filepath.Join(dataDir, "Activities", "a.gpx"), indexTestTrack, mtime)
+	writeTrack(t, filepath.Join(dataDir, "Plans", "b.gpx"), indexTestTrack, mtime)
+
+	s := NewService(dataDir, cacheDir)
+	if files, _ := s.ListFiles(); len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+
+	if err := os.Remove(filepath.Join(dataDir, "Plans", "b.gpx")); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := s.ListFiles(); len(files) != 1 {
+		t.Fatalf("expected 1 file after removal, got %d", len(files))
+	}
+
+	data, err := os.ReadFile(filepath.Join(cacheDir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx indexFile
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 1 || idx.Entries["Activities/a.gpx"] == nil {
+		t.Errorf("unexpected persisted entries: %+v", idx.Entries)
+	}
+	if idx.Entries["Activities/a.gpx"].Hash == "" {
+		t.Error("expected content hash to be recorded")
+	}
+}
+
+func TestIndex_RebuildsCorruptOrOutdatedIndex(t *testing.T) {
+	for name, content := range map[string]string{
+		"corrupt":  "{not json",
+		"outdated": `{"version": 0, "entries": {"Activities/a.gpx": {"size": 1, "modTime": 1}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			cacheDir := t.TempDir()
+			writeTrack(t, filepath.Join(dataDir, "Activities", "a.gpx"), indexTestTrack, time.Now())
+			if err := os.WriteFile(filepath.Join(cacheDir, indexFileName), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := distanceOf(t, NewService(dataDir, cacheDir), "Activities/a.gpx"); got == 0 {
+				t.Error("expected stats to be rebuilt")
+			}
+		})
+	}
+}
+
+func TestIndex_RetriesFailures(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	mtime := time.Now()
+	writeTrack(t, filepath.Join(dataDir, "Activities", "a.gpx"), indexTestTrack, mtime)
+	writeTrack(t, filepath.Join(dataDir, "Activities", "broken.gpx"), "<gpx>", mtime)
+
+	s := NewService(dataDir, cacheDir)
+	if files, err := s.ListFiles(); err != nil || len(files) != 2 {
+		t.Fatalf("expected both files listed, got %d (%v)", len(files), err)
+	}
+
+	// Parse failures stay out of the persisted index.
+	data, err := os.ReadFile(filepath.Join(cacheDir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx indexFile
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 1 || idx.Entries["Activities/broken.gpx"] != nil {
+		t.Errorf("unexpected persisted entries: %+v", idx.Entries)
+	}
+
+	// A file that could not be read is read again on the next refresh, even
+	// though its size and mtime did not change.
+	s.mu.Lock()
+	entry := s.entries["Activities/a.gpx"]
+	s.entries["Activities/a.gpx"] = &indexEntry{Size: entry.Size, ModTime: entry.ModTime, Error: "permission denied"}
+	s.mu.Unlock()
+	if got := distanceOf(t, s, "Activities/a.gpx"); got == 0 {
+		t.Error("expected the unreadable file to be retried")
+	}
+}
+
+func TestIndex_ConcurrentRefreshes(t *testing.T) {
+	dataDir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeTrack(t, filepath.Join(dataDir, "Activities", name+".gpx"), indexTestTrack, time.Now())
+	}
+	s := NewService(dataDir, t.TempDir())
+
+	// Refreshes release the lock while parsing; concurrent ones must still
+	// all see the complete library.
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			files, _ := s.ListFiles()
+			for _, f := range files {
+				if f.Stats != nil {
+					counts[i]++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, n := range counts {
+		if n != 4 {
+			t.Errorf("listing %d saw %d indexed files, want 4", i, n)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package gpx
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gpx-self-host/internal/model"
+)
+
+// Move renames a library file and/or moves it to another folder under the
+// scan roots. The extension cannot change, since it selects the parser, and
+// an existing file is never replaced.
+func (s *Service) Move(relPath string, req model.MoveRequest) (model.GPXFile, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+
+	dir, name := path.Split(relPath)
+	if folder := strings.Trim(strings.ReplaceAll(req.Folder, "\\", "/"), "/"); folder != "" {
+		if err := validFolder(folder); err != nil {
+			return model.GPXFile{}, err
+		}
+		dir = folder + "/"
+	}
+	if newName := strings.TrimSpace(req.Name); newName != "" {
+		if path.Ext(newName) == "" {
+			newName += path.Ext(name)
+		}
+		if !validSegment(newName) {
+			return model.GPXFile{}, fmt.Errorf("invalid destination: bad file name %q", newName)
+		}
+		if !strings.EqualFold(path.Ext(newName), path.Ext(name)) {
+			return model.GPXFile{}, fmt.Errorf("invalid destination: cannot change the file type")
+		}
+		name = newName
+	}
+	target := dir + name
+	if target == relPath {
+		return model.GPXFile{}, fmt.Errorf("invalid destination: file is already there")
+	}
+	targetPath, _, err := s.resolvePath(target)
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkConfined(fullPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := statFile(fullPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := s.checkConfined(targetPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	// Only a case change on a case-insensitive filesystem may find the
+	// source itself here.
+	if _, err := os.Lstat(targetPath); err == nil && !strings.EqualFold(target, relPath) {
+		return model.GPXFile{}, fmt.Errorf("already exists")
+	}
+	if err := os.Rename(fullPath, targetPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	s.removeEmptyDirs(filepath.Dir(fullPath))
+
+	if _, err := s.refresh(); err != nil {
+		return model.GPXFile{}, err
+	}
+	return s.fileFor(target), nil
+}
+
+// validFolder checks a destination folder relative to the data dir: one of
+// the scan roots, optionally followed by visible subfolder names.
+func validFolder(folder string) error {
+	parts := strings.Split(folder, "/")
+	inRoot := false
+	for _, root := range scanRoots {
+		if parts[0] == root {
+			inRoot = true
+			break
+		}
+	}
+	if !inRoot {
+		return fmt.Errorf("invalid destination: folder must be under %s", strings.Join(scanRoots, " or "))
+	}
+	for _, part := range parts[1:] {
+		if !validSegment(part) {
+			return fmt.Errorf("invalid destination: bad folder name %q", part)
+		}
+	}
+	return nil
+}
+
+// statFile reports "not found" unless fullPath is an existing regular file.
+func statFile(fullPath string) error {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("not found")
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not found")
+	}
+	return nil
+}
+
+// checkConfined makes sure the directory holding fullPath resolves, after
+// following symlinks, to a location inside the data dir, so a linked folder
+// cannot be used to move files elsewhere on the host.
+func (s *Service) checkConfined(fullPath string) error {
+	root, err := filepath.EvalSymlinks(s.DataDir)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fullPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("not found")
+		}
+		return err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid path")
+	}
+	return nil
+}
+
+// removeEmptyDirs deletes dir and its parents while they are empty, stopping
+// at the scan roots so Activities/ and Plans/ themselves are kept.
+func (s *Service) removeEmptyDirs(dir string) {
+	for {
+		rel, err := filepath.Rel(s.DataDir, dir)
+		if err != nil || !strings.Contains(filepath.ToSlash(rel), "/") {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,203 @@
+package gpx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+func newManageLibrary(t *testing.T) (string, *Service) {
+	t.Helper()
+	dataDir := t.TempDir()
+	content := lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 3)
+	for _, rel := range []string{"Activities/Gravel/ride.gpx", "Activities/Gravel/other.gpx", "Plans/route.gpx"} {
+		full := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewService(dataDir, "")
+	if _, err := service.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir, service
+}
+
+func listedPaths(t *testing.T, service *Service) map[string]bool {
+	t.Helper()
+	files, err := service.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]bool)
+	for _, f := range files {
+		paths[f.RelativePath] = true
+	}
+	return paths
+}
+
+func TestMove(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+
+	file, err := service.Move("Activities/Gravel/ride.gpx", model.MoveRequest{Name: "Morning ride"})
+	if err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if file.RelativePath != "Activities/Gravel/Morning ride.gpx" || file.Stats == nil {
+		t.Errorf("unexpected renamed entry: %+v", file)
+	}
+
+	file, err = service.Move("Plans/route.gpx", model.MoveRequest{Folder: "Activities/Hiking"})
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if file.RelativePath != "Activities/Hiking/route.gpx" || file.Activity != "Hiking" {
+		t.Errorf("unexpected moved entry: %+v", file)
+	}
+
+	// Moving the last file out of a folder removes the folder, but never a root.
+	if _, err := service.Move("Activities/Hiking/route.gpx", model.MoveRequest{Folder: "Plans", Name: "loop.gpx"}); err != nil {
+		t.Fatalf("move back failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "Activities", "Hiking")); !os.IsNotExist(err) {
+		t.Errorf("expected the empty activity folder to be removed, got %v", err)
+	}
+
+	paths := listedPaths(t, service)
+	for _, want := range []string{"Activities/Gravel/Morning ride.gpx", "Activities/Gravel/other.gpx", "Plans/loop.gpx"} {
+		if !paths[want] {
+			t.Errorf("expected %s in the listing, got %v", want, paths)
+		}
+	}
+}
+
+func TestMove_Rejects(t *testing.T) {
+	_, service := newManageLibrary(t)
+
+	tests := []struct {
+		name string
+		path string
+		req  model.MoveRequest
+		want string
+	}{
+		{"traversal source", "Activities/../../etc/passwd.gpx", model.MoveRequest{Name: "x.gpx"}, "invalid path"},
+		{"missing source", "Activities/Gravel/missing.gpx", model.MoveRequest{Name: "x.gpx"}, "not found"},
+		{"traversal folder", "Activities/Gravel/ride.gpx", model.MoveRequest{Folder: "Activities/../../tmp"}, "invalid destination"},
+		{"outside roots", "Activities/Gravel/ride.gpx", model.MoveRequest{Folder: "cache"}, "invalid destination"},
+		{"hidden folder", "Activities/Gravel/ride.gpx", model.MoveRequest{Folder: ".trash"}, "invalid destination"},
+		{"name with slash", "Activities/Gravel/ride.gpx", model.MoveRequest{Name: "../ride.gpx"}, "invalid destination"},
+		{"type change", "Activities/Gravel/ride.gpx", model.MoveRequest{Name: "ride.fit"}, "invalid destination"},
+		{"existing target", "Activities/Gravel/ride.gpx", model.MoveRequest{Name: "other.gpx"}, "already exists"},
+		{"same place", "Activities/Gravel/ride.gpx", model.MoveRequest{Folder: "Activities/Gravel"}, "invalid destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Move(tt.path, tt.req); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMove_RejectsSymlinkedFolder(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dataDir, "Activities", "Escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	if _, err := service.Move("Activities/Gravel/ride.gpx", model.MoveRequest{Folder: "Activities/Escape"}); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("file escaped the data dir: %v", entries)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+
+	item, err := service.Delete("Activities/Gravel/ride.gpx")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if item.RelativePath != "Activities/Gravel/ride.gpx" || item.Name != "ride.gpx" || item.Size == 0 || time.Since(item.DeletedAt) > time.Minute {
+		t.Errorf("unexpected trash item: %+v", item)
+	}
+	if listedPaths(t, service)["Activities/Gravel/ride.gpx"] {
+		t.Error("deleted file is still listed")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ".trash", item.ID, "Activities", "Gravel", "ride.gpx")); err != nil {
+		t.Errorf("expected file in trash: %v", err)
+	}
+
+	second, err := service.Delete("Plans/route.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := service.ListTrash()
+	if err != nil || len(items) != 2 || items[0].ID != second.ID {
+		t.Fatalf("expected two items, newest first: %+v %v", items, err)
+	}
+
+	// The original path is taken again before the restore.
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "Gravel", "ride.gpx"), []byte("<gpx/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := service.RestoreTrash(item.ID)
+	if err != nil {
+		t.Fatalf("RestoreTrash failed: %v", err)
+	}
+	if file.RelativePath != "Activities/Gravel/ride (2).gpx" || file.Stats == nil || file.Stats.PointCount != 3 {
+		t.Errorf("unexpected restored entry: %+v", file)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ".trash", item.ID)); !os.IsNotExist(err) {
+		t.Errorf("restored item should leave the trash, got %v", err)
+	}
+
+	if err := service.PurgeTrash(second.ID); err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+	if items, _ := service.ListTrash(); len(items) != 0 {
+		t.Errorf("expected empty trash, got %+v", items)
+	}
+
+	if _, err := service.Delete("Activities/Gravel/other.gpx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.PurgeTrash(""); err != nil {
+		t.Fatalf("purging everything failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ".trash")); !os.IsNotExist(err) {
+		t.Errorf("expected the trash dir to be gone, got %v", err)
+	}
+}
+
+func TestTrash_Rejects(t *testing.T) {
+	_, service := newManageLibrary(t)
+
+	if _, err := service.Delete("../outside.gpx"); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path, got %v", err)
+	}
+	if _, err := service.Delete("Activities/Gravel/missing.gpx"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+	for _, id := range []string{"", "..", "../Activities", "ABC", "zzzzzzzzzzzzzzzz"} {
+		if _, err := service.RestoreTrash(id); err == nil || err.Error() != "invalid path" {
+			t.Errorf("RestoreTrash(%q): expected invalid path, got %v", id, err)
+		}
+	}
+	if err := service.PurgeTrash("abc"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if items, err := service.ListTrash(); err != nil || len(items) != 0 {
+		t.Errorf("expected an empty trash without a trash dir, got %+v %v", items, err)
+	}
+}
@@ -0,0 +1,159 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// maxMergeSources bounds how many files one merge may read.
+const maxMergeSources = 100
+
+// Merge joins the tracks of several library files into one new GPX file. See
+// model.MergeRequest for ordering and naming; the sources are left untouched.
+func (s *Service) Merge(req model.MergeRequest) (model.GPXFile, error) {
+	if len(req.Paths) < 2 {
+		return model.GPXFile{}, fmt.Errorf("invalid merge: need at least two files")
+	}
+	if len(req.Paths) > maxMergeSources {
+		return model.GPXFile{}, fmt.Errorf("invalid merge: at most %d files", maxMergeSources)
+	}
+	fullPaths := make([]string, len(req.Paths))
+	seen := make(map[string]bool, len(req.Paths))
+	for i, p := range req.Paths {
+		fullPath, relPath, err := s.resolvePath(p)
+		if err != nil {
+			return model.GPXFile{}, err
+		}
+		if seen[relPath] {
+			return model.GPXFile{}, fmt.Errorf("invalid merge: %s is listed twice", relPath)
+		}
+		seen[relPath] = true
+		fullPaths[i] = fullPath
+		req.Paths[i] = relPath
+	}
+
+	dir := path.Dir(req.Paths[0])
+	if folder := strings.Trim(strings.ReplaceAll(req.Folder, "\\", "/"), "/"); folder != "" {
+		if err := validFolder(folder); err != nil {
+			return model.GPXFile{}, err
+		}
+		dir = folder
+	}
+	name, err := derivedName(req.Paths[0], req.Name, "-merged")
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs := make([]*track.Document, len(fullPaths))
+	for i, fullPath := range fullPaths {
+		if err := s.checkConfined(fullPath); err != nil {
+			return model.GPXFile{}, err
+		}
+		if docs[i], err = parseFile(fullPath); err != nil {
+			return model.GPXFile{}, err
+		}
+	}
+	merged := track.Merge(docs, req.SegmentPerSource)
+	if merged.TrackPointCount() == 0 {
+		return model.GPXFile{}, fmt.Errorf("invalid merge: files have no track points")
+	}
+
+	var buf bytes.Buffer
+	if err := track.WriteGPX(&buf, merged); err != nil {
+		return model.GPXFile{}, err
+	}
+	target, err := s.freePath(path.Join(dir, name))
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+	targetPath := filepath.Join(s.DataDir, filepath.FromSlash(target))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := s.checkConfined(targetPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := writeFileAtomic(targetPath, buf.Bytes()); err != nil {
+		return model.GPXFile{}, err
+	}
+
+	if _, err := s.refresh(); err != nil {
+		return model.GPXFile{}, err
+	}
+	return s.fileFor(target), nil
+}
+
+// Split cuts a track into several new GPX files next to the original, at the
+// requested times and/or long pauses. The original is kept.
+func (s *Service) Split(relPath string, req model.SplitRequest) ([]model.GPXFile, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	var pause time.Duration
+	if req.Pause != "" {
+		if pause, err = time.ParseDuration(req.Pause); err != nil || pause <= 0 {
+			return nil, fmt.Errorf("invalid split: bad pause %q", req.Pause)
+		}
+	}
+	if len(req.At) == 0 && pause == 0 {
+		return nil, fmt.Errorf("invalid split: missing split times or pause")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkConfined(fullPath); err != nil {
+		return nil, err
+	}
+	doc, err := parseFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	parts := doc.Split(req.At, pause)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid split: track would not be split")
+	}
+
+	targets := make([]string, 0, len(parts))
+	for i, part := range parts {
+		var buf bytes.Buffer
+		if err := track.WriteGPX(&buf, part); err != nil {
+			return nil, err
+		}
+		name, _ := derivedName(relPath, "", "-"+strconv.Itoa(i+1))
+		target, err := s.freePath(path.Join(path.Dir(relPath), name))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(filepath.Join(s.DataDir, filepath.FromSlash(target)), buf.Bytes()); err != nil {
+			// Leave no partial split behind.
+			for _, t := range targets {
+				os.Remove(filepath.Join(s.DataDir, filepath.FromSlash(t)))
+			}
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+	files := make([]model.GPXFile, len(targets))
+	for i, t := range targets {
+		files[i] = s.fileFor(t)
+	}
+	return files, nil
+}
@@ -0,0 +1,116 @@
+package gpx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+func TestMerge(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	later := lineTrack(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), 4)
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "Gravel", "later.gpx"), []byte(later), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := service.Merge(model.MergeRequest{Paths: []string{"Activities/Gravel/later.gpx", "Activities/Gravel/ride.gpx"}})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if file.RelativePath != "Activities/Gravel/later-merged.gpx" || file.Stats == nil || file.Stats.PointCount != 7 {
+		t.Errorf("unexpected merged file: %+v", file)
+	}
+	if file.Stats.StartTime == nil || !file.Stats.StartTime.Equal(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the earlier track first, got %+v", file.Stats.StartTime)
+	}
+	if files := listedPaths(t, service); !files[file.RelativePath] || !files["Activities/Gravel/ride.gpx"] {
+		t.Errorf("expected sources and merged file in the listing, got %v", files)
+	}
+
+	file, err = service.Merge(model.MergeRequest{
+		Paths:            []string{"Activities/Gravel/ride.gpx", "Plans/route.gpx"},
+		Name:             "Both",
+		Folder:           "Activities/Merged",
+		SegmentPerSource: true,
+	})
+	if err != nil || file.RelativePath != "Activities/Merged/Both.gpx" {
+		t.Errorf("unexpected merge into folder: %+v %v", file, err)
+	}
+
+	tests := []struct {
+		name string
+		req  model.MergeRequest
+		want string
+	}{
+		{"one file", model.MergeRequest{Paths: []string{"Activities/Gravel/ride.gpx"}}, "invalid merge"},
+		{"same file twice", model.MergeRequest{Paths: []string{"Activities/Gravel/ride.gpx", "Activities/Gravel//ride.gpx"}}, "invalid merge"},
+		{"missing file", model.MergeRequest{Paths: []string{"Activities/Gravel/ride.gpx", "Activities/missing.gpx"}}, "not found"},
+		{"outside roots", model.MergeRequest{Paths: []string{"Activities/Gravel/ride.gpx", "../x.gpx"}}, "invalid path"},
+		{"bad folder", model.MergeRequest{Paths: []string{"Activities/Gravel/ride.gpx", "Plans/route.gpx"}, Folder: "tmp"}, "invalid destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Merge(tt.req); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	gap := `<gpx version="1.1"><trk><trkseg>
+<trkpt lat="59.00" lon="24"><time>2025-06-01T08:00:00Z</time></trkpt>
+<trkpt lat="59.01" lon="24"><time>2025-06-01T08:01:00Z</time></trkpt>
+<trkpt lat="59.02" lon="24"><time>2025-06-01T10:00:00Z</time></trkpt>
+<trkpt lat="59.03" lon="24"><time>2025-06-01T10:01:00Z</time></trkpt>
+</trkseg></trk></gpx>`
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "day.gpx"), []byte(gap), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := service.Split("Activities/day.gpx", model.SplitRequest{Pause: "1h"})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(files) != 2 || files[0].RelativePath != "Activities/day-1.gpx" || files[1].RelativePath != "Activities/day-2.gpx" {
+		t.Fatalf("unexpected parts: %+v", files)
+	}
+	if files[1].Stats == nil || files[1].Stats.PointCount != 2 {
+		t.Errorf("expected the second part indexed with 2 points, got %+v", files[1].Stats)
+	}
+	if listed := listedPaths(t, service); !listed["Activities/day.gpx"] || !listed["Activities/day-2.gpx"] {
+		t.Errorf("expected original and parts in the listing, got %v", listed)
+	}
+
+	at := []time.Time{time.Date(2025, 6, 1, 8, 0, 10, 0, time.UTC)}
+	files, err = service.Split("Activities/Gravel/ride.gpx", model.SplitRequest{At: at})
+	if err != nil || len(files) != 2 || files[0].Stats.PointCount != 1 {
+		t.Errorf("unexpected split at time: %+v %v", files, err)
+	}
+
+	tests := []struct {
+		name string
+		req  model.SplitRequest
+		want string
+	}{
+		{"nothing given", model.SplitRequest{}, "invalid split"},
+		{"bad pause", model.SplitRequest{Pause: "soon"}, "invalid split"},
+		{"negative pause", model.SplitRequest{Pause: "-5m"}, "invalid split"},
+		{"no cut", model.SplitRequest{Pause: "3h"}, "invalid split"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Split("Activities/day.gpx", tt.req); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+	if _, err := service.Split("Activities/missing.gpx", model.SplitRequest{Pause: "1h"}); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package gpx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/url"
+	"sync"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
+)
+
+// redaction removes the points inside privacy zones from parsed documents. A
+// nil *redaction leaves documents as they are.
+type redaction struct {
+	zones    []track.PrivacyZone
+	truncate bool
+	key      string // identifies zones and mode in derived cache names
+}
+
+func (r *redaction) apply(doc *track.Document) *track.Document {
+	if r == nil {
+		return doc
+	}
+	return doc.Redact(r.zones, r.truncate)
+}
+
+// line removes the hidden points of a bare polyline, such as a segment, the
+// way routes are redacted.
+func (r *redaction) line(points []track.Point) []track.Point {
+	if r == nil {
+		return points
+	}
+	doc := (&track.Document{Routes: []track.Route{{Points: points}}}).Redact(r.zones, r.truncate)
+	out := []track.Point{}
+	for _, route := range doc.Routes {
+		out = append(out, route.Points...)
+	}
+	return out
+}
+
+// listing withholds the bounds of a listing entry when they reach into a
+// zone, since a corner of the box can sit on a hidden point. The entry's
+// stats are copied, never changed in place.
+func (r *redaction) listing(f model.GPXFile) model.GPXFile {
+	if r == nil || f.Stats == nil || f.Stats.Bounds == nil || !r.touches(*f.Stats.Bounds) {
+		return f
+	}
+	stats := *f.Stats
+	stats.Bounds = nil
+	f.Stats = &stats
+	return f
+}
+
+// touches reports whether b overlaps the bounding box of any zone.
+func (r *redaction) touches(b model.BoundsDTO) bool {
+	for _, z := range r.zones {
+		zone := model.BoundsDTO{West: 180, South: 90, East: -180, North: -90}
+		if len(z.Polygon) == 0 {
+			zone = circleBounds(z.Lat, z.Lon, z.Radius)
+		}
+		for _, v := range z.Polygon {
+			zone.South, zone.North = math.Min(zone.South, v[0]), math.Max(zone.North, v[0])
+			zone.West, zone.East = math.Min(zone.West, v[1]), math.Max(zone.East, v[1])
+		}
+		for _, box := range boundsBoxes(zone) {
+			if b.South <= box.MaxLat && b.North >= box.MinLat && b.West <= box.MaxLon && b.East >= box.MinLon {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheKey prefixes derived cache names so redacted output is kept apart
+// from the full output and from output made with other zones.
+func (r *redaction) cacheKey() string {
+	if r == nil {
+		return ""
+	}
+	return "redacted-" + r.key + "-"
+}
+
+// Redacted serves the read endpoints of a Service with the points inside the
+// given privacy zones removed (see track.Document.Redact for truncate). All
+// other methods act on the underlying Service.
+type Redacted struct {
+	*Service
+	red *redaction
+}
+
+// Redacted returns a view of the library for untrusted clients.
+func (s *Service) Redacted(zones []track.PrivacyZone, truncate bool) *Redacted {
+	encoded, _ := json.Marshal(struct {
+		Zones    []track.PrivacyZone
+		Truncate bool
+	}{zones, truncate})
+	sum := sha256.Sum256(encoded)
+	return &Redacted{
+		Service: s,
+		red:     &redaction{zones: zones, truncate: truncate, key: hex.EncodeToString(sum[:8])},
+	}
+}
+
+// Listings leave out bounds that reach into a zone, and bbox and near
+// filters only match the geometry that is left after redaction.
+func (r *Redacted) ListFiles() ([]model.GPXFile, error) {
+	files, err := r.Service.ListFiles()
+	for i := range files {
+		files[i] = r.red.listing(files[i])
+	}
+	return files, err
+}
+
+func (r *Redacted) Search(values url.Values) ([]model.GPXFile, int, error) {
+	files, total, err := r.search(values, r.red)
+	for i := range files {
+		files[i] = r.red.listing(files[i])
+	}
+	return files, total, err
+}
+
+func (r *Redacted) StatsSummary(values url.Values) (model.StatsSummaryResponse, error) {
+	return r.statsSummary(values, r.red)
+}
+
+// Subscribe passes library events on with their entries redacted like the
+// listing.
+func (r *Redacted) Subscribe() (<-chan model.LibraryEvent, func()) {
+	events, unsubscribe := r.Service.Subscribe()
+	out := make(chan model.LibraryEvent, subscriberBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for ev := range events {
+			if ev.File != nil {
+				file := r.red.listing(*ev.File)
+				ev.File = &file
+			}
+			select {
+			case out <- ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+func (r *Redacted) GetTrack(relPath string) (model.GPXDetailResponse, error) {
+	return r.getTrack(relPath, r.red)
+}
+
+func (r *Redacted) GetProfile(relPath string, points int) (model.ProfileResponse, error) {
+	return r.getProfile(relPath, points, r.red)
+}
+
+func (r *Redacted) GetGeoJSON(relPath string, tolerance float64, algorithm string) ([]byte, error) {
+	return r.getGeoJSON(relPath, tolerance, algorithm, r.red)
+}
+
+func (r *Redacted) Thumbnail(relPath, format string, size int, base *render.Basemap) ([]byte, error) {
+	return r.thumbnail(relPath, format, size, base, r.red)
+}
+
+func (r *Redacted) Snapshot(req model.SnapshotRequest, base *render.Basemap) ([]byte, error) {
+	return r.snapshot(req, base, r.red)
+}
+
+func (r *Redacted) Compare(planPath, activityPath string) (model.CompareResponse, error) {
+	return r.compare(planPath, activityPath, r.red)
+}
+
+// Segments cut from a library file only take the points the client may see;
+// stored segments are listed without their hidden points and matched against
+// redacted tracks.
+func (r *Redacted) CreateSegment(req model.SegmentRequest) (model.Segment, error) {
+	return r.createSegment(req, r.red)
+}
+
+func (r *Redacted) ListSegments() ([]model.Segment, error) {
+	return r.listSegments(r.red)
+}
+
+func (r *Redacted) GetSegment(id string) (model.SegmentResponse, error) {
+	return r.getSegment(id, r.red)
+}
+
+func (r *Redacted) HeatmapTile(z, x, y int, values url.Values) ([]byte, error) {
+	return r.heatmapTile(z, x, y, values, r.red)
+}
+
+// Export always decodes and rewrites the file, so unlike Service.Export it
+// never hands out the original bytes.
+func (r *Redacted) Export(relPath, format string) ([]byte, error) {
+	return r.export(relPath, format, r.red)
+}
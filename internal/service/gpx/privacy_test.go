@@ -0,0 +1,125 @@
+package gpx
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/track"
+)
+
+func TestRedacted(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	full := filepath.Join(dataDir, "Activities", "run.gpx")
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Points at 59.00, 59.01, ... 59.05; the zone hides the first two.
+	original := lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 6)
+	if err := os.WriteFile(full, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(dataDir, cacheDir)
+	redacted := service.Redacted([]track.PrivacyZone{{Lat: 59, Lon: 24, Radius: 1500}}, false)
+
+	resp, err := redacted.GetTrack("Activities/run.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := resp.Track.TrackPointCount(); n != 4 || resp.File.Stats.PointCount != 4 || resp.Track.Tracks[0].Segments[0].Points[0].Lat != 59.02 {
+		t.Errorf("expected the hidden points removed, got %d points", n)
+	}
+	if resp, _ := service.GetTrack("Activities/run.gpx"); resp.Track.TrackPointCount() != 6 {
+		t.Error("the service itself should stay unfiltered")
+	}
+
+	profile, err := redacted.GetProfile("Activities/run.gpx", 100)
+	if err != nil || len(profile.Points) != 4 || profile.Points[0].Lat != 59.02 {
+		t.Errorf("unexpected redacted profile: %+v %v", profile.Points, err)
+	}
+
+	data, err := redacted.Export("Activities/run.gpx", "gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, []byte(original)) || strings.Contains(string(data), `lat="59.01"`) {
+		t.Error("export should rewrite the file without the hidden points")
+	}
+	if data, _ := service.Export("Activities/run.gpx", "gpx"); !bytes.Equal(data, []byte(original)) {
+		t.Error("unfiltered export should return the original bytes")
+	}
+
+	// Redacted and full GeoJSON are cached separately.
+	plain, err := service.GetGeoJSON("Activities/run.gpx", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := redacted.GetGeoJSON("Activities/run.gpx", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := service.GetGeoJSON("Activities/run.gpx", 0, "")
+	if bytes.Equal(plain, hidden) || !bytes.Equal(plain, again) || strings.Contains(string(hidden), "59.01") {
+		t.Errorf("expected distinct redacted GeoJSON, got %s", hidden)
+	}
+	other, _ := service.Redacted([]track.PrivacyZone{{Lat: 59.05, Lon: 24, Radius: 500}}, false).GetGeoJSON("Activities/run.gpx", 0, "")
+	if bytes.Equal(other, hidden) {
+		t.Error("different zones must not share cached output")
+	}
+}
+
+func TestRedacted_SpatialSearchAndBounds(t *testing.T) {
+	dataDir := t.TempDir()
+	for _, rel := range []string{"Activities/run.gpx", "Activities/far.gpx"} {
+		full := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 6)
+		if rel == "Activities/far.gpx" {
+			content = strings.ReplaceAll(content, `lon="24.0"`, `lon="25.0"`)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service := NewService(dataDir, "")
+	// The zone hides the first two points of run.gpx, at 59.00 and 59.01.
+	redacted := service.Redacted([]track.PrivacyZone{{Lat: 59, Lon: 24, Radius: 1500}}, false)
+
+	near := url.Values{"near": {"59,24"}, "radius": {"500m"}}
+	if _, total, err := service.Search(near); err != nil || total != 1 {
+		t.Fatalf("expected the full library to match near the zone, got %d (%v)", total, err)
+	}
+	if files, total, err := redacted.Search(near); err != nil || total != 0 {
+		t.Errorf("a hidden point must not be found by a near search, got %+v (%v)", files, err)
+	}
+	box := url.Values{"bbox": {"23.99,58.995,24.01,59.005"}}
+	if _, total, _ := redacted.Search(box); total != 0 {
+		t.Errorf("a hidden point must not be found by a bbox search, got %d", total)
+	}
+	if files, total, _ := redacted.Search(url.Values{"near": {"59.04,24"}}); total != 1 || files[0].Name != "run.gpx" {
+		t.Errorf("visible points should still match, got %+v", files)
+	}
+	if resp, err := redacted.StatsSummary(near); err != nil || len(resp.Groups) != 0 {
+		t.Errorf("stats should not count tracks matched by hidden points: %+v (%v)", resp, err)
+	}
+
+	files, err := redacted.ListFiles()
+	if err != nil || len(files) != 2 {
+		t.Fatalf("unexpected listing: %+v (%v)", files, err)
+	}
+	for _, f := range files {
+		if touches := f.Name == "run.gpx"; (f.Stats.Bounds == nil) != touches {
+			t.Errorf("%s: bounds reaching into a zone should be withheld, others kept: %+v", f.Name, f.Stats.Bounds)
+		}
+	}
+	if files, _ := service.ListFiles(); files[0].Stats.Bounds == nil || files[1].Stats.Bounds == nil {
+		t.Error("the service's own listing must keep its bounds")
+	}
+}
@@ -0,0 +1,434 @@
+package gpx
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gpx-self-host/internal/model"
+)
+
+const maxQueryLimit = 10000
+
+// Query is the parsed form of the /api/gpx search parameters.
+type Query struct {
+	Terms          []string // free text, matched against name and relative path
+	Activities     []string
+	Folders        []string
+	Years          []int
+	After          *time.Time // inclusive
+	Before         *time.Time // exclusive
+	MinDistance    float64    // meters; 0 means unset
+	MaxDistance    float64    // meters; 0 means unset
+	Spatial        *spatialFilter
+	HideDuplicates bool // leave out copies reported by /api/duplicates
+	Sort           string
+	Desc           bool
+	Limit          int
+	Offset         int
+}
+
+var sortKeys = map[string]bool{
+	"date":      true,
+	"name":      true,
+	"path":      true,
+	"distance":  true,
+	"duration":  true,
+	"elevation": true,
+}
+
+// ParseQuery reads the q, bbox, near, radius, duplicates, sort, limit and
+// offset parameters. q holds
+// space-separated tokens such as `activity:gravel year:2025 after:2025-06-01
+// minDistance:20km folder:Finland lake`; values may be double-quoted to
+// include spaces (`activity:"speed hiking"`). Repeated keys of the same kind
+// are OR-ed; different keys are AND-ed. bbox=w,s,e,n or near=lat,lon with an
+// optional radius (default 500m) restrict results to tracks passing through
+// that area. duplicates=hide leaves out the files reported by /api/duplicates
+// as copies of another one.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{Sort: "date", Desc: true}
+
+	for _, token := range tokenize(values.Get("q")) {
+		key, value, hasKey := strings.Cut(token, ":")
+		if !hasKey || value == "" {
+			q.Terms = append(q.Terms, strings.ToLower(strings.Trim(token, `"`)))
+			continue
+		}
+		value = strings.Trim(value, `"`)
+
+		switch strings.ToLower(key) {
+		case "activity":
+			q.Activities = append(q.Activities, strings.ToLower(value))
+		case "folder":
+			q.Folders = append(q.Folders, strings.ToLower(value))
+		case "year":
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid query: year %q", value)
+			}
+			q.Years = append(q.Years, year)
+		case "after":
+			t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid query: after %q", value)
+			}
+			q.After = &t
+		case "before":
+			t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid query: before %q", value)
+			}
+			q.Before = &t
+		case "mindistance":
+			d, err := ParseDistance(value)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid query: minDistance %q", value)
+			}
+			q.MinDistance = d
+		case "maxdistance":
+			d, err := ParseDistance(value)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid query: maxDistance %q", value)
+			}
+			q.MaxDistance = d
+		default:
+			// Unknown keys are treated as text so paths like "C:foo" still match.
+			q.Terms = append(q.Terms, strings.ToLower(token))
+		}
+	}
+
+	spatialFilter, err := parseSpatial(values)
+	if err != nil {
+		return Query{}, err
+	}
+	q.Spatial = spatialFilter
+
+	switch values.Get("duplicates") {
+	case "", "show":
+	case "hide":
+		q.HideDuplicates = true
+	default:
+		return Query{}, fmt.Errorf("invalid query: duplicates %q", values.Get("duplicates"))
+	}
+
+	if sortParam := values.Get("sort"); sortParam != "" {
+		key := strings.TrimPrefix(sortParam, "-")
+		if !sortKeys[key] {
+			return Query{}, fmt.Errorf("invalid query: sort %q", sortParam)
+		}
+		q.Sort = key
+		q.Desc = strings.HasPrefix(sortParam, "-")
+	}
+
+	if q.Limit, err = parseNonNegative(values.Get("limit")); err != nil {
+		return Query{}, fmt.Errorf("invalid query: limit %q", values.Get("limit"))
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	if q.Offset, err = parseNonNegative(values.Get("offset")); err != nil {
+		return Query{}, fmt.Errorf("invalid query: offset %q", values.Get("offset"))
+	}
+
+	return q, nil
+}
+
+func parseNonNegative(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// tokenize splits on whitespace outside double quotes.
+func tokenize(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuotes:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+var distancePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(km|m|mi)?$`)
+
+// ParseDistance converts values like "20km", "500m", "12mi" or a bare number
+// (kilometers, matching the units the UI shows) into meters.
+func ParseDistance(s string) (float64, error) {
+	m := distancePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid distance %q", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	switch m[2] {
+	case "m":
+		return v, nil
+	case "mi":
+		return v * 1609.344, nil
+	default:
+		return v * 1000, nil
+	}
+}
+
+// Search lists the library filtered, sorted and paginated according to q. It
+// also returns the number of matches before pagination.
+func (s *Service) Search(values url.Values) ([]model.GPXFile, int, error) {
+	return s.search(values, nil)
+}
+
+func (s *Service) search(values url.Values, red *redaction) ([]model.GPXFile, int, error) {
+	q, err := ParseQuery(values)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matched, err := s.filter(q, red)
+	if err != nil {
+		return nil, 0, err
+	}
+	q.sortFiles(matched)
+
+	total := len(matched)
+	start := q.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+	return matched[start:end], total, nil
+}
+
+// filter lists the library files that match q, in listing order. Spatial
+// filters are checked against the geometry red leaves visible.
+func (s *Service) filter(q Query, red *redaction) ([]model.GPXFile, error) {
+	files, err := s.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var inArea map[string]bool
+	if q.Spatial != nil {
+		inArea = s.spatialMatches(q.Spatial, red)
+	}
+
+	matched := files[:0]
+	for _, f := range files {
+		if inArea != nil && !inArea[f.RelativePath] {
+			continue
+		}
+		if q.HideDuplicates && f.DuplicateOf != "" {
+			continue
+		}
+		if q.matches(f) {
+			matched = append(matched, f)
+		}
+	}
+	return matched, nil
+}
+
+func (q Query) matches(f model.GPXFile) bool {
+	name := strings.ToLower(f.Name)
+	rel := strings.ToLower(f.RelativePath)
+	for _, term := range q.Terms {
+		if !strings.Contains(name, term) && !strings.Contains(rel, term) {
+			return false
+		}
+	}
+
+	if len(q.Activities) > 0 && !containsString(q.Activities, strings.ToLower(f.Activity)) {
+		return false
+	}
+
+	if len(q.Folders) > 0 {
+		dirs := strings.Split(strings.ToLower(f.RelativePath), "/")
+		dirs = dirs[:len(dirs)-1]
+		found := false
+		for _, folder := range q.Folders {
+			if containsString(dirs, folder) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(q.Years) > 0 || q.After != nil || q.Before != nil {
+		date, ok := fileDate(f)
+		if !ok {
+			return false
+		}
+		if len(q.Years) > 0 {
+			found := false
+			for _, y := range q.Years {
+				if date.Year() == y {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if q.After != nil && date.Before(*q.After) {
+			return false
+		}
+		if q.Before != nil && !date.Before(*q.Before) {
+			return false
+		}
+	}
+
+	if q.MinDistance > 0 || q.MaxDistance > 0 {
+		if f.Stats == nil {
+			return false
+		}
+		if q.MinDistance > 0 && f.Stats.Distance < q.MinDistance {
+			return false
+		}
+		if q.MaxDistance > 0 && f.Stats.Distance > q.MaxDistance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q Query) sortFiles(files []model.GPXFile) {
+	less := func(a, b model.GPXFile) int {
+		switch q.Sort {
+		case "name":
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "path":
+			return strings.Compare(strings.ToLower(a.RelativePath), strings.ToLower(b.RelativePath))
+		case "distance":
+			return compareStat(a, b, func(st *model.TrackStats) float64 { return st.Distance })
+		case "duration":
+			return compareStat(a, b, func(st *model.TrackStats) float64 { return st.MovingTime })
+		case "elevation":
+			return compareStat(a, b, func(st *model.TrackStats) float64 { return st.ElevationGain })
+		default:
+			da, okA := fileDate(a)
+			db, okB := fileDate(b)
+			switch {
+			case okA && okB:
+				return da.Compare(db)
+			case okA:
+				return 1
+			case okB:
+				return -1
+			}
+			return 0
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		c := less(files[i], files[j])
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(files[i].RelativePath), strings.ToLower(files[j].RelativePath))
+		}
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareStat orders files without stats before those with them, so they end
+// up last in the (default) descending order.
+func compareStat(a, b model.GPXFile, value func(*model.TrackStats) float64) int {
+	switch {
+	case a.Stats == nil && b.Stats == nil:
+		return 0
+	case a.Stats == nil:
+		return -1
+	case b.Stats == nil:
+		return 1
+	}
+	va, vb := value(a.Stats), value(b.Stats)
+	switch {
+	case va < vb:
+		return -1
+	case va > vb:
+		return 1
+	}
+	return 0
+}
+
+var filenameDatePattern = regexp.MustCompile(`^(\d{4})-?(\d{2})-?(\d{2})`)
+
+// fileDate returns the recorded start time, falling back to the date prefix
+// in the filename that the sidebar uses.
+func fileDate(f model.GPXFile) (time.Time, bool) {
+	if f.Stats != nil && f.Stats.StartTime != nil {
+		return f.Stats.StartTime.In(time.Local), true
+	}
+	m := filenameDatePattern.FindStringSubmatch(f.Name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102", m[1]+m[2]+m[3], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// deriveActivity mirrors deriveActivity in utils.js: the first segment under
+// Activities/ names the activity, and everything under Plans/ is "Plans". A
+// file directly under Activities/ is labelled by its own name, as in the SPA.
+func deriveActivity(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	switch strings.ToLower(segments[0]) {
+	case "plans":
+		return "Plans"
+	case "activities":
+		if len(segments) > 1 && segments[1] != "" {
+			return segments[1]
+		}
+		return "Other"
+	}
+	if segments[0] == "" {
+		return "Other"
+	}
+	return segments[0]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package gpx
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lineTrack returns a northbound track starting at start with n points 0.01°
+// (~1.1 km) apart.
+func lineTrack(start time.Time, n int) string {
+	var b strings.Builder
+	b.WriteString(`<gpx version="1.1"><trk><trkseg>`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<trkpt lat="%.2f" lon="24.0"><time>%s</time></trkpt>`,
+			59+float64(i)*0.01, start.Add(time.Duration(i)*10*time.Second).UTC().Format(time.RFC3339))
+	}
+	b.WriteString(`</trkseg></trk></gpx>`)
+	return b.String()
+}
+
+func newQueryLibrary(t *testing.T) *Service {
+	t.Helper()
+	dataDir := t.TempDir()
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 9, 0, 0, 0, time.Local) }
+	tracks := []struct {
+		path    string
+		content string
+	}{
+		{"Activities/Running/Finland/lake loop.gpx", lineTrack(day(2025, 6, 14), 6)}, // ~5.6 km
+		{"Activities/Running/city.gpx", lineTrack(day(2024, 3, 2), 11)},              // ~11 km
+		{"Activities/Gravel/long ride.gpx", lineTrack(day(2025, 8, 1), 26)},          // ~28 km
+		{"Activities/loose.gpx", lineTrack(day(2023, 1, 5), 2)},
+		{"Plans/Finland/2025-07-01 plan.gpx", `<gpx version="1.1"><rte><rtept lat="60" lon="25"/><rtept lat="60.03" lon="25"/></rte></gpx>`},
+	}
+	for _, tr := range tracks {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(tr.path)), tr.content, time.Now())
+	}
+	return NewService(dataDir, "")
+}
+
+func TestSearch(t *testing.T) {
+	s := newQueryLibrary(t)
+
+	tests := []struct {
+		name     string
+		params   string
+		expected []string
+		total    int
+	}{
+		{"Default sort is newest first", "", []string{"long ride.gpx", "2025-07-01 plan.gpx", "lake loop.gpx", "city.gpx", "loose.gpx"}, 5},
+		{"Activity", "q=activity:running", []string{"lake loop.gpx", "city.gpx"}, 2},
+		{"Activity of loose file", "q=activity:loose.gpx", []string{"loose.gpx"}, 1},
+		{"Repeated activity is OR", "q=activity:running+activity:gravel&sort=name", []string{"city.gpx", "lake loop.gpx", "long ride.gpx"}, 3},
+		{"Year", "q=year:2025&sort=date", []string{"lake loop.gpx", "2025-07-01 plan.gpx", "long ride.gpx"}, 3},
+		{"Year from filename", "q=year:2025+activity:plans", []string{"2025-07-01 plan.gpx"}, 1},
+		{"After and before", "q=after:2025-06-14+before:2025-08-01", []string{"2025-07-01 plan.gpx", "lake loop.gpx"}, 2},
+		{"Min distance", "q=minDistance:10km&sort=-distance", []string{"long ride.gpx", "city.gpx"}, 2},
+		{"Max distance in meters", "q=maxDistance:6000m+activity:running", []string{"lake loop.gpx"}, 1},
+		{"Folder", "q=folder:finland&sort=path", []string{"lake loop.gpx", "2025-07-01 plan.gpx"}, 2},
+		{"Free text", "q=lake", []string{"lake loop.gpx"}, 1},
+		{"Quoted free text", `q="lake+loop"`, []string{"lake loop.gpx"}, 1},
+		{"Combined", "q=activity:running+year:2025+minDistance:5", []string{"lake loop.gpx"}, 1},
+		{"Sort by name ascending", "sort=name&limit=2", []string{"2025-07-01 plan.gpx", "city.gpx"}, 5},
+		{"Offset", "sort=name&limit=2&offset=2", []string{"lake loop.gpx", "long ride.gpx"}, 5},
+		{"Offset past end", "offset=50", []string{}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, total, err := s.Search(params)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if total != tt.total {
+				t.Errorf("expected total %d, got %d", tt.total, total)
+			}
+			got := make([]string, len(files))
+			for i, f := range files {
+				got[i] = f.Name
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSearch_InvalidQuery(t *testing.T) {
+	s := newQueryLibrary(t)
+
+	for _, params := range []string{
+		"q=year:twenty",
+		"q=after:yesterday",
+		"q=minDistance:far",
+		"sort=color",
+		"limit=-1",
+		"offset=x",
+	} {
+		values, _ := url.ParseQuery(params)
+		if _, _, err := s.Search(values); err == nil || !strings.HasPrefix(err.Error(), "invalid query") {
+			t.Errorf("%s: expected invalid query error, got %v", params, err)
+		}
+	}
+}
+
+func TestParseDistance(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected float64
+	}{
+		{"20km", 20000},
+		{"500m", 500},
+		{"1.5", 1500},
+		{"2mi", 3218.688},
+		{"10 KM", 10000},
+	}
+	for _, tt := range tests {
+		got, err := ParseDistance(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.in, tt.expected, got)
+		}
+	}
+
+	if _, err := ParseDistance("-5km"); err == nil {
+		t.Error("expected error for negative distance")
+	}
+}
+
+func TestDeriveActivity(t *testing.T) {
+	tests := map[string]string{
+		"":                                "Other",
+		"Activities/":                     "Other",
+		"Activities/Running/2025/run.gpx": "Running",
+		"Activities/loose.gpx":            "loose.gpx",
+		"activities/loose.gpx":            "loose.gpx",
+		"Plans/Finland/plan.gpx":          "Plans",
+		"Plans/plan.gpx":                  "Plans",
+		"some/random/path.gpx":            "some",
+	}
+	for in, expected := range tests {
+		if got := deriveActivity(in); got != expected {
+			t.Errorf("%s: expected %q, got %q", in, expected, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gpx
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// fastestRecords names the record for each of track.EffortDistances.
+var fastestRecords = []string{"fastest-1k", "fastest-5k", "fastest-10k", "fastest-half-marathon"}
+
+// recordCandidate is one file's value for a record type; lower is better for
+// the fastest-* records, higher for the rest.
+type recordCandidate struct {
+	value float64
+	start *time.Time
+}
+
+// fileRecords lists a file's candidate for every record type it has a
+// value for.
+func fileRecords(entry *indexEntry) map[string]recordCandidate {
+	out := make(map[string]recordCandidate)
+	if entry.Efforts != nil {
+		for _, e := range entry.Efforts.Fastest {
+			for i, dist := range track.EffortDistances {
+				if e.Distance == dist {
+					start := e.Start
+					out[fastestRecords[i]] = recordCandidate{e.Seconds, &start}
+				}
+			}
+		}
+		if c := entry.Efforts.Climb; c != nil {
+			out["biggest-climb"] = recordCandidate{c.Gain, c.Start}
+		}
+	}
+	if st := entry.Stats; st != nil {
+		if st.Distance > 0 {
+			out["longest-distance"] = recordCandidate{st.Distance, st.StartTime}
+		}
+		if st.MovingTime > 0 {
+			out["longest-moving-time"] = recordCandidate{st.MovingTime, st.StartTime}
+		}
+	}
+	return out
+}
+
+// Records returns the personal bests of each activity (of activity only,
+// compared case-insensitively, when it is not empty): the fastest 1 km, 5 km,
+// 10 km and half marathon, the biggest single climb, and the longest
+// distance and moving time. Best efforts are computed per file when the
+// file is indexed, so new files only add their own efforts. Files under
+// Plans/ are not activities and never hold records, nor do duplicates of
+// other files.
+func (s *Service) Records(activity string) (model.RecordsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scanned, err := s.refresh()
+	if err != nil {
+		return model.RecordsResponse{}, err
+	}
+
+	type best struct {
+		recordCandidate
+		relPath string
+	}
+	byActivity := make(map[string]map[string]*best)
+	for _, f := range scanned {
+		entry := s.entries[f.relPath]
+		name := deriveActivity(f.relPath)
+		if entry == nil || name == "Plans" || s.duplicates().keepOf[f.relPath] != "" || (activity != "" && !strings.EqualFold(name, activity)) {
+			continue
+		}
+		bests := byActivity[name]
+		if bests == nil {
+			bests = make(map[string]*best)
+			byActivity[name] = bests
+		}
+		for typ, c := range fileRecords(entry) {
+			b := bests[typ]
+			lower := strings.HasPrefix(typ, "fastest-")
+			if b == nil || (lower && c.value < b.value) || (!lower && c.value > b.value) {
+				bests[typ] = &best{c, f.relPath}
+			}
+		}
+	}
+
+	order := append(append([]string{}, fastestRecords...), "biggest-climb", "longest-distance", "longest-moving-time")
+	resp := model.RecordsResponse{Activities: []model.ActivityRecords{}}
+	for name, bests := range byActivity {
+		ar := model.ActivityRecords{Activity: name, Records: []model.Record{}}
+		for _, typ := range order {
+			if b := bests[typ]; b != nil {
+				ar.Records = append(ar.Records, model.Record{Type: typ, Value: b.value, Start: b.start, File: s.fileFor(b.relPath)})
+			}
+		}
+		resp.Activities = append(resp.Activities, ar)
+	}
+	sort.Slice(resp.Activities, func(i, j int) bool {
+		a, b := resp.Activities[i].Activity, resp.Activities[j].Activity
+		if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+			return la < lb
+		}
+		return a < b
+	})
+	return resp, nil
+}
@@ -0,0 +1,86 @@
+package gpx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTrack is n points ~111 m apart, every secs seconds from start, climbing
+// climb meters per point.
+func runTrack(start time.Time, n, secs int, climb float64) string {
+	var b strings.Builder
+	b.WriteString(`<gpx version="1.1"><trk><trkseg>`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<trkpt lat="%.3f" lon="24.0"><ele>%.1f</ele><time>%s</time></trkpt>`,
+			59+float64(i)*0.001, float64(i)*climb, start.Add(time.Duration(i*secs)*time.Second).UTC().Format(time.RFC3339))
+	}
+	b.WriteString(`</trkseg></trk></gpx>`)
+	return b.String()
+}
+
+func TestRecords(t *testing.T) {
+	dataDir := t.TempDir()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 8, 0, 0, 0, time.UTC) }
+	for path, content := range map[string]string{
+		"Activities/Running/easy.gpx":    runTrack(day(1), 40, 12, 0), // ~4.3 km
+		"Activities/Running/tempo.gpx":   runTrack(day(2), 60, 10, 0), // ~6.6 km
+		"Activities/Gravel/hills.gpx":    runTrack(day(3), 30, 10, 5),
+		"Plans/2025-05-04 fast plan.gpx": runTrack(day(4), 60, 5, 0),
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, "")
+
+	resp, err := s.Records("")
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(resp.Activities) != 2 || resp.Activities[0].Activity != "Gravel" || resp.Activities[1].Activity != "Running" {
+		t.Fatalf("expected Gravel and Running (plans left out), got %+v", resp.Activities)
+	}
+
+	running := make(map[string]string)
+	var types []string
+	for _, r := range resp.Activities[1].Records {
+		running[r.Type] = r.File.Name
+		types = append(types, r.Type)
+		if r.Value <= 0 || r.Start == nil {
+			t.Errorf("%s: expected a value and start, got %+v", r.Type, r)
+		}
+	}
+	if got := strings.Join(types, ","); got != "fastest-1k,fastest-5k,longest-distance,longest-moving-time" {
+		t.Errorf("unexpected running record types %s", got)
+	}
+	if running["fastest-1k"] != "tempo.gpx" || running["fastest-5k"] != "tempo.gpx" || running["longest-moving-time"] != "tempo.gpx" {
+		t.Errorf("expected tempo.gpx to hold the running records, got %v", running)
+	}
+	gravel := resp.Activities[0].Records
+	if len(gravel) == 0 || gravel[1].Type != "biggest-climb" || gravel[1].Value < 100 || gravel[1].File.RelativePath != "Activities/Gravel/hills.gpx" {
+		t.Errorf("expected the gravel climb record, got %+v", gravel)
+	}
+
+	// A new, faster run takes over the 1k record.
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Running", "race.gpx"), runTrack(day(5), 12, 8, 0), time.Now())
+	resp, err = s.Records("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Activities) != 1 || resp.Activities[0].Activity != "Running" {
+		t.Fatalf("expected only Running, got %+v", resp.Activities)
+	}
+	for _, r := range resp.Activities[0].Records {
+		if r.Type == "fastest-1k" && r.File.Name != "race.gpx" {
+			t.Errorf("expected race.gpx to hold the 1k record, got %s", r.File.Name)
+		}
+		if r.Type == "fastest-5k" && r.File.Name != "tempo.gpx" {
+			t.Errorf("expected tempo.gpx to keep the 5k record, got %s", r.File.Name)
+		}
+	}
+
+	if resp, err := s.Records("swimming"); err != nil || len(resp.Activities) != 0 {
+		t.Errorf("expected no records for an unknown activity, got %+v %v", resp, err)
+	}
+}
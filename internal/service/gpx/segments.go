@@ -0,0 +1,319 @@
+package gpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/spatial"
+	"gpx-self-host/internal/track"
+)
+
+// segmentsFile holds the segment definitions. Like the trash it lives in the
+// data dir, outside the scan roots, because segments are user data rather
+// than something that can be rebuilt.
+const segmentsFile = ".segments.json"
+
+const (
+	maxSegmentPoints = 10000
+	minSegmentLength = 2 * track.SegmentTolerance // meters
+)
+
+// ListSegments returns the defined segments, oldest first.
+func (s *Service) ListSegments() ([]model.Segment, error) {
+	return s.listSegments(nil)
+}
+
+func (s *Service) listSegments(red *redaction) ([]model.Segment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	segments, err := s.readSegments()
+	if err != nil {
+		return nil, err
+	}
+	for i := range segments {
+		segments[i].Points = red.line(segments[i].Points)
+	}
+	return segments, nil
+}
+
+// CreateSegment stores a new segment drawn as a polyline or cut from a
+// library file; see model.SegmentRequest.
+func (s *Service) CreateSegment(req model.SegmentRequest) (model.Segment, error) {
+	return s.createSegment(req, nil)
+}
+
+func (s *Service) createSegment(req model.SegmentRequest, red *redaction) (model.Segment, error) {
+	name := strings.TrimSpace(req.Name)
+	byTime := req.Start != nil || req.End != nil
+	byIndex := req.From != nil || req.To != nil
+	switch {
+	case name == "":
+		return model.Segment{}, fmt.Errorf("invalid segment: missing name")
+	case len(req.Points) > 0 && req.Path != "":
+		return model.Segment{}, fmt.Errorf("invalid segment: use either points or a path")
+	case len(req.Points) == 0 && req.Path == "":
+		return model.Segment{}, fmt.Errorf("invalid segment: missing points or path")
+	case req.Path == "" && (byTime || byIndex):
+		return model.Segment{}, fmt.Errorf("invalid segment: a range needs a path")
+	case byTime && byIndex:
+		return model.Segment{}, fmt.Errorf("invalid segment: use either a time or an index range")
+	}
+
+	var points []track.Point
+	if req.Path != "" {
+		fullPath, _, err := s.resolvePath(req.Path)
+		if err != nil {
+			return model.Segment{}, err
+		}
+		doc, err := parseFile(fullPath)
+		if err != nil {
+			return model.Segment{}, err
+		}
+		// Ranges refer to the points the client was shown.
+		doc = red.apply(doc)
+		switch {
+		case byTime:
+			var start, end time.Time
+			if req.Start != nil {
+				start = *req.Start
+			}
+			if req.End != nil {
+				end = *req.End
+			}
+			doc = doc.TrimTime(start, end)
+		case byIndex:
+			from, to := 0, doc.TrackPointCount()
+			if req.From != nil {
+				from = *req.From
+			}
+			if req.To != nil {
+				to = *req.To
+			}
+			doc = doc.TrimIndex(from, to)
+		}
+		for _, t := range doc.Tracks {
+			for _, seg := range t.Segments {
+				points = append(points, seg.Points...)
+			}
+		}
+	} else {
+		points = req.Points
+	}
+
+	if len(points) < 2 || len(points) > maxSegmentPoints {
+		return model.Segment{}, fmt.Errorf("invalid segment: need 2 to %d points", maxSegmentPoints)
+	}
+	line := make([]track.Point, len(points))
+	for i, p := range points {
+		if !validLat(p.Lat) || !validLon(p.Lon) {
+			return model.Segment{}, fmt.Errorf("invalid segment: point %d is out of range", i)
+		}
+		line[i] = track.Point{Lat: p.Lat, Lon: p.Lon}
+	}
+	length := track.Length(line)
+	if length < minSegmentLength {
+		return model.Segment{}, fmt.Errorf("invalid segment: shorter than %g m", minSegmentLength)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.readSegments()
+	if err != nil {
+		return model.Segment{}, err
+	}
+	now := time.Now()
+	seg := model.Segment{
+		ID:        strconv.FormatInt(now.UnixNano(), 36),
+		Name:      name,
+		Distance:  length,
+		Points:    line,
+		CreatedAt: now.UTC(),
+	}
+	if err := s.writeSegments(append(segments, seg)); err != nil {
+		return model.Segment{}, err
+	}
+	return seg, nil
+}
+
+// DeleteSegment removes a segment and its cached matches.
+func (s *Service) DeleteSegment(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.readSegments()
+	if err != nil {
+		return err
+	}
+	for i, seg := range segments {
+		if seg.ID != id {
+			continue
+		}
+		if err := s.writeSegments(append(segments[:i:i], segments[i+1:]...)); err != nil {
+			return err
+		}
+		if s.cacheDir != "" {
+			stale, _ := filepath.Glob(filepath.Join(s.cacheDir, "segments", "*", "*-"+id+".json"))
+			for _, path := range stale {
+				os.Remove(path)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("segment not found")
+}
+
+// GetSegment returns a segment with every traversal found in the library,
+// ranked by elapsed time. Only files with indexed geometry near both ends
+// of the segment are read, and their traversals are cached by file
+// contents, so after the first request only new or changed files are
+// matched. Files under Plans/ are not activities and are skipped, as are
+// duplicates of other files so one effort is not ranked twice.
+func (s *Service) GetSegment(id string) (model.SegmentResponse, error) {
+	return s.getSegment(id, nil)
+}
+
+func (s *Service) getSegment(id string, red *redaction) (model.SegmentResponse, error) {
+	s.mu.Lock()
+	segments, err := s.readSegments()
+	if err != nil {
+		s.mu.Unlock()
+		return model.SegmentResponse{}, err
+	}
+	var seg *model.Segment
+	for i := range segments {
+		if segments[i].ID == id {
+			seg = &segments[i]
+		}
+	}
+	if seg == nil {
+		s.mu.Unlock()
+		return model.SegmentResponse{}, fmt.Errorf("segment not found")
+	}
+	if _, err := s.refresh(); err != nil {
+		s.mu.Unlock()
+		return model.SegmentResponse{}, err
+	}
+	if s.tree == nil {
+		s.buildTree()
+	}
+	near := func(p track.Point) map[string]bool {
+		found := make(map[string]bool)
+		b := circleBounds(p.Lat, p.Lon, track.SegmentTolerance)
+		for _, box := range boundsBoxes(b) {
+			s.tree.Search(spatialRect(box), func(it spatial.Item) bool {
+				found[s.treeRefs[it.ID].relPath] = true
+				return true
+			})
+		}
+		return found
+	}
+	type candidate struct {
+		file model.GPXFile
+		hash string
+	}
+	var candidates []candidate
+	atEnd := near(seg.Points[len(seg.Points)-1])
+	for relPath := range near(seg.Points[0]) {
+		if !atEnd[relPath] || deriveActivity(relPath) == "Plans" || s.duplicates().keepOf[relPath] != "" {
+			continue
+		}
+		candidates = append(candidates, candidate{s.fileFor(relPath), s.entries[relPath].Hash})
+	}
+	s.mu.Unlock()
+
+	resp := model.SegmentResponse{Segment: *seg, Efforts: []model.SegmentEffort{}}
+	for _, c := range candidates {
+		for _, t := range s.segmentTraversals(seg, c.file.RelativePath, c.hash, red) {
+			resp.Efforts = append(resp.Efforts, model.SegmentEffort{
+				File:    c.file,
+				Start:   t.Start,
+				Elapsed: t.Seconds,
+				Speed:   seg.Distance / t.Seconds,
+			})
+		}
+	}
+	sort.Slice(resp.Efforts, func(i, j int) bool {
+		a, b := resp.Efforts[i], resp.Efforts[j]
+		if a.Elapsed != b.Elapsed {
+			return a.Elapsed < b.Elapsed
+		}
+		return a.Start.Before(b.Start)
+	})
+	for i := range resp.Efforts {
+		resp.Efforts[i].Rank = i + 1
+	}
+	resp.Segment.Points = red.line(seg.Points)
+	return resp, nil
+}
+
+// segmentTraversals matches one file against a segment, using the cached
+// result for the file's contents when there is one.
+func (s *Service) segmentTraversals(seg *model.Segment, relPath, hash string, red *redaction) []track.Traversal {
+	cachePath := s.derivedPath("segments", hash, red.cacheKey()+seg.ID+".json")
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var cached []track.Traversal
+			if json.Unmarshal(data, &cached) == nil {
+				return cached
+			}
+		}
+	}
+
+	doc, err := parseFile(filepath.Join(s.DataDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil
+	}
+	found := red.apply(doc).MatchSegment(seg.Points)
+	if found == nil {
+		found = []track.Traversal{}
+	}
+	if cachePath != "" {
+		data, err := json.Marshal(found)
+		if err == nil {
+			err = writeFileAtomic(cachePath, data)
+		}
+		if err != nil {
+			slog.Warn("Failed to cache segment matches", "path", cachePath, "error", err)
+		}
+	}
+	return found
+}
+
+// readSegments loads the segment definitions. Callers must hold s.mu.
+func (s *Service) readSegments() ([]model.Segment, error) {
+	data, err := os.ReadFile(filepath.Join(s.DataDir, segmentsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.Segment{}, nil
+		}
+		return nil, err
+	}
+	var segments []model.Segment
+	if err := json.Unmarshal(data, &segments); err != nil {
+		return nil, fmt.Errorf("%s: %w", segmentsFile, err)
+	}
+	for _, seg := range segments {
+		if len(seg.Points) < 2 {
+			return nil, fmt.Errorf("%s: segment %q has no line", segmentsFile, seg.ID)
+		}
+	}
+	return segments, nil
+}
+
+// writeSegments saves the segment definitions. Callers must hold s.mu.
+func (s *Service) writeSegments(segments []model.Segment) error {
+	data, err := json.MarshalIndent(segments, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.DataDir, segmentsFile), data)
+}
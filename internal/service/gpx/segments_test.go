@@ -0,0 +1,133 @@
+package gpx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// climbTrack rides north along lon 24 from 59.000 to 59.012, a point every
+// ~22 m, secs seconds apart.
+func climbTrack(start time.Time, secs int) string {
+	var b strings.Builder
+	b.WriteString(`<gpx version="1.1"><trk><trkseg>`)
+	for i := 0; i <= 60; i++ {
+		fmt.Fprintf(&b, `<trkpt lat="%.4f" lon="24.0000"><time>%s</time></trkpt>`,
+			59+float64(i)*0.0002, start.Add(time.Duration(i*secs)*time.Second).UTC().Format(time.RFC3339))
+	}
+	b.WriteString(`</trkseg></trk></gpx>`)
+	return b.String()
+}
+
+func TestSegments(t *testing.T) {
+	dataDir, cacheDir := t.TempDir(), t.TempDir()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 8, 0, 0, 0, time.UTC) }
+	for path, content := range map[string]string{
+		"Activities/Cycling/slow.gpx":        climbTrack(day(1), 6),
+		"Activities/Cycling/fast.gpx":        climbTrack(day(2), 4),
+		"Activities/Cycling/next street.gpx": strings.ReplaceAll(climbTrack(day(3), 2), `lon="24.0000"`, `lon="24.0010"`), // ~57 m east
+		"Plans/2025-05-04 the climb.gpx":     climbTrack(day(4), 1),
+	} {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(path)), content, time.Now())
+	}
+	s := NewService(dataDir, cacheDir)
+
+	// A slice of an existing track: points 5 to 50, ~1 km.
+	from, to := 5, 50
+	seg, err := s.CreateSegment(model.SegmentRequest{Name: " The climb ", Path: "Activities/Cycling/slow.gpx", From: &from, To: &to})
+	if err != nil {
+		t.Fatalf("CreateSegment failed: %v", err)
+	}
+	if seg.ID == "" || seg.Name != "The climb" || len(seg.Points) != 46 || seg.Points[0].Time != nil || seg.Distance < 990 || seg.Distance > 1010 {
+		t.Fatalf("unexpected segment %+v", seg)
+	}
+
+	resp, err := s.GetSegment(seg.ID)
+	if err != nil {
+		t.Fatalf("GetSegment failed: %v", err)
+	}
+	if len(resp.Efforts) != 2 {
+		t.Fatalf("expected two efforts (plans and the next street left out), got %+v", resp.Efforts)
+	}
+	best := resp.Efforts[0]
+	if best.Rank != 1 || best.File.Name != "fast.gpx" || resp.Efforts[1].Rank != 2 || resp.Efforts[1].File.Name != "slow.gpx" {
+		t.Errorf("expected fast.gpx ranked first, got %+v", resp.Efforts)
+	}
+	if best.Elapsed < 175 || best.Elapsed > 185 || best.Speed < 5.3 || best.Speed > 5.8 {
+		t.Errorf("expected ~180 s at ~5.5 m/s, got %+v", best)
+	}
+	if cached, _ := filepath.Glob(filepath.Join(cacheDir, "segments", "*", "*-"+seg.ID+".json")); len(cached) != 2 {
+		t.Errorf("expected the matches of both candidates to be cached, got %v", cached)
+	}
+
+	// A new file is matched on the next request; its copy is not ranked again.
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Cycling", "race.gpx"), climbTrack(day(5), 3), time.Now())
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Cycling", "race (1).gpx"), climbTrack(day(5), 3), time.Now())
+	resp, err = s.GetSegment(seg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Efforts) != 3 || resp.Efforts[0].File.Name != "race.gpx" {
+		t.Errorf("expected race.gpx alone to take the lead, got %+v", resp.Efforts)
+	}
+
+	// Segments survive a restart.
+	list, err := NewService(dataDir, cacheDir).ListSegments()
+	if err != nil || len(list) != 1 || list[0].ID != seg.ID {
+		t.Fatalf("expected the stored segment, got %+v %v", list, err)
+	}
+
+	drawn, err := s.CreateSegment(model.SegmentRequest{Name: "Drawn", Points: []track.Point{{Lat: 59.002, Lon: 24}, {Lat: 59.004, Lon: 24}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := s.GetSegment(drawn.ID); err != nil || len(resp.Efforts) != 3 {
+		t.Errorf("expected three efforts on the drawn segment, got %+v %v", resp, err)
+	}
+
+	if err := s.DeleteSegment(seg.ID); err != nil {
+		t.Fatalf("DeleteSegment failed: %v", err)
+	}
+	if cached, _ := filepath.Glob(filepath.Join(cacheDir, "segments", "*", "*-"+seg.ID+".json")); len(cached) != 0 {
+		t.Errorf("expected cached matches to be removed, got %v", cached)
+	}
+	if _, err := s.GetSegment(seg.ID); err == nil || err.Error() != "segment not found" {
+		t.Errorf("expected segment not found, got %v", err)
+	}
+	if err := s.DeleteSegment(seg.ID); err == nil || err.Error() != "segment not found" {
+		t.Errorf("expected segment not found, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, segmentsFile)); err != nil {
+		t.Errorf("expected segments in the data dir: %v", err)
+	}
+}
+
+func TestCreateSegmentValidation(t *testing.T) {
+	s := NewService(t.TempDir(), "")
+	line := []track.Point{{Lat: 59, Lon: 24}, {Lat: 59.01, Lon: 24}}
+	start := time.Now()
+	from := 0
+	for _, req := range []model.SegmentRequest{
+		{Points: line},
+		{Name: "x"},
+		{Name: "x", Points: line, Path: "Activities/a.gpx"},
+		{Name: "x", Points: line, From: &from},
+		{Name: "x", Path: "Activities/a.gpx", Start: &start, From: &from},
+		{Name: "x", Points: line[:1]},
+		{Name: "x", Points: []track.Point{{Lat: 59, Lon: 24}, {Lat: 91, Lon: 24}}},
+		{Name: "x", Points: []track.Point{{Lat: 59, Lon: 24}, {Lat: 59.0001, Lon: 24}}},
+	} {
+		if _, err := s.CreateSegment(req); err == nil || !strings.HasPrefix(err.Error(), "invalid segment") {
+			t.Errorf("expected an invalid segment error for %+v, got %v", req, err)
+		}
+	}
+	if _, err := s.CreateSegment(model.SegmentRequest{Name: "x", Path: "Activities/missing.gpx"}); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
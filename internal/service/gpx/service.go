@@ -1,62 +1,203 @@
 package gpx
 
 import (
-	"io/fs"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/spatial"
+	"gpx-self-host/internal/track"
 )
 
+var scanRoots = []string{"Activities", "Plans"}
+
 type Service struct {
 	DataDir string
+
+	cacheDir  string
+	indexPath string
+	refreshMu sync.Mutex // serialises refreshes, which release mu while parsing
+	mu        sync.Mutex
+	entries   map[string]*indexEntry
+	indexed   bool
+	tree      *spatial.RTree // rebuilt lazily after the index changes
+	treeRefs  []chunkRef
+
+	heatmapGen uint64 // bumped whenever heatmap tiles are invalidated
+
+	dupes     *duplicateSet      // nil until computed, reset when the index changes
+	dismissed map[[2]string]bool // hash pairs marked as not duplicates; nil until loaded
+
+	subMu       sync.Mutex
+	subscribers map[chan model.LibraryEvent]struct{}
+	closed      bool
 }
 
-func NewService(dataDir string) *Service {
-	return &Service{DataDir: dataDir}
+// NewService creates a GPX service. When cacheDir is non-empty the library
+// index is persisted there and reused across restarts.
+func NewService(dataDir, cacheDir string) *Service {
+	s := &Service{DataDir: dataDir, cacheDir: cacheDir}
+	if cacheDir != "" {
+		s.indexPath = filepath.Join(cacheDir, indexFileName)
+	}
+	return s
 }
 
 func (s *Service) ListFiles() ([]model.GPXFile, error) {
-	var files []model.GPXFile
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	scanRoots := []string{"Activities", "Plans"}
+	scanned, err := s.refresh()
+	if err != nil {
+		return nil, err
+	}
 
-	for _, root := range scanRoots {
-		rootPath := filepath.Join(s.DataDir, root)
-		info, err := os.Stat(rootPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				continue
-			}
-			return nil, err
+	files := make([]model.GPXFile, 0, len(scanned))
+	for _, f := range scanned {
+		files = append(files, s.fileFor(f.relPath))
+	}
+	return files, nil
+}
+
+// fileFor builds the listing entry for an indexed file. Callers must hold s.mu.
+func (s *Service) fileFor(relPath string) model.GPXFile {
+	file := newGPXFile(relPath)
+	if entry := s.entries[relPath]; entry != nil {
+		file.Stats = entry.Stats
+	}
+	file.DuplicateOf = s.duplicates().keepOf[relPath]
+	return file
+}
+
+// GetTrack parses a single library file identified by its path relative to
+// the data dir (as returned in GPXFile.RelativePath).
+func (s *Service) GetTrack(relPath string) (model.GPXDetailResponse, error) {
+	return s.getTrack(relPath, nil)
+}
+
+func (s *Service) getTrack(relPath string, red *redaction) (model.GPXDetailResponse, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return model.GPXDetailResponse{}, err
+	}
+
+	doc, err := parseFile(fullPath)
+	if err != nil {
+		return model.GPXDetailResponse{}, err
+	}
+	doc = red.apply(doc)
+
+	file := newGPXFile(relPath)
+	file.Stats = statsDTO(doc.Stats())
+	return model.GPXDetailResponse{File: file, Track: doc}, nil
+}
+
+// GetProfile returns the elevation/speed profile of a library file reduced to
+// at most points samples.
+func (s *Service) GetProfile(relPath string, points int) (model.ProfileResponse, error) {
+	return s.getProfile(relPath, points, nil)
+}
+
+func (s *Service) getProfile(relPath string, points int, red *redaction) (model.ProfileResponse, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return model.ProfileResponse{}, err
+	}
+
+	doc, err := parseFile(fullPath)
+	if err != nil {
+		return model.ProfileResponse{}, err
+	}
+	doc = red.apply(doc)
+
+	stats := doc.Stats()
+	file := newGPXFile(relPath)
+	file.Stats = statsDTO(stats)
+	return model.ProfileResponse{
+		File:        file,
+		TotalPoints: stats.PointCount,
+		Points:      doc.Profile(points),
+	}, nil
+}
+
+func newGPXFile(relPath string) model.GPXFile {
+	file := model.GPXFile{
+		Name:         path.Base(relPath),
+		Path:         "/data/" + relPath,
+		RelativePath: relPath,
+		Format:       track.FormatOf(relPath),
+		Activity:     deriveActivity(relPath),
+	}
+	// The map loads tracks as GPX; other formats are converted on the fly.
+	if file.Format != "gpx" {
+		file.Path += "?format=gpx"
+	}
+	return file
+}
+
+func parseFile(fullPath string) (*track.Document, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return track.Parse(track.FormatOf(fullPath), f)
+}
+
+func statsDTO(st track.Stats) *model.TrackStats {
+	dto := &model.TrackStats{
+		StartTime:     st.StartTime,
+		EndTime:       st.EndTime,
+		Distance:      st.Distance,
+		TotalTime:     st.TotalTime.Seconds(),
+		MovingTime:    st.MovingTime.Seconds(),
+		ElevationGain: st.ElevationGain,
+		ElevationLoss: st.ElevationLoss,
+		PointCount:    st.PointCount,
+	}
+	if st.Bounds != nil {
+		dto.Bounds = &model.BoundsDTO{
+			North: st.Bounds.MaxLat,
+			South: st.Bounds.MinLat,
+			East:  st.Bounds.MaxLon,
+			West:  st.Bounds.MinLon,
 		}
-		if !info.IsDir() {
-			continue
+	}
+	return dto
+}
+
+// resolvePath validates a client-supplied relative path and maps it onto the
+// data dir. Only files under the scanned roots are reachable.
+func (s *Service) resolvePath(relPath string) (string, string, error) {
+	relPath = strings.ReplaceAll(relPath, "\\", "/")
+	if relPath == "" || strings.HasPrefix(relPath, "/") {
+		return "", "", fmt.Errorf("invalid path")
+	}
+	for _, part := range strings.Split(relPath, "/") {
+		if part == ".." {
+			return "", "", fmt.Errorf("invalid path")
 		}
+	}
+	cleaned := path.Clean(relPath)
 
-		err = filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".gpx") {
-				relPath, err := filepath.Rel(s.DataDir, path)
-				if err != nil {
-					return err
-				}
-				relPath = filepath.ToSlash(relPath)
-				files = append(files, model.GPXFile{
-					Name:         d.Name(),
-					Path:         "/data/" + relPath,
-					RelativePath: relPath,
-				})
-			}
-			return nil
-		})
-		if err != nil {
-			return nil, err
+	inRoot := false
+	for _, root := range scanRoots {
+		if strings.HasPrefix(cleaned, root+"/") {
+			inRoot = true
+			break
 		}
 	}
+	if !inRoot || track.FormatOf(cleaned) == "" {
+		return "", "", fmt.Errorf("invalid path")
+	}
 
-	return files, nil
+	return filepath.Join(s.DataDir, filepath.FromSlash(cleaned)), cleaned, nil
 }
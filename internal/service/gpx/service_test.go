@@ -3,7 +3,9 @@ package gpx
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestListFiles(t *testing.T) {
@@ -33,7 +35,7 @@ func TestListFiles(t *testing.T) {
 		}
 	}
 
-	service := NewService(dataDir)
+	service := NewService(dataDir, "")
 	result, err := service.ListFiles()
 	if err != nil {
 		t.Fatalf("ListFiles failed: %v", err)
@@ -63,6 +65,56 @@ func TestListFiles(t *testing.T) {
 	}
 }
 
+func TestListFiles_IncludesStats(t *testing.T) {
+	dataDir := t.TempDir()
+	content := `<gpx version="1.1"><trk><trkseg>
+		<trkpt lat="59.0" lon="24.0"><ele>10</ele><time>2025-06-01T08:00:00Z</time></trkpt>
+		<trkpt lat="59.01" lon="24.0"><ele>30</ele><time>2025-06-01T08:00:10Z</time></trkpt>
+	</trkseg></trk></gpx>`
+	for name, body := range map[string]string{"good.gpx": content, "bad.gpx": "not xml"} {
+		fullPath := filepath.Join(dataDir, "Activities", name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := NewService(dataDir, "").ListFiles()
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(result))
+	}
+
+	for _, f := range result {
+		switch f.Name {
+		case "bad.gpx":
+			if f.Stats != nil {
+				t.Errorf("expected no stats for unparsable file, got %+v", f.Stats)
+			}
+		case "good.gpx":
+			if f.Stats == nil {
+				t.Fatal("expected stats for good.gpx")
+			}
+			if f.Stats.PointCount != 2 || f.Stats.MovingTime != 10 {
+				t.Errorf("unexpected stats: %+v", f.Stats)
+			}
+			if f.Stats.Distance < 1100 || f.Stats.Distance > 1125 {
+				t.Errorf("unexpected distance: %v", f.Stats.Distance)
+			}
+			if f.Stats.StartTime == nil || f.Stats.StartTime.Format("2006-01-02") != "2025-06-01" {
+				t.Errorf("unexpected start time: %v", f.Stats.StartTime)
+			}
+			if f.Stats.Bounds == nil || f.Stats.Bounds.North != 59.01 || f.Stats.Bounds.South != 59.0 {
+				t.Errorf("unexpected bounds: %+v", f.Stats.Bounds)
+			}
+		}
+	}
+}
+
 func TestListFiles_RootsAreFiles(t *testing.T) {
 	dataDir := t.TempDir()
 	// Create "Activities" as a file instead of a directory
@@ -70,7 +122,7 @@ func TestListFiles_RootsAreFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	service := NewService(dataDir)
+	service := NewService(dataDir, "")
 	result, err := service.ListFiles()
 	if err != nil {
 		t.Fatalf("ListFiles failed: %v", err)
@@ -80,3 +132,86 @@ func TestListFiles_RootsAreFiles(t *testing.T) {
 		t.Errorf("expected 0 files, got %d", len(result))
 	}
 }
+
+func TestGetTrack(t *testing.T) {
+	dataDir := t.TempDir()
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="test">
+	<trk><name>Morning</name><trkseg>
+		<trkpt lat="59.1" lon="24.1"><ele>10</ele></trkpt>
+		<trkpt lat="59.2" lon="24.2"><ele>12</ele></trkpt>
+	</trkseg></trk>
+</gpx>`
+	fullPath := filepath.Join(dataDir, "Activities", "Gravel", "ride.gpx")
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "broken.gpx"), []byte("not xml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(dataDir, "")
+	resp, err := service.GetTrack("Activities/Gravel/ride.gpx")
+	if err != nil {
+		t.Fatalf("GetTrack failed: %v", err)
+	}
+	if resp.File.Path != "/data/Activities/Gravel/ride.gpx" || resp.File.Name != "ride.gpx" {
+		t.Errorf("unexpected file info: %+v", resp.File)
+	}
+	if len(resp.Track.Tracks) != 1 || len(resp.Track.Tracks[0].Segments[0].Points) != 2 {
+		t.Fatalf("unexpected track contents: %+v", resp.Track)
+	}
+
+	if _, err := service.GetTrack("Activities/missing.gpx"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err := service.GetTrack("Activities/broken.gpx"); err == nil || !strings.HasPrefix(err.Error(), "invalid gpx") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestGetTrack_RejectsPathsOutsideRoots(t *testing.T) {
+	service := NewService(t.TempDir(), "")
+	paths := []string{
+		"",
+		"/etc/passwd",
+		"../secret.gpx",
+		"Activities/../../secret.gpx",
+		"root.gpx",
+		"Activities/notes.txt",
+		"Cache/tile.gpx",
+	}
+	for _, p := range paths {
+		if _, err := service.GetTrack(p); err == nil || err.Error() != "invalid path" {
+			t.Errorf("GetTrack(%q): expected invalid path error, got %v", p, err)
+		}
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	dataDir := t.TempDir()
+	writeTrack(t, filepath.Join(dataDir, "Activities", "Running", "long.gpx"), lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 40), time.Now())
+
+	service := NewService(dataDir, "")
+	resp, err := service.GetProfile("Activities/Running/long.gpx", 10)
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if resp.TotalPoints != 40 || len(resp.Points) != 10 {
+		t.Fatalf("expected 10 of 40 points, got %d of %d", len(resp.Points), resp.TotalPoints)
+	}
+	last := resp.Points[len(resp.Points)-1]
+	if resp.File.Stats == nil || last.Distance != resp.File.Stats.Distance {
+		t.Errorf("last sample distance %.1f should equal track distance %+v", last.Distance, resp.File.Stats)
+	}
+	if last.Speed == nil {
+		t.Error("expected speed for timed points")
+	}
+
+	if _, err := service.GetProfile("../escape.gpx", 10); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path error, got %v", err)
+	}
+}
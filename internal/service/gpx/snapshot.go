@@ -0,0 +1,113 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
+)
+
+const (
+	maxSnapshotTracks = 50
+	snapshotPadding   = 32 // pixels between the tracks and the edge
+	snapshotMaxZoom   = 17
+	snapshotPen       = 3 // pixels
+	attributionMargin = 3 // pixels around the attribution text
+)
+
+// snapshotColors follow the SPA's multi-track colours, so a snapshot looks
+// like the map it was taken from.
+var snapshotColors = []color.NRGBA{
+	{0x00, 0x00, 0xFF, 0xFF},
+	{0xFF, 0x00, 0x00, 0xFF},
+	{0x00, 0xAA, 0x00, 0xFF},
+	{0x9B, 0x59, 0xB6, 0xFF},
+	{0xF1, 0xC4, 0x0F, 0xFF},
+	{0x00, 0xFF, 0xFF, 0xFF},
+	{0xFF, 0x80, 0x00, 0xFF},
+}
+
+var (
+	snapshotBackground = color.NRGBA{0xDD, 0xDD, 0xDD, 0xFF} // where tiles are missing
+	attributionBox     = color.NRGBA{0xFF, 0xFF, 0xFF, 0xCC}
+	attributionText    = color.NRGBA{0x33, 0x33, 0x33, 0xFF}
+)
+
+// Snapshot renders the files in req over the tiles of base (if not nil) as
+// a PNG, fitted at the highest zoom that shows all of them, with start and
+// end markers per file and the base map attribution in the bottom-right
+// corner. Tiles that base cannot provide are left grey.
+func (s *Service) Snapshot(req model.SnapshotRequest, base *render.Basemap) ([]byte, error) {
+	return s.snapshot(req, base, nil)
+}
+
+func (s *Service) snapshot(req model.SnapshotRequest, base *render.Basemap, red *redaction) ([]byte, error) {
+	if len(req.Paths) == 0 {
+		return nil, fmt.Errorf("invalid snapshot: no tracks")
+	}
+	if len(req.Paths) > maxSnapshotTracks {
+		return nil, fmt.Errorf("invalid snapshot: at most %d tracks", maxSnapshotTracks)
+	}
+	if req.Width <= 0 || req.Height <= 0 {
+		return nil, fmt.Errorf("invalid snapshot: size %dx%d", req.Width, req.Height)
+	}
+
+	tracks := make([][][]track.Point, len(req.Paths))
+	var all [][]track.Point
+	for i, p := range req.Paths {
+		fullPath, _, err := s.resolvePath(p)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		tracks[i] = drawable(red.apply(doc))
+		all = append(all, tracks[i]...)
+	}
+
+	maxZoom := snapshotMaxZoom
+	if base != nil && base.MaxZoom < maxZoom {
+		maxZoom = base.MaxZoom
+	}
+	v := fitLines(all, req.Width, req.Height, snapshotPadding, maxZoom)
+
+	img := image.NewNRGBA(image.Rect(0, 0, req.Width, req.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(snapshotBackground), image.Point{}, draw.Src)
+	if base != nil {
+		v.DrawTiles(img, base.Tile)
+	}
+	for i, lines := range tracks {
+		drawLines(img, v, lines, snapshotPen, snapshotColors[i%len(snapshotColors)])
+	}
+	if base != nil && base.Attribution != "" {
+		drawAttribution(img, base.Attribution)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawAttribution prints text on a light box in the bottom-right corner,
+// where Leaflet puts it, at double size when it fits in half the width.
+func drawAttribution(img draw.Image, text string) {
+	b := img.Bounds()
+	scale := 2
+	if w, _ := render.TextSize(text, scale); w > b.Dx()/2 {
+		scale = 1
+	}
+	w, h := render.TextSize(text, scale)
+	box := image.Rect(b.Max.X-w-2*attributionMargin, b.Max.Y-h-2*attributionMargin, b.Max.X, b.Max.Y)
+	draw.Draw(img, box, image.NewUniform(attributionBox), image.Point{}, draw.Over)
+	render.Text(img, box.Min.X-b.Min.X+attributionMargin, box.Min.Y-b.Min.Y+attributionMargin, text, scale, attributionText)
+}
@@ -0,0 +1,103 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/render"
+)
+
+func TestSnapshot(t *testing.T) {
+	dataDir := t.TempDir()
+	write := func(rel, content string) {
+		full := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Activities/a.gpx", lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 6))
+	write("Activities/b.gpx", `<gpx version="1.1"><trk><trkseg><trkpt lat="59.02" lon="24.0"/><trkpt lat="59.02" lon="24.05"/></trkseg></trk></gpx>`)
+	service := NewService(dataDir, "")
+
+	// Every other tile is missing, as when the cache is cold and the server
+	// offline.
+	white := image.NewUniform(color.White)
+	var tiles []string
+	base := &render.Basemap{Key: "test", MaxZoom: 19, Attribution: "© Test", Tile: func(z, x, y int) (image.Image, error) {
+		tiles = append(tiles, fmt.Sprintf("%d/%d/%d", z, x, y))
+		if len(tiles)%2 == 0 {
+			return nil, fmt.Errorf("offline mode")
+		}
+		return white, nil
+	}}
+
+	data, err := service.Snapshot(model.SnapshotRequest{Paths: []string{"Activities/a.gpx", "Activities/b.gpx"}, Width: 400, Height: 300}, base)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() != 400 || img.Bounds().Dy() != 300 {
+		t.Fatalf("expected a 400x300 PNG: %v", err)
+	}
+	if len(tiles) < 2 {
+		t.Fatalf("expected several tiles, got %v", tiles)
+	}
+
+	counts := make(map[color.NRGBA]int)
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			counts[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+		}
+	}
+	for name, c := range map[string]color.NRGBA{
+		"first track":  snapshotColors[0],
+		"second track": snapshotColors[1],
+		"start marker": startColor,
+		"tile":         {255, 255, 255, 255},
+		"missing tile": snapshotBackground,
+	} {
+		if counts[c] == 0 {
+			t.Errorf("expected pixels of the %s", name)
+		}
+	}
+	// The attribution text sits in the bottom-right corner.
+	text := 0
+	for y := 280; y < 300; y++ {
+		for x := 300; x < 400; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA) == attributionText {
+				text++
+			}
+		}
+	}
+	if text == 0 {
+		t.Error("expected the attribution in the corner")
+	}
+
+	if _, err := service.Snapshot(model.SnapshotRequest{Paths: []string{"Activities/a.gpx"}, Width: 200, Height: 200}, nil); err != nil {
+		t.Errorf("a snapshot without a base map failed: %v", err)
+	}
+	for _, tt := range []struct {
+		req  model.SnapshotRequest
+		want string
+	}{
+		{model.SnapshotRequest{Width: 100, Height: 100}, "invalid snapshot: no tracks"},
+		{model.SnapshotRequest{Paths: make([]string, 51), Width: 100, Height: 100}, "invalid snapshot: at most 50 tracks"},
+		{model.SnapshotRequest{Paths: []string{"Activities/missing.gpx"}, Width: 100, Height: 100}, "not found"},
+		{model.SnapshotRequest{Paths: []string{"../a.gpx"}, Width: 100, Height: 100}, "invalid path"},
+	} {
+		if _, err := service.Snapshot(tt.req, nil); err == nil || err.Error() != tt.want {
+			t.Errorf("expected %q, got %v", tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package gpx
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/spatial"
+	"gpx-self-host/internal/track"
+)
+
+// Chunks are kept small enough that a chunk lying fully inside the query area
+// is common, which lets most matches skip re-reading the file.
+const (
+	chunkMaxPoints = 64
+	chunkMaxSpan   = 1000.0 // meters
+	chunkPrecision = 1e5    // stored bounds are rounded outward to ~1 m
+
+	defaultNearRadius = 500.0 // meters
+)
+
+// chunkBounds is the compact on-disk form of a chunk: minLon, minLat,
+// maxLon, maxLat.
+type chunkBounds [4]float64
+
+// chunkRef maps an R-tree item back to its file.
+type chunkRef struct {
+	relPath string
+	bounds  chunkBounds
+}
+
+func (c chunkBounds) rect() spatial.Rect {
+	return spatial.Rect{MinX: c[0], MinY: c[1], MaxX: c[2], MaxY: c[3]}
+}
+
+// spatialLines returns the geometry used for spatial search: routes and track
+// segments, or the waypoints when a file has nothing else, mirroring how the
+// listing bounds are computed.
+func spatialLines(doc *track.Document) [][]track.Point {
+	lines := doc.Lines()
+	if len(lines) > 0 {
+		return lines
+	}
+	for _, w := range doc.Waypoints {
+		lines = append(lines, []track.Point{w})
+	}
+	return lines
+}
+
+func chunksFor(doc *track.Document) []chunkBounds {
+	var chunks []chunkBounds
+	for _, line := range spatialLines(doc) {
+		for _, b := range track.ChunkBounds(line, chunkMaxPoints, chunkMaxSpan) {
+			chunks = append(chunks, chunkBounds{
+				math.Floor(b.MinLon*chunkPrecision) / chunkPrecision,
+				math.Floor(b.MinLat*chunkPrecision) / chunkPrecision,
+				math.Ceil(b.MaxLon*chunkPrecision) / chunkPrecision,
+				math.Ceil(b.MaxLat*chunkPrecision) / chunkPrecision,
+			})
+		}
+	}
+	return chunks
+}
+
+// spatialFilter describes a bbox or near query. Boxes holds one rectangle,
+// or two when the area crosses the antimeridian.
+type spatialFilter struct {
+	boxes []track.Bounds
+
+	near             bool
+	lat, lon, radius float64
+}
+
+func parseSpatial(values map[string][]string) (*spatialFilter, error) {
+	get := func(key string) string {
+		if v := values[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	bbox, near, radius := get("bbox"), get("near"), get("radius")
+
+	switch {
+	case bbox != "" && near != "":
+		return nil, fmt.Errorf("invalid query: bbox and near cannot be combined")
+	case radius != "" && near == "":
+		return nil, fmt.Errorf("invalid query: radius requires near")
+	case bbox != "":
+		nums, err := parseFloats(bbox, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: bbox %q", bbox)
+		}
+		b := model.BoundsDTO{West: nums[0], South: nums[1], East: nums[2], North: nums[3]}
+		if !validLat(b.South) || !validLat(b.North) || !validLon(b.West) || !validLon(b.East) || b.South > b.North {
+			return nil, fmt.Errorf("invalid query: bbox %q", bbox)
+		}
+		return &spatialFilter{boxes: boundsBoxes(b)}, nil
+	case near != "":
+		nums, err := parseFloats(near, 2)
+		if err != nil || !validLat(nums[0]) || !validLon(nums[1]) {
+			return nil, fmt.Errorf("invalid query: near %q", near)
+		}
+		r := defaultNearRadius
+		if radius != "" {
+			if r, err = ParseDistance(radius); err != nil || r <= 0 {
+				return nil, fmt.Errorf("invalid query: radius %q", radius)
+			}
+		}
+		f := &spatialFilter{near: true, lat: nums[0], lon: nums[1], radius: r}
+		f.boxes = boundsBoxes(circleBounds(f.lat, f.lon, r))
+		return f, nil
+	}
+	return nil, nil
+}
+
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d numbers", n)
+	}
+	nums := make([]float64, n)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid number %q", p)
+		}
+		nums[i] = v
+	}
+	return nums, nil
+}
+
+func validLat(v float64) bool { return v >= -90 && v <= 90 }
+func validLon(v float64) bool { return v >= -180 && v <= 180 }
+
+// circleBounds returns a box enclosing the circle, with longitudes possibly
+// outside ±180 when it wraps.
+func circleBounds(lat, lon, radius float64) model.BoundsDTO {
+	dLat := radius / 6371000.0 * 180 / math.Pi
+	south, north := math.Max(lat-dLat, -90), math.Min(lat+dLat, 90)
+	cos := math.Cos(lat * math.Pi / 180)
+	if cos < 1e-6 || north == 90 || south == -90 {
+		return model.BoundsDTO{West: -180, South: south, East: 180, North: north}
+	}
+	dLon := math.Min(dLat/cos, 180)
+	return model.BoundsDTO{West: lon - dLon, South: south, East: lon + dLon, North: north}
+}
+
+// boundsBoxes splits a box that crosses the antimeridian (west > east, or
+// longitudes beyond ±180) into two.
+func boundsBoxes(b model.BoundsDTO) []track.Bounds {
+	west, east := b.West, b.East
+	if west < -180 {
+		west += 360
+	}
+	if east > 180 {
+		east -= 360
+	}
+	if west <= east {
+		return []track.Bounds{{MinLat: b.South, MinLon: west, MaxLat: b.North, MaxLon: east}}
+	}
+	return []track.Bounds{
+		{MinLat: b.South, MinLon: west, MaxLat: b.North, MaxLon: 180},
+		{MinLat: b.South, MinLon: -180, MaxLat: b.North, MaxLon: east},
+	}
+}
+
+// contains reports whether every point of the chunk is known to match, so the
+// file need not be read.
+func (f *spatialFilter) contains(c chunkBounds) bool {
+	if f.near {
+		for _, corner := range [4][2]float64{{c[1], c[0]}, {c[1], c[2]}, {c[3], c[0]}, {c[3], c[2]}} {
+			if track.Haversine(f.lat, f.lon, corner[0], corner[1]) > f.radius {
+				return false
+			}
+		}
+		return true
+	}
+	for _, b := range f.boxes {
+		if spatialRect(b).Contains(c.rect()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLines checks the actual geometry.
+func (f *spatialFilter) matchesLines(lines [][]track.Point) bool {
+	for _, line := range lines {
+		if len(line) == 1 {
+			line = []track.Point{line[0], line[0]}
+		}
+		for i := 1; i < len(line); i++ {
+			a, b := line[i-1], line[i]
+			if f.near {
+				if track.DistanceToSegment(f.lat, f.lon, a, b) <= f.radius {
+					return true
+				}
+				continue
+			}
+			for _, box := range f.boxes {
+				if track.SegmentIntersectsBounds(a, b, box) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func spatialRect(b track.Bounds) spatial.Rect {
+	return spatial.Rect{MinX: b.MinLon, MinY: b.MinLat, MaxX: b.MaxLon, MaxY: b.MaxLat}
+}
+
+// spatialMatches returns the relative paths of files whose geometry
+// intersects the filter area. Candidates come from the R-tree; files with a
+// chunk fully inside the area match outright, the rest are re-read and
+// checked segment by segment. With a redaction the chunks, which cover the
+// full geometry, only pick candidates: every one is re-read and checked
+// after the hidden points are removed, so a small area around a hidden point
+// matches nothing.
+func (s *Service) spatialMatches(f *spatialFilter, red *redaction) map[string]bool {
+	s.mu.Lock()
+	if s.tree == nil {
+		s.buildTree()
+	}
+	matched := make(map[string]bool)
+	candidates := make(map[string]bool)
+	for _, box := range f.boxes {
+		s.tree.Search(spatialRect(box), func(it spatial.Item) bool {
+			ref := s.treeRefs[it.ID]
+			relPath := ref.relPath
+			if matched[relPath] {
+				return true
+			}
+			if red == nil && f.contains(ref.bounds) {
+				matched[relPath] = true
+				delete(candidates, relPath)
+			} else {
+				candidates[relPath] = true
+			}
+			return true
+		})
+	}
+	s.mu.Unlock()
+
+	for relPath := range candidates {
+		doc, err := parseFile(filepath.Join(s.DataDir, filepath.FromSlash(relPath)))
+		if err != nil {
+			continue
+		}
+		if f.matchesLines(spatialLines(red.apply(doc))) {
+			matched[relPath] = true
+		}
+	}
+	return matched
+}
+
+// buildTree packs every indexed chunk into a fresh R-tree. Callers must hold
+// s.mu.
+func (s *Service) buildTree() {
+	var items []spatial.Item
+	s.treeRefs = s.treeRefs[:0]
+	for relPath, entry := range s.entries {
+		for _, c := range entry.Chunks {
+			items = append(items, spatial.Item{Rect: c.rect(), ID: len(s.treeRefs)})
+			s.treeRefs = append(s.treeRefs, chunkRef{relPath: relPath, bounds: c})
+		}
+	}
+	s.tree = spatial.NewRTree(items)
+}
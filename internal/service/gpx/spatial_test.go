@@ -0,0 +1,127 @@
+package gpx
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The spatial library has three tracks around Tallinn plus one on either side
+// of the antimeridian:
+//
+//	north: a long northbound line along lon 24.0 from lat 59.0 to 59.5
+//	east:  a short east-west line at lat 59.2 from lon 24.10 to 24.12
+//	diag:  a single segment from (59.30, 24.20) to (59.40, 24.30)
+//	fiji:  a segment at lat -17 from lon 179.98 to 179.99
+//	samoa: a segment at lat -17 from lon -179.99 to -179.98
+func newSpatialLibrary(t *testing.T) *Service {
+	t.Helper()
+	dataDir := t.TempDir()
+	var north strings.Builder
+	north.WriteString(`<gpx version="1.1"><trk><trkseg>`)
+	for i := 0; i <= 500; i++ {
+		fmt.Fprintf(&north, `<trkpt lat="%.3f" lon="24.0"/>`, 59+float64(i)*0.001)
+	}
+	north.WriteString(`</trkseg></trk></gpx>`)
+
+	tracks := map[string]string{
+		"Activities/Running/north.gpx": north.String(),
+		"Activities/Running/east.gpx":  `<gpx version="1.1"><trk><trkseg><trkpt lat="59.2" lon="24.10"/><trkpt lat="59.2" lon="24.12"/></trkseg></trk></gpx>`,
+		"Plans/diag.gpx":               `<gpx version="1.1"><rte><rtept lat="59.30" lon="24.20"/><rtept lat="59.40" lon="24.30"/></rte></gpx>`,
+		"Activities/Sailing/fiji.gpx":  `<gpx version="1.1"><trk><trkseg><trkpt lat="-17" lon="179.98"/><trkpt lat="-17" lon="179.99"/></trkseg></trk></gpx>`,
+		"Activities/Sailing/samoa.gpx": `<gpx version="1.1"><trk><trkseg><trkpt lat="-17" lon="-179.99"/><trkpt lat="-17" lon="-179.98"/></trkseg></trk></gpx>`,
+		"Activities/Running/empty.gpx": `<gpx version="1.1"></gpx>`,
+	}
+	for relPath, content := range tracks {
+		writeTrack(t, filepath.Join(dataDir, filepath.FromSlash(relPath)), content, time.Now())
+	}
+	return NewService(dataDir, t.TempDir())
+}
+
+func TestSearch_Spatial(t *testing.T) {
+	s := newSpatialLibrary(t)
+
+	tests := []struct {
+		name     string
+		params   string
+		expected []string
+	}{
+		{"BBox containing a chunk", "bbox=23.99,59.10,24.01,59.11", []string{"north.gpx"}},
+		{"BBox between points of a segment", "bbox=24.105,59.19,24.115,59.21", []string{"east.gpx"}},
+		{"BBox crossing a segment without vertices", "bbox=24.24,59.34,24.26,59.36", []string{"diag.gpx"}},
+		{"BBox near but off the diagonal", "bbox=24.28,59.30,24.30,59.32", nil},
+		{"BBox covering everything in Estonia", "bbox=23,58,25,60", []string{"diag.gpx", "east.gpx", "north.gpx"}},
+		{"BBox across the antimeridian", "bbox=179.95,-18,-179.95,-16", []string{"fiji.gpx", "samoa.gpx"}},
+		{"Near with default radius", "near=59.2,24.111", []string{"east.gpx"}},
+		{"Near out of range", "near=59.2,24.05&radius=1km", nil},
+		{"Near with larger radius", "near=59.2,24.05&radius=3km", []string{"east.gpx", "north.gpx"}},
+		{"Near combined with query", "near=59.2,24.05&radius=3km&q=east", []string{"east.gpx"}},
+		{"Near across the antimeridian", "near=-17,180&radius=2km", []string{"fiji.gpx", "samoa.gpx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, total, err := s.Search(params)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, f.Name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") || total != len(tt.expected) {
+				t.Errorf("expected %v, got %v (total %d)", tt.expected, got, total)
+			}
+		})
+	}
+}
+
+func TestSearch_SpatialSeesChanges(t *testing.T) {
+	s := newSpatialLibrary(t)
+	params := url.Values{"near": {"40.0,-3.7"}}
+
+	if files, _, err := s.Search(params); err != nil || len(files) != 0 {
+		t.Fatalf("expected no matches, got %v (%v)", files, err)
+	}
+
+	writeTrack(t, filepath.Join(s.DataDir, "Activities", "Running", "madrid.gpx"),
+		`<gpx version="1.1"><wpt lat="40.0" lon="-3.7"/></gpx>`, time.Now())
+
+	files, _, err := s.Search(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "madrid.gpx" {
+		t.Errorf("expected the new waypoint file to match, got %v", files)
+	}
+}
+
+func TestSearch_InvalidSpatialQuery(t *testing.T) {
+	s := newSpatialLibrary(t)
+
+	for _, params := range []string{
+		"bbox=1,2,3",
+		"bbox=a,b,c,d",
+		"bbox=0,10,1,5",
+		"bbox=0,0,200,1",
+		"near=95,0",
+		"near=59",
+		"near=59,24&radius=-1",
+		"radius=5km",
+		"bbox=0,0,1,1&near=0,0",
+	} {
+		values, _ := url.ParseQuery(params)
+		if _, _, err := s.Search(values); err == nil || !strings.HasPrefix(err.Error(), "invalid query") {
+			t.Errorf("%s: expected invalid query error, got %v", params, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package gpx
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gpx-self-host/internal/model"
+)
+
+// groupKeys turns a file into its value for each groupBy dimension. Date
+// dimensions use the same date as the listing (start time, else the
+// filename prefix) in local time; weeks are ISO 8601 weeks.
+var groupKeys = map[string]func(f model.GPXFile) string{
+	"activity": func(f model.GPXFile) string { return f.Activity },
+	"year": func(f model.GPXFile) string {
+		if d, ok := fileDate(f); ok {
+			return strconv.Itoa(d.Year())
+		}
+		return ""
+	},
+	"month": func(f model.GPXFile) string {
+		if d, ok := fileDate(f); ok {
+			return d.Format("2006-01")
+		}
+		return ""
+	},
+	"week": func(f model.GPXFile) string {
+		if d, ok := fileDate(f); ok {
+			year, week := d.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week)
+		}
+		return ""
+	},
+}
+
+// StatsSummary totals distance, moving time, elevation gain and count over
+// the activities matching the /api/gpx search parameters (files under Plans/
+// and duplicates of other files are never counted), grouped by the
+// comma-separated groupBy dimensions: activity, year, month and week. Groups
+// are sorted by key in groupBy order, undated groups last.
+func (s *Service) StatsSummary(values url.Values) (model.StatsSummaryResponse, error) {
+	return s.statsSummary(values, nil)
+}
+
+func (s *Service) statsSummary(values url.Values, red *redaction) (model.StatsSummaryResponse, error) {
+	var groupBy []string
+	for _, v := range values["groupBy"] {
+		for _, dim := range strings.Split(v, ",") {
+			if dim = strings.TrimSpace(dim); dim == "" {
+				continue
+			}
+			if groupKeys[dim] == nil {
+				return model.StatsSummaryResponse{}, fmt.Errorf("invalid query: groupBy %q", dim)
+			}
+			if containsString(groupBy, dim) {
+				return model.StatsSummaryResponse{}, fmt.Errorf("invalid query: groupBy %q is listed twice", dim)
+			}
+			groupBy = append(groupBy, dim)
+		}
+	}
+	q, err := ParseQuery(values)
+	if err != nil {
+		return model.StatsSummaryResponse{}, err
+	}
+	q.HideDuplicates = true
+	files, err := s.filter(q, red)
+	if err != nil {
+		return model.StatsSummaryResponse{}, err
+	}
+
+	resp := model.StatsSummaryResponse{GroupBy: groupBy, Groups: []model.StatsGroup{}}
+	if resp.GroupBy == nil {
+		resp.GroupBy = []string{}
+	}
+	groups := make(map[string]*model.StatsGroup)
+	for _, f := range files {
+		if f.Activity == "Plans" {
+			continue
+		}
+		key := make(map[string]string, len(groupBy))
+		parts := make([]string, len(groupBy))
+		for i, dim := range groupBy {
+			key[dim] = groupKeys[dim](f)
+			parts[i] = key[dim]
+		}
+		id := strings.Join(parts, "\x00")
+		g := groups[id]
+		if g == nil {
+			g = &model.StatsGroup{Key: key}
+			groups[id] = g
+		}
+		addStats(&g.StatsTotals, f)
+		addStats(&resp.Total, f)
+	}
+
+	for _, g := range groups {
+		resp.Groups = append(resp.Groups, *g)
+	}
+	sort.Slice(resp.Groups, func(i, j int) bool {
+		a, b := resp.Groups[i].Key, resp.Groups[j].Key
+		for _, dim := range groupBy {
+			if a[dim] == b[dim] {
+				continue
+			}
+			if a[dim] == "" || b[dim] == "" {
+				return b[dim] == ""
+			}
+			if la, lb := strings.ToLower(a[dim]), strings.ToLower(b[dim]); la != lb {
+				return la < lb
+			}
+			return a[dim] < b[dim]
+		}
+		return false
+	})
+	return resp, nil
+}
+
+func addStats(t *model.StatsTotals, f model.GPXFile) {
+	t.Count++
+	if f.Stats == nil {
+		return
+	}
+	t.Distance += f.Stats.Distance
+	t.MovingTime += f.Stats.MovingTime
+	t.ElevationGain += f.Stats.ElevationGain
+}
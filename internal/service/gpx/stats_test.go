@@ -0,0 +1,76 @@
+package gpx
+
+import (
+	"math"
+	"net/url"
+	"testing"
+)
+
+func TestStatsSummary(t *testing.T) {
+	s := newQueryLibrary(t)
+	files, err := s.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	distance := make(map[string]float64)
+	for _, f := range files {
+		distance[f.Name] = f.Stats.Distance
+	}
+
+	resp, err := s.StatsSummary(url.Values{"groupBy": {"activity,year"}})
+	if err != nil {
+		t.Fatalf("StatsSummary failed: %v", err)
+	}
+	type row struct {
+		activity, year string
+		count          int
+		distance       float64
+	}
+	expected := []row{
+		{"Gravel", "2025", 1, distance["long ride.gpx"]},
+		{"loose.gpx", "2023", 1, distance["loose.gpx"]},
+		{"Running", "2024", 1, distance["city.gpx"]},
+		{"Running", "2025", 1, distance["lake loop.gpx"]},
+	}
+	if len(resp.Groups) != len(expected) {
+		t.Fatalf("expected %d groups (plans left out), got %+v", len(expected), resp.Groups)
+	}
+	for i, want := range expected {
+		g := resp.Groups[i]
+		if g.Key["activity"] != want.activity || g.Key["year"] != want.year || g.Count != want.count || g.Distance != want.distance {
+			t.Errorf("group %d: expected %+v, got %+v", i, want, g)
+		}
+		if g.MovingTime <= 0 {
+			t.Errorf("group %d: expected moving time", i)
+		}
+	}
+	total := distance["long ride.gpx"] + distance["loose.gpx"] + distance["city.gpx"] + distance["lake loop.gpx"]
+	if resp.Total.Count != 4 || math.Abs(resp.Total.Distance-total) > 1e-6 {
+		t.Errorf("unexpected total %+v", resp.Total)
+	}
+
+	// Filters narrow the set; month and week keys follow the activity date.
+	resp, err = s.StatsSummary(url.Values{"groupBy": {"month", "week"}, "q": {"activity:running"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Groups) != 2 || resp.Groups[0].Key["month"] != "2024-03" || resp.Groups[0].Key["week"] != "2024-W09" ||
+		resp.Groups[1].Key["month"] != "2025-06" || resp.Groups[1].Key["week"] != "2025-W24" {
+		t.Errorf("unexpected month/week groups %+v", resp.Groups)
+	}
+
+	resp, err = s.StatsSummary(url.Values{})
+	if err != nil || len(resp.Groups) != 1 || resp.Groups[0].Count != 4 || len(resp.GroupBy) != 0 {
+		t.Errorf("expected a single group without groupBy, got %+v %v", resp, err)
+	}
+
+	for _, bad := range []url.Values{
+		{"groupBy": {"day"}},
+		{"groupBy": {"year,year"}},
+		{"groupBy": {"year"}, "q": {"year:soon"}},
+	} {
+		if _, err := s.StatsSummary(bad); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
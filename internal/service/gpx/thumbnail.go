@@ -0,0 +1,221 @@
+package gpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
+)
+
+const (
+	thumbnailPadding = 8  // pixels between the track and the edge
+	thumbnailMaxZoom = 16 // short tracks are not blown up past street level
+)
+
+// Colours match the SPA: its first track colour, with green/red start and
+// end markers.
+var (
+	trackColor = color.NRGBA{0, 0, 255, 255}
+	haloColor  = color.NRGBA{255, 255, 255, 200}
+	startColor = color.NRGBA{0, 170, 0, 255}
+	endColor   = color.NRGBA{255, 0, 0, 255}
+)
+
+// Thumbnail renders a size×size mini-map of a file's routes and track
+// segments as "png" or "svg", over the tiles of base when it is not nil.
+// Results are cached by file contents, so they are replaced when the file
+// changes; a thumbnail missing some base map tiles is not cached.
+func (s *Service) Thumbnail(relPath, format string, size int, base *render.Basemap) ([]byte, error) {
+	return s.thumbnail(relPath, format, size, base, nil)
+}
+
+func (s *Service) thumbnail(relPath, format string, size int, base *render.Basemap, red *redaction) ([]byte, error) {
+	if format != "png" && format != "svg" {
+		return nil, fmt.Errorf("invalid format")
+	}
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, err
+	}
+
+	baseKey := "plain"
+	if base != nil {
+		baseKey = base.Key
+	}
+	sum := sha256.Sum256(data)
+	cachePath := s.derivedPath("thumbnails", hex.EncodeToString(sum[:]),
+		red.cacheKey()+strconv.Itoa(size)+"-"+baseKey+"."+format)
+	if cachePath != "" {
+		if cached, err := os.ReadFile(cachePath); err == nil {
+			return cached, nil
+		}
+	}
+
+	doc, err := track.Parse(track.FormatOf(relPath), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	lines := drawable(red.apply(doc))
+
+	maxZoom := thumbnailMaxZoom
+	if base != nil && base.MaxZoom < maxZoom {
+		maxZoom = base.MaxZoom
+	}
+	v := fitLines(lines, size, size, thumbnailPadding, maxZoom)
+
+	var baseImg *image.NRGBA
+	complete := true
+	if base != nil {
+		baseImg = image.NewNRGBA(image.Rect(0, 0, size, size))
+		complete = v.DrawTiles(baseImg, base.Tile)
+	}
+
+	var out []byte
+	if format == "png" {
+		img := baseImg
+		if img == nil {
+			img = image.NewNRGBA(image.Rect(0, 0, size, size))
+		}
+		drawLines(img, v, lines, 2, trackColor)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	} else {
+		if out, err = svgLines(v, lines, baseImg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cachePath != "" && complete {
+		if err := writeFileAtomic(cachePath, out); err != nil {
+			slog.Warn("Failed to cache thumbnail", "path", cachePath, "error", err)
+		}
+	}
+	return out, nil
+}
+
+// drawable returns the lines of a document to draw on a map; files with
+// only waypoints show them as dots.
+func drawable(doc *track.Document) [][]track.Point {
+	lines := doc.Lines()
+	if len(lines) == 0 {
+		for _, w := range doc.Waypoints {
+			lines = append(lines, []track.Point{w})
+		}
+	}
+	return lines
+}
+
+// fitLines returns the viewport showing all lines; without any points it
+// shows the whole world.
+func fitLines(lines [][]track.Point, w, h, padding, maxZoom int) render.Viewport {
+	minLat, minLon, maxLat, maxLon := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, line := range lines {
+		for _, p := range line {
+			minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+			minLon, maxLon = math.Min(minLon, p.Lon), math.Max(maxLon, p.Lon)
+		}
+	}
+	if math.IsInf(minLat, 1) {
+		return render.Fit(-85, -180, 85, 180, w, h, 0, 0)
+	}
+	return render.Fit(minLat, minLon, maxLat, maxLon, w, h, padding, maxZoom)
+}
+
+func projectLine(v render.Viewport, line []track.Point) [][2]float64 {
+	pts := make([][2]float64, len(line))
+	for i, p := range line {
+		x, y := v.Project(p.Lat, p.Lon)
+		pts[i] = [2]float64{x, y}
+	}
+	return pts
+}
+
+// drawLines draws the lines in colour c on a light halo that keeps them
+// visible over any base map, then marks where the first line starts and the
+// last one ends.
+func drawLines(img draw.Image, v render.Viewport, lines [][]track.Point, pen int, c color.Color) {
+	projected := make([][][2]float64, len(lines))
+	for i, line := range lines {
+		projected[i] = projectLine(v, line)
+	}
+	for _, pts := range projected {
+		render.Stroke(img, pts, pen+2, haloColor)
+	}
+	for _, pts := range projected {
+		render.Stroke(img, pts, pen, c)
+	}
+	if len(projected) == 0 {
+		return
+	}
+	r := float64(pen) + 1
+	first, last := projected[0][0], projected[len(projected)-1][len(projected[len(projected)-1])-1]
+	render.Disc(img, first[0], first[1], r+1, haloColor)
+	render.Disc(img, first[0], first[1], r, startColor)
+	render.Disc(img, last[0], last[1], r+1, haloColor)
+	render.Disc(img, last[0], last[1], r, endColor)
+}
+
+// svgLines is the vector counterpart of drawLines; a base map is embedded
+// as a PNG image.
+func svgLines(v render.Viewport, lines [][]track.Point, base *image.NRGBA) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, v.W, v.H, v.W, v.H)
+	if base != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, base); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, `<image width="%d" height="%d" href="data:image/png;base64,%s"/>`, v.W, v.H, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	var d strings.Builder
+	var first, last [2]float64
+	for i, line := range lines {
+		pts := projectLine(v, line)
+		if i == 0 {
+			first = pts[0]
+		}
+		last = pts[len(pts)-1]
+		for j, p := range pts {
+			cmd := "L"
+			if j == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&d, "%s%.1f %.1f", cmd, p[0], p[1])
+		}
+		if len(pts) == 1 {
+			d.WriteString("h0") // a lone point still gets a round cap
+		}
+	}
+	if d.Len() > 0 {
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#fff" stroke-opacity="0.8" stroke-width="4" stroke-linecap="round" stroke-linejoin="round"/>`, d.String())
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#00f" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>`, d.String())
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#0a0" stroke="#fff"/>`, first[0], first[1])
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#f00" stroke="#fff"/>`, last[0], last[1])
+	}
+	b.WriteString("</svg>")
+	return []byte(b.String()), nil
+}
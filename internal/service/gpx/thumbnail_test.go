@@ -0,0 +1,134 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/render"
+	"gpx-self-host/internal/track"
+)
+
+func TestThumbnail(t *testing.T) {
+	dataDir := t.TempDir()
+	cacheDir := t.TempDir()
+	full := filepath.Join(dataDir, "Activities", "run.gpx")
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 6)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(dataDir, cacheDir)
+	if _, err := service.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	cached := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(cacheDir, "thumbnails", "*", "*"))
+		return matches
+	}
+
+	data, err := service.Thumbnail("Activities/run.gpx", "png", 96, nil)
+	if err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() != 96 || img.Bounds().Dy() != 96 {
+		t.Fatalf("expected a 96px PNG: %v", err)
+	}
+	// The track runs north, so it starts at the bottom and ends at the top.
+	var blue, green, red int
+	for y := 0; y < 96; y++ {
+		for x := 0; x < 96; x++ {
+			switch color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA) {
+			case trackColor:
+				blue++
+			case startColor:
+				if y < 48 {
+					t.Errorf("start marker at the top: %d,%d", x, y)
+				}
+				green++
+			case endColor:
+				if y > 48 {
+					t.Errorf("end marker at the bottom: %d,%d", x, y)
+				}
+				red++
+			}
+		}
+	}
+	if blue < 40 || green == 0 || red == 0 {
+		t.Errorf("expected the track with start and end markers, got %d/%d/%d pixels", blue, green, red)
+	}
+	if len(cached()) != 1 {
+		t.Errorf("expected the thumbnail in the cache, got %v", cached())
+	}
+
+	svg, err := service.Thumbnail("Activities/run.gpx", "svg", 96, nil)
+	if err != nil || !strings.HasPrefix(string(svg), "<svg") || !strings.Contains(string(svg), `<path d="M`) {
+		t.Errorf("expected an SVG with the track path, got %v %.80s", err, svg)
+	}
+
+	// Base map tiles show under the track; an incomplete base map is not
+	// cached so the thumbnail fills in once the tiles are downloaded.
+	grey := image.NewUniform(color.NRGBA{200, 200, 200, 255})
+	tiles := 0
+	base := &render.Basemap{Key: "test", MaxZoom: 19, Tile: func(z, x, y int) (image.Image, error) {
+		tiles++
+		if tiles == 1 {
+			return nil, fmt.Errorf("tile not cached")
+		}
+		return grey, nil
+	}}
+	before := len(cached())
+	if _, err := service.Thumbnail("Activities/run.gpx", "png", 96, base); err != nil {
+		t.Fatal(err)
+	}
+	if len(cached()) != before {
+		t.Error("a thumbnail with missing tiles must not be cached")
+	}
+	data, err = service.Thumbnail("Activities/run.gpx", "png", 96, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _ = png.Decode(bytes.NewReader(data))
+	if c := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); c != (color.NRGBA{200, 200, 200, 255}) {
+		t.Errorf("expected the base map in the corner, got %v", c)
+	}
+	if len(cached()) != before+1 {
+		t.Error("expected the complete thumbnail in the cache")
+	}
+
+	// Editing the file drops its thumbnails with the next refresh.
+	if err := os.WriteFile(full, []byte(lineTrack(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), 4)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cached()) != 0 {
+		t.Errorf("expected stale thumbnails to be pruned, got %v", cached())
+	}
+
+	if _, err := service.Thumbnail("Activities/run.gpx", "jpg", 96, nil); err == nil || err.Error() != "invalid format" {
+		t.Errorf("expected invalid format, got %v", err)
+	}
+	if _, err := service.Thumbnail("Activities/missing.gpx", "png", 96, nil); err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := service.Thumbnail("../run.gpx", "png", 96, nil); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path, got %v", err)
+	}
+
+	redacted := service.Redacted([]track.PrivacyZone{{Lat: 59, Lon: 24, Radius: 100000}}, false)
+	svg, err = redacted.Thumbnail("Activities/run.gpx", "svg", 96, nil)
+	if err != nil || strings.Contains(string(svg), "<path") {
+		t.Errorf("expected an empty redacted thumbnail, got %v %s", err, svg)
+	}
+}
@@ -0,0 +1,205 @@
+package gpx
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+// trashDir lives in the data dir, outside the scan roots, so deleted files
+// drop out of the library but stay on the same filesystem and can be moved
+// back with a rename. Each deleted file gets its own item directory, named by
+// its ID, that mirrors the file's original relative path.
+const trashDir = ".trash"
+
+// Delete moves a library file into the trash.
+func (s *Service) Delete(relPath string) (model.TrashItem, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return model.TrashItem{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkConfined(fullPath); err != nil {
+		return model.TrashItem{}, err
+	}
+	if err := statFile(fullPath); err != nil {
+		return model.TrashItem{}, err
+	}
+
+	id, err := s.moveToTrash(fullPath, relPath)
+	if err != nil {
+		return model.TrashItem{}, err
+	}
+	s.removeEmptyDirs(filepath.Dir(fullPath))
+
+	if _, err := s.refresh(); err != nil {
+		return model.TrashItem{}, err
+	}
+	return s.trashItem(id)
+}
+
+// moveToTrash moves a file into a new trash item and returns the item's ID.
+// Callers must hold s.mu.
+func (s *Service) moveToTrash(fullPath, relPath string) (string, error) {
+	now := time.Now()
+	id := strconv.FormatInt(now.UnixNano(), 36)
+	itemDir := filepath.Join(s.DataDir, trashDir, id)
+	for {
+		if _, err := os.Lstat(itemDir); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Nanosecond)
+		id = strconv.FormatInt(now.UnixNano(), 36)
+		itemDir = filepath.Join(s.DataDir, trashDir, id)
+	}
+	target := filepath.Join(itemDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(fullPath, target); err != nil {
+		os.RemoveAll(itemDir)
+		return "", err
+	}
+	return id, nil
+}
+
+// ListTrash returns the deleted files, most recently deleted first.
+func (s *Service) ListTrash() ([]model.TrashItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.DataDir, trashDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.TrashItem{}, nil
+		}
+		return nil, err
+	}
+	items := make([]model.TrashItem, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() || !validTrashID(e.Name()) {
+			continue
+		}
+		item, err := s.trashItem(e.Name())
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// RestoreTrash moves a deleted file back to where it was. If that path has
+// been taken in the meantime, the restored file gets a " (2)" style suffix.
+func (s *Service) RestoreTrash(id string) (model.GPXFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.trashItem(id)
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+	if _, _, err := s.resolvePath(item.RelativePath); err != nil {
+		return model.GPXFile{}, err
+	}
+	relPath, err := s.freePath(item.RelativePath)
+	if err != nil {
+		return model.GPXFile{}, err
+	}
+	fullPath := filepath.Join(s.DataDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return model.GPXFile{}, err
+	}
+	if err := s.checkConfined(fullPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	itemDir := filepath.Join(s.DataDir, trashDir, id)
+	if err := os.Rename(filepath.Join(itemDir, filepath.FromSlash(item.RelativePath)), fullPath); err != nil {
+		return model.GPXFile{}, err
+	}
+	os.RemoveAll(itemDir)
+
+	if _, err := s.refresh(); err != nil {
+		return model.GPXFile{}, err
+	}
+	return s.fileFor(relPath), nil
+}
+
+// PurgeTrash permanently deletes one trash item, or all of them when id is
+// empty.
+func (s *Service) PurgeTrash(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == "" {
+		return os.RemoveAll(filepath.Join(s.DataDir, trashDir))
+	}
+	if _, err := s.trashItem(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.DataDir, trashDir, id))
+}
+
+// trashItem describes the file held in a trash item directory. Callers must
+// hold s.mu.
+func (s *Service) trashItem(id string) (model.TrashItem, error) {
+	if !validTrashID(id) {
+		return model.TrashItem{}, fmt.Errorf("invalid path")
+	}
+	nanos, _ := strconv.ParseInt(id, 36, 64)
+	itemDir := filepath.Join(s.DataDir, trashDir, id)
+
+	item := model.TrashItem{ID: id, DeletedAt: time.Unix(0, nanos)}
+	found := false
+	err := filepath.WalkDir(itemDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(itemDir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		item.RelativePath = filepath.ToSlash(rel)
+		item.Name = d.Name()
+		item.Size = info.Size()
+		found = true
+		return fs.SkipAll
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.TrashItem{}, fmt.Errorf("not found")
+		}
+		return model.TrashItem{}, err
+	}
+	if !found {
+		return model.TrashItem{}, fmt.Errorf("not found")
+	}
+	return item, nil
+}
+
+// validTrashID accepts the base-36 timestamps Delete generates, which also
+// keeps IDs from naming anything outside the trash.
+func validTrashID(id string) bool {
+	if id == "" || len(id) > 13 {
+		return false
+	}
+	return strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyz") == ""
+}
@@ -0,0 +1,143 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// Trim cuts a track down to a time or point-index range and writes the result
+// as GPX, keeping metadata and extensions. See model.TrimRequest for where the
+// result goes. Only GPX files can be replaced in place; other formats get a
+// trimmed GPX copy.
+func (s *Service) Trim(relPath string, req model.TrimRequest) (model.TrimResponse, error) {
+	fullPath, relPath, err := s.resolvePath(relPath)
+	if err != nil {
+		return model.TrimResponse{}, err
+	}
+	byTime := req.Start != nil || req.End != nil
+	byIndex := req.From != nil || req.To != nil
+	switch {
+	case byTime && byIndex:
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: use either a time or an index range")
+	case !byTime && !byIndex:
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: missing range")
+	case req.Start != nil && req.End != nil && req.End.Before(*req.Start):
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: end is before start")
+	case (req.From != nil && *req.From < 0) || (req.To != nil && *req.To < 0):
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: negative index")
+	case req.From != nil && req.To != nil && *req.To < *req.From:
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: to is before from")
+	case req.Replace && req.Name != "":
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: name cannot be combined with replace")
+	case req.Replace && track.FormatOf(relPath) != "gpx":
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: only GPX files can be replaced")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkConfined(fullPath); err != nil {
+		return model.TrimResponse{}, err
+	}
+	doc, err := parseFile(fullPath)
+	if err != nil {
+		return model.TrimResponse{}, err
+	}
+	var trimmed *track.Document
+	if byTime {
+		var start, end time.Time
+		if req.Start != nil {
+			start = *req.Start
+		}
+		if req.End != nil {
+			end = *req.End
+		}
+		trimmed = doc.TrimTime(start, end)
+	} else {
+		from, to := 0, doc.TrackPointCount()
+		if req.From != nil {
+			from = *req.From
+		}
+		if req.To != nil {
+			to = *req.To
+		}
+		trimmed = doc.TrimIndex(from, to)
+	}
+	if trimmed.TrackPointCount() == 0 {
+		return model.TrimResponse{}, fmt.Errorf("invalid trim: range selects no track points")
+	}
+
+	var buf bytes.Buffer
+	if err := track.WriteGPX(&buf, trimmed); err != nil {
+		return model.TrimResponse{}, err
+	}
+
+	var resp model.TrimResponse
+	target := relPath
+	if req.Replace {
+		id, err := s.moveToTrash(fullPath, relPath)
+		if err != nil {
+			return model.TrimResponse{}, err
+		}
+		if err := writeFileAtomic(fullPath, buf.Bytes()); err != nil {
+			// Put the original back rather than leave a hole in the library.
+			os.Rename(filepath.Join(s.DataDir, trashDir, id, filepath.FromSlash(relPath)), fullPath)
+			os.RemoveAll(filepath.Join(s.DataDir, trashDir, id))
+			return model.TrimResponse{}, err
+		}
+		backup, err := s.trashItem(id)
+		if err != nil {
+			return model.TrimResponse{}, err
+		}
+		resp.Backup = &backup
+	} else {
+		name, err := derivedName(relPath, req.Name, "-trimmed")
+		if err != nil {
+			return model.TrimResponse{}, err
+		}
+		if target, err = s.freePath(path.Join(path.Dir(relPath), name)); err != nil {
+			return model.TrimResponse{}, err
+		}
+		targetPath := filepath.Join(s.DataDir, filepath.FromSlash(target))
+		if err := s.checkConfined(targetPath); err != nil {
+			return model.TrimResponse{}, err
+		}
+		if err := writeFileAtomic(targetPath, buf.Bytes()); err != nil {
+			return model.TrimResponse{}, err
+		}
+	}
+
+	if _, err := s.refresh(); err != nil {
+		return model.TrimResponse{}, err
+	}
+	resp.File = s.fileFor(target)
+	return resp, nil
+}
+
+// derivedName picks the file name for a GPX file written from relPath: the
+// requested name (".gpx" is appended when missing), or the source name with
+// suffix added before a ".gpx" extension.
+func derivedName(relPath, requested, suffix string) (string, error) {
+	if requested = strings.TrimSpace(requested); requested == "" {
+		base := path.Base(relPath)
+		return strings.TrimSuffix(base, path.Ext(base)) + suffix + ".gpx", nil
+	}
+	if path.Ext(requested) == "" {
+		requested += ".gpx"
+	}
+	if !validSegment(requested) {
+		return "", fmt.Errorf("invalid destination: bad file name %q", requested)
+	}
+	if !strings.EqualFold(path.Ext(requested), ".gpx") {
+		return "", fmt.Errorf("invalid destination: output is always GPX")
+	}
+	return requested, nil
+}
@@ -0,0 +1,123 @@
+package gpx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+func TestTrim(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	to := 1
+
+	resp, err := service.Trim("Activities/Gravel/ride.gpx", model.TrimRequest{To: &to})
+	if err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	if resp.File.RelativePath != "Activities/Gravel/ride-trimmed.gpx" || resp.File.Stats == nil || resp.File.Stats.PointCount != 2 || resp.Backup != nil {
+		t.Errorf("unexpected trimmed copy: %+v", resp)
+	}
+	if files := listedPaths(t, service); !files["Activities/Gravel/ride.gpx"] || !files["Activities/Gravel/ride-trimmed.gpx"] {
+		t.Errorf("expected original and copy in the listing, got %v", files)
+	}
+
+	// A second copy does not overwrite the first.
+	resp, err = service.Trim("Activities/Gravel/ride.gpx", model.TrimRequest{To: &to})
+	if err != nil || resp.File.RelativePath != "Activities/Gravel/ride-trimmed (2).gpx" {
+		t.Errorf("unexpected second copy: %+v %v", resp, err)
+	}
+
+	end := start.Add(15 * time.Second)
+	resp, err = service.Trim("Activities/Gravel/ride.gpx", model.TrimRequest{End: &end, Replace: true})
+	if err != nil {
+		t.Fatalf("Trim with replace failed: %v", err)
+	}
+	if resp.File.RelativePath != "Activities/Gravel/ride.gpx" || resp.File.Stats.PointCount != 2 {
+		t.Errorf("unexpected replaced file: %+v", resp.File)
+	}
+	if resp.Backup == nil || resp.Backup.RelativePath != "Activities/Gravel/ride.gpx" {
+		t.Fatalf("expected the original in the trash, got %+v", resp.Backup)
+	}
+	backup, err := os.ReadFile(filepath.Join(dataDir, ".trash", resp.Backup.ID, "Activities", "Gravel", "ride.gpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := track.ParseGPX(strings.NewReader(string(backup))); err != nil || doc.TrackPointCount() != 3 {
+		t.Errorf("backup should hold the untrimmed track: %v", err)
+	}
+}
+
+func TestTrim_Rejects(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	if err := os.WriteFile(filepath.Join(dataDir, "Activities", "ride.tcx"), []byte(testTCX), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	before := at.Add(-time.Hour)
+	one, two := 1, 2
+
+	tests := []struct {
+		name string
+		path string
+		req  model.TrimRequest
+		want string
+	}{
+		{"no range", "Activities/Gravel/ride.gpx", model.TrimRequest{}, "invalid trim"},
+		{"mixed range", "Activities/Gravel/ride.gpx", model.TrimRequest{Start: &at, To: &one}, "invalid trim"},
+		{"reversed time", "Activities/Gravel/ride.gpx", model.TrimRequest{Start: &at, End: &before}, "invalid trim"},
+		{"reversed index", "Activities/Gravel/ride.gpx", model.TrimRequest{From: &two, To: &one}, "invalid trim"},
+		{"empty result", "Activities/Gravel/ride.gpx", model.TrimRequest{End: &before}, "invalid trim"},
+		{"replace non-gpx", "Activities/ride.tcx", model.TrimRequest{To: &one, Replace: true}, "invalid trim"},
+		{"bad name", "Activities/Gravel/ride.gpx", model.TrimRequest{To: &one, Name: "../x.gpx"}, "invalid destination"},
+		{"non-gpx name", "Activities/Gravel/ride.gpx", model.TrimRequest{To: &one, Name: "x.fit"}, "invalid destination"},
+		{"missing file", "Activities/Gravel/missing.gpx", model.TrimRequest{To: &one}, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Trim(tt.path, tt.req); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// A TCX source still yields a trimmed GPX copy.
+	resp, err := service.Trim("Activities/ride.tcx", model.TrimRequest{From: &one, Name: "short"})
+	if err != nil || resp.File.RelativePath != "Activities/short.gpx" || resp.File.Stats.PointCount != 1 {
+		t.Errorf("unexpected TCX trim: %+v %v", resp, err)
+	}
+}
+
+func TestTrim_RejectsSymlinkedFolder(t *testing.T) {
+	dataDir, service := newManageLibrary(t)
+	outside := t.TempDir()
+	ride, err := os.ReadFile(filepath.Join(dataDir, "Activities", "Gravel", "ride.gpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "ride.gpx"), ride, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dataDir, "Activities", "Escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	one := 1
+
+	for _, replace := range []bool{false, true} {
+		if _, err := service.Trim("Activities/Escape/ride.gpx", model.TrimRequest{To: &one, Replace: replace}); err == nil || err.Error() != "invalid path" {
+			t.Errorf("replace=%v: expected invalid path, got %v", replace, err)
+		}
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 1 {
+		t.Errorf("trim wrote outside the data dir: %v", entries)
+	}
+	if got, _ := os.ReadFile(filepath.Join(outside, "ride.gpx")); string(got) != string(ride) {
+		t.Error("file outside the data dir was modified")
+	}
+}
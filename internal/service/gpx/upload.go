@@ -0,0 +1,141 @@
+package gpx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gpx-self-host/internal/model"
+	"gpx-self-host/internal/track"
+)
+
+// Upload validates and stores files in the library. Every file must parse in
+// the format its extension names; if any does not, nothing is written. Files
+// are written atomically and never replace an existing file: a clashing name
+// gets a " (2)", " (3)", ... suffix. The new listing entries are returned in
+// request order.
+func (s *Service) Upload(req model.UploadRequest) ([]model.GPXFile, error) {
+	dir, err := uploadDir(req.Destination, req.Activity)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("invalid upload: no files")
+	}
+
+	names := make([]string, len(req.Files))
+	for i, f := range req.Files {
+		name, err := uploadName(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := track.Parse(track.FormatOf(name), bytes.NewReader(f.Data)); err != nil {
+			return nil, fmt.Errorf("invalid upload: %s: %w", name, err)
+		}
+		names[i] = name
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	relPaths := make([]string, len(req.Files))
+	for i, f := range req.Files {
+		relPath, err := s.freePath(path.Join(dir, names[i]))
+		if err != nil {
+			return nil, err
+		}
+		fullPath := filepath.Join(s.DataDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return nil, err
+		}
+		if err := s.checkConfined(fullPath); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(fullPath, f.Data); err != nil {
+			return nil, err
+		}
+		relPaths[i] = relPath
+	}
+
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+	files := make([]model.GPXFile, len(relPaths))
+	for i, relPath := range relPaths {
+		files[i] = s.fileFor(relPath)
+	}
+	return files, nil
+}
+
+// uploadDir maps the requested destination onto a directory relative to the
+// data dir.
+func uploadDir(destination, activity string) (string, error) {
+	activity = strings.TrimSpace(activity)
+	switch strings.ToLower(destination) {
+	case "", "activities":
+		if activity == "" {
+			return "Activities", nil
+		}
+		if !validSegment(activity) {
+			return "", fmt.Errorf("invalid destination: bad activity name %q", activity)
+		}
+		return "Activities/" + activity, nil
+	case "plans":
+		if activity != "" {
+			return "", fmt.Errorf("invalid destination: plans have no activity")
+		}
+		return "Plans", nil
+	default:
+		return "", fmt.Errorf("invalid destination: %q", destination)
+	}
+}
+
+// uploadName reduces a client-supplied file name to its base name and checks
+// that it is a visible file of a supported format.
+func uploadName(name string) (string, error) {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if !validSegment(name) {
+		return "", fmt.Errorf("invalid upload: bad file name %q", name)
+	}
+	if track.FormatOf(name) == "" {
+		return "", fmt.Errorf("invalid upload: %s: unsupported file type", name)
+	}
+	return name, nil
+}
+
+// validSegment reports whether s can be used as a single file or folder name:
+// no separators, not hidden, no control characters.
+func validSegment(s string) bool {
+	if s == "" || strings.HasPrefix(s, ".") || strings.ContainsAny(s, `/\:`) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// freePath returns relPath, or the first "name (n).ext" variant of it that
+// does not exist yet. Callers must hold s.mu.
+func (s *Service) freePath(relPath string) (string, error) {
+	ext := path.Ext(relPath)
+	base := strings.TrimSuffix(relPath, ext)
+	candidate := relPath
+	for n := 2; ; n++ {
+		_, err := os.Lstat(filepath.Join(s.DataDir, filepath.FromSlash(candidate)))
+		if os.IsNotExist(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = base + " (" + strconv.Itoa(n) + ")" + ext
+	}
+}
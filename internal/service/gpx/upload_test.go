@@ -0,0 +1,116 @@
+package gpx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+func TestUpload(t *testing.T) {
+	dataDir := t.TempDir()
+	service := NewService(dataDir, "")
+	if _, err := service.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := service.Subscribe()
+	defer unsubscribe()
+
+	ride := []byte(lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 5))
+	files, err := service.Upload(model.UploadRequest{
+		Activity: "Gravel",
+		Files: []model.UploadFile{
+			{Name: `C:\Users\me\ride.gpx`, Data: ride},
+			{Name: "ride.gpx", Data: ride},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(files) != 2 || files[0].RelativePath != "Activities/Gravel/ride.gpx" || files[1].RelativePath != "Activities/Gravel/ride (2).gpx" {
+		t.Fatalf("unexpected entries: %+v", files)
+	}
+	if files[0].Stats == nil || files[0].Stats.PointCount != 5 || files[0].Activity != "Gravel" {
+		t.Errorf("uploaded entry should come with stats: %+v", files[0])
+	}
+	if got, err := os.ReadFile(filepath.Join(dataDir, "Activities", "Gravel", "ride (2).gpx")); err != nil || string(got) != string(ride) {
+		t.Errorf("unexpected file on disk: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-events:
+			if ev.Type != model.LibraryFileAdded {
+				t.Errorf("unexpected event %+v", ev)
+			}
+		default:
+			t.Fatalf("expected two file-added events, got %d", i)
+		}
+	}
+
+	plans, err := service.Upload(model.UploadRequest{Destination: "plans", Files: []model.UploadFile{{Name: "route.gpx", Data: ride}}})
+	if err != nil || len(plans) != 1 || plans[0].RelativePath != "Plans/route.gpx" {
+		t.Fatalf("unexpected plan upload: %+v %v", plans, err)
+	}
+
+	listed, err := service.ListFiles()
+	if err != nil || len(listed) != 3 {
+		t.Errorf("expected uploads in the listing, got %d (%v)", len(listed), err)
+	}
+}
+
+func TestUpload_Rejects(t *testing.T) {
+	dataDir := t.TempDir()
+	service := NewService(dataDir, "")
+	good := model.UploadFile{Name: "ok.gpx", Data: []byte(lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 2))}
+
+	tests := []struct {
+		name   string
+		req    model.UploadRequest
+		prefix string
+	}{
+		{"no files", model.UploadRequest{}, "invalid upload"},
+		{"unknown destination", model.UploadRequest{Destination: "cache", Files: []model.UploadFile{good}}, "invalid destination"},
+		{"activity traversal", model.UploadRequest{Activity: "..", Files: []model.UploadFile{good}}, "invalid destination"},
+		{"nested activity", model.UploadRequest{Activity: "a/b", Files: []model.UploadFile{good}}, "invalid destination"},
+		{"plan with activity", model.UploadRequest{Destination: "plans", Activity: "Gravel", Files: []model.UploadFile{good}}, "invalid destination"},
+		{"hidden file", model.UploadRequest{Files: []model.UploadFile{{Name: ".ride.gpx", Data: good.Data}}}, "invalid upload"},
+		{"unsupported type", model.UploadRequest{Files: []model.UploadFile{{Name: "notes.txt", Data: good.Data}}}, "invalid upload"},
+		{"malformed", model.UploadRequest{Files: []model.UploadFile{good, {Name: "bad.gpx", Data: []byte("<gpx>")}}}, "invalid upload: bad.gpx: invalid gpx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Upload(tt.req); err == nil || !strings.HasPrefix(err.Error(), tt.prefix) {
+				t.Errorf("expected %q error, got %v", tt.prefix, err)
+			}
+		})
+	}
+
+	// A rejected batch must not leave any of its files behind.
+	files, err := service.ListFiles()
+	if err != nil || len(files) != 0 {
+		t.Errorf("expected an empty library, got %+v (%v)", files, err)
+	}
+}
+
+func TestUpload_RejectsSymlinkedActivity(t *testing.T) {
+	dataDir := t.TempDir()
+	service := NewService(dataDir, "")
+	if err := os.MkdirAll(filepath.Join(dataDir, "Activities"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dataDir, "Activities", "Escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	ride := []byte(lineTrack(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), 2))
+	if _, err := service.Upload(model.UploadRequest{Activity: "Escape", Files: []model.UploadFile{{Name: "ride.gpx", Data: ride}}}); err == nil || err.Error() != "invalid path" {
+		t.Errorf("expected invalid path, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("file escaped the data dir: %v", entries)
+	}
+}
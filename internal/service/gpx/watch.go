@@ -0,0 +1,96 @@
+package gpx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+const subscriberBuffer = 64
+
+// Subscribe registers a listener for library changes. The returned function
+// must be called to unsubscribe; the channel is closed afterwards or when the
+// service is closed.
+func (s *Service) Subscribe() (<-chan model.LibraryEvent, func()) {
+	ch := make(chan model.LibraryEvent, subscriberBuffer)
+
+	s.subMu.Lock()
+	if s.closed {
+		s.subMu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan model.LibraryEvent]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.subMu.Unlock()
+
+	return ch, func() {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends all subscriptions so long-lived event streams can finish during
+// server shutdown.
+func (s *Service) Close() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.closed = true
+	for ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+}
+
+func (s *Service) publish(events []model.LibraryEvent) {
+	if len(events) == 0 {
+		return
+	}
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.subscribers {
+		for _, ev := range events {
+			select {
+			case ch <- ev:
+			default:
+				// A stalled client must not block indexing; it will resync on reconnect.
+				slog.Warn("Dropping library event for slow subscriber", "type", ev.Type, "path", ev.RelativePath)
+			}
+		}
+	}
+}
+
+// Rescan refreshes the index immediately, publishing any changes found.
+func (s *Service) Rescan() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.refresh()
+	return err
+}
+
+// Watch polls the data dir every interval until ctx is canceled. Polling
+// keeps the implementation portable and dependency-free; a rescan only stats
+// files, so it stays cheap even for large libraries.
+func (s *Service) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Rescan(); err != nil {
+				slog.Warn("Library rescan failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gpx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gpx-self-host/internal/model"
+)
+
+func receiveEvent(t *testing.T, ch <-chan model.LibraryEvent) model.LibraryEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("event channel closed unexpectedly")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for library event")
+	}
+	return model.LibraryEvent{}
+}
+
+func TestRescan_PublishesChanges(t *testing.T) {
+	dataDir := t.TempDir()
+	existing := filepath.Join(dataDir, "Activities", "existing.gpx")
+	writeTrack(t, existing, indexTestTrack, time.Now().Add(-time.Hour))
+
+	s := NewService(dataDir, "")
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	// The initial build does not announce the whole library.
+	if err := s.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event on initial scan: %+v", ev)
+	default:
+	}
+
+	writeTrack(t, filepath.Join(dataDir, "Activities", "new.gpx"), indexTestTrack, time.Now())
+	if err := s.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	ev := receiveEvent(t, events)
+	if ev.Type != model.LibraryFileAdded || ev.RelativePath != "Activities/new.gpx" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if ev.File == nil || ev.File.Path != "/data/Activities/new.gpx" || ev.File.Stats == nil {
+		t.Errorf("expected added event to carry the indexed file, got %+v", ev.File)
+	}
+
+	writeTrack(t, existing, sameSizeTrack, time.Now())
+	if err := s.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receiveEvent(t, events); ev.Type != model.LibraryFileChanged || ev.RelativePath != "Activities/existing.gpx" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	if err := os.Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	ev = receiveEvent(t, events)
+	if ev.Type != model.LibraryFileRemoved || ev.RelativePath != "Activities/existing.gpx" || ev.File != nil {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestWatch_PollsUntilCanceled(t *testing.T) {
+	dataDir := t.TempDir()
+	s := NewService(dataDir, "")
+	if _, err := s.ListFiles(); err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Watch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	writeTrack(t, filepath.Join(dataDir, "Plans", "plan.gpx"), indexTestTrack, time.Now())
+	if ev := receiveEvent(t, events); ev.Type != model.LibraryFileAdded || ev.RelativePath != "Plans/plan.gpx" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not stop after cancel")
+	}
+}
+
+func TestClose_EndsSubscriptions(t *testing.T) {
+	s := NewService(t.TempDir(), "")
+	events, unsubscribe := s.Subscribe()
+
+	s.Close()
+	if _, ok := <-events; ok {
+		t.Fatal("expected channel to be closed")
+	}
+	unsubscribe() // must not panic after Close
+
+	late, _ := s.Subscribe()
+	if _, ok := <-late; ok {
+		t.Fatal("expected subscriptions after Close to be closed immediately")
+	}
+}
@@ -0,0 +1,51 @@
+package tiles
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // some providers serve JPEG under .png paths
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// TileImage returns tile z/x/y of a provider, decoded, for drawing server-side
+// images. Tiles are numbered as in XYZ URLs; for TMS providers the row is
+// flipped here. With cachedOnly the tile must already be in the cache and is
+// never downloaded, which keeps bulk renders from hitting the upstream.
+func (s *Service) TileImage(ctx context.Context, providerName string, z, x, y int, cachedOnly bool) (image.Image, error) {
+	provider, ok := s.cfg.Providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider")
+	}
+	if provider.IsTMS {
+		y = (1<<z - 1) - y
+	}
+	zs, xs, yPng := strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".png"
+
+	var path string
+	if cachedOnly {
+		path = filepath.Join(s.cfg.CacheDir, "tiles", providerName, zs, xs, yPng)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("tile not cached")
+		}
+	} else {
+		var err error
+		if path, err = s.GetTile(ctx, providerName, zs, xs, yPng); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile: %w", err)
+	}
+	return img, nil
+}
@@ -1,7 +1,10 @@
 package tiles
 
 import (
+	"bytes"
 	"context"
+	"image"
+	"image/png"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -196,3 +199,49 @@ func TestGetTile_UnknownProvider(t *testing.T) {
 		t.Errorf("expected 'unknown provider' error, got %v", err)
 	}
 }
+
+func TestTileImage(t *testing.T) {
+	cacheDir := t.TempDir()
+	seed := func(provider, rel string) {
+		img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(cacheDir, "tiles", provider, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed("xyz", "3/1/2.png")
+	seed("tms", "3/1/5.png") // row 2 counted from the south
+
+	cfg := &config.Config{
+		CacheDir: cacheDir,
+		Offline:  true,
+		Providers: map[string]config.TileProviderConfig{
+			"xyz": {Name: "XYZ"},
+			"tms": {Name: "TMS", IsTMS: true},
+		},
+	}
+	service := NewService(cfg)
+
+	for _, provider := range []string{"xyz", "tms"} {
+		img, err := service.TileImage(context.Background(), provider, 3, 1, 2, true)
+		if err != nil || img.Bounds().Dx() != 256 {
+			t.Errorf("%s: expected the cached tile, got %v", provider, err)
+		}
+	}
+	if _, err := service.TileImage(context.Background(), "xyz", 3, 1, 3, true); err == nil {
+		t.Error("expected an error for a tile that is not cached")
+	}
+	if _, err := service.TileImage(context.Background(), "xyz", 3, 1, 3, false); err == nil || err.Error() != "offline mode" {
+		t.Errorf("expected the offline error from GetTile, got %v", err)
+	}
+	if _, err := service.TileImage(context.Background(), "nope", 3, 1, 2, true); err == nil || err.Error() != "unknown provider" {
+		t.Errorf("expected unknown provider, got %v", err)
+	}
+}
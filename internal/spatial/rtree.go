@@ -0,0 +1,158 @@
+// Package spatial provides a static R-tree for bounding-box lookups over
+// track geometry.
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+// nodeCapacity is the maximum number of children per node. Sixteen keeps the
+// tree shallow for libraries with a few hundred thousand chunks.
+const nodeCapacity = 16
+
+// Rect is an axis-aligned rectangle in lon/lat degrees.
+type Rect struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Intersects reports whether r and o share any point, edges included.
+func (r Rect) Intersects(o Rect) bool {
+	return r.MinX <= o.MaxX && o.MinX <= r.MaxX && r.MinY <= o.MaxY && o.MinY <= r.MaxY
+}
+
+// Contains reports whether o lies entirely inside r.
+func (r Rect) Contains(o Rect) bool {
+	return r.MinX <= o.MinX && o.MaxX <= r.MaxX && r.MinY <= o.MinY && o.MaxY <= r.MaxY
+}
+
+func (r Rect) union(o Rect) Rect {
+	return Rect{
+		MinX: math.Min(r.MinX, o.MinX),
+		MinY: math.Min(r.MinY, o.MinY),
+		MaxX: math.Max(r.MaxX, o.MaxX),
+		MaxY: math.Max(r.MaxY, o.MaxY),
+	}
+}
+
+func (r Rect) centerX() float64 { return (r.MinX + r.MaxX) / 2 }
+func (r Rect) centerY() float64 { return (r.MinY + r.MaxY) / 2 }
+
+// Item is a rectangle tagged with a caller-defined identifier.
+type Item struct {
+	Rect Rect
+	ID   int
+}
+
+type node struct {
+	rect     Rect
+	children []*node
+	items    []Item // leaves only
+}
+
+// RTree is an immutable R-tree packed with the Sort-Tile-Recursive
+// algorithm. Rebuilding from scratch is cheap enough that it is simpler to
+// rebuild on change than to support inserts and deletes.
+type RTree struct {
+	root *node
+	size int
+}
+
+// NewRTree bulk-loads the given items.
+func NewRTree(items []Item) *RTree {
+	t := &RTree{size: len(items)}
+	if len(items) == 0 {
+		return t
+	}
+
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+
+	var level []*node
+	for _, group := range strTiles(len(sorted), func(i int) Rect { return sorted[i].Rect }, func(i, j int) { sorted[i], sorted[j] = sorted[j], sorted[i] }) {
+		n := &node{items: sorted[group[0]:group[1]]}
+		n.rect = n.items[0].Rect
+		for _, it := range n.items[1:] {
+			n.rect = n.rect.union(it.Rect)
+		}
+		level = append(level, n)
+	}
+
+	for len(level) > 1 {
+		nodes := level
+		var next []*node
+		for _, group := range strTiles(len(nodes), func(i int) Rect { return nodes[i].rect }, func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] }) {
+			n := &node{children: nodes[group[0]:group[1]]}
+			n.rect = n.children[0].rect
+			for _, c := range n.children[1:] {
+				n.rect = n.rect.union(c.rect)
+			}
+			next = append(next, n)
+		}
+		level = next
+	}
+	t.root = level[0]
+	return t
+}
+
+// strTiles sorts n entries into vertical slices by x, then each slice by y,
+// and returns [start, end) ranges of at most nodeCapacity entries.
+func strTiles(n int, rect func(int) Rect, swap func(i, j int)) [][2]int {
+	leaves := (n + nodeCapacity - 1) / nodeCapacity
+	slices := int(math.Ceil(math.Sqrt(float64(leaves))))
+	sliceSize := slices * nodeCapacity
+
+	sort.Sort(&sorter{n: n, less: func(i, j int) bool { return rect(i).centerX() < rect(j).centerX() }, swap: swap})
+
+	var groups [][2]int
+	for start := 0; start < n; start += sliceSize {
+		end := min(start+sliceSize, n)
+		sort.Sort(&sorter{
+			n:    end - start,
+			less: func(i, j int) bool { return rect(start+i).centerY() < rect(start+j).centerY() },
+			swap: func(i, j int) { swap(start+i, start+j) },
+		})
+		for g := start; g < end; g += nodeCapacity {
+			groups = append(groups, [2]int{g, min(g+nodeCapacity, end)})
+		}
+	}
+	return groups
+}
+
+type sorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s *sorter) Len() int           { return s.n }
+func (s *sorter) Less(i, j int) bool { return s.less(i, j) }
+func (s *sorter) Swap(i, j int)      { s.swap(i, j) }
+
+// Len returns the number of items in the tree.
+func (t *RTree) Len() int { return t.size }
+
+// Search calls fn for every item whose rectangle intersects r. Returning
+// false from fn stops the search.
+func (t *RTree) Search(r Rect, fn func(Item) bool) {
+	if t.root != nil {
+		search(t.root, r, fn)
+	}
+}
+
+func search(n *node, r Rect, fn func(Item) bool) bool {
+	if !n.rect.Intersects(r) {
+		return true
+	}
+	for _, it := range n.items {
+		if it.Rect.Intersects(r) && !fn(it) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		if !search(c, r, fn) {
+			return false
+		}
+	}
+	return true
+}
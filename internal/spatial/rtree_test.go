@@ -0,0 +1,84 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRTree_MatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomRect := func(span float64) Rect {
+		x := rng.Float64()*360 - 180
+		y := rng.Float64()*170 - 85
+		return Rect{MinX: x, MinY: y, MaxX: x + rng.Float64()*span, MaxY: y + rng.Float64()*span}
+	}
+
+	for _, n := range []int{0, 1, 15, 16, 17, 1000} {
+		items := make([]Item, n)
+		for i := range items {
+			items[i] = Item{Rect: randomRect(2), ID: i}
+		}
+		tree := NewRTree(items)
+		if tree.Len() != n {
+			t.Fatalf("expected %d items, got %d", n, tree.Len())
+		}
+
+		for q := 0; q < 50; q++ {
+			query := randomRect(40)
+			var want, got []int
+			for _, it := range items {
+				if it.Rect.Intersects(query) {
+					want = append(want, it.ID)
+				}
+			}
+			tree.Search(query, func(it Item) bool {
+				got = append(got, it.ID)
+				return true
+			})
+			sort.Ints(got)
+			if len(got) != len(want) {
+				t.Fatalf("n=%d: expected %d matches, got %d", n, len(want), len(got))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("n=%d: expected %v, got %v", n, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestRTree_SearchStops(t *testing.T) {
+	items := make([]Item, 100)
+	for i := range items {
+		items[i] = Item{Rect: Rect{MinX: 0, MinY: 0, MaxX: 1, MaxY: 1}, ID: i}
+	}
+	tree := NewRTree(items)
+
+	calls := 0
+	tree.Search(Rect{MinX: 0, MinY: 0, MaxX: 1, MaxY: 1}, func(Item) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Errorf("expected search to stop after 3 items, got %d", calls)
+	}
+}
+
+func TestRect(t *testing.T) {
+	outer := Rect{MinX: 0, MinY: 0, MaxX: 10, MaxY: 10}
+	inner := Rect{MinX: 2, MinY: 2, MaxX: 3, MaxY: 3}
+	touching := Rect{MinX: 10, MinY: 10, MaxX: 11, MaxY: 11}
+	apart := Rect{MinX: 11, MinY: 0, MaxX: 12, MaxY: 1}
+
+	if !outer.Contains(inner) || inner.Contains(outer) {
+		t.Error("unexpected Contains result")
+	}
+	if !outer.Intersects(touching) {
+		t.Error("touching rectangles should intersect")
+	}
+	if outer.Intersects(apart) {
+		t.Error("disjoint rectangles should not intersect")
+	}
+}
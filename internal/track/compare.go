@@ -0,0 +1,234 @@
+package track
+
+import "math"
+
+const (
+	// OffRouteTolerance is how far (meters) a track may stray from the line
+	// it is compared with before it counts as off route: GPS error plus the
+	// width of a road or the other side of a trail.
+	OffRouteTolerance = 50.0
+
+	// compareStep is the spacing (meters) of the samples checked along a
+	// line, so sparse lines such as planned routes are checked between their
+	// points too.
+	compareStep = 10.0
+
+	// compareChunk is the number of edges grouped under one bounding box
+	// when searching for the closest point of a line.
+	compareChunk = 32
+)
+
+// Section is a stretch of one line that lies further than OffRouteTolerance
+// from the other. Points follow the line, starting and ending where it
+// crosses the tolerance; the line's own points keep their time and
+// elevation.
+type Section struct {
+	Distance    float64 `json:"distance"`    // meters along the line
+	MaxOffRoute float64 `json:"maxOffRoute"` // meters
+	Points      []Point `json:"points"`
+}
+
+// Comparison reports how an actual track deviated from a planned one.
+type Comparison struct {
+	PlanDistance   float64   `json:"planDistance"`   // meters
+	ActualDistance float64   `json:"actualDistance"` // meters
+	DistanceDiff   float64   `json:"distanceDiff"`   // actual minus plan, meters
+	MaxOffRoute    float64   `json:"maxOffRoute"`    // furthest the actual track got from the plan, meters
+	MeanOffRoute   float64   `json:"meanOffRoute"`   // distance from the plan averaged along the actual track, meters
+	Added          []Section `json:"added"`          // stretches of the actual track away from the plan
+	Skipped        []Section `json:"skipped"`        // stretches of the plan the actual track never came near
+}
+
+// Compare measures the lines of an actual track against the lines of a plan
+// (see Document.Lines). Both are sampled every compareStep meters and every
+// sample is measured to the closest point of the other side's lines, so the
+// order and direction in which the plan was followed do not matter. Runs of
+// samples beyond OffRouteTolerance become Added (on the actual track) and
+// Skipped (on the plan) sections; runs shorter than the tolerance are GPS
+// noise and left out of the sections, but not out of MaxOffRoute.
+func Compare(plan, actual [][]Point) Comparison {
+	c := Comparison{Added: []Section{}, Skipped: []Section{}}
+	for _, line := range plan {
+		c.PlanDistance += Length(line)
+	}
+	for _, line := range actual {
+		c.ActualDistance += Length(line)
+	}
+	c.DistanceDiff = c.ActualDistance - c.PlanDistance
+
+	planIndex, actualIndex := newLineIndex(plan), newLineIndex(actual)
+	if planIndex.empty() || actualIndex.empty() {
+		return c
+	}
+	var sum, length float64
+	for _, line := range actual {
+		dev := deviate(line, planIndex)
+		c.Added = append(c.Added, dev.sections...)
+		c.MaxOffRoute = math.Max(c.MaxOffRoute, dev.max)
+		sum += dev.sum
+		length += dev.length
+	}
+	if length > 0 {
+		c.MeanOffRoute = sum / length
+	}
+	for _, line := range plan {
+		c.Skipped = append(c.Skipped, deviate(line, actualIndex).sections...)
+	}
+	return c
+}
+
+// deviation is the result of measuring one line against another.
+type deviation struct {
+	sections    []Section
+	max         float64
+	sum, length float64 // distance from the other line integrated along this one, and its length
+}
+
+// sample is a point along a line with its distance from the other line.
+// Original points are kept as they are, with time and elevation.
+type sample struct {
+	p    Point
+	d    float64
+	orig bool
+}
+
+// deviate samples line every compareStep meters and measures each sample
+// against other.
+func deviate(line []Point, other *lineIndex) deviation {
+	var dev deviation
+	var run []sample
+	runLength := 0.0
+	flush := func() {
+		if len(run) > 0 && runLength >= OffRouteTolerance {
+			sec := Section{Distance: runLength}
+			for i, s := range run {
+				sec.MaxOffRoute = math.Max(sec.MaxOffRoute, s.d)
+				if s.orig || i == 0 || i == len(run)-1 {
+					sec.Points = append(sec.Points, s.p)
+				}
+			}
+			dev.sections = append(dev.sections, sec)
+		}
+		run, runLength = nil, 0
+	}
+
+	hint := 0
+	var prev *sample
+	visit := func(s sample) {
+		s.d = other.distance(s.p, &hint)
+		dev.max = math.Max(dev.max, s.d)
+		step := 0.0
+		if prev != nil {
+			step = Haversine(prev.p.Lat, prev.p.Lon, s.p.Lat, s.p.Lon)
+			dev.sum += step * (prev.d + s.d) / 2
+			dev.length += step
+		}
+		if s.d > OffRouteTolerance {
+			// A run starts and ends with the samples either side of it, so
+			// its line joins the other one.
+			if len(run) == 0 && prev != nil {
+				run = append(run, *prev)
+			}
+			if len(run) > 0 {
+				runLength += step
+			}
+			run = append(run, s)
+		} else if len(run) > 0 {
+			run = append(run, s)
+			runLength += step
+			flush()
+		}
+		prev = &s
+	}
+
+	for i, p := range line {
+		if i > 0 {
+			a := line[i-1]
+			if n := int(Haversine(a.Lat, a.Lon, p.Lat, p.Lon) / compareStep); n > 1 {
+				for k := 1; k < n; k++ {
+					f := float64(k) / float64(n)
+					visit(sample{p: Point{Lat: a.Lat + f*(p.Lat-a.Lat), Lon: a.Lon + f*(p.Lon-a.Lon)}})
+				}
+			}
+		}
+		visit(sample{p: Point{Lat: p.Lat, Lon: p.Lon, Ele: p.Ele, Time: p.Time}, orig: true})
+	}
+	flush()
+	return dev
+}
+
+// lineIndex finds the closest point of a set of lines, skipping runs of
+// edges whose bounding box is further away than the best match so far.
+type lineIndex struct {
+	lines  [][]Point
+	chunks []lineChunk
+}
+
+// lineChunk covers the edges from..to (point indexes, inclusive) of a line.
+type lineChunk struct {
+	line, from, to int
+	bounds         Bounds
+}
+
+func newLineIndex(lines [][]Point) *lineIndex {
+	ix := &lineIndex{lines: lines}
+	for li, line := range lines {
+		for from := 0; from < len(line); from += compareChunk {
+			to := min(from+compareChunk, len(line)-1)
+			b := *NewBounds(line[from].Lat, line[from].Lon)
+			for _, p := range line[from+1 : to+1] {
+				b.Extend(p.Lat, p.Lon)
+			}
+			ix.chunks = append(ix.chunks, lineChunk{li, from, to, b})
+			if to == len(line)-1 {
+				break
+			}
+		}
+	}
+	return ix
+}
+
+func (ix *lineIndex) empty() bool { return len(ix.chunks) == 0 }
+
+// distance returns the distance in meters from p to the closest line. hint
+// is the chunk that held the closest point last time, which is checked
+// first since consecutive samples are usually closest to the same stretch.
+func (ix *lineIndex) distance(p Point, hint *int) float64 {
+	best := math.Inf(1)
+	check := func(ci int) {
+		c := ix.chunks[ci]
+		if boundsDistance(p, c.bounds) >= best {
+			return
+		}
+		line := ix.lines[c.line]
+		if c.from == c.to {
+			if d := Haversine(p.Lat, p.Lon, line[c.from].Lat, line[c.from].Lon); d < best {
+				best, *hint = d, ci
+			}
+			return
+		}
+		for i := c.from; i < c.to; i++ {
+			if d := DistanceToSegment(p.Lat, p.Lon, line[i], line[i+1]); d < best {
+				best, *hint = d, ci
+			}
+		}
+	}
+	first := *hint
+	check(first)
+	for ci := range ix.chunks {
+		if ci != first {
+			check(ci)
+		}
+	}
+	return best
+}
+
+// boundsDistance returns the distance in meters from p to the closest point
+// of b, measured on the same tangent plane as DistanceToSegment so it never
+// exceeds the distance to anything inside b.
+func boundsDistance(p Point, b Bounds) float64 {
+	lat := math.Max(b.MinLat, math.Min(b.MaxLat, p.Lat))
+	lon := math.Max(b.MinLon, math.Min(b.MaxLon, p.Lon))
+	x, y := project(p.Lat, p.Lon, lat, lon)
+	return math.Hypot(x, y)
+}
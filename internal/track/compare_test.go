@@ -0,0 +1,69 @@
+package track
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	// walk returns points every ~22 m from one latitude to another at lon.
+	walk := func(from, to, lon float64) []Point {
+		var pts []Point
+		for lat := from; lat <= to+1e-9; lat += 0.0002 {
+			pts = append(pts, Point{Lat: lat, Lon: lon})
+		}
+		return pts
+	}
+	plan := [][]Point{{{Lat: 59, Lon: 24}, {Lat: 59.02, Lon: 24}}} // a sparse 2.2 km route
+
+	t.Run("as planned", func(t *testing.T) {
+		c := Compare(plan, [][]Point{walk(59, 59.02, 24.00001)})
+		if len(c.Added) != 0 || len(c.Skipped) != 0 || c.MaxOffRoute > 1 || math.Abs(c.DistanceDiff) > 1 {
+			t.Errorf("expected no deviation, got %+v", c)
+		}
+	})
+
+	t.Run("detour and short cut", func(t *testing.T) {
+		// On the route to 59.008, ~170 m east until 59.012, back on the
+		// route and home early at 59.016.
+		var line []Point
+		line = append(line, walk(59, 59.008, 24)...)
+		line = append(line, walk(59.008, 59.012, 24.003)...)
+		line = append(line, walk(59.012, 59.016, 24)...)
+		c := Compare(plan, [][]Point{line})
+
+		if len(c.Added) != 1 || math.Abs(c.Added[0].MaxOffRoute-172) > 3 || c.Added[0].Distance < 445 {
+			t.Fatalf("expected one detour of ~172 m, got %+v", c.Added)
+		}
+		if first, last := c.Added[0].Points[0], c.Added[0].Points[len(c.Added[0].Points)-1]; first.Lat > 59.0081 || last.Lat < 59.0119 {
+			t.Errorf("expected the detour to span it, got %+v to %+v", first, last)
+		}
+		if math.Abs(c.MaxOffRoute-172) > 3 || c.MeanOffRoute < 30 || c.MeanOffRoute > 60 {
+			t.Errorf("unexpected off-route distances: max %.1f, mean %.1f", c.MaxOffRoute, c.MeanOffRoute)
+		}
+		// The stretch the detour bypassed and the missing end are skipped,
+		// each less the tolerance where the track was still near.
+		if len(c.Skipped) != 2 || math.Abs(c.Skipped[0].Distance-360) > 10 || math.Abs(c.Skipped[1].Distance-400) > 10 {
+			t.Fatalf("expected the bypassed stretch and the end to be skipped, got %+v", c.Skipped)
+		}
+		if want := c.ActualDistance - c.PlanDistance; c.DistanceDiff != want || math.Abs(c.PlanDistance-2224) > 2 {
+			t.Errorf("unexpected distances %+v", c)
+		}
+	})
+
+	t.Run("gps noise", func(t *testing.T) {
+		line := walk(59, 59.02, 24)
+		line[50].Lon += 0.001 // one point ~57 m off
+		c := Compare(plan, [][]Point{line})
+		if len(c.Added) != 0 || c.MaxOffRoute < 50 {
+			t.Errorf("expected a spike in MaxOffRoute but no section, got %+v", c)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := Compare(plan, nil)
+		if c.ActualDistance != 0 || len(c.Added) != 0 || len(c.Skipped) != 0 {
+			t.Errorf("unexpected comparison %+v", c)
+		}
+	})
+}
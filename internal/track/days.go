@@ -0,0 +1,126 @@
+package track
+
+import (
+	"sort"
+	"time"
+)
+
+// splitInterval is the width of a TimeSplit in seconds. Time zone offsets are
+// multiples of a quarter hour, so every split lies on a single calendar day
+// whatever the zone.
+const splitInterval = 15 * 60
+
+// DaySplit is the part of a document recorded on one calendar day.
+type DaySplit struct {
+	Date       string  `json:"date"`       // YYYY-MM-DD
+	Distance   float64 `json:"distance"`   // meters
+	MovingTime float64 `json:"movingTime"` // seconds
+}
+
+// TimeSplit is the part of a document recorded in one quarter hour. Unlike
+// DaySplit it does not depend on a time zone, so it can be stored and turned
+// into days later with DaySplits.
+type TimeSplit struct {
+	Start      int64   `json:"start"`      // Unix seconds, a multiple of 900
+	Distance   float64 `json:"distance"`   // meters
+	MovingTime float64 `json:"movingTime"` // seconds
+}
+
+// DailySplits divides the distance and moving time of Stats by the calendar
+// day, in loc, on which they were recorded, so a multi-day track counts
+// towards every day it covers. Each stretch belongs to the day of the timed
+// point it starts from; untimed points follow the last timed point before
+// them, or the first one after at the start of a line. Days are in order
+// and only days with timed points are listed; a document without times has
+// no splits.
+func (d *Document) DailySplits(loc *time.Location) []DaySplit {
+	return DaySplits(d.TimeSplits(), loc)
+}
+
+// TimeSplits divides the distance and moving time of Stats like DailySplits,
+// but by quarter hour. Splits are in time order.
+func (d *Document) TimeSplits() []TimeSplit {
+	byStart := make(map[int64]*TimeSplit)
+	split := func(t time.Time) *TimeSplit {
+		start := t.Unix() - ((t.Unix()%splitInterval)+splitInterval)%splitInterval
+		if byStart[start] == nil {
+			byStart[start] = &TimeSplit{Start: start}
+		}
+		return byStart[start]
+	}
+
+	firstTime := func(line []Point) *time.Time {
+		for _, p := range line {
+			if p.Time != nil {
+				return p.Time
+			}
+		}
+		return nil
+	}
+	lines := d.Lines()
+	var cur *TimeSplit
+	for _, line := range lines {
+		if t := firstTime(line); t != nil {
+			cur = split(*t)
+			break
+		}
+	}
+	if cur == nil {
+		return nil
+	}
+
+	for _, line := range lines {
+		if t := firstTime(line); t != nil {
+			cur = split(*t)
+		}
+		for i, p := range line {
+			if i > 0 {
+				prev := line[i-1]
+				cur.Distance += Distance(prev, p)
+				if prev.Time != nil && p.Time != nil {
+					dt := p.Time.Sub(*prev.Time)
+					if dt < 0 {
+						dt = -dt
+					}
+					if dt < maxPointInterval {
+						cur.MovingTime += dt.Seconds()
+					}
+				}
+			}
+			if p.Time != nil {
+				cur = split(*p.Time)
+			}
+		}
+	}
+
+	splits := make([]TimeSplit, 0, len(byStart))
+	for _, s := range byStart {
+		splits = append(splits, *s)
+	}
+	sort.Slice(splits, func(i, j int) bool { return splits[i].Start < splits[j].Start })
+	return splits
+}
+
+// DaySplits adds up time splits by their calendar day in loc. Days are in
+// order; no splits give no days.
+func DaySplits(splits []TimeSplit, loc *time.Location) []DaySplit {
+	if len(splits) == 0 {
+		return nil
+	}
+	byDate := make(map[string]*DaySplit)
+	for _, s := range splits {
+		date := time.Unix(s.Start, 0).In(loc).Format("2006-01-02")
+		if byDate[date] == nil {
+			byDate[date] = &DaySplit{Date: date}
+		}
+		byDate[date].Distance += s.Distance
+		byDate[date].MovingTime += s.MovingTime
+	}
+
+	days := make([]DaySplit, 0, len(byDate))
+	for _, d := range byDate {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
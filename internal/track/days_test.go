@@ -0,0 +1,84 @@
+package track
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDailySplits(t *testing.T) {
+	loc := time.FixedZone("EET", 2*3600)
+	at := func(day, hour, min, sec int) *time.Time {
+		t := time.Date(2025, 7, day, hour, min, sec, 0, loc)
+		return &t
+	}
+	// A hike past midnight: a stretch over midnight, a break and another
+	// stretch, with one untimed point carried by the day before it.
+	doc := &Document{Tracks: []Track{{Segments: []Segment{
+		{Points: []Point{
+			{Lat: 59, Lon: 24, Time: at(1, 23, 59, 50)},
+			{Lat: 59.0001, Lon: 24, Time: at(1, 23, 59, 55)},
+			{Lat: 59.0002, Lon: 24},
+			{Lat: 59.0003, Lon: 24, Time: at(2, 0, 0, 5)},
+			{Lat: 59.0004, Lon: 24, Time: at(2, 0, 0, 10)},
+		}},
+		{Points: []Point{
+			{Lat: 59.01, Lon: 24, Time: at(2, 1, 0, 0)},
+			{Lat: 59.0101, Lon: 24, Time: at(2, 1, 0, 10)},
+		}},
+	}}}}
+
+	splits := doc.DailySplits(loc)
+	if len(splits) != 2 || splits[0].Date != "2025-07-01" || splits[1].Date != "2025-07-02" {
+		t.Fatalf("expected two days, got %+v", splits)
+	}
+	step := Distance(Point{Lat: 59, Lon: 24}, Point{Lat: 59.0001, Lon: 24})
+	if math.Abs(splits[0].Distance-3*step) > 1e-6 || splits[0].MovingTime != 5 {
+		t.Errorf("unexpected first day %+v", splits[0])
+	}
+
+	st := doc.Stats()
+	total := splits[0].Distance + splits[1].Distance
+	if math.Abs(total-st.Distance) > 1e-6 || splits[0].MovingTime+splits[1].MovingTime != st.MovingTime.Seconds() {
+		t.Errorf("splits %+v do not add up to stats %+v", splits, st)
+	}
+
+	// The same instants fall on one UTC day.
+	if utc := doc.DailySplits(time.UTC); len(utc) != 1 || utc[0].Date != "2025-07-01" {
+		t.Errorf("expected one UTC day, got %+v", utc)
+	}
+
+	untimed := &Document{Routes: []Route{{Points: []Point{{Lat: 59, Lon: 24}, {Lat: 59.1, Lon: 24}}}}}
+	if splits := untimed.DailySplits(loc); splits != nil {
+		t.Errorf("expected no splits without times, got %+v", splits)
+	}
+}
+
+func TestDaySplits(t *testing.T) {
+	// 23:59 to 00:01 in Kolkata (+05:30), i.e. 18:29 to 18:31 UTC.
+	india := time.FixedZone("IST", 5*3600+1800)
+	start := time.Date(2025, 7, 1, 23, 59, 0, 0, india)
+	var points []Point
+	for i := 0; i <= 12; i++ {
+		at := start.Add(time.Duration(i) * 10 * time.Second)
+		points = append(points, Point{Lat: 59 + float64(i)*0.001, Lon: 24, Time: &at})
+	}
+	doc := &Document{Tracks: []Track{{Segments: []Segment{{Points: points}}}}}
+
+	splits := doc.TimeSplits()
+	if len(splits) != 2 || splits[0].Start%900 != 0 || splits[0].Start > start.Unix() {
+		t.Fatalf("expected two quarter hours, got %+v", splits)
+	}
+
+	// The same splits give the days of any zone, without the points.
+	local := DaySplits(splits, india)
+	if len(local) != 2 || local[0].Date != "2025-07-01" || local[0].MovingTime != 60 || local[1].MovingTime != 60 {
+		t.Errorf("unexpected days in +05:30: %+v", local)
+	}
+	if utc := DaySplits(splits, time.UTC); len(utc) != 1 || utc[0].Date != "2025-07-01" || utc[0].MovingTime != 120 {
+		t.Errorf("unexpected UTC days: %+v", utc)
+	}
+	if days := DaySplits(nil, india); days != nil {
+		t.Errorf("expected no days without splits, got %+v", days)
+	}
+}
@@ -0,0 +1,158 @@
+package track
+
+import "time"
+
+// EffortDistances are the distances best efforts are timed over: 1 km, 5 km,
+// 10 km and the half marathon, in meters.
+var EffortDistances = []float64{1000, 5000, 10000, 21097.5}
+
+// climbTolerance is how far (meters, after smoothing) a climb may dip
+// before it counts as over, so a short descent on the way up does not split
+// one climb into two.
+const climbTolerance = 10.0
+
+// maxEffortSpeed (m/s, about 250 km/h) rejects GPS jumps: a timed point
+// that could only be reached faster than this is left out of best efforts.
+const maxEffortSpeed = 70.0
+
+// Effort is the fastest a document covers Distance: the elapsed time from
+// Start, interpolated between points so the window is exactly Distance long.
+type Effort struct {
+	Distance float64   `json:"distance"` // meters
+	Seconds  float64   `json:"seconds"`
+	Start    time.Time `json:"start"`
+}
+
+// Climb is the largest elevation gain of a single ascent.
+type Climb struct {
+	Gain     float64    `json:"gain"`     // meters, smoothed like Stats
+	Distance float64    `json:"distance"` // meters from the bottom to the top
+	Start    *time.Time `json:"start,omitempty"`
+}
+
+// Efforts are the best efforts within one document.
+type Efforts struct {
+	Fastest []Effort `json:"fastest,omitempty"` // per EffortDistances entry the tracks are long enough for
+	Climb   *Climb   `json:"climb,omitempty"`
+}
+
+// BestEfforts finds the fastest time over each of EffortDistances within a
+// single track (distance between segments is not counted, the time is) and
+// the biggest climb on any route or track segment.
+func (d *Document) BestEfforts() Efforts {
+	var e Efforts
+	for _, dist := range EffortDistances {
+		var best *Effort
+		for _, t := range d.Tracks {
+			if eff := fastest(t, dist); eff != nil && (best == nil || eff.Seconds < best.Seconds) {
+				best = eff
+			}
+		}
+		if best != nil {
+			e.Fastest = append(e.Fastest, *best)
+		}
+	}
+	for _, line := range d.Lines() {
+		if c := biggestClimb(line); c != nil && (e.Climb == nil || c.Gain > e.Climb.Gain) {
+			e.Climb = c
+		}
+	}
+	return e
+}
+
+// fastest slides a window of at least dist meters over the timed points of
+// a track and returns the shortest elapsed time.
+func fastest(t Track, dist float64) *Effort {
+	type sample struct {
+		d float64
+		t time.Time
+	}
+	var samples []sample
+	cum := 0.0
+	for _, seg := range t.Segments {
+		var prev *Point
+		for _, p := range seg.Points {
+			step := 0.0
+			if prev != nil {
+				step = Distance(*prev, p)
+			}
+			if p.Time != nil && len(samples) > 0 {
+				last := samples[len(samples)-1]
+				dt := p.Time.Sub(last.t).Seconds()
+				if dt <= 0 || (cum+step-last.d)/dt > maxEffortSpeed {
+					continue
+				}
+			}
+			cum += step
+			prev = &p
+			if p.Time != nil {
+				samples = append(samples, sample{cum, *p.Time})
+			}
+		}
+	}
+
+	var best *Effort
+	i := 0
+	for j := 1; j < len(samples); j++ {
+		for i+1 < j && samples[j].d-samples[i+1].d >= dist {
+			i++
+		}
+		covered := samples[j].d - samples[i].d
+		if covered < dist {
+			continue
+		}
+		// Move the start forward so the window is exactly dist long.
+		start := samples[i].t
+		if span := samples[i+1].d - samples[i].d; span > 0 {
+			frac := (covered - dist) / span
+			start = start.Add(time.Duration(frac * float64(samples[i+1].t.Sub(samples[i].t))))
+		}
+		secs := samples[j].t.Sub(start).Seconds()
+		if best == nil || secs < best.Seconds {
+			best = &Effort{Distance: dist, Seconds: secs, Start: start}
+		}
+	}
+	return best
+}
+
+// biggestClimb walks the smoothed elevation of a line and returns the
+// ascent with the largest gain from its lowest to its highest point.
+func biggestClimb(line []Point) *Climb {
+	var idx []int
+	var elevations []float64
+	for i, p := range line {
+		if p.Ele != nil {
+			idx = append(idx, i)
+			elevations = append(elevations, *p.Ele)
+		}
+	}
+	if len(elevations) < 2 {
+		return nil
+	}
+	smoothed := smoothElevations(elevations)
+	cum := make([]float64, len(line))
+	for i := 1; i < len(line); i++ {
+		cum[i] = cum[i-1] + Distance(line[i-1], line[i])
+	}
+
+	var best *Climb
+	record := func(lo, hi int) {
+		gain := smoothed[hi] - smoothed[lo]
+		if gain <= 0 || (best != nil && gain <= best.Gain) {
+			return
+		}
+		best = &Climb{Gain: gain, Distance: cum[idx[hi]] - cum[idx[lo]], Start: line[idx[lo]].Time}
+	}
+	lo, peak := 0, 0
+	for i := 1; i < len(smoothed); i++ {
+		switch {
+		case smoothed[i] > smoothed[peak]:
+			peak = i
+		case smoothed[i] < smoothed[lo], smoothed[peak]-smoothed[i] > climbTolerance:
+			record(lo, peak)
+			lo, peak = i, i
+		}
+	}
+	record(lo, peak)
+	return best
+}
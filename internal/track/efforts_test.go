@@ -0,0 +1,85 @@
+package track
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBestEfforts(t *testing.T) {
+	start := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	var pts []Point
+	at := start
+	// 60 steps of ~111 m north: 20 s apart, then 10 s for the middle third.
+	for i := 0; i <= 60; i++ {
+		tm := at
+		pts = append(pts, Point{Lat: 59 + float64(i)*0.001, Lon: 24, Time: &tm})
+		if i >= 20 && i < 40 {
+			at = at.Add(10 * time.Second)
+		} else {
+			at = at.Add(20 * time.Second)
+		}
+	}
+	// A GPS jump in the fast section must not shorten the fastest kilometre.
+	glitchTime := pts[30].Time.Add(5 * time.Second)
+	glitch := Point{Lat: 60, Lon: 24, Time: &glitchTime}
+	pts = append(pts[:31], append([]Point{glitch}, pts[31:]...)...)
+
+	doc := &Document{Tracks: []Track{{Segments: []Segment{{Points: pts}}}}}
+	e := doc.BestEfforts()
+
+	if len(e.Fastest) != 2 || e.Fastest[0].Distance != 1000 || e.Fastest[1].Distance != 5000 {
+		t.Fatalf("expected 1k and 5k efforts for a ~6.7 km track, got %+v", e.Fastest)
+	}
+	step := Distance(pts[0], pts[1])
+	if want := 1000 / (step / 10); math.Abs(e.Fastest[0].Seconds-want) > 1e-6 {
+		t.Errorf("fastest 1k: expected %.3f s, got %.3f s", want, e.Fastest[0].Seconds)
+	}
+	if e.Fastest[0].Start.Before(*pts[20].Time) {
+		t.Errorf("fastest 1k should start in the fast section, got %v", e.Fastest[0].Start)
+	}
+	if e.Climb != nil {
+		t.Errorf("expected no climb without elevation, got %+v", e.Climb)
+	}
+
+	untimed := &Document{Routes: []Route{{Points: []Point{{Lat: 59, Lon: 24}, {Lat: 59.1, Lon: 24}}}}}
+	if e := untimed.BestEfforts(); len(e.Fastest) != 0 {
+		t.Errorf("expected no efforts without times, got %+v", e.Fastest)
+	}
+}
+
+func TestBiggestClimb(t *testing.T) {
+	line := func(elevations ...float64) []Point {
+		pts := make([]Point, len(elevations))
+		for i := range elevations {
+			pts[i] = Point{Lat: 59 + float64(i)*0.001, Lon: 24, Ele: &elevations[i]}
+		}
+		return pts
+	}
+	tests := []struct {
+		name     string
+		line     []Point
+		min, max float64
+	}{
+		{"flat", line(10, 10, 10, 10, 10, 10), 0, 0},
+		{"short dip stays one climb", line(0, 0, 0, 20, 40, 60, 80, 75, 75, 100, 120, 140, 160, 160, 160), 150, 161},
+		{"long descent splits", line(0, 0, 0, 40, 80, 120, 120, 120, 80, 40, 0, 0, 0, 30, 60, 90, 90, 90), 100, 121},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := biggestClimb(tt.line)
+			if tt.max == 0 {
+				if c != nil {
+					t.Errorf("expected no climb, got %+v", c)
+				}
+				return
+			}
+			if c == nil || c.Gain < tt.min || c.Gain > tt.max {
+				t.Fatalf("expected a gain in [%v, %v], got %+v", tt.min, tt.max, c)
+			}
+			if c.Distance <= 0 {
+				t.Errorf("expected the climb distance, got %v", c.Distance)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FIT global message numbers and the fields read from them. Field numbers
+// come from the FIT SDK profile; everything else in the file is skipped.
+const (
+	fitMsgFileID     = 0
+	fitMsgSession    = 18
+	fitMsgLap        = 19
+	fitMsgRecord     = 20
+	fitMsgEvent      = 21
+	fitMsgDeviceInfo = 23
+
+	fitFieldTimestamp = 253
+)
+
+// fitEpoch is 1989-12-31T00:00:00Z, the zero of FIT timestamps.
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+const semicirclesToDegrees = 180.0 / (1 << 31)
+
+var errFITTruncated = errors.New("unexpected end of data")
+
+// ParseFIT decodes a FIT activity file: records become track points (with
+// heart rate, cadence, temperature and power as sensor extensions), timer
+// stops split segments, and laps, sessions and device info are kept on the
+// document. Chained FIT files are read in sequence.
+func ParseFIT(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fit: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("invalid fit: %w", errFITTruncated)
+	}
+
+	d := &fitDecoder{}
+	for len(data) > 0 {
+		n, err := d.decodeFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fit: %w", err)
+		}
+		data = data[n:]
+	}
+	return d.document(), nil
+}
+
+type fitFieldDef struct {
+	num      uint8
+	size     uint8
+	baseType uint8
+}
+
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitFieldDef
+	devSize   int // developer fields are skipped
+}
+
+// fitValue is a decoded field. Only the first element of array fields is
+// kept; nothing read here needs more.
+type fitValue struct {
+	num float64
+	str string
+}
+
+type fitMessage map[uint8]fitValue
+
+func (m fitMessage) float(num uint8, scale, offset float64) *float64 {
+	v, ok := m[num]
+	if !ok {
+		return nil
+	}
+	f := v.num/scale - offset
+	return &f
+}
+
+func (m fitMessage) int(num uint8) *int {
+	v, ok := m[num]
+	if !ok {
+		return nil
+	}
+	i := int(v.num)
+	return &i
+}
+
+func (m fitMessage) time(num uint8) *time.Time {
+	v, ok := m[num]
+	if !ok {
+		return nil
+	}
+	t := fitEpoch.Add(time.Duration(v.num) * time.Second)
+	return &t
+}
+
+type fitDecoder struct {
+	defs          [16]*fitDefinition
+	lastTimestamp uint32
+
+	fileID   fitMessage
+	segments [][]Point
+	paused   bool
+	laps     []Lap
+	sessions []Session
+	devices  []Device
+}
+
+// decodeFile reads one FIT file (header, records, CRC) and returns the
+// number of bytes consumed.
+func (d *fitDecoder) decodeFile(data []byte) (int, error) {
+	if len(data) < 12 {
+		return 0, errFITTruncated
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize {
+		return 0, fmt.Errorf("bad header size %d", headerSize)
+	}
+	if string(data[8:12]) != ".FIT" {
+		return 0, errors.New("missing .FIT signature")
+	}
+	if headerSize >= 14 {
+		if crc := binary.LittleEndian.Uint16(data[12:14]); crc != 0 && crc != fitCRC(data[:12]) {
+			return 0, errors.New("header checksum mismatch")
+		}
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if len(data) < end+2 {
+		return 0, errFITTruncated
+	}
+	if fitCRC(data[:end+2]) != 0 {
+		return 0, errors.New("checksum mismatch")
+	}
+
+	// Local definitions do not carry over between chained files, and each
+	// file starts its own segment.
+	d.defs = [16]*fitDefinition{}
+	d.paused = true
+	buf := data[headerSize:end]
+	for len(buf) > 0 {
+		n, err := d.decodeRecord(buf)
+		if err != nil {
+			return 0, err
+		}
+		buf = buf[n:]
+	}
+	return end + 2, nil
+}
+
+func (d *fitDecoder) decodeRecord(buf []byte) (int, error) {
+	header := buf[0]
+	pos := 1
+
+	if header&0x80 != 0 {
+		// Compressed timestamp header: a 5-bit offset from the last timestamp.
+		local := (header >> 5) & 0x03
+		offset := uint32(header & 0x1F)
+		ts := d.lastTimestamp&^0x1F | offset
+		if offset < d.lastTimestamp&0x1F {
+			ts += 0x20
+		}
+		d.lastTimestamp = ts
+		n, err := d.decodeData(buf[pos:], local, &ts)
+		return pos + n, err
+	}
+
+	local := header & 0x0F
+	if header&0x40 != 0 {
+		n, err := d.decodeDefinition(buf[pos:], local, header&0x20 != 0)
+		return pos + n, err
+	}
+	n, err := d.decodeData(buf[pos:], local, nil)
+	return pos + n, err
+}
+
+func (d *fitDecoder) decodeDefinition(buf []byte, local uint8, hasDev bool) (int, error) {
+	if len(buf) < 5 {
+		return 0, errFITTruncated
+	}
+	def := &fitDefinition{bigEndian: buf[1] == 1}
+	if def.bigEndian {
+		def.global = binary.BigEndian.Uint16(buf[2:4])
+	} else {
+		def.global = binary.LittleEndian.Uint16(buf[2:4])
+	}
+	numFields := int(buf[4])
+	pos := 5
+	if len(buf) < pos+numFields*3 {
+		return 0, errFITTruncated
+	}
+	for i := 0; i < numFields; i++ {
+		def.fields = append(def.fields, fitFieldDef{num: buf[pos], size: buf[pos+1], baseType: buf[pos+2]})
+		pos += 3
+	}
+	if hasDev {
+		if len(buf) < pos+1 {
+			return 0, errFITTruncated
+		}
+		numDev := int(buf[pos])
+		pos++
+		if len(buf) < pos+numDev*3 {
+			return 0, errFITTruncated
+		}
+		for i := 0; i < numDev; i++ {
+			def.devSize += int(buf[pos+1])
+			pos += 3
+		}
+	}
+	d.defs[local] = def
+	return pos, nil
+}
+
+func (d *fitDecoder) decodeData(buf []byte, local uint8, ts *uint32) (int, error) {
+	def := d.defs[local]
+	if def == nil {
+		return 0, fmt.Errorf("data for undefined local message %d", local)
+	}
+
+	interesting := false
+	switch def.global {
+	case fitMsgFileID, fitMsgSession, fitMsgLap, fitMsgRecord, fitMsgEvent, fitMsgDeviceInfo:
+		interesting = true
+	}
+
+	var msg fitMessage
+	if interesting {
+		msg = make(fitMessage, len(def.fields))
+	}
+	pos := 0
+	for _, f := range def.fields {
+		size := int(f.size)
+		if len(buf) < pos+size {
+			return 0, errFITTruncated
+		}
+		if interesting {
+			if v, ok := decodeFITValue(buf[pos:pos+size], f.baseType, def.bigEndian); ok {
+				msg[f.num] = v
+			}
+		}
+		pos += size
+	}
+	if len(buf) < pos+def.devSize {
+		return 0, errFITTruncated
+	}
+	pos += def.devSize
+
+	if v, ok := msg[fitFieldTimestamp]; ok {
+		d.lastTimestamp = uint32(v.num)
+	} else if ts != nil && interesting {
+		msg[fitFieldTimestamp] = fitValue{num: float64(*ts)}
+	}
+	if interesting {
+		d.handle(def.global, msg)
+	}
+	return pos, nil
+}
+
+// decodeFITValue decodes the first element of a field and reports whether it
+// holds a valid (non-sentinel) value.
+func decodeFITValue(b []byte, baseType uint8, bigEndian bool) (fitValue, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	switch baseType & 0x1F {
+	case 0x07: // string
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		if len(b) == 0 {
+			return fitValue{}, false
+		}
+		return fitValue{str: string(b)}, true
+	case 0x00, 0x02, 0x0D: // enum, uint8, byte
+		if len(b) < 1 || b[0] == 0xFF {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(b[0])}, true
+	case 0x01: // sint8
+		if len(b) < 1 || b[0] == 0x7F {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(int8(b[0]))}, true
+	case 0x0A: // uint8z
+		if len(b) < 1 || b[0] == 0 {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(b[0])}, true
+	case 0x03: // sint16
+		if len(b) < 2 {
+			return fitValue{}, false
+		}
+		v := order.Uint16(b)
+		if v == 0x7FFF {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(int16(v))}, true
+	case 0x04, 0x0B: // uint16, uint16z
+		if len(b) < 2 {
+			return fitValue{}, false
+		}
+		v := order.Uint16(b)
+		if v == 0xFFFF || (baseType&0x1F == 0x0B && v == 0) {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(v)}, true
+	case 0x05: // sint32
+		if len(b) < 4 {
+			return fitValue{}, false
+		}
+		v := order.Uint32(b)
+		if v == 0x7FFFFFFF {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(int32(v))}, true
+	case 0x06, 0x0C: // uint32, uint32z
+		if len(b) < 4 {
+			return fitValue{}, false
+		}
+		v := order.Uint32(b)
+		if v == 0xFFFFFFFF || (baseType&0x1F == 0x0C && v == 0) {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(v)}, true
+	case 0x08: // float32
+		if len(b) < 4 {
+			return fitValue{}, false
+		}
+		v := order.Uint32(b)
+		if v == 0xFFFFFFFF {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(math.Float32frombits(v))}, true
+	case 0x09: // float64
+		if len(b) < 8 {
+			return fitValue{}, false
+		}
+		v := order.Uint64(b)
+		if v == math.MaxUint64 {
+			return fitValue{}, false
+		}
+		return fitValue{num: math.Float64frombits(v)}, true
+	case 0x0E: // sint64
+		if len(b) < 8 {
+			return fitValue{}, false
+		}
+		v := order.Uint64(b)
+		if v == 0x7FFFFFFFFFFFFFFF {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(int64(v))}, true
+	case 0x0F, 0x10: // uint64, uint64z
+		if len(b) < 8 {
+			return fitValue{}, false
+		}
+		v := order.Uint64(b)
+		if v == math.MaxUint64 || (baseType&0x1F == 0x10 && v == 0) {
+			return fitValue{}, false
+		}
+		return fitValue{num: float64(v)}, true
+	}
+	return fitValue{}, false
+}
+
+func (d *fitDecoder) handle(global uint16, m fitMessage) {
+	switch global {
+	case fitMsgFileID:
+		if d.fileID == nil {
+			d.fileID = m
+		}
+	case fitMsgRecord:
+		d.record(m)
+	case fitMsgEvent:
+		// Timer stop (event 0, type stop or stop_all) ends the segment.
+		if ev, ok := m[0]; ok && ev.num == 0 {
+			if typ, ok := m[1]; ok && (typ.num == 1 || typ.num == 4) {
+				d.paused = true
+			}
+		}
+	case fitMsgLap:
+		d.laps = append(d.laps, Lap{
+			Summary:   fitSummary(m, 2, 7, 8, 9, 11, 15, 16, 17, 13, 14, 21, 22),
+			Intensity: lookupName(fitIntensities, m.int(23)),
+			Trigger:   lookupName(fitLapTriggers, m.int(24)),
+		})
+	case fitMsgSession:
+		d.sessions = append(d.sessions, Session{
+			Summary:  fitSummary(m, 2, 7, 8, 9, 11, 16, 17, 18, 14, 15, 22, 23),
+			Sport:    lookupName(fitSports, m.int(5)),
+			SubSport: lookupName(fitSubSports, m.int(6)),
+		})
+	case fitMsgDeviceInfo:
+		dev := Device{
+			Index:        m.int(0),
+			Manufacturer: lookupName(fitManufacturers, m.int(2)),
+		}
+		if v, ok := m[27]; ok && v.str != "" {
+			dev.Product = v.str
+		} else if p := m.int(4); p != nil {
+			dev.Product = strconv.Itoa(*p)
+		}
+		if s := m.int(3); s != nil {
+			dev.SerialNumber = strconv.Itoa(*s)
+		}
+		if sw := m.float(5, 100, 0); sw != nil {
+			dev.SoftwareVersion = strconv.FormatFloat(*sw, 'f', 2, 64)
+		}
+		if dev.Manufacturer != "" || dev.Product != "" || dev.SerialNumber != "" {
+			d.devices = append(d.devices, dev)
+		}
+	}
+}
+
+func (d *fitDecoder) record(m fitMessage) {
+	lat, okLat := m[0]
+	lon, okLon := m[1]
+	if !okLat || !okLon {
+		return
+	}
+	p := Point{
+		Lat:  lat.num * semicirclesToDegrees,
+		Lon:  lon.num * semicirclesToDegrees,
+		Time: m.time(fitFieldTimestamp),
+	}
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return
+	}
+	if ele := m.float(78, 5, 500); ele != nil {
+		p.Ele = ele
+	} else {
+		p.Ele = m.float(2, 5, 500)
+	}
+	if speed := m.float(73, 1000, 0); speed != nil {
+		p.Speed = speed
+	} else {
+		p.Speed = m.float(6, 1000, 0)
+	}
+	p.Extensions = Sensors{
+		HeartRate:   m.float(3, 1, 0),
+		Cadence:     m.float(4, 1, 0),
+		Temperature: m.float(13, 1, 0),
+		Power:       m.float(7, 1, 0),
+	}.Extensions()
+
+	if d.paused || len(d.segments) == 0 {
+		d.segments = append(d.segments, nil)
+		d.paused = false
+	}
+	last := len(d.segments) - 1
+	d.segments[last] = append(d.segments[last], p)
+}
+
+// fitSummary reads the lap/session totals, whose field numbers differ only
+// slightly between the two messages.
+func fitSummary(m fitMessage, start, elapsed, timer, dist, cal, avgHR, maxHR, avgCad, avgSpeed, maxSpeed, ascent, descent uint8) Summary {
+	s := Summary{
+		StartTime:    m.time(start),
+		Calories:     m.int(cal),
+		AvgHeartRate: m.float(avgHR, 1, 0),
+		MaxHeartRate: m.float(maxHR, 1, 0),
+		AvgCadence:   m.float(avgCad, 1, 0),
+		AvgSpeed:     m.float(avgSpeed, 1000, 0),
+		MaxSpeed:     m.float(maxSpeed, 1000, 0),
+		Ascent:       m.float(ascent, 1, 0),
+		Descent:      m.float(descent, 1, 0),
+	}
+	if v := m.float(elapsed, 1000, 0); v != nil {
+		s.ElapsedTime = *v
+	}
+	if v := m.float(timer, 1000, 0); v != nil {
+		s.TimerTime = *v
+	}
+	if v := m.float(dist, 100, 0); v != nil {
+		s.Distance = *v
+	}
+	return s
+}
+
+func (d *fitDecoder) document() *Document {
+	doc := &Document{
+		Creator:  "FIT",
+		Laps:     d.laps,
+		Sessions: d.sessions,
+		Devices:  d.devices,
+	}
+	if d.fileID != nil {
+		if m := d.fileID.int(1); m != nil && fitManufacturers[*m] != "" {
+			doc.Creator = fitManufacturers[*m]
+		}
+		if t := d.fileID.time(4); t != nil {
+			doc.Metadata = &Metadata{Time: t}
+		}
+	}
+
+	if len(d.segments) > 0 {
+		trk := Track{}
+		if len(d.sessions) > 0 {
+			trk.Type = d.sessions[0].Sport
+		}
+		for _, pts := range d.segments {
+			trk.Segments = append(trk.Segments, Segment{Points: pts})
+		}
+		doc.Tracks = []Track{trk}
+	}
+	return doc
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitCRC is the CRC-16 defined by the FIT protocol. Over a file including
+// its trailing checksum it yields zero.
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+func lookupName(names map[int]string, v *int) string {
+	if v == nil {
+		return ""
+	}
+	if name, ok := names[*v]; ok {
+		return name
+	}
+	return strconv.Itoa(*v)
+}
+
+var fitSports = map[int]string{
+	0: "generic", 1: "running", 2: "cycling", 3: "transition", 4: "fitness_equipment",
+	5: "swimming", 10: "training", 11: "walking", 12: "cross_country_skiing",
+	13: "alpine_skiing", 14: "snowboarding", 15: "rowing", 16: "mountaineering",
+	17: "hiking", 18: "multisport", 19: "paddling", 21: "e_biking", 30: "inline_skating",
+	31: "rock_climbing", 32: "sailing", 33: "ice_skating", 35: "snowshoeing",
+	37: "stand_up_paddleboarding", 41: "kayaking",
+}
+
+var fitSubSports = map[int]string{
+	0: "generic", 1: "treadmill", 2: "street", 3: "trail", 4: "track", 5: "spin",
+	6: "indoor_cycling", 7: "road", 8: "mountain", 9: "downhill", 11: "cyclocross",
+	14: "indoor_rowing", 17: "lap_swimming", 18: "open_water",
+}
+
+var fitManufacturers = map[int]string{
+	1: "garmin", 15: "dynastream", 23: "suunto", 32: "wahoo_fitness",
+	255: "development", 260: "zwift", 265: "strava", 294: "coros",
+}
+
+var fitLapTriggers = map[int]string{
+	0: "manual", 1: "time", 2: "distance", 3: "position_start", 4: "position_lap",
+	5: "position_waypoint", 6: "position_marked", 7: "session_end", 8: "fitness_equipment",
+}
+
+var fitIntensities = map[int]string{0: "active", 1: "rest", 2: "warmup", 3: "cooldown"}
@@ -0,0 +1,247 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fitBuilder assembles FIT files for tests.
+type fitBuilder struct {
+	body bytes.Buffer
+}
+
+type fitTestField struct {
+	num      uint8
+	baseType uint8
+	value    any // int64, uint64, float64 or string
+	size     uint8
+}
+
+func (b *fitBuilder) define(local uint8, global uint16, bigEndian bool, fields []fitTestField, devSizes ...uint8) {
+	header := 0x40 | local
+	if len(devSizes) > 0 {
+		header |= 0x20
+	}
+	b.body.WriteByte(header)
+	b.body.WriteByte(0)
+	arch := byte(0)
+	var g [2]byte
+	binary.LittleEndian.PutUint16(g[:], global)
+	if bigEndian {
+		arch = 1
+		binary.BigEndian.PutUint16(g[:], global)
+	}
+	b.body.WriteByte(arch)
+	b.body.Write(g[:])
+	b.body.WriteByte(byte(len(fields)))
+	for _, f := range fields {
+		b.body.Write([]byte{f.num, f.size, f.baseType})
+	}
+	if len(devSizes) > 0 {
+		b.body.WriteByte(byte(len(devSizes)))
+		for i, size := range devSizes {
+			b.body.Write([]byte{byte(i), size, 0})
+		}
+	}
+}
+
+func (b *fitBuilder) data(header uint8, bigEndian bool, fields []fitTestField, dev ...byte) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	b.body.WriteByte(header)
+	for _, f := range fields {
+		buf := make([]byte, f.size)
+		switch v := f.value.(type) {
+		case string:
+			copy(buf, v)
+		case float64:
+			order.PutUint32(buf, math.Float32bits(float32(v)))
+		case int64:
+			switch f.size {
+			case 1:
+				buf[0] = byte(v)
+			case 2:
+				order.PutUint16(buf, uint16(v))
+			case 4:
+				order.PutUint32(buf, uint32(v))
+			}
+		}
+		b.body.Write(buf)
+	}
+	b.body.Write(dev)
+}
+
+func (b *fitBuilder) bytes() []byte {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:], 2132)
+	binary.LittleEndian.PutUint32(header[4:], uint32(b.body.Len()))
+	copy(header[8:], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:], fitCRC(header[:12]))
+
+	out := append(header, b.body.Bytes()...)
+	var crc [2]byte
+	binary.LittleEndian.PutUint16(crc[:], fitCRC(out))
+	return append(out, crc[:]...)
+}
+
+func fitTime(t time.Time) int64 { return int64(t.Sub(fitEpoch) / time.Second) }
+
+func semicircles(deg float64) int64 { return int64(math.Round(deg / semicirclesToDegrees)) }
+
+func f(num, baseType, size uint8, value any) fitTestField {
+	return fitTestField{num: num, baseType: baseType, size: size, value: value}
+}
+
+func buildTestFIT() []byte {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	var b fitBuilder
+
+	fileID := []fitTestField{f(0, 0x00, 1, int64(4)), f(1, 0x84, 2, int64(1)), f(4, 0x86, 4, fitTime(start))}
+	b.define(0, fitMsgFileID, false, fileID)
+	b.data(0, false, fileID)
+
+	device := []fitTestField{f(0, 0x02, 1, int64(0)), f(2, 0x84, 2, int64(1)), f(3, 0x8C, 4, int64(3900123456)), f(5, 0x84, 2, int64(1234)), f(27, 0x07, 8, "Edge 540")}
+	b.define(1, fitMsgDeviceInfo, false, device)
+	b.data(1, false, device)
+
+	// Records use a big-endian definition with a developer field to make sure
+	// both are handled.
+	rec := func(i int, hr int64) []fitTestField {
+		return []fitTestField{
+			f(fitFieldTimestamp, 0x86, 4, fitTime(start.Add(time.Duration(i)*time.Second))),
+			f(0, 0x85, 4, semicircles(59+float64(i)*0.0001)),
+			f(1, 0x85, 4, semicircles(24)),
+			f(78, 0x86, 4, int64((10+float64(i)+500)*5)),
+			f(3, 0x02, 1, hr),
+			f(4, 0x02, 1, int64(0xFF)), // invalid cadence
+		}
+	}
+	b.define(2, fitMsgRecord, true, rec(0, 0), 2)
+	for i := 0; i < 3; i++ {
+		b.data(2, true, rec(i, 120+int64(i)), 0xAA, 0xBB)
+	}
+
+	// Timer stop, then a record using a compressed timestamp header (which can
+	// only address local messages 0-3).
+	event := []fitTestField{f(fitFieldTimestamp, 0x86, 4, fitTime(start.Add(3*time.Second))), f(0, 0x00, 1, int64(0)), f(1, 0x00, 1, int64(4))}
+	b.define(3, fitMsgEvent, false, event)
+	b.data(3, false, event)
+
+	compact := []fitTestField{f(0, 0x85, 4, semicircles(59.01)), f(1, 0x85, 4, semicircles(24))}
+	b.define(3, fitMsgRecord, false, compact)
+	offset := uint8((fitTime(start.Add(20 * time.Second))) & 0x1F)
+	b.data(0x80|3<<5|offset, false, compact)
+
+	lap := []fitTestField{
+		f(fitFieldTimestamp, 0x86, 4, fitTime(start.Add(40*time.Second))),
+		f(2, 0x86, 4, fitTime(start)),
+		f(7, 0x86, 4, int64(40000)),
+		f(9, 0x86, 4, int64(123456)),
+		f(15, 0x02, 1, int64(121)),
+		f(24, 0x00, 1, int64(0)),
+	}
+	b.define(5, fitMsgLap, false, lap)
+	b.data(5, false, lap)
+
+	session := []fitTestField{
+		f(2, 0x86, 4, fitTime(start)),
+		f(5, 0x00, 1, int64(2)),
+		f(6, 0x00, 1, int64(7)),
+		f(7, 0x86, 4, int64(40000)),
+		f(9, 0x86, 4, int64(123456)),
+		f(22, 0x84, 2, int64(15)),
+	}
+	b.define(6, fitMsgSession, false, session)
+	b.data(6, false, session)
+
+	return b.bytes()
+}
+
+func TestParseFIT(t *testing.T) {
+	doc, err := ParseFIT(bytes.NewReader(buildTestFIT()))
+	if err != nil {
+		t.Fatalf("ParseFIT failed: %v", err)
+	}
+
+	if doc.Creator != "garmin" || doc.Metadata == nil || !doc.Metadata.Time.Equal(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected header: %q %+v", doc.Creator, doc.Metadata)
+	}
+	if len(doc.Tracks) != 1 || doc.Tracks[0].Type != "cycling" {
+		t.Fatalf("unexpected tracks: %+v", doc.Tracks)
+	}
+	segs := doc.Tracks[0].Segments
+	if len(segs) != 2 || len(segs[0].Points) != 3 || len(segs[1].Points) != 1 {
+		t.Fatalf("expected the timer stop to split 3+1 points, got %+v", segs)
+	}
+
+	p := segs[0].Points[1]
+	if math.Abs(p.Lat-59.0001) > 1e-7 || math.Abs(p.Lon-24) > 1e-7 {
+		t.Errorf("unexpected position: %f, %f", p.Lat, p.Lon)
+	}
+	if p.Ele == nil || *p.Ele != 11 {
+		t.Errorf("unexpected elevation: %v", p.Ele)
+	}
+	if p.Time == nil || !p.Time.Equal(time.Date(2025, 6, 1, 8, 0, 1, 0, time.UTC)) {
+		t.Errorf("unexpected time: %v", p.Time)
+	}
+	sensors := p.Sensors()
+	if sensors.HeartRate == nil || *sensors.HeartRate != 121 || sensors.Cadence != nil {
+		t.Errorf("unexpected sensors: %+v", sensors)
+	}
+
+	compact := segs[1].Points[0]
+	if compact.Time == nil || !compact.Time.Equal(time.Date(2025, 6, 1, 8, 0, 20, 0, time.UTC)) {
+		t.Errorf("compressed timestamp decoded as %v", compact.Time)
+	}
+
+	if len(doc.Laps) != 1 || doc.Laps[0].ElapsedTime != 40 || doc.Laps[0].Distance != 1234.56 || doc.Laps[0].Trigger != "manual" {
+		t.Errorf("unexpected laps: %+v", doc.Laps)
+	}
+	if len(doc.Sessions) != 1 || doc.Sessions[0].Sport != "cycling" || doc.Sessions[0].SubSport != "road" || *doc.Sessions[0].Ascent != 15 {
+		t.Errorf("unexpected sessions: %+v", doc.Sessions)
+	}
+	if len(doc.Devices) != 1 || doc.Devices[0].Product != "Edge 540" || doc.Devices[0].SerialNumber != "3900123456" || doc.Devices[0].SoftwareVersion != "12.34" {
+		t.Errorf("unexpected devices: %+v", doc.Devices)
+	}
+
+	if st := doc.Stats(); st.PointCount != 4 || st.Distance <= 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+func TestParseFIT_Chained(t *testing.T) {
+	data := buildTestFIT()
+	doc, err := ParseFIT(bytes.NewReader(append(append([]byte{}, data...), data...)))
+	if err != nil {
+		t.Fatalf("ParseFIT failed: %v", err)
+	}
+	if len(doc.Laps) != 2 || len(doc.Tracks[0].Segments) != 4 {
+		t.Errorf("expected both files to be read, got %d laps and %d segments", len(doc.Laps), len(doc.Tracks[0].Segments))
+	}
+}
+
+func TestParseFIT_Errors(t *testing.T) {
+	good := buildTestFIT()
+	corrupt := append([]byte{}, good...)
+	corrupt[40] ^= 0xFF
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"not fit":   []byte("<gpx version=\"1.1\"></gpx>"),
+		"truncated": good[:len(good)-10],
+		"checksum":  corrupt,
+	}
+	for name, data := range tests {
+		if _, err := ParseFIT(bytes.NewReader(data)); err == nil || !strings.HasPrefix(err.Error(), "invalid fit") {
+			t.Errorf("%s: expected invalid fit error, got %v", name, err)
+		}
+	}
+}
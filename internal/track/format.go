@@ -0,0 +1,36 @@
+package track
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// parsers maps a lower-case file extension to its reader.
+var parsers = map[string]func(io.Reader) (*Document, error){
+	"gpx": ParseGPX,
+	"fit": ParseFIT,
+	"tcx": ParseTCX,
+	"kml": ParseKML,
+	"kmz": ParseKMZ,
+}
+
+// FormatOf returns the format of a file name ("gpx", "fit", ...) from its
+// extension, or "" when the file is not a supported track format.
+func FormatOf(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if _, ok := parsers[ext]; ok {
+		return ext
+	}
+	return ""
+}
+
+// Parse reads a document in the given format.
+func Parse(format string, r io.Reader) (*Document, error) {
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return parse(r)
+}
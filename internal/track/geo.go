@@ -0,0 +1,141 @@
+package track
+
+import "math"
+
+// earthRadius matches the radius leaflet-gpx uses so distances agree with the
+// numbers the browser used to show.
+const earthRadius = 6371000.0
+
+// Haversine returns the great-circle distance in meters between two points.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Distance returns the distance between two points, including the vertical
+// component when both carry an elevation.
+func Distance(a, b Point) float64 {
+	d := Haversine(a.Lat, a.Lon, b.Lat, b.Lon)
+	if a.Ele != nil && b.Ele != nil {
+		dz := *b.Ele - *a.Ele
+		d = math.Sqrt(d*d + dz*dz)
+	}
+	return d
+}
+
+// Extend grows b to include the given coordinate. A nil receiver is not
+// allowed; use NewBounds for the first point.
+func (b *Bounds) Extend(lat, lon float64) {
+	b.MinLat = math.Min(b.MinLat, lat)
+	b.MinLon = math.Min(b.MinLon, lon)
+	b.MaxLat = math.Max(b.MaxLat, lat)
+	b.MaxLon = math.Max(b.MaxLon, lon)
+}
+
+func NewBounds(lat, lon float64) *Bounds {
+	return &Bounds{MinLat: lat, MinLon: lon, MaxLat: lat, MaxLon: lon}
+}
+
+// Contains reports whether the coordinate lies inside b, edges included.
+func (b Bounds) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// ChunkBounds splits a line into runs of consecutive points and returns the
+// bounds of each run. A run ends after maxPoints points or once its diagonal
+// exceeds maxSpan meters; neighbouring runs share their boundary point so the
+// chunks cover every segment of the line.
+func ChunkBounds(line []Point, maxPoints int, maxSpan float64) []Bounds {
+	if len(line) == 0 {
+		return nil
+	}
+
+	var chunks []Bounds
+	cur := NewBounds(line[0].Lat, line[0].Lon)
+	count := 1
+	for _, p := range line[1:] {
+		cur.Extend(p.Lat, p.Lon)
+		count++
+		if count >= maxPoints || Haversine(cur.MinLat, cur.MinLon, cur.MaxLat, cur.MaxLon) >= maxSpan {
+			chunks = append(chunks, *cur)
+			cur = NewBounds(p.Lat, p.Lon)
+			count = 1
+		}
+	}
+	if count > 1 || len(chunks) == 0 {
+		chunks = append(chunks, *cur)
+	}
+	return chunks
+}
+
+// project maps a coordinate to meters on a plane tangent at (lat0, lon0).
+// It is accurate for the short distances used in proximity checks.
+func project(lat0, lon0, lat, lon float64) (x, y float64) {
+	dLon := lon - lon0
+	if dLon > 180 {
+		dLon -= 360
+	} else if dLon < -180 {
+		dLon += 360
+	}
+	x = dLon * math.Pi / 180 * earthRadius * math.Cos(lat0*math.Pi/180)
+	y = (lat - lat0) * math.Pi / 180 * earthRadius
+	return x, y
+}
+
+// DistanceToSegment returns the distance in meters from the coordinate to
+// the closest point of the segment a–b.
+func DistanceToSegment(lat, lon float64, a, b Point) float64 {
+	d, _ := closestOnSegment(lat, lon, a, b)
+	return d
+}
+
+// closestOnSegment returns the distance in meters from the coordinate to the
+// segment a–b and where the closest point lies, from 0 at a to 1 at b.
+func closestOnSegment(lat, lon float64, a, b Point) (dist, t float64) {
+	ax, ay := project(lat, lon, a.Lat, a.Lon)
+	bx, by := project(lat, lon, b.Lat, b.Lon)
+	dx, dy := bx-ax, by-ay
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l2))
+	}
+	px, py := ax+t*dx, ay+t*dy
+	return math.Hypot(px, py), t
+}
+
+// SegmentIntersectsBounds reports whether any part of the segment a–b lies
+// inside b, treating coordinates as planar lon/lat.
+func SegmentIntersectsBounds(a, b Point, bb Bounds) bool {
+	if bb.Contains(a.Lat, a.Lon) || bb.Contains(b.Lat, b.Lon) {
+		return true
+	}
+	// Liang–Barsky clipping of the parametric segment against the box.
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.Lon-a.Lon, b.Lat-a.Lat
+	for _, edge := range [4][2]float64{
+		{-dx, a.Lon - bb.MinLon},
+		{dx, bb.MaxLon - a.Lon},
+		{-dy, a.Lat - bb.MinLat},
+		{dy, bb.MaxLat - a.Lat},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			t0 = math.Max(t0, r)
+		} else {
+			t1 = math.Min(t1, r)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
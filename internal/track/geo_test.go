@@ -0,0 +1,73 @@
+package track
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChunkBounds(t *testing.T) {
+	var line []Point
+	for i := 0; i < 10; i++ {
+		line = append(line, Point{Lat: 59 + float64(i)*0.001, Lon: 24})
+	}
+
+	chunks := ChunkBounds(line, 4, math.Inf(1))
+	// 10 points, 4 per chunk, sharing boundaries: 0-3, 3-6, 6-9.
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].MinLat != line[0].Lat || chunks[0].MaxLat != line[3].Lat || chunks[1].MinLat != line[3].Lat {
+		t.Errorf("chunks should share boundary points: %+v", chunks)
+	}
+	if chunks[2].MaxLat != line[9].Lat {
+		t.Errorf("last chunk should end at the last point: %+v", chunks[2])
+	}
+
+	// ~111 m between points; a 150 m span closes a chunk every second segment.
+	if got := len(ChunkBounds(line, 100, 150)); got != 5 {
+		t.Errorf("expected 5 span-limited chunks, got %d", got)
+	}
+
+	if got := ChunkBounds(line[:1], 4, 100); len(got) != 1 {
+		t.Errorf("single point should give one chunk, got %d", len(got))
+	}
+}
+
+func TestDistanceToSegment(t *testing.T) {
+	a := Point{Lat: 59, Lon: 24}
+	b := Point{Lat: 59, Lon: 24.01}
+
+	// Perpendicular to the middle of the segment, ~111 m north.
+	if d := DistanceToSegment(59.001, 24.005, a, b); math.Abs(d-111.2) > 1 {
+		t.Errorf("expected ~111 m, got %.1f", d)
+	}
+	// Beyond the end: distance to the endpoint.
+	want := Haversine(59, 24.02, 59, 24.01)
+	if d := DistanceToSegment(59, 24.02, a, b); math.Abs(d-want) > 1 {
+		t.Errorf("expected ~%.1f m, got %.1f", want, d)
+	}
+	// Degenerate segment.
+	if d := DistanceToSegment(59, 24, a, a); d != 0 {
+		t.Errorf("expected 0, got %f", d)
+	}
+}
+
+func TestSegmentIntersectsBounds(t *testing.T) {
+	bb := Bounds{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}
+	tests := []struct {
+		name     string
+		a, b     Point
+		expected bool
+	}{
+		{"Inside", Point{Lat: 0.5, Lon: 0.5}, Point{Lat: 0.6, Lon: 0.6}, true},
+		{"Crosses", Point{Lat: 0.5, Lon: -1}, Point{Lat: 0.5, Lon: 2}, true},
+		{"Diagonal through corner region", Point{Lat: -0.5, Lon: 0.5}, Point{Lat: 0.5, Lon: 1.5}, true},
+		{"Misses corner", Point{Lat: -0.5, Lon: 1.2}, Point{Lat: 0.5, Lon: 2.2}, false},
+		{"Parallel outside", Point{Lat: 2, Lon: -1}, Point{Lat: 2, Lon: 2}, false},
+	}
+	for _, tt := range tests {
+		if got := SegmentIntersectsBounds(tt.a, tt.b, bb); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package track
+
+import "time"
+
+// FeatureCollection is the GeoJSON (RFC 7946) form of a document: one
+// feature per route and track, plus one per waypoint.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	BBox     []float64 `json:"bbox,omitempty"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry holds Point, LineString or MultiLineString coordinates. Positions
+// are [lon, lat] or [lon, lat, ele].
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// GeoJSON converts the document, passing every route and track segment
+// through simplify (which may be nil). Tracks become MultiLineStrings with
+// one line per segment; per-feature properties carry names and, for tracks,
+// the start and end times of the retained points.
+func (d *Document) GeoJSON(simplify func([]Point) []Point) FeatureCollection {
+	if simplify == nil {
+		simplify = func(p []Point) []Point { return p }
+	}
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	var bounds *Bounds
+	extend := func(p Point) {
+		if bounds == nil {
+			bounds = NewBounds(p.Lat, p.Lon)
+		} else {
+			bounds.Extend(p.Lat, p.Lon)
+		}
+	}
+
+	for i, r := range d.Routes {
+		if len(r.Points) == 0 {
+			continue
+		}
+		pts := simplify(r.Points)
+		for _, p := range pts {
+			extend(p)
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "LineString", Coordinates: positions(pts)},
+			Properties: lineProperties("route", i, r.Name, r.Desc, r.Type, nil),
+		})
+	}
+
+	for i, t := range d.Tracks {
+		var lines [][][]float64
+		var times []time.Time
+		for _, s := range t.Segments {
+			if len(s.Points) == 0 {
+				continue
+			}
+			pts := simplify(s.Points)
+			for _, p := range pts {
+				extend(p)
+				if p.Time != nil {
+					times = append(times, *p.Time)
+				}
+			}
+			lines = append(lines, positions(pts))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "MultiLineString", Coordinates: lines},
+			Properties: lineProperties("track", i, t.Name, t.Desc, t.Type, times),
+		})
+	}
+
+	for _, w := range d.Waypoints {
+		extend(w)
+		props := map[string]any{"kind": "waypoint"}
+		if w.Name != "" {
+			props["name"] = w.Name
+		}
+		if w.Desc != "" {
+			props["desc"] = w.Desc
+		}
+		if w.Sym != "" {
+			props["sym"] = w.Sym
+		}
+		if w.Time != nil {
+			props["time"] = *w.Time
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "Point", Coordinates: position(w)},
+			Properties: props,
+		})
+	}
+
+	if bounds != nil {
+		fc.BBox = []float64{bounds.MinLon, bounds.MinLat, bounds.MaxLon, bounds.MaxLat}
+	}
+	return fc
+}
+
+func lineProperties(kind string, index int, name, desc, typ string, times []time.Time) map[string]any {
+	props := map[string]any{"kind": kind, "index": index}
+	if name != "" {
+		props["name"] = name
+	}
+	if desc != "" {
+		props["desc"] = desc
+	}
+	if typ != "" {
+		props["type"] = typ
+	}
+	if len(times) > 0 {
+		start, end := times[0], times[0]
+		for _, t := range times[1:] {
+			if t.Before(start) {
+				start = t
+			}
+			if t.After(end) {
+				end = t
+			}
+		}
+		props["startTime"] = start
+		props["endTime"] = end
+	}
+	return props
+}
+
+func positions(pts []Point) [][]float64 {
+	out := make([][]float64, len(pts))
+	for i, p := range pts {
+		out[i] = position(p)
+	}
+	return out
+}
+
+func position(p Point) []float64 {
+	if p.Ele != nil {
+		return []float64{p.Lon, p.Lat, *p.Ele}
+	}
+	return []float64{p.Lon, p.Lat}
+}
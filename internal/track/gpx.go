@@ -0,0 +1,492 @@
+package track
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseGPX decodes a GPX 1.0 or 1.1 document. Required attributes (lat/lon)
+// must be valid; optional values that fail to parse are dropped instead of
+// rejecting the whole file, mirroring how lenient browser parsers behave.
+func ParseGPX(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+
+	var raw gpxXML
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid gpx: %w", err)
+	}
+
+	doc, err := raw.toDocument()
+	if err != nil {
+		return nil, fmt.Errorf("invalid gpx: %w", err)
+	}
+	return doc, nil
+}
+
+type gpxXML struct {
+	XMLName  xml.Name     `xml:"gpx"`
+	Version  string       `xml:"version,attr"`
+	Creator  string       `xml:"creator,attr"`
+	Metadata *metadataXML `xml:"metadata"`
+
+	// GPX 1.0 carries document metadata directly on the root element.
+	Name     string     `xml:"name"`
+	Desc     string     `xml:"desc"`
+	Author   string     `xml:"author"`
+	Email    string     `xml:"email"`
+	URL      string     `xml:"url"`
+	URLName  string     `xml:"urlname"`
+	Time     string     `xml:"time"`
+	Keywords string     `xml:"keywords"`
+	Bounds   *boundsXML `xml:"bounds"`
+
+	Waypoints  []pointXML     `xml:"wpt"`
+	Routes     []routeXML     `xml:"rte"`
+	Tracks     []trackXML     `xml:"trk"`
+	Extensions *extensionsXML `xml:"extensions"`
+	Extra      []nodeXML      `xml:",any"`
+}
+
+type metadataXML struct {
+	Name       string         `xml:"name"`
+	Desc       string         `xml:"desc"`
+	Author     *personXML     `xml:"author"`
+	Copyright  *copyrightXML  `xml:"copyright"`
+	Links      []linkXML      `xml:"link"`
+	Time       string         `xml:"time"`
+	Keywords   string         `xml:"keywords"`
+	Bounds     *boundsXML     `xml:"bounds"`
+	Extensions *extensionsXML `xml:"extensions"`
+}
+
+type personXML struct {
+	Name  string `xml:"name"`
+	Email *struct {
+		ID     string `xml:"id,attr"`
+		Domain string `xml:"domain,attr"`
+	} `xml:"email"`
+	Link *linkXML `xml:"link"`
+}
+
+type copyrightXML struct {
+	Author  string `xml:"author,attr"`
+	Year    string `xml:"year"`
+	License string `xml:"license"`
+}
+
+type linkXML struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text"`
+	Type string `xml:"type"`
+}
+
+type boundsXML struct {
+	MinLat string `xml:"minlat,attr"`
+	MinLon string `xml:"minlon,attr"`
+	MaxLat string `xml:"maxlat,attr"`
+	MaxLon string `xml:"maxlon,attr"`
+}
+
+type routeXML struct {
+	Name       string         `xml:"name"`
+	Comment    string         `xml:"cmt"`
+	Desc       string         `xml:"desc"`
+	Source     string         `xml:"src"`
+	Links      []linkXML      `xml:"link"`
+	URL        string         `xml:"url"`
+	URLName    string         `xml:"urlname"`
+	Number     string         `xml:"number"`
+	Type       string         `xml:"type"`
+	Points     []pointXML     `xml:"rtept"`
+	Extensions *extensionsXML `xml:"extensions"`
+	Extra      []nodeXML      `xml:",any"`
+}
+
+type trackXML struct {
+	Name       string         `xml:"name"`
+	Comment    string         `xml:"cmt"`
+	Desc       string         `xml:"desc"`
+	Source     string         `xml:"src"`
+	Links      []linkXML      `xml:"link"`
+	URL        string         `xml:"url"`
+	URLName    string         `xml:"urlname"`
+	Number     string         `xml:"number"`
+	Type       string         `xml:"type"`
+	Segments   []segmentXML   `xml:"trkseg"`
+	Extensions *extensionsXML `xml:"extensions"`
+	Extra      []nodeXML      `xml:",any"`
+}
+
+type segmentXML struct {
+	Points     []pointXML     `xml:"trkpt"`
+	Extensions *extensionsXML `xml:"extensions"`
+	Extra      []nodeXML      `xml:",any"`
+}
+
+type pointXML struct {
+	Lat           string         `xml:"lat,attr"`
+	Lon           string         `xml:"lon,attr"`
+	Ele           string         `xml:"ele"`
+	Time          string         `xml:"time"`
+	MagVar        string         `xml:"magvar"`
+	GeoidHeight   string         `xml:"geoidheight"`
+	Name          string         `xml:"name"`
+	Comment       string         `xml:"cmt"`
+	Desc          string         `xml:"desc"`
+	Source        string         `xml:"src"`
+	Links         []linkXML      `xml:"link"`
+	URL           string         `xml:"url"`
+	URLName       string         `xml:"urlname"`
+	Sym           string         `xml:"sym"`
+	Type          string         `xml:"type"`
+	Fix           string         `xml:"fix"`
+	Sat           string         `xml:"sat"`
+	HDOP          string         `xml:"hdop"`
+	VDOP          string         `xml:"vdop"`
+	PDOP          string         `xml:"pdop"`
+	AgeOfDGPSData string         `xml:"ageofdgpsdata"`
+	DGPSID        string         `xml:"dgpsid"`
+	Course        string         `xml:"course"`
+	Speed         string         `xml:"speed"`
+	Extensions    *extensionsXML `xml:"extensions"`
+	Extra         []nodeXML      `xml:",any"`
+}
+
+type extensionsXML struct {
+	Nodes []nodeXML `xml:",any"`
+}
+
+type nodeXML struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []nodeXML  `xml:",any"`
+}
+
+func (g *gpxXML) toDocument() (*Document, error) {
+	doc := &Document{
+		Version: g.Version,
+		Creator: g.Creator,
+	}
+
+	if g.Metadata != nil {
+		doc.Metadata = g.Metadata.toMetadata()
+	} else if meta := g.legacyMetadata(); meta != nil {
+		doc.Metadata = meta
+	}
+
+	for i, w := range g.Waypoints {
+		p, err := w.toPoint()
+		if err != nil {
+			return nil, fmt.Errorf("wpt %d: %w", i, err)
+		}
+		doc.Waypoints = append(doc.Waypoints, p)
+	}
+
+	for i, r := range g.Routes {
+		route := Route{
+			Name:       strings.TrimSpace(r.Name),
+			Comment:    strings.TrimSpace(r.Comment),
+			Desc:       strings.TrimSpace(r.Desc),
+			Source:     strings.TrimSpace(r.Source),
+			Links:      convertLinks(r.Links, r.URL, r.URLName),
+			Number:     parseOptInt(r.Number),
+			Type:       strings.TrimSpace(r.Type),
+			Points:     []Point{},
+			Extensions: convertExtensions(r.Extensions, r.Extra),
+		}
+		for j, rp := range r.Points {
+			p, err := rp.toPoint()
+			if err != nil {
+				return nil, fmt.Errorf("rte %d rtept %d: %w", i, j, err)
+			}
+			route.Points = append(route.Points, p)
+		}
+		doc.Routes = append(doc.Routes, route)
+	}
+
+	for i, t := range g.Tracks {
+		trk := Track{
+			Name:       strings.TrimSpace(t.Name),
+			Comment:    strings.TrimSpace(t.Comment),
+			Desc:       strings.TrimSpace(t.Desc),
+			Source:     strings.TrimSpace(t.Source),
+			Links:      convertLinks(t.Links, t.URL, t.URLName),
+			Number:     parseOptInt(t.Number),
+			Type:       strings.TrimSpace(t.Type),
+			Segments:   []Segment{},
+			Extensions: convertExtensions(t.Extensions, t.Extra),
+		}
+		for j, s := range t.Segments {
+			seg := Segment{
+				Points:     []Point{},
+				Extensions: convertExtensions(s.Extensions, s.Extra),
+			}
+			for k, tp := range s.Points {
+				p, err := tp.toPoint()
+				if err != nil {
+					return nil, fmt.Errorf("trk %d trkseg %d trkpt %d: %w", i, j, k, err)
+				}
+				seg.Points = append(seg.Points, p)
+			}
+			trk.Segments = append(trk.Segments, seg)
+		}
+		doc.Tracks = append(doc.Tracks, trk)
+	}
+
+	doc.Extensions = convertExtensions(g.Extensions, g.Extra)
+	return doc, nil
+}
+
+func (g *gpxXML) legacyMetadata() *Metadata {
+	meta := &Metadata{
+		Name:     strings.TrimSpace(g.Name),
+		Desc:     strings.TrimSpace(g.Desc),
+		Links:    convertLinks(nil, g.URL, g.URLName),
+		Time:     parseOptTime(g.Time),
+		Keywords: strings.TrimSpace(g.Keywords),
+		Bounds:   g.Bounds.toBounds(),
+	}
+	author := strings.TrimSpace(g.Author)
+	email := strings.TrimSpace(g.Email)
+	if author != "" || email != "" {
+		meta.Author = &Person{Name: author, Email: email}
+	}
+	if meta.Name == "" && meta.Desc == "" && meta.Author == nil && len(meta.Links) == 0 &&
+		meta.Time == nil && meta.Keywords == "" && meta.Bounds == nil {
+		return nil
+	}
+	return meta
+}
+
+func (m *metadataXML) toMetadata() *Metadata {
+	meta := &Metadata{
+		Name:       strings.TrimSpace(m.Name),
+		Desc:       strings.TrimSpace(m.Desc),
+		Links:      convertLinks(m.Links, "", ""),
+		Time:       parseOptTime(m.Time),
+		Keywords:   strings.TrimSpace(m.Keywords),
+		Bounds:     m.Bounds.toBounds(),
+		Extensions: convertExtensions(m.Extensions, nil),
+	}
+	if m.Author != nil {
+		person := &Person{Name: strings.TrimSpace(m.Author.Name)}
+		if m.Author.Email != nil && m.Author.Email.ID != "" {
+			person.Email = m.Author.Email.ID + "@" + m.Author.Email.Domain
+		}
+		if m.Author.Link != nil {
+			link := m.Author.Link.toLink()
+			person.Link = &link
+		}
+		meta.Author = person
+	}
+	if m.Copyright != nil {
+		meta.Copyright = &Copyright{
+			Author:  m.Copyright.Author,
+			Year:    strings.TrimSpace(m.Copyright.Year),
+			License: strings.TrimSpace(m.Copyright.License),
+		}
+	}
+	return meta
+}
+
+func (b *boundsXML) toBounds() *Bounds {
+	if b == nil {
+		return nil
+	}
+	minLat, err1 := strconv.ParseFloat(strings.TrimSpace(b.MinLat), 64)
+	minLon, err2 := strconv.ParseFloat(strings.TrimSpace(b.MinLon), 64)
+	maxLat, err3 := strconv.ParseFloat(strings.TrimSpace(b.MaxLat), 64)
+	maxLon, err4 := strconv.ParseFloat(strings.TrimSpace(b.MaxLon), 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return nil
+	}
+	return &Bounds{MinLat: minLat, MinLon: minLon, MaxLat: maxLat, MaxLon: maxLon}
+}
+
+func (l linkXML) toLink() Link {
+	return Link{
+		Href: strings.TrimSpace(l.Href),
+		Text: strings.TrimSpace(l.Text),
+		Type: strings.TrimSpace(l.Type),
+	}
+}
+
+// convertLinks merges GPX 1.1 <link> elements with the GPX 1.0 <url>/<urlname>
+// pair.
+func convertLinks(links []linkXML, url, urlName string) []Link {
+	var out []Link
+	for _, l := range links {
+		out = append(out, l.toLink())
+	}
+	if url = strings.TrimSpace(url); url != "" {
+		out = append(out, Link{Href: url, Text: strings.TrimSpace(urlName)})
+	}
+	return out
+}
+
+func (p *pointXML) toPoint() (Point, error) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(p.Lat), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("invalid lat %q", p.Lat)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(p.Lon), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Point{}, fmt.Errorf("invalid lon %q", p.Lon)
+	}
+
+	return Point{
+		Lat:           lat,
+		Lon:           lon,
+		Ele:           parseOptFloat(p.Ele),
+		Time:          parseOptTime(p.Time),
+		MagVar:        parseOptFloat(p.MagVar),
+		GeoidHeight:   parseOptFloat(p.GeoidHeight),
+		Name:          strings.TrimSpace(p.Name),
+		Comment:       strings.TrimSpace(p.Comment),
+		Desc:          strings.TrimSpace(p.Desc),
+		Source:        strings.TrimSpace(p.Source),
+		Links:         convertLinks(p.Links, p.URL, p.URLName),
+		Sym:           strings.TrimSpace(p.Sym),
+		Type:          strings.TrimSpace(p.Type),
+		Fix:           strings.TrimSpace(p.Fix),
+		Sat:           parseOptInt(p.Sat),
+		HDOP:          parseOptFloat(p.HDOP),
+		VDOP:          parseOptFloat(p.VDOP),
+		PDOP:          parseOptFloat(p.PDOP),
+		AgeOfDGPSData: parseOptFloat(p.AgeOfDGPSData),
+		DGPSID:        parseOptInt(p.DGPSID),
+		Course:        parseOptFloat(p.Course),
+		Speed:         parseOptFloat(p.Speed),
+		Extensions:    convertExtensions(p.Extensions, p.Extra),
+	}, nil
+}
+
+func convertExtensions(ext *extensionsXML, extra []nodeXML) []Extension {
+	var out []Extension
+	if ext != nil {
+		for _, n := range ext.Nodes {
+			out = append(out, n.toExtension())
+		}
+	}
+	for _, n := range extra {
+		out = append(out, n.toExtension())
+	}
+	return out
+}
+
+func (n nodeXML) toExtension() Extension {
+	ext := Extension{
+		Space: n.XMLName.Space,
+		Name:  n.XMLName.Local,
+		Text:  strings.TrimSpace(n.Text),
+	}
+	for _, a := range n.Attrs {
+		// Namespace declarations are an artifact of serialization, not data.
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		ext.Attrs = append(ext.Attrs, Attr{Space: a.Name.Space, Name: a.Name.Local, Value: a.Value})
+	}
+	for _, c := range n.Children {
+		ext.Children = append(ext.Children, c.toExtension())
+	}
+	return ext
+}
+
+func parseOptFloat(s string) *float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func parseOptInt(s string) *int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// parseOptTime parses an xsd:dateTime. Timestamps without a zone are taken
+// as UTC, which is what GPX mandates even though some writers omit the "Z".
+func parseOptTime(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// charsetReader handles the single-byte encodings older GPS software still
+// declares; encoding/xml only understands UTF-8 on its own.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "windows-1252", "cp1252", "us-ascii", "ascii":
+		return &latin1Reader{r: bufio.NewReader(input)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+type latin1Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(l.buf) > 0 {
+			c := copy(p[n:], l.buf)
+			l.buf = l.buf[c:]
+			n += c
+			continue
+		}
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		var enc [utf8.UTFMax]byte
+		size := utf8.EncodeRune(enc[:], rune(b))
+		l.buf = append(l.buf[:0], enc[:size]...)
+	}
+	return n, nil
+}
@@ -0,0 +1,179 @@
+package track
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const gpx11 = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1"
+     xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+     version="1.1" creator="Garmin Connect">
+	<metadata>
+		<name><![CDATA[Loop]]></name>
+		<author><name>Someone</name><email id="someone" domain="example.com"/></author>
+		<link href="connect.garmin.com"><text>Garmin Connect</text></link>
+		<time>2025-11-15T08:56:09.000Z</time>
+		<bounds minlat="59.1" minlon="24.1" maxlat="59.3" maxlon="24.3"/>
+	</metadata>
+	<wpt lat="59.3" lon="24.3">
+		<name>Hut</name>
+		<sym>Lodge</sym>
+	</wpt>
+	<rte>
+		<name>Planned</name>
+		<rtept lat="59.1" lon="24.1"/>
+		<rtept lat="59.2" lon="24.2"/>
+	</rte>
+	<trk>
+		<name>3000k</name>
+		<type>hiking</type>
+		<trkseg>
+			<trkpt lat="59.1" lon="24.1">
+				<ele>62.5</ele>
+				<time>2025-11-15T08:56:14.000Z</time>
+				<extensions>
+					<gpxtpx:TrackPointExtension>
+						<gpxtpx:hr>120</gpxtpx:hr>
+					</gpxtpx:TrackPointExtension>
+				</extensions>
+			</trkpt>
+			<trkpt lat="59.2" lon="24.2">
+				<ele></ele>
+				<time>2025-11-15T08:56:19</time>
+			</trkpt>
+		</trkseg>
+		<trkseg/>
+	</trk>
+</gpx>`
+
+func TestParseGPX_V11(t *testing.T) {
+	doc, err := ParseGPX(strings.NewReader(gpx11))
+	if err != nil {
+		t.Fatalf("ParseGPX failed: %v", err)
+	}
+
+	if doc.Version != "1.1" || doc.Creator != "Garmin Connect" {
+		t.Errorf("unexpected header: %q %q", doc.Version, doc.Creator)
+	}
+	if doc.Metadata == nil || doc.Metadata.Name != "Loop" {
+		t.Fatalf("unexpected metadata: %+v", doc.Metadata)
+	}
+	if doc.Metadata.Author == nil || doc.Metadata.Author.Email != "someone@example.com" {
+		t.Errorf("unexpected author: %+v", doc.Metadata.Author)
+	}
+	if len(doc.Metadata.Links) != 1 || doc.Metadata.Links[0].Text != "Garmin Connect" {
+		t.Errorf("unexpected links: %+v", doc.Metadata.Links)
+	}
+	wantTime := time.Date(2025, 11, 15, 8, 56, 9, 0, time.UTC)
+	if doc.Metadata.Time == nil || !doc.Metadata.Time.Equal(wantTime) {
+		t.Errorf("unexpected metadata time: %v", doc.Metadata.Time)
+	}
+	if doc.Metadata.Bounds == nil || doc.Metadata.Bounds.MaxLon != 24.3 {
+		t.Errorf("unexpected bounds: %+v", doc.Metadata.Bounds)
+	}
+
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Hut" || doc.Waypoints[0].Sym != "Lodge" {
+		t.Errorf("unexpected waypoints: %+v", doc.Waypoints)
+	}
+	if len(doc.Routes) != 1 || len(doc.Routes[0].Points) != 2 {
+		t.Errorf("unexpected routes: %+v", doc.Routes)
+	}
+
+	if len(doc.Tracks) != 1 {
+		t.Fatalf("expected 1 track, got %d", len(doc.Tracks))
+	}
+	trk := doc.Tracks[0]
+	if trk.Name != "3000k" || trk.Type != "hiking" || len(trk.Segments) != 2 {
+		t.Fatalf("unexpected track: %+v", trk)
+	}
+	pts := trk.Segments[0].Points
+	if len(pts) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(pts))
+	}
+	if pts[0].Ele == nil || *pts[0].Ele != 62.5 {
+		t.Errorf("unexpected elevation: %v", pts[0].Ele)
+	}
+	if pts[1].Ele != nil {
+		t.Errorf("expected empty elevation to be dropped, got %v", *pts[1].Ele)
+	}
+	if pts[1].Time == nil || !pts[1].Time.Equal(time.Date(2025, 11, 15, 8, 56, 19, 0, time.UTC)) {
+		t.Errorf("expected zone-less time to parse as UTC, got %v", pts[1].Time)
+	}
+
+	if len(pts[0].Extensions) != 1 {
+		t.Fatalf("expected 1 extension, got %+v", pts[0].Extensions)
+	}
+	ext := pts[0].Extensions[0]
+	if ext.Space != "http://www.garmin.com/xmlschemas/TrackPointExtension/v1" || ext.Name != "TrackPointExtension" {
+		t.Errorf("unexpected extension name: %s %s", ext.Space, ext.Name)
+	}
+	if len(ext.Children) != 1 || ext.Children[0].Name != "hr" || ext.Children[0].Text != "120" {
+		t.Errorf("unexpected extension children: %+v", ext.Children)
+	}
+}
+
+func TestParseGPX_V10(t *testing.T) {
+	const gpx10 = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/0" xmlns:x="urn:example" version="1.0" creator="old">
+	<name>Caf` + "\xe9" + ` run</name>
+	<author>Jane</author>
+	<email>jane@example.com</email>
+	<url>http://example.com</url>
+	<urlname>Example</urlname>
+	<time>2004-05-01T10:00:00Z</time>
+	<trk>
+		<trkseg>
+			<trkpt lat="1.5" lon="2.5">
+				<course>90</course>
+				<speed>3.2</speed>
+				<x:power>250</x:power>
+			</trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+	doc, err := ParseGPX(strings.NewReader(gpx10))
+	if err != nil {
+		t.Fatalf("ParseGPX failed: %v", err)
+	}
+	if doc.Metadata == nil || doc.Metadata.Name != "Café run" {
+		t.Fatalf("unexpected metadata: %+v", doc.Metadata)
+	}
+	if doc.Metadata.Author == nil || doc.Metadata.Author.Name != "Jane" || doc.Metadata.Author.Email != "jane@example.com" {
+		t.Errorf("unexpected author: %+v", doc.Metadata.Author)
+	}
+	if len(doc.Metadata.Links) != 1 || doc.Metadata.Links[0].Href != "http://example.com" || doc.Metadata.Links[0].Text != "Example" {
+		t.Errorf("unexpected links: %+v", doc.Metadata.Links)
+	}
+
+	pt := doc.Tracks[0].Segments[0].Points[0]
+	if pt.Course == nil || *pt.Course != 90 || pt.Speed == nil || *pt.Speed != 3.2 {
+		t.Errorf("unexpected GPX 1.0 point fields: %+v", pt)
+	}
+	if len(pt.Extensions) != 1 || pt.Extensions[0].Space != "urn:example" || pt.Extensions[0].Text != "250" {
+		t.Errorf("expected inline foreign element as extension, got %+v", pt.Extensions)
+	}
+}
+
+func TestParseGPX_Errors(t *testing.T) {
+	tests := map[string]string{
+		"not xml":      "gpx content",
+		"wrong root":   `<kml></kml>`,
+		"bad lat":      `<gpx><wpt lat="abc" lon="1"/></gpx>`,
+		"missing lon":  `<gpx><trk><trkseg><trkpt lat="1"/></trkseg></trk></gpx>`,
+		"out of range": `<gpx><rte><rtept lat="91" lon="1"/></rte></gpx>`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseGPX(strings.NewReader(input))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.HasPrefix(err.Error(), "invalid gpx") {
+				t.Errorf("expected invalid gpx prefix, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package track
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// Prefixes for extension namespaces GPS software commonly expects to see
+// spelled a particular way; anything else gets ns1, ns2, ...
+var knownPrefixes = map[string]string{
+	TrackPointExtensionNS: "gpxtpx",
+	"http://www.garmin.com/xmlschemas/TrackPointExtension/v2": "gpxtpx2",
+	"http://www.garmin.com/xmlschemas/GpxExtensions/v3":       "gpxx",
+	PowerExtensionNS: "pwr",
+}
+
+// WriteGPX encodes the document as GPX 1.1, keeping metadata and extensions.
+// The GPX 1.0-only point fields (course, speed) have no 1.1 element and are
+// omitted, as are laps, sessions and devices.
+func WriteGPX(w io.Writer, d *Document) error {
+	gw := &gpxWriter{w: bufio.NewWriter(w), prefixes: map[string]string{}}
+	gw.collectNamespaces(d)
+
+	creator := d.Creator
+	if creator == "" {
+		creator = "gpx-self-host"
+	}
+
+	gw.raw(xml.Header)
+	gw.raw(`<gpx version="1.1" creator="` + xmlEscape(creator) + `" xmlns="` + gpxNamespace + `"`)
+	spaces := make([]string, 0, len(gw.prefixes))
+	for space := range gw.prefixes {
+		spaces = append(spaces, space)
+	}
+	sort.Strings(spaces)
+	for _, space := range spaces {
+		gw.raw(` xmlns:` + gw.prefixes[space] + `="` + xmlEscape(space) + `"`)
+	}
+	gw.raw(">\n")
+
+	if d.Metadata != nil {
+		gw.metadata(1, d.Metadata)
+	}
+	for _, p := range d.Waypoints {
+		gw.point(1, "wpt", p)
+	}
+	for _, r := range d.Routes {
+		gw.open(1, "rte")
+		gw.description(2, r.Name, r.Comment, r.Desc, r.Source, r.Links, r.Number, r.Type)
+		gw.extensions(2, r.Extensions)
+		for _, p := range r.Points {
+			gw.point(2, "rtept", p)
+		}
+		gw.close(1, "rte")
+	}
+	for _, t := range d.Tracks {
+		gw.open(1, "trk")
+		gw.description(2, t.Name, t.Comment, t.Desc, t.Source, t.Links, t.Number, t.Type)
+		gw.extensions(2, t.Extensions)
+		for _, s := range t.Segments {
+			gw.open(2, "trkseg")
+			for _, p := range s.Points {
+				gw.point(3, "trkpt", p)
+			}
+			gw.extensions(3, s.Extensions)
+			gw.close(2, "trkseg")
+		}
+		gw.close(1, "trk")
+	}
+	gw.extensions(1, d.Extensions)
+	gw.raw("</gpx>\n")
+
+	if gw.err != nil {
+		return gw.err
+	}
+	return gw.w.Flush()
+}
+
+type gpxWriter struct {
+	w        *bufio.Writer
+	err      error
+	prefixes map[string]string
+}
+
+func (g *gpxWriter) collectNamespaces(d *Document) {
+	var spaces []string
+	var walk func([]Extension)
+	walk = func(exts []Extension) {
+		for _, e := range exts {
+			spaces = append(spaces, e.Space)
+			for _, a := range e.Attrs {
+				spaces = append(spaces, a.Space)
+			}
+			walk(e.Children)
+		}
+	}
+	walk(d.Extensions)
+	if d.Metadata != nil {
+		walk(d.Metadata.Extensions)
+	}
+	for _, p := range d.Waypoints {
+		walk(p.Extensions)
+	}
+	for _, r := range d.Routes {
+		walk(r.Extensions)
+		for _, p := range r.Points {
+			walk(p.Extensions)
+		}
+	}
+	for _, t := range d.Tracks {
+		walk(t.Extensions)
+		for _, s := range t.Segments {
+			walk(s.Extensions)
+			for _, p := range s.Points {
+				walk(p.Extensions)
+			}
+		}
+	}
+
+	sort.Strings(spaces)
+	n := 0
+	for _, space := range spaces {
+		if space == "" || space == gpxNamespace || g.prefixes[space] != "" {
+			continue
+		}
+		if prefix, ok := knownPrefixes[space]; ok {
+			g.prefixes[space] = prefix
+			continue
+		}
+		n++
+		g.prefixes[space] = "ns" + strconv.Itoa(n)
+	}
+}
+
+func (g *gpxWriter) raw(s string) {
+	if g.err == nil {
+		_, g.err = g.w.WriteString(s)
+	}
+}
+
+func (g *gpxWriter) indent(depth int) {
+	g.raw(strings.Repeat("  ", depth))
+}
+
+func (g *gpxWriter) open(depth int, name string) {
+	g.indent(depth)
+	g.raw("<" + name + ">\n")
+}
+
+func (g *gpxWriter) close(depth int, name string) {
+	g.indent(depth)
+	g.raw("</" + name + ">\n")
+}
+
+func (g *gpxWriter) text(depth int, name, value string) {
+	if value == "" {
+		return
+	}
+	g.indent(depth)
+	g.raw("<" + name + ">" + xmlEscape(value) + "</" + name + ">\n")
+}
+
+func (g *gpxWriter) float(depth int, name string, v *float64) {
+	if v != nil {
+		g.text(depth, name, formatFloat(*v))
+	}
+}
+
+func (g *gpxWriter) int(depth int, name string, v *int) {
+	if v != nil {
+		g.text(depth, name, strconv.Itoa(*v))
+	}
+}
+
+func (g *gpxWriter) time(depth int, name string, t *time.Time) {
+	if t != nil {
+		g.text(depth, name, t.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+func (g *gpxWriter) link(depth int, name string, l Link) {
+	g.indent(depth)
+	g.raw("<" + name + ` href="` + xmlEscape(l.Href) + `">`)
+	if l.Text == "" && l.Type == "" {
+		g.raw("</" + name + ">\n")
+		return
+	}
+	g.raw("\n")
+	g.text(depth+1, "text", l.Text)
+	g.text(depth+1, "type", l.Type)
+	g.close(depth, name)
+}
+
+func (g *gpxWriter) metadata(depth int, m *Metadata) {
+	g.open(depth, "metadata")
+	g.text(depth+1, "name", m.Name)
+	g.text(depth+1, "desc", m.Desc)
+	if a := m.Author; a != nil {
+		g.open(depth+1, "author")
+		g.text(depth+2, "name", a.Name)
+		if id, domain, ok := strings.Cut(a.Email, "@"); ok {
+			g.indent(depth + 2)
+			g.raw(`<email id="` + xmlEscape(id) + `" domain="` + xmlEscape(domain) + `"/>` + "\n")
+		}
+		if a.Link != nil {
+			g.link(depth+2, "link", *a.Link)
+		}
+		g.close(depth+1, "author")
+	}
+	if c := m.Copyright; c != nil {
+		g.indent(depth + 1)
+		g.raw(`<copyright author="` + xmlEscape(c.Author) + `">` + "\n")
+		g.text(depth+2, "year", c.Year)
+		g.text(depth+2, "license", c.License)
+		g.close(depth+1, "copyright")
+	}
+	for _, l := range m.Links {
+		g.link(depth+1, "link", l)
+	}
+	g.time(depth+1, "time", m.Time)
+	g.text(depth+1, "keywords", m.Keywords)
+	if b := m.Bounds; b != nil {
+		g.indent(depth + 1)
+		g.raw(fmt.Sprintf(`<bounds minlat="%s" minlon="%s" maxlat="%s" maxlon="%s"/>`+"\n",
+			formatFloat(b.MinLat), formatFloat(b.MinLon), formatFloat(b.MaxLat), formatFloat(b.MaxLon)))
+	}
+	g.extensions(depth+1, m.Extensions)
+	g.close(depth, "metadata")
+}
+
+func (g *gpxWriter) description(depth int, name, cmt, desc, src string, links []Link, number *int, typ string) {
+	g.text(depth, "name", name)
+	g.text(depth, "cmt", cmt)
+	g.text(depth, "desc", desc)
+	g.text(depth, "src", src)
+	for _, l := range links {
+		g.link(depth, "link", l)
+	}
+	g.int(depth, "number", number)
+	g.text(depth, "type", typ)
+}
+
+func (g *gpxWriter) point(depth int, name string, p Point) {
+	g.indent(depth)
+	g.raw("<" + name + ` lat="` + formatFloat(p.Lat) + `" lon="` + formatFloat(p.Lon) + `"`)
+	if p.Ele == nil && p.Time == nil && p.MagVar == nil && p.GeoidHeight == nil && p.Name == "" &&
+		p.Comment == "" && p.Desc == "" && p.Source == "" && len(p.Links) == 0 && p.Sym == "" &&
+		p.Type == "" && p.Fix == "" && p.Sat == nil && p.HDOP == nil && p.VDOP == nil &&
+		p.PDOP == nil && p.AgeOfDGPSData == nil && p.DGPSID == nil && len(p.Extensions) == 0 {
+		g.raw("/>\n")
+		return
+	}
+	g.raw(">\n")
+	d := depth + 1
+	g.float(d, "ele", p.Ele)
+	g.time(d, "time", p.Time)
+	g.float(d, "magvar", p.MagVar)
+	g.float(d, "geoidheight", p.GeoidHeight)
+	g.text(d, "name", p.Name)
+	g.text(d, "cmt", p.Comment)
+	g.text(d, "desc", p.Desc)
+	g.text(d, "src", p.Source)
+	for _, l := range p.Links {
+		g.link(d, "link", l)
+	}
+	g.text(d, "sym", p.Sym)
+	g.text(d, "type", p.Type)
+	g.text(d, "fix", p.Fix)
+	g.int(d, "sat", p.Sat)
+	g.float(d, "hdop", p.HDOP)
+	g.float(d, "vdop", p.VDOP)
+	g.float(d, "pdop", p.PDOP)
+	g.float(d, "ageofdgpsdata", p.AgeOfDGPSData)
+	g.int(d, "dgpsid", p.DGPSID)
+	g.extensions(d, p.Extensions)
+	g.close(depth, name)
+}
+
+func (g *gpxWriter) extensions(depth int, exts []Extension) {
+	if len(exts) == 0 {
+		return
+	}
+	g.open(depth, "extensions")
+	for _, e := range exts {
+		g.extension(depth+1, e)
+	}
+	g.close(depth, "extensions")
+}
+
+func (g *gpxWriter) qualified(space, name string) string {
+	if prefix := g.prefixes[space]; prefix != "" {
+		return prefix + ":" + name
+	}
+	return name
+}
+
+func (g *gpxWriter) extension(depth int, e Extension) {
+	name := g.qualified(e.Space, e.Name)
+	g.indent(depth)
+	g.raw("<" + name)
+	for _, a := range e.Attrs {
+		g.raw(" " + g.qualified(a.Space, a.Name) + `="` + xmlEscape(a.Value) + `"`)
+	}
+	switch {
+	case len(e.Children) > 0:
+		g.raw(">\n")
+		for _, c := range e.Children {
+			g.extension(depth+1, c)
+		}
+		g.indent(depth)
+		g.raw("</" + name + ">\n")
+	case e.Text != "":
+		g.raw(">" + xmlEscape(e.Text) + "</" + name + ">\n")
+	default:
+		g.raw("/>\n")
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// xmlEscape escapes text for use in element content and attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package track
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteGPX_RoundTrip(t *testing.T) {
+	want, err := ParseGPX(strings.NewReader(gpx11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Something the writer has to escape and a foreign namespace it has not
+	// seen before.
+	want.Tracks[0].Desc = `Fish & chips <"after">`
+	want.Extensions = []Extension{{Space: "urn:example", Name: "note", Attrs: []Attr{{Space: "urn:example", Name: "kind", Value: "a&b"}}, Text: "hi"}}
+
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, want); err != nil {
+		t.Fatalf("WriteGPX failed: %v", err)
+	}
+	got, err := ParseGPX(&buf)
+	if err != nil {
+		t.Fatalf("written GPX does not parse: %v\n%s", err, buf.String())
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the document\nwant %+v\ngot  %+v", want, got)
+	}
+}
+
+func TestWriteGPX_NamespacePrefixes(t *testing.T) {
+	hr := 150.0
+	doc := &Document{Tracks: []Track{{Segments: []Segment{{Points: []Point{
+		{Lat: 59, Lon: 24, Extensions: Sensors{HeartRate: &hr}.Extensions()},
+	}}}}}}
+
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`xmlns="http://www.topografix.com/GPX/1/1"`,
+		`xmlns:gpxtpx="` + TrackPointExtensionNS + `"`,
+		`<gpxtpx:hr>150</gpxtpx:hr>`,
+		`creator="gpx-self-host"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	parsed, err := ParseGPX(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := parsed.Tracks[0].Segments[0].Points[0].Sensors(); s.HeartRate == nil || *s.HeartRate != 150 {
+		t.Errorf("expected heart rate to survive, got %+v", s)
+	}
+}
@@ -0,0 +1,244 @@
+package track
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// maxKMZEntrySize bounds how much of a KMZ's KML entry is decompressed, so a
+// zip bomb cannot exhaust memory.
+const maxKMZEntrySize = 64 << 20
+
+// ParseKML decodes a KML 2.2 document. Placemarks are collected from any
+// depth of Document/Folder nesting: LineStrings and polygon outlines become
+// routes, gx:Track and gx:MultiTrack become tracks (keeping their timestamps)
+// and Points become waypoints. Styles, overlays and the like are ignored.
+func ParseKML(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+
+	doc := &Document{Creator: "KML"}
+	var stack []string
+	sawRoot := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid kml: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !sawRoot {
+				if t.Name.Local != "kml" {
+					return nil, fmt.Errorf("invalid kml: unexpected root element %q", t.Name.Local)
+				}
+				sawRoot = true
+			}
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			switch {
+			case t.Name.Local == "Placemark":
+				var pm kmlPlacemarkXML
+				if err := dec.DecodeElement(&pm, &t); err != nil {
+					return nil, fmt.Errorf("invalid kml: %w", err)
+				}
+				pm.addTo(doc)
+				continue
+			case t.Name.Local == "name" && parent == "Document" && doc.Metadata == nil:
+				var name string
+				if err := dec.DecodeElement(&name, &t); err != nil {
+					return nil, fmt.Errorf("invalid kml: %w", err)
+				}
+				if name = strings.TrimSpace(name); name != "" {
+					doc.Metadata = &Metadata{Name: name}
+				}
+				continue
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if !sawRoot {
+		return nil, fmt.Errorf("invalid kml: empty document")
+	}
+	return doc, nil
+}
+
+// ParseKMZ reads a KMZ archive: a zip holding doc.kml (or, failing that, the
+// first .kml file at the top level) plus optional resources, which are ignored.
+func ParseKMZ(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid kmz: %w", err)
+	}
+
+	var entry *zip.File
+	for _, f := range zr.File {
+		if strings.EqualFold(f.Name, "doc.kml") {
+			entry = f
+			break
+		}
+		if entry == nil && !strings.Contains(f.Name, "/") && strings.EqualFold(path.Ext(f.Name), ".kml") {
+			entry = f
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("invalid kmz: no KML document in archive")
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid kmz: %w", err)
+	}
+	defer rc.Close()
+	kml, err := io.ReadAll(io.LimitReader(rc, maxKMZEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid kmz: %w", err)
+	}
+	if len(kml) > maxKMZEntrySize {
+		return nil, fmt.Errorf("invalid kmz: %s is too large", entry.Name)
+	}
+	return ParseKML(bytes.NewReader(kml))
+}
+
+type kmlPlacemarkXML struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	kmlGeometryXML
+}
+
+// kmlGeometryXML holds the geometries a Placemark or MultiGeometry can contain.
+type kmlGeometryXML struct {
+	Points      []kmlCoordinatesXML `xml:"Point"`
+	LineStrings []kmlCoordinatesXML `xml:"LineString"`
+	Polygons    []struct {
+		Outer string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+	} `xml:"Polygon"`
+	Tracks      []kmlTrackXML `xml:"Track"`
+	MultiTracks []struct {
+		Tracks []kmlTrackXML `xml:"Track"`
+	} `xml:"MultiTrack"`
+	Multi []kmlGeometryXML `xml:"MultiGeometry"`
+}
+
+type kmlCoordinatesXML struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlTrackXML is a gx:Track: parallel lists of timestamps and "lon lat alt"
+// coordinates.
+type kmlTrackXML struct {
+	When  []string `xml:"when"`
+	Coord []string `xml:"coord"`
+}
+
+func (pm *kmlPlacemarkXML) addTo(doc *Document) {
+	name := strings.TrimSpace(pm.Name)
+	desc := strings.TrimSpace(pm.Description)
+
+	var walk func(g *kmlGeometryXML)
+	walk = func(g *kmlGeometryXML) {
+		for _, p := range g.Points {
+			if pts := parseKMLCoordinates(p.Coordinates); len(pts) > 0 {
+				w := pts[0]
+				w.Name, w.Desc = name, desc
+				doc.Waypoints = append(doc.Waypoints, w)
+			}
+		}
+		addRoute := func(coords string) {
+			if pts := parseKMLCoordinates(coords); len(pts) > 0 {
+				doc.Routes = append(doc.Routes, Route{Name: name, Desc: desc, Points: pts})
+			}
+		}
+		for _, l := range g.LineStrings {
+			addRoute(l.Coordinates)
+		}
+		for _, p := range g.Polygons {
+			addRoute(p.Outer)
+		}
+		addTrack := func(tracks []kmlTrackXML) {
+			trk := Track{Name: name, Desc: desc}
+			for _, t := range tracks {
+				if seg := t.toSegment(); len(seg.Points) > 0 {
+					trk.Segments = append(trk.Segments, seg)
+				}
+			}
+			if len(trk.Segments) > 0 {
+				doc.Tracks = append(doc.Tracks, trk)
+			}
+		}
+		for _, t := range g.Tracks {
+			addTrack([]kmlTrackXML{t})
+		}
+		for _, mt := range g.MultiTracks {
+			addTrack(mt.Tracks)
+		}
+		for i := range g.Multi {
+			walk(&g.Multi[i])
+		}
+	}
+	walk(&pm.kmlGeometryXML)
+}
+
+func (t *kmlTrackXML) toSegment() Segment {
+	var seg Segment
+	for i, c := range t.Coord {
+		f := strings.Fields(c)
+		if len(f) < 2 {
+			continue
+		}
+		p, ok := kmlPoint(f)
+		if !ok {
+			continue
+		}
+		if i < len(t.When) {
+			p.Time = parseOptTime(t.When[i])
+		}
+		seg.Points = append(seg.Points, p)
+	}
+	return seg
+}
+
+// parseKMLCoordinates reads a whitespace-separated list of lon,lat[,alt]
+// tuples, skipping malformed ones.
+func parseKMLCoordinates(s string) []Point {
+	var pts []Point
+	for _, tuple := range strings.Fields(s) {
+		if p, ok := kmlPoint(strings.Split(tuple, ",")); ok {
+			pts = append(pts, p)
+		}
+	}
+	return pts
+}
+
+func kmlPoint(f []string) (Point, bool) {
+	if len(f) < 2 {
+		return Point{}, false
+	}
+	lon, lat := parseOptFloat(f[0]), parseOptFloat(f[1])
+	if lat == nil || lon == nil || *lat < -90 || *lat > 90 || *lon < -180 || *lon > 180 {
+		return Point{}, false
+	}
+	p := Point{Lat: *lat, Lon: *lon}
+	if len(f) > 2 {
+		p.Ele = parseOptFloat(f[2])
+	}
+	return p, true
+}
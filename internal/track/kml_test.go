@@ -0,0 +1,167 @@
+package track
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+  <Document>
+    <name>Weekend plan</name>
+    <Style id="red"><LineStyle><color>ff0000ff</color></LineStyle></Style>
+    <Folder>
+      <name>Day 1</name>
+      <Placemark>
+        <name>Route</name>
+        <description>Coastal path</description>
+        <LineString><coordinates>
+          24.0,59.0,10 24.0,59.001,12
+          bogus 24.0,59.002
+        </coordinates></LineString>
+      </Placemark>
+      <Folder>
+        <Placemark>
+          <name>Camp</name>
+          <Point><coordinates>24.01,59.01,5</coordinates></Point>
+        </Placemark>
+      </Folder>
+    </Folder>
+    <Placemark>
+      <name>Split</name>
+      <MultiGeometry>
+        <LineString><coordinates>25,60 25,60.01</coordinates></LineString>
+        <Polygon><outerBoundaryIs><LinearRing><coordinates>26,61 26.01,61 26,61.01 26,61</coordinates></LinearRing></outerBoundaryIs></Polygon>
+      </MultiGeometry>
+    </Placemark>
+    <Placemark>
+      <name>Recorded</name>
+      <gx:MultiTrack>
+        <gx:Track>
+          <when>2025-06-01T08:00:00Z</when><when>2025-06-01T08:00:10Z</when>
+          <gx:coord>24 59 10</gx:coord><gx:coord>24 59.001 11</gx:coord>
+        </gx:Track>
+        <gx:Track>
+          <when>2025-06-01T09:00:00Z</when>
+          <gx:coord>24 59.002 12</gx:coord>
+        </gx:Track>
+      </gx:MultiTrack>
+    </Placemark>
+  </Document>
+</kml>`
+
+func TestParseKML(t *testing.T) {
+	doc, err := ParseKML(strings.NewReader(testKML))
+	if err != nil {
+		t.Fatalf("ParseKML failed: %v", err)
+	}
+
+	if doc.Metadata == nil || doc.Metadata.Name != "Weekend plan" {
+		t.Errorf("unexpected metadata: %+v", doc.Metadata)
+	}
+	if len(doc.Routes) != 3 {
+		t.Fatalf("expected 3 routes, got %+v", doc.Routes)
+	}
+	r := doc.Routes[0]
+	if r.Name != "Route" || r.Desc != "Coastal path" || len(r.Points) != 3 || r.Points[0].Ele == nil || *r.Points[0].Ele != 10 || r.Points[2].Ele != nil {
+		t.Errorf("unexpected first route: %+v", r)
+	}
+	if doc.Routes[1].Name != "Split" || len(doc.Routes[2].Points) != 4 {
+		t.Errorf("unexpected MultiGeometry routes: %+v", doc.Routes[1:])
+	}
+
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Camp" || doc.Waypoints[0].Lon != 24.01 {
+		t.Errorf("unexpected waypoints: %+v", doc.Waypoints)
+	}
+
+	if len(doc.Tracks) != 1 || len(doc.Tracks[0].Segments) != 2 {
+		t.Fatalf("unexpected tracks: %+v", doc.Tracks)
+	}
+	p := doc.Tracks[0].Segments[0].Points[1]
+	if p.Lat != 59.001 || p.Time == nil || !p.Time.Equal(time.Date(2025, 6, 1, 8, 0, 10, 0, time.UTC)) {
+		t.Errorf("unexpected gx:Track point: %+v", p)
+	}
+}
+
+func TestParseKMZ(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"files/icon.png": "png",
+		"doc.kml":        testKML,
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ParseKMZ(&buf)
+	if err != nil {
+		t.Fatalf("ParseKMZ failed: %v", err)
+	}
+	if len(doc.Routes) != 3 || len(doc.Tracks) != 1 {
+		t.Errorf("unexpected KMZ contents: %+v", doc)
+	}
+
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	zw.Create("readme.txt")
+	zw.Close()
+	if _, err := ParseKMZ(&buf); err == nil || !strings.HasPrefix(err.Error(), "invalid kmz") {
+		t.Errorf("expected error for archive without KML, got %v", err)
+	}
+	if _, err := ParseKMZ(strings.NewReader(testKML)); err == nil || !strings.HasPrefix(err.Error(), "invalid kmz") {
+		t.Errorf("expected error for non-zip input, got %v", err)
+	}
+}
+
+func TestParseKML_Errors(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":     "",
+		"not xml":   "kml",
+		"wrong doc": `<gpx version="1.1"></gpx>`,
+		"truncated": `<kml><Document><Placemark><name>x</name>`,
+	} {
+		if _, err := ParseKML(strings.NewReader(data)); err == nil || !strings.HasPrefix(err.Error(), "invalid kml") {
+			t.Errorf("%s: expected invalid kml error, got %v", name, err)
+		}
+	}
+}
+
+func TestWriteKML_RoundTrip(t *testing.T) {
+	src, err := ParseKML(strings.NewReader(testKML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, src); err != nil {
+		t.Fatalf("WriteKML failed: %v", err)
+	}
+	doc, err := ParseKML(&buf)
+	if err != nil {
+		t.Fatalf("written KML does not parse: %v\n%s", err, buf.String())
+	}
+
+	if doc.Metadata == nil || doc.Metadata.Name != "Weekend plan" {
+		t.Errorf("document name lost: %+v", doc.Metadata)
+	}
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Camp" {
+		t.Errorf("unexpected waypoints: %+v", doc.Waypoints)
+	}
+	// Tracks are written as plain lines, so the recorded track comes back as
+	// two routes next to the original three.
+	if len(doc.Routes) != 5 || doc.Routes[3].Name != "Recorded" || len(doc.Tracks) != 0 {
+		t.Fatalf("unexpected routes: %+v", doc.Routes)
+	}
+	if got, want := doc.Stats().Distance, src.Stats().Distance; got != want {
+		t.Errorf("distance changed from %f to %f", want, got)
+	}
+}
@@ -0,0 +1,103 @@
+package track
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+// WriteKML encodes the document as KML 2.2 for Google Earth and similar tools.
+// Waypoints become Points, routes LineStrings and tracks a LineString per
+// segment (a MultiGeometry when there are several). Only names, descriptions,
+// coordinates and elevations are kept; timestamps and extensions are dropped.
+func WriteKML(w io.Writer, d *Document) error {
+	// gpxWriter's indentation and escaping helpers are not GPX-specific.
+	kw := &gpxWriter{w: bufio.NewWriter(w)}
+
+	kw.raw(xml.Header)
+	kw.raw(`<kml xmlns="` + kmlNamespace + `">` + "\n")
+	kw.open(1, "Document")
+	if d.Metadata != nil {
+		kw.text(2, "name", d.Metadata.Name)
+		kw.text(2, "description", d.Metadata.Desc)
+	}
+
+	for _, p := range d.Waypoints {
+		kw.open(2, "Placemark")
+		kw.text(3, "name", p.Name)
+		kw.text(3, "description", p.Desc)
+		kw.open(3, "Point")
+		kw.text(4, "coordinates", kmlCoordinates([]Point{p}))
+		kw.close(3, "Point")
+		kw.close(2, "Placemark")
+	}
+	for _, r := range d.Routes {
+		if len(r.Points) == 0 {
+			continue
+		}
+		kw.open(2, "Placemark")
+		kw.text(3, "name", r.Name)
+		kw.text(3, "description", r.Desc)
+		kw.lineString(3, r.Points)
+		kw.close(2, "Placemark")
+	}
+	for _, t := range d.Tracks {
+		var lines [][]Point
+		for _, s := range t.Segments {
+			if len(s.Points) > 0 {
+				lines = append(lines, s.Points)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		kw.open(2, "Placemark")
+		kw.text(3, "name", t.Name)
+		kw.text(3, "description", t.Desc)
+		if len(lines) == 1 {
+			kw.lineString(3, lines[0])
+		} else {
+			kw.open(3, "MultiGeometry")
+			for _, line := range lines {
+				kw.lineString(4, line)
+			}
+			kw.close(3, "MultiGeometry")
+		}
+		kw.close(2, "Placemark")
+	}
+
+	kw.close(1, "Document")
+	kw.raw("</kml>\n")
+
+	if kw.err != nil {
+		return kw.err
+	}
+	return kw.w.Flush()
+}
+
+func (g *gpxWriter) lineString(depth int, pts []Point) {
+	g.open(depth, "LineString")
+	g.text(depth+1, "tessellate", "1")
+	g.text(depth+1, "coordinates", kmlCoordinates(pts))
+	g.close(depth, "LineString")
+}
+
+func kmlCoordinates(pts []Point) string {
+	var b strings.Builder
+	for i, p := range pts {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(formatFloat(p.Lon))
+		b.WriteByte(',')
+		b.WriteString(formatFloat(p.Lat))
+		if p.Ele != nil {
+			b.WriteByte(',')
+			b.WriteString(formatFloat(*p.Ele))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package track
+
+import (
+	"sort"
+	"time"
+)
+
+// Merge combines documents into one with a single track. Sources are ordered
+// by their first timestamp (untimed sources keep their relative order, after
+// the timed ones). All track points go into one segment, or into one segment
+// per source when segmentPerSource is set. Metadata and the track's name,
+// type and extensions come from the earliest source; waypoints and routes of
+// all sources are kept.
+func Merge(docs []*Document, segmentPerSource bool) *Document {
+	ordered := make([]*Document, len(docs))
+	copy(ordered, docs)
+	starts := make(map[*Document]*time.Time, len(docs))
+	for _, d := range ordered {
+		starts[d] = d.firstTime()
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := starts[ordered[i]], starts[ordered[j]]
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return a.Before(*b)
+	})
+
+	out := &Document{}
+	trk := Track{}
+	var seg Segment
+	for i, d := range ordered {
+		if i == 0 {
+			out.Version, out.Creator, out.Metadata, out.Extensions = d.Version, d.Creator, d.Metadata, d.Extensions
+			if len(d.Tracks) > 0 {
+				t := d.Tracks[0]
+				trk.Name, trk.Comment, trk.Desc, trk.Source = t.Name, t.Comment, t.Desc, t.Source
+				trk.Links, trk.Type, trk.Extensions = t.Links, t.Type, t.Extensions
+			}
+		}
+		out.Waypoints = append(out.Waypoints, d.Waypoints...)
+		out.Routes = append(out.Routes, d.Routes...)
+		for _, t := range d.Tracks {
+			for _, s := range t.Segments {
+				seg.Points = append(seg.Points, s.Points...)
+			}
+		}
+		if segmentPerSource && len(seg.Points) > 0 {
+			trk.Segments = append(trk.Segments, seg)
+			seg = Segment{}
+		}
+	}
+	if len(seg.Points) > 0 {
+		trk.Segments = append(trk.Segments, seg)
+	}
+	if len(trk.Segments) > 0 {
+		out.Tracks = []Track{trk}
+	}
+	if out.Metadata != nil && out.Metadata.Bounds != nil {
+		meta := *out.Metadata
+		meta.Bounds = out.Stats().Bounds
+		out.Metadata = &meta
+	}
+	return out
+}
+
+// Split cuts the document's tracks into consecutive parts: a new part starts
+// at the first point recorded at or after each time in at, and after every
+// gap of at least pause between two timestamped points (pause 0 disables
+// this). Track and segment structure, metadata and extensions are kept in
+// every part. Timed waypoints go to the part covering their time; untimed
+// ones, those logged before the first part and all routes go to the first
+// part. Parts without track points are left out.
+func (d *Document) Split(at []time.Time, pause time.Duration) []*Document {
+	cuts := make([]time.Time, len(at))
+	copy(cuts, at)
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].Before(cuts[j]) })
+
+	type cursor struct{ track, segment int }
+	var parts []*Document
+	var last []cursor
+	var partStarts []*time.Time
+	part, nextCut := 0, 0
+	var prevTime *time.Time
+
+	for ti, t := range d.Tracks {
+		for si, s := range t.Segments {
+			for _, p := range s.Points {
+				if p.Time != nil {
+					if pause > 0 && prevTime != nil && p.Time.Sub(*prevTime) >= pause {
+						part++
+					}
+					for nextCut < len(cuts) && !p.Time.Before(cuts[nextCut]) {
+						part++
+						nextCut++
+					}
+					prevTime = p.Time
+				}
+				for len(parts) <= part {
+					meta := d.Metadata
+					if meta != nil && meta.Bounds != nil {
+						m := *meta
+						meta = &m
+					}
+					parts = append(parts, &Document{Version: d.Version, Creator: d.Creator, Metadata: meta, Extensions: d.Extensions})
+					last = append(last, cursor{-1, -1})
+					partStarts = append(partStarts, nil)
+				}
+				doc := parts[part]
+				if last[part].track != ti {
+					nt := t
+					nt.Segments = nil
+					doc.Tracks = append(doc.Tracks, nt)
+					last[part] = cursor{ti, -1}
+				}
+				nt := &doc.Tracks[len(doc.Tracks)-1]
+				if last[part].segment != si {
+					nt.Segments = append(nt.Segments, Segment{Extensions: s.Extensions})
+					last[part].segment = si
+				}
+				ns := &nt.Segments[len(nt.Segments)-1]
+				ns.Points = append(ns.Points, p)
+				if partStarts[part] == nil && p.Time != nil {
+					partStarts[part] = p.Time
+				}
+			}
+		}
+	}
+
+	var out []*Document
+	var pending []Point // waypoints of left-out parts, passed on to the next part
+	for i, doc := range parts {
+		for _, w := range d.Waypoints {
+			if waypointPart(w, partStarts) == i {
+				pending = append(pending, w)
+			}
+		}
+		if doc.TrackPointCount() == 0 {
+			continue
+		}
+		if len(out) == 0 {
+			doc.Routes = d.Routes
+		}
+		doc.Waypoints, pending = pending, nil
+		if doc.Metadata != nil && doc.Metadata.Bounds != nil {
+			doc.Metadata.Bounds = doc.Stats().Bounds
+		}
+		out = append(out, doc)
+	}
+	return out
+}
+
+// waypointPart returns the index of the last part starting at or before the
+// waypoint's time, or 0 when the waypoint is untimed, precedes all parts or
+// no part has a time.
+func waypointPart(w Point, starts []*time.Time) int {
+	if w.Time == nil {
+		return 0
+	}
+	idx := 0
+	for i, st := range starts {
+		if st != nil && !w.Time.Before(*st) {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// firstTime returns the earliest timestamp of the document's track points.
+func (d *Document) firstTime() *time.Time {
+	var first *time.Time
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for _, p := range s.Points {
+				if p.Time != nil && (first == nil || p.Time.Before(*first)) {
+					first = p.Time
+				}
+			}
+		}
+	}
+	return first
+}
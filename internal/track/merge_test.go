@@ -0,0 +1,115 @@
+package track
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	late, err := ParseGPX(strings.NewReader(`<gpx version="1.1"><wpt lat="60" lon="25"><name>Cafe</name></wpt><trk><name>Afternoon</name><trkseg>
+<trkpt lat="60.0" lon="25"><time>2025-06-01T14:00:00Z</time></trkpt>
+<trkpt lat="60.1" lon="25"><time>2025-06-01T14:10:00Z</time></trkpt>
+</trkseg></trk></gpx>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	early, err := ParseGPX(strings.NewReader(trimGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := Merge([]*Document{late, early}, false)
+	if len(merged.Tracks) != 1 || len(merged.Tracks[0].Segments) != 1 {
+		t.Fatalf("expected one track with one segment, got %+v", merged.Tracks)
+	}
+	pts := merged.Tracks[0].Segments[0].Points
+	if len(pts) != 8 || pts[0].Lat != 59 || pts[7].Lat != 60.1 {
+		t.Errorf("expected the earlier source first, got %d points", len(pts))
+	}
+	if merged.Tracks[0].Name != "Hike" || len(merged.Tracks[0].Extensions) != 1 || merged.Metadata.Name != "Hike" {
+		t.Errorf("track and metadata should come from the earliest source: %+v", merged.Tracks[0])
+	}
+	if len(merged.Waypoints) != 2 {
+		t.Errorf("expected waypoints of both sources, got %d", len(merged.Waypoints))
+	}
+	if b := merged.Metadata.Bounds; b == nil || b.MaxLat != 60.1 {
+		t.Errorf("expected recomputed bounds, got %+v", b)
+	}
+	if early.Metadata.Bounds.MaxLat != 59.005 {
+		t.Error("sources must not be modified")
+	}
+
+	merged = Merge([]*Document{late, early}, true)
+	if segs := merged.Tracks[0].Segments; len(segs) != 2 || len(segs[0].Points) != 6 || len(segs[1].Points) != 2 {
+		t.Errorf("expected one segment per source, got %+v", segs)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	doc, err := ParseGPX(strings.NewReader(trimGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := func(parts []*Document) []int {
+		var n []int
+		for _, p := range parts {
+			n = append(n, p.TrackPointCount())
+		}
+		return n
+	}
+
+	// Both 30 minute gaps are pauses; the untimed point stays with its
+	// predecessor.
+	parts := doc.Split(nil, 25*time.Minute)
+	if got := counts(parts); len(got) != 3 || got[0] != 4 || got[1] != 1 || got[2] != 1 {
+		t.Fatalf("expected parts of 4, 1 and 1 points, got %v", got)
+	}
+	if parts[0].Tracks[0].Name != "Hike" || len(parts[1].Tracks[0].Extensions) != 1 || len(parts[1].Tracks[0].Segments) != 1 {
+		t.Errorf("track data should be kept in every part: %+v", parts[1].Tracks)
+	}
+	if len(parts[0].Waypoints) != 1 || len(parts[1].Waypoints) != 0 {
+		t.Error("untimed waypoints belong to the first part")
+	}
+	if b := parts[1].Metadata.Bounds; b == nil || b.MinLat != 59.004 || doc.Metadata.Bounds.MinLat != 59 {
+		t.Errorf("expected per-part bounds without touching the source, got %+v", b)
+	}
+
+	at := []time.Time{time.Date(2025, 6, 1, 9, 15, 0, 0, time.UTC), time.Date(2025, 6, 1, 8, 5, 0, 0, time.UTC)}
+	if got := counts(doc.Split(at, 0)); len(got) != 3 || got[0] != 1 || got[1] != 4 || got[2] != 1 {
+		t.Errorf("expected parts of 1, 4 and 1 points, got %v", got)
+	}
+	if got := doc.Split([]time.Time{time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}, 0); len(got) != 1 {
+		t.Errorf("a cut after the track should leave it whole, got %d parts", len(got))
+	}
+}
+
+func TestSplit_Waypoints(t *testing.T) {
+	early := strings.Replace(trimGPX, `<wpt lat="59" lon="24"><name>Start</name></wpt>`,
+		`<wpt lat="59" lon="24"><time>2025-06-01T07:50:00Z</time><name>Parked</name></wpt>`+
+			`<wpt lat="59.004" lon="24"><time>2025-06-01T09:10:00Z</time><name>Summit</name></wpt>`, 1)
+	doc, err := ParseGPX(strings.NewReader(early))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := doc.Split(nil, 25*time.Minute)
+	if len(parts) != 3 || len(parts[0].Waypoints) != 1 || parts[0].Waypoints[0].Name != "Parked" {
+		t.Fatalf("a waypoint logged before the first point belongs to the first part, got %+v", parts)
+	}
+	if len(parts[1].Waypoints) != 1 || parts[1].Waypoints[0].Name != "Summit" || len(parts[2].Waypoints) != 0 {
+		t.Errorf("timed waypoints should follow their part: %+v %+v", parts[1].Waypoints, parts[2].Waypoints)
+	}
+
+	// Without any track point times every waypoint stays with the one part.
+	untimed := regexp.MustCompile(`<time>[^<]*</time>`).ReplaceAllString(early, "")
+	untimed = strings.Replace(untimed, `<wpt lat="59" lon="24">`, `<wpt lat="59" lon="24"><time>2025-06-01T07:50:00Z</time>`, 1)
+	doc, err = ParseGPX(strings.NewReader(untimed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts := doc.Split(nil, time.Minute); len(parts) != 1 || len(parts[0].Waypoints) != 2 {
+		t.Errorf("expected one part with both waypoints, got %+v", parts)
+	}
+}
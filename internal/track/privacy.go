@@ -0,0 +1,140 @@
+package track
+
+import "fmt"
+
+// PrivacyZone is an area whose points are withheld from untrusted clients:
+// either a circle (Lat, Lon and Radius in meters) or a polygon of [lat, lon]
+// vertices.
+type PrivacyZone struct {
+	Name    string       `json:"name,omitempty"`
+	Lat     float64      `json:"lat,omitempty"`
+	Lon     float64      `json:"lon,omitempty"`
+	Radius  float64      `json:"radius,omitempty"`
+	Polygon [][2]float64 `json:"polygon,omitempty"`
+}
+
+// Validate reports whether the zone describes exactly one usable shape.
+func (z PrivacyZone) Validate() error {
+	switch {
+	case len(z.Polygon) > 0 && z.Radius != 0:
+		return fmt.Errorf("zone %q: use either a radius or a polygon", z.Name)
+	case len(z.Polygon) > 0 && len(z.Polygon) < 3:
+		return fmt.Errorf("zone %q: polygon needs at least 3 vertices", z.Name)
+	case len(z.Polygon) == 0 && z.Radius <= 0:
+		return fmt.Errorf("zone %q: radius must be positive", z.Name)
+	case len(z.Polygon) == 0 && (z.Lat < -90 || z.Lat > 90 || z.Lon < -180 || z.Lon > 180):
+		return fmt.Errorf("zone %q: center out of range", z.Name)
+	}
+	return nil
+}
+
+// Contains reports whether the coordinate lies inside the zone.
+func (z PrivacyZone) Contains(lat, lon float64) bool {
+	if len(z.Polygon) == 0 {
+		return Haversine(z.Lat, z.Lon, lat, lon) <= z.Radius
+	}
+	// Even-odd ray casting; zones are small enough to treat degrees as planar.
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a[0] > lat) != (b[0] > lat) && lon < (b[1]-a[1])*(lat-a[0])/(b[0]-a[0])+a[1] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Redact returns a copy of the document with the points inside any of the
+// zones removed. By default every such point goes and segments are split
+// where points were removed, so no line is drawn through a zone. With
+// truncate only the leading and trailing runs of each segment and route
+// inside a zone are cut, hiding where a recording started and ended while
+// keeping passes through a zone. Waypoints inside a zone are always removed;
+// metadata bounds are recomputed when present.
+func (d *Document) Redact(zones []PrivacyZone, truncate bool) *Document {
+	if len(zones) == 0 {
+		return d
+	}
+	hidden := func(p Point) bool {
+		for _, z := range zones {
+			if z.Contains(p.Lat, p.Lon) {
+				return true
+			}
+		}
+		return false
+	}
+
+	out := *d
+	out.Waypoints = nil
+	for _, w := range d.Waypoints {
+		if !hidden(w) {
+			out.Waypoints = append(out.Waypoints, w)
+		}
+	}
+
+	out.Routes = nil
+	for _, r := range d.Routes {
+		for _, pts := range redactRun(r.Points, hidden, truncate) {
+			nr := r
+			nr.Points = pts
+			out.Routes = append(out.Routes, nr)
+		}
+	}
+
+	out.Tracks = nil
+	for _, t := range d.Tracks {
+		nt := t
+		nt.Segments = nil
+		for _, s := range t.Segments {
+			for _, pts := range redactRun(s.Points, hidden, truncate) {
+				nt.Segments = append(nt.Segments, Segment{Points: pts, Extensions: s.Extensions})
+			}
+		}
+		if len(nt.Segments) > 0 {
+			out.Tracks = append(out.Tracks, nt)
+		}
+	}
+
+	if d.Metadata != nil && d.Metadata.Bounds != nil {
+		meta := *d.Metadata
+		meta.Bounds = out.Stats().Bounds
+		out.Metadata = &meta
+	}
+	return &out
+}
+
+// redactRun returns the visible stretches of a point sequence: the maximal
+// runs outside the zones, or with truncate the one stretch between the first
+// and the last visible point.
+func redactRun(points []Point, hidden func(Point) bool, truncate bool) [][]Point {
+	var runs [][]Point
+	if truncate {
+		first, last := -1, -1
+		for i, p := range points {
+			if !hidden(p) {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first >= 0 {
+			runs = append(runs, points[first:last+1:last+1])
+		}
+		return runs
+	}
+	start := -1
+	for i, p := range points {
+		switch {
+		case !hidden(p) && start < 0:
+			start = i
+		case hidden(p) && start >= 0:
+			runs = append(runs, points[start:i:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, points[start:len(points):len(points)])
+	}
+	return runs
+}
@@ -0,0 +1,89 @@
+package track
+
+import (
+	"strings"
+	"testing"
+)
+
+const privacyGPX = `<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+<metadata><bounds minlat="59" minlon="24" maxlat="59.05" maxlon="24"/></metadata>
+<wpt lat="59.0001" lon="24"><name>Home</name></wpt>
+<wpt lat="59.03" lon="24"><name>View</name></wpt>
+<trk><trkseg>
+  <trkpt lat="59.000" lon="24"/>
+  <trkpt lat="59.001" lon="24"/>
+  <trkpt lat="59.010" lon="24"/>
+  <trkpt lat="59.020" lon="24"/>
+  <trkpt lat="59.030" lon="24.0005"/>
+  <trkpt lat="59.040" lon="24"/>
+  <trkpt lat="59.050" lon="24"/>
+  <trkpt lat="59.0002" lon="24"/>
+</trkseg></trk>
+</gpx>`
+
+func TestPrivacyZoneContains(t *testing.T) {
+	circle := PrivacyZone{Lat: 59, Lon: 24, Radius: 200}
+	if !circle.Contains(59.001, 24) || circle.Contains(59.01, 24) {
+		t.Error("circle should contain points within its radius only")
+	}
+	square := PrivacyZone{Polygon: [][2]float64{{59.025, 23.99}, {59.025, 24.01}, {59.035, 24.01}, {59.035, 23.99}}}
+	if !square.Contains(59.03, 24) || square.Contains(59.04, 24) || square.Contains(59.03, 24.02) {
+		t.Error("polygon should contain points inside its outline only")
+	}
+
+	for _, z := range []PrivacyZone{
+		{Lat: 59, Lon: 24},
+		{Lat: 99, Lon: 24, Radius: 10},
+		{Radius: 10, Polygon: square.Polygon},
+		{Polygon: square.Polygon[:2]},
+	} {
+		if z.Validate() == nil {
+			t.Errorf("expected %+v to be rejected", z)
+		}
+	}
+	if circle.Validate() != nil || square.Validate() != nil {
+		t.Error("valid zones should pass")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	doc, err := ParseGPX(strings.NewReader(privacyGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := []PrivacyZone{
+		{Name: "Home", Lat: 59, Lon: 24, Radius: 200},
+		{Polygon: [][2]float64{{59.025, 23.99}, {59.025, 24.01}, {59.035, 24.01}, {59.035, 23.99}}},
+	}
+
+	stripped := doc.Redact(zones, false)
+	segs := stripped.Tracks[0].Segments
+	if len(segs) != 2 || len(segs[0].Points) != 2 || len(segs[1].Points) != 2 {
+		t.Fatalf("expected the track split around both zones, got %+v", segs)
+	}
+	if segs[0].Points[0].Lat != 59.01 || segs[1].Points[1].Lat != 59.05 {
+		t.Errorf("unexpected points kept: %+v", segs)
+	}
+	if len(stripped.Waypoints) != 0 {
+		t.Errorf("waypoints inside zones should be removed, got %+v", stripped.Waypoints)
+	}
+	if b := stripped.Metadata.Bounds; b == nil || b.MinLat != 59.01 {
+		t.Errorf("expected recomputed bounds, got %+v", b)
+	}
+
+	truncated := doc.Redact(zones, true)
+	if segs := truncated.Tracks[0].Segments; len(segs) != 1 || len(segs[0].Points) != 5 || segs[0].Points[2].Lat != 59.03 {
+		t.Errorf("truncate should only cut the start and end, got %+v", segs)
+	}
+
+	if doc.TrackPointCount() != 8 || len(doc.Waypoints) != 2 {
+		t.Error("the source document must not be modified")
+	}
+	if doc.Redact(nil, false) != doc {
+		t.Error("no zones should leave the document as is")
+	}
+	everything := []PrivacyZone{{Lat: 59.025, Lon: 24, Radius: 5000}}
+	if out := doc.Redact(everything, false); len(out.Tracks) != 0 {
+		t.Errorf("a zone covering the whole track should remove it, got %+v", out.Tracks)
+	}
+}
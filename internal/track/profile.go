@@ -0,0 +1,159 @@
+package track
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// speedWindow is how many points on each side contribute to a sample's
+	// speed, which evens out GPS jitter between consecutive fixes.
+	speedWindow = 2
+	// gradeSpan is the along-track distance in meters on each side used for
+	// the grade, so a single noisy elevation cannot produce a 40% spike.
+	gradeSpan = 50.0
+	// minGradeRun avoids dividing by tiny distances when standing still.
+	minGradeRun = 10.0
+)
+
+// ProfileSample is one point of an elevation/speed profile. Distance is
+// cumulative along all routes and segments; Segment is the index of the line
+// the point belongs to so charts can break between segments.
+type ProfileSample struct {
+	Distance float64    `json:"distance"`
+	Ele      *float64   `json:"ele,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	Speed    *float64   `json:"speed,omitempty"` // m/s
+	Grade    *float64   `json:"grade,omitempty"` // percent
+	Lat      float64    `json:"lat"`
+	Lon      float64    `json:"lon"`
+	Segment  int        `json:"segment"`
+}
+
+// Profile returns the full-resolution profile of the document's lines,
+// downsampled to at most maxSamples (when maxSamples > 0). Downsampling keeps
+// the first and last points and picks real points, so every sample's lat/lon
+// lies on the track.
+func (d *Document) Profile(maxSamples int) []ProfileSample {
+	var samples []ProfileSample
+	total := 0.0
+	for seg, line := range d.Lines() {
+		start := len(samples)
+		for i, p := range line {
+			if i > 0 {
+				total += Distance(line[i-1], p)
+			}
+			s := ProfileSample{Distance: total, Lat: p.Lat, Lon: p.Lon, Segment: seg}
+			if p.Ele != nil {
+				e := *p.Ele
+				s.Ele = &e
+			}
+			if p.Time != nil {
+				t := *p.Time
+				s.Time = &t
+			}
+			samples = append(samples, s)
+		}
+		fillSpeedAndGrade(samples[start:])
+	}
+
+	if maxSamples > 0 && len(samples) > maxSamples {
+		samples = downsample(samples, maxSamples)
+	}
+	return samples
+}
+
+func fillSpeedAndGrade(line []ProfileSample) {
+	for i := range line {
+		lo, hi := max(i-speedWindow, 0), min(i+speedWindow, len(line)-1)
+		if a, b := line[lo], line[hi]; a.Time != nil && b.Time != nil {
+			if dt := b.Time.Sub(*a.Time).Seconds(); dt > 0 {
+				v := (b.Distance - a.Distance) / dt
+				line[i].Speed = &v
+			}
+		}
+
+		lo, hi = i, i
+		for lo > 0 && line[i].Distance-line[lo].Distance < gradeSpan {
+			lo--
+		}
+		for hi < len(line)-1 && line[hi].Distance-line[i].Distance < gradeSpan {
+			hi++
+		}
+		a, b := line[lo], line[hi]
+		if run := b.Distance - a.Distance; a.Ele != nil && b.Ele != nil && run >= minGradeRun {
+			g := (*b.Ele - *a.Ele) / run * 100
+			line[i].Grade = &g
+		}
+	}
+}
+
+// downsample applies Largest-Triangle-Three-Buckets over (distance,
+// elevation), which preserves peaks and valleys far better than taking every
+// nth point. Without elevation data it picks evenly spaced points instead.
+func downsample(samples []ProfileSample, n int) []ProfileSample {
+	if n < 3 {
+		if n <= 1 {
+			return samples[:n]
+		}
+		return []ProfileSample{samples[0], samples[len(samples)-1]}
+	}
+
+	// Missing elevations inherit the previous value, as in Stats.
+	ys := make([]float64, len(samples))
+	hasEle := false
+	last := 0.0
+	for i, s := range samples {
+		if s.Ele != nil {
+			last = *s.Ele
+			if !hasEle {
+				for j := range ys[:i] {
+					ys[j] = last
+				}
+			}
+			hasEle = true
+		}
+		ys[i] = last
+	}
+
+	out := make([]ProfileSample, 0, n)
+	out = append(out, samples[0])
+	bucket := float64(len(samples)-2) / float64(n-2)
+	prev := 0
+	for b := 0; b < n-2; b++ {
+		start := int(float64(b)*bucket) + 1
+		end := int(float64(b+1)*bucket) + 1
+
+		// Average of the next bucket (or the last point) as the third vertex.
+		nextStart, nextEnd := end, min(int(float64(b+2)*bucket)+1, len(samples))
+		if b == n-3 {
+			nextStart, nextEnd = len(samples)-1, len(samples)
+		}
+		avgX, avgY := 0.0, 0.0
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += samples[j].Distance
+			avgY += ys[j]
+		}
+		cnt := float64(nextEnd - nextStart)
+		avgX, avgY = avgX/cnt, avgY/cnt
+
+		best, bestArea := start, math.Inf(-1)
+		for j := start; j < end; j++ {
+			var area float64
+			if hasEle {
+				area = math.Abs((samples[prev].Distance-avgX)*(ys[j]-ys[prev]) -
+					(samples[prev].Distance-samples[j].Distance)*(avgY-ys[prev]))
+			} else {
+				// Closest to the bucket's midpoint distance.
+				mid := (samples[start].Distance + samples[end-1].Distance) / 2
+				area = -math.Abs(samples[j].Distance - mid)
+			}
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		out = append(out, samples[best])
+		prev = best
+	}
+	return append(out, samples[len(samples)-1])
+}
@@ -0,0 +1,108 @@
+package track
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func profileDoc(n int, ele func(i int) float64) *Document {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < n; i++ {
+		e := ele(i)
+		t := start.Add(time.Duration(i) * 10 * time.Second)
+		// ~11.1 m per step northwards.
+		points = append(points, Point{Lat: 59 + float64(i)*0.0001, Lon: 24, Ele: &e, Time: &t})
+	}
+	return &Document{Tracks: []Track{{Segments: []Segment{{Points: points}}}}}
+}
+
+func TestProfile_FullResolution(t *testing.T) {
+	// Steady 1 m climb per ~11.1 m step: a ~9% grade at ~1.1 m/s.
+	doc := profileDoc(50, func(i int) float64 { return float64(i) })
+	samples := doc.Profile(0)
+
+	if len(samples) != 50 {
+		t.Fatalf("expected 50 samples, got %d", len(samples))
+	}
+	last := samples[len(samples)-1]
+	if math.Abs(last.Distance-doc.Stats().Distance) > 1e-6 {
+		t.Errorf("cumulative distance %.2f should match stats %.2f", last.Distance, doc.Stats().Distance)
+	}
+	mid := samples[25]
+	if mid.Speed == nil || math.Abs(*mid.Speed-1.11) > 0.02 {
+		t.Errorf("expected ~1.11 m/s, got %v", mid.Speed)
+	}
+	if mid.Grade == nil || math.Abs(*mid.Grade-9.0) > 0.2 {
+		t.Errorf("expected ~9%% grade, got %v", mid.Grade)
+	}
+	if mid.Lat != 59.0025 || mid.Time == nil {
+		t.Errorf("sample should carry its point's position and time: %+v", mid)
+	}
+}
+
+func TestProfile_DownsampleKeepsPeaks(t *testing.T) {
+	// Flat with one sharp spike that every-nth sampling would likely miss.
+	doc := profileDoc(10000, func(i int) float64 {
+		if i == 4321 {
+			return 500
+		}
+		return 100
+	})
+	samples := doc.Profile(200)
+
+	if len(samples) != 200 {
+		t.Fatalf("expected 200 samples, got %d", len(samples))
+	}
+	if samples[0].Distance != 0 || samples[199].Lat != 59+9999*0.0001 {
+		t.Error("downsampling must keep the first and last points")
+	}
+	foundPeak := false
+	for i, s := range samples {
+		if s.Ele != nil && *s.Ele == 500 {
+			foundPeak = true
+		}
+		if i > 0 && s.Distance < samples[i-1].Distance {
+			t.Fatalf("samples out of order at %d", i)
+		}
+	}
+	if !foundPeak {
+		t.Error("expected the elevation spike to survive downsampling")
+	}
+}
+
+func TestProfile_WithoutElevationOrTime(t *testing.T) {
+	doc := &Document{Routes: []Route{{Points: []Point{
+		{Lat: 59, Lon: 24}, {Lat: 59.001, Lon: 24}, {Lat: 59.002, Lon: 24}, {Lat: 59.003, Lon: 24}, {Lat: 59.004, Lon: 24},
+	}}}}
+	samples := doc.Profile(3)
+
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	if samples[1].Lat != 59.002 {
+		t.Errorf("expected the middle point, got %+v", samples[1])
+	}
+	for _, s := range samples {
+		if s.Ele != nil || s.Speed != nil || s.Grade != nil || s.Time != nil {
+			t.Errorf("unexpected derived values: %+v", s)
+		}
+	}
+}
+
+func TestProfile_Segments(t *testing.T) {
+	doc := &Document{Tracks: []Track{{Segments: []Segment{
+		{Points: []Point{{Lat: 59, Lon: 24}, {Lat: 59.001, Lon: 24}}},
+		{Points: []Point{{Lat: 60, Lon: 24}, {Lat: 60.001, Lon: 24}}},
+	}}}}
+	samples := doc.Profile(0)
+
+	if len(samples) != 4 || samples[2].Segment != 1 {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+	// The jump between segments does not count towards distance.
+	if samples[2].Distance != samples[1].Distance {
+		t.Errorf("distance should not include the gap between segments: %+v", samples)
+	}
+}
@@ -0,0 +1,166 @@
+package track
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// SegmentTolerance is how far (meters) a track may pass from a segment's
+	// checkpoints and still count as riding it, which absorbs ordinary GPS
+	// error and the width of a road.
+	SegmentTolerance = 25.0
+
+	// checkpointSpacing is the distance (meters) between the checkpoints a
+	// traversal must pass in order.
+	checkpointSpacing = 50.0
+)
+
+// Traversal is one pass along a segment: from the closest approach to its
+// start to the closest approach to its end.
+type Traversal struct {
+	Start   time.Time `json:"start"`
+	Seconds float64   `json:"seconds"`
+}
+
+// Length returns the length of a line in meters.
+func Length(line []Point) float64 {
+	total := 0.0
+	for i := 1; i < len(line); i++ {
+		total += Distance(line[i-1], line[i])
+	}
+	return total
+}
+
+// checkpoints samples a segment every checkpointSpacing meters, always
+// including both ends, and returns the distance along the segment between
+// consecutive checkpoints.
+func checkpoints(segment []Point) (cps []Point, gaps []float64) {
+	cps = []Point{segment[0]}
+	last, next := 0.0, checkpointSpacing
+	along := 0.0
+	for i := 1; i < len(segment); i++ {
+		a, b := segment[i-1], segment[i]
+		step := Distance(a, b)
+		for step > 0 && along+step > next {
+			f := (next - along) / step
+			cps = append(cps, Point{Lat: a.Lat + f*(b.Lat-a.Lat), Lon: a.Lon + f*(b.Lon-a.Lon)})
+			gaps = append(gaps, next-last)
+			last, next = next, next+checkpointSpacing
+		}
+		along += step
+	}
+	if along > last {
+		cps = append(cps, segment[len(segment)-1])
+		gaps = append(gaps, along-last)
+	}
+	return cps, gaps
+}
+
+// MatchSegment finds every traversal of segment by the timed track points of
+// the document, in the direction the segment is drawn. A traversal has to
+// pass within SegmentTolerance of every checkpoint along the segment in
+// order, without covering more than twice the distance between two
+// checkpoints (plus the tolerance) on the way, so detours and tracks that
+// only touch the start and end do not count. Distances are measured to the
+// lines between track points, which keeps sparse recordings matchable, and
+// times are interpolated at the closest approach to the start and end.
+// Traversals do not overlap.
+func (d *Document) MatchSegment(segment []Point) []Traversal {
+	if len(segment) < 2 {
+		return nil
+	}
+	cps, gaps := checkpoints(segment)
+	if len(cps) < 2 {
+		return nil
+	}
+
+	var out []Traversal
+	for _, t := range d.Tracks {
+		for _, seg := range t.Segments {
+			var timed []Point
+			for _, p := range seg.Points {
+				if p.Time != nil {
+					timed = append(timed, p)
+				}
+			}
+			out = append(out, matchLine(timed, cps, gaps)...)
+		}
+	}
+	return out
+}
+
+// matchLine runs MatchSegment over one line of timed points.
+func matchLine(pts []Point, cps []Point, gaps []float64) []Traversal {
+	if len(pts) < 2 {
+		return nil
+	}
+	cum := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		cum[i] = cum[i-1] + Distance(pts[i-1], pts[i])
+	}
+	// pos is a position on the line: edge i (from pts[i] to pts[i+1]) and
+	// the fraction f along it.
+	type pos struct {
+		i int
+		f float64
+	}
+	along := func(p pos) float64 { return cum[p.i] + p.f*(cum[p.i+1]-cum[p.i]) }
+	at := func(p pos) time.Time {
+		a, b := *pts[p.i].Time, *pts[p.i+1].Time
+		return a.Add(time.Duration(p.f * float64(b.Sub(a))))
+	}
+	// approach finds the first edge from `from` on that comes within
+	// SegmentTolerance of cp and returns the closest position within that
+	// run of nearby edges, giving up once the line has gone further than
+	// limit meters past from.
+	approach := func(cp Point, from pos, limit float64) (pos, bool) {
+		start := along(from)
+		for i := from.i; i < len(pts)-1; i++ {
+			if cum[i]-start > limit {
+				return pos{}, false
+			}
+			d, f := closestOnSegment(cp.Lat, cp.Lon, pts[i], pts[i+1])
+			if i == from.i && f < from.f {
+				continue
+			}
+			if d > SegmentTolerance {
+				continue
+			}
+			best, bestD := pos{i, f}, d
+			for j := i + 1; j < len(pts)-1; j++ {
+				d, f := closestOnSegment(cp.Lat, cp.Lon, pts[j], pts[j+1])
+				if d > SegmentTolerance {
+					break
+				}
+				if d < bestD {
+					best, bestD = pos{j, f}, d
+				}
+			}
+			return best, true
+		}
+		return pos{}, false
+	}
+
+	var out []Traversal
+	from := pos{0, 0}
+	for {
+		start, ok := approach(cps[0], from, math.Inf(1))
+		if !ok {
+			return out
+		}
+		cur := start
+		for k := 1; k < len(cps) && ok; k++ {
+			cur, ok = approach(cps[k], cur, 2*gaps[k-1]+SegmentTolerance)
+		}
+		if secs := at(cur).Sub(at(start)).Seconds(); ok && secs > 0 && along(cur) > along(start) {
+			out = append(out, Traversal{Start: at(start), Seconds: secs})
+			from = cur
+			continue
+		}
+		// Try again from the next edge.
+		if from = (pos{start.i + 1, 0}); from.i >= len(pts)-1 {
+			return out
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package track
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMatchSegment(t *testing.T) {
+	segment := []Point{{Lat: 59, Lon: 24}, {Lat: 59.005, Lon: 24}, {Lat: 59.01, Lon: 24}}
+	length := Length(segment)
+	start := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// ride returns points every ~20 m between two latitudes at 5 m/s, with
+	// a few meters of zig-zag GPS noise.
+	ride := func(from, to float64, at time.Time) ([]Point, time.Time) {
+		var pts []Point
+		step := 0.00018 * math.Copysign(1, to-from)
+		for i := 0; ; i++ {
+			lat := from + float64(i)*step
+			if (step > 0 && lat > to) || (step < 0 && lat < to) {
+				break
+			}
+			tm := at.Add(time.Duration(i*4) * time.Second)
+			noise := 0.0001 * float64(i%3-1) // about ±6 m
+			pts = append(pts, Point{Lat: lat, Lon: 24 + noise, Time: &tm})
+		}
+		return pts, *pts[len(pts)-1].Time
+	}
+	doc := func(lines ...[]Point) *Document {
+		var segs []Segment
+		for _, l := range lines {
+			segs = append(segs, Segment{Points: l})
+		}
+		return &Document{Tracks: []Track{{Segments: segs}}}
+	}
+
+	t.Run("noisy ride", func(t *testing.T) {
+		pts, _ := ride(58.998, 59.012, start)
+		got := doc(pts).MatchSegment(segment)
+		if len(got) != 1 {
+			t.Fatalf("expected one traversal, got %+v", got)
+		}
+		if want := length / 5; math.Abs(got[0].Seconds-want) > 5 {
+			t.Errorf("expected about %.0f s, got %.1f s", want, got[0].Seconds)
+		}
+		if d := got[0].Start.Sub(start).Seconds(); math.Abs(d-Haversine(58.998, 24, 59, 24)/5) > 5 {
+			t.Errorf("unexpected start %v", got[0].Start)
+		}
+	})
+
+	t.Run("wrong direction", func(t *testing.T) {
+		pts, _ := ride(59.012, 58.998, start)
+		if got := doc(pts).MatchSegment(segment); len(got) != 0 {
+			t.Errorf("expected no traversal against the segment's direction, got %+v", got)
+		}
+	})
+
+	t.Run("out and back twice", func(t *testing.T) {
+		out1, at := ride(58.998, 59.012, start)
+		back, at := ride(59.012, 58.998, at.Add(time.Minute))
+		out2, _ := ride(58.998, 59.012, at.Add(time.Minute))
+		line := append(append(out1, back...), out2...)
+		if got := doc(line).MatchSegment(segment); len(got) != 2 {
+			t.Errorf("expected two traversals, got %+v", got)
+		}
+	})
+
+	t.Run("detour", func(t *testing.T) {
+		first, at := ride(58.998, 59.005, start)
+		east, west := at.Add(2*time.Minute), at.Add(4*time.Minute)
+		detour := []Point{{Lat: 59.005, Lon: 24.01, Time: &east}, {Lat: 59.0052, Lon: 24.01, Time: &west}}
+		second, _ := ride(59.0052, 59.012, west.Add(2*time.Minute))
+		line := append(append(first, detour...), second...)
+		if got := doc(line).MatchSegment(segment); len(got) != 0 {
+			t.Errorf("expected a detour not to count, got %+v", got)
+		}
+	})
+
+	t.Run("sparse recording", func(t *testing.T) {
+		a, b := start, start.Add(5*time.Minute)
+		pts := []Point{{Lat: 58.999, Lon: 24.0001, Time: &a}, {Lat: 59.011, Lon: 24.0001, Time: &b}}
+		got := doc(pts).MatchSegment(segment)
+		if len(got) != 1 || math.Abs(got[0].Seconds-300*length/Haversine(58.999, 24, 59.011, 24)) > 1 {
+			t.Errorf("expected an interpolated traversal, got %+v", got)
+		}
+	})
+
+	t.Run("elsewhere", func(t *testing.T) {
+		pts, _ := ride(60, 60.02, start)
+		if got := doc(pts).MatchSegment(segment); len(got) != 0 {
+			t.Errorf("expected no traversal, got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package track
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Sensor readings are carried as Garmin extensions, the form GPX exports from
+// watches and apps already use, so they survive a round trip through GPX.
+const (
+	TrackPointExtensionNS = "http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+	PowerExtensionNS      = "http://www.garmin.com/xmlschemas/PowerExtension/v1"
+)
+
+// Sensors is one sample of optional sensor data attached to a point.
+type Sensors struct {
+	HeartRate   *float64 // bpm
+	Cadence     *float64 // rpm
+	Temperature *float64 // °C
+	Power       *float64 // W
+}
+
+// Extensions encodes the readings as TrackPointExtension/PowerExtension
+// elements. It returns nil when there is nothing to encode.
+func (s Sensors) Extensions() []Extension {
+	var tpx []Extension
+	add := func(name string, v *float64) {
+		if v != nil {
+			tpx = append(tpx, Extension{Space: TrackPointExtensionNS, Name: name, Text: strconv.FormatFloat(*v, 'f', -1, 64)})
+		}
+	}
+	// Schema order: atemp, wtemp, depth, hr, cad.
+	add("atemp", s.Temperature)
+	add("hr", s.HeartRate)
+	add("cad", s.Cadence)
+
+	var out []Extension
+	if len(tpx) > 0 {
+		out = append(out, Extension{Space: TrackPointExtensionNS, Name: "TrackPointExtension", Children: tpx})
+	}
+	if s.Power != nil {
+		out = append(out, Extension{Space: PowerExtensionNS, Name: "PowerInWatts", Text: strconv.FormatFloat(*s.Power, 'f', -1, 64)})
+	}
+	return out
+}
+
+// Sensors reads heart rate, cadence, temperature and power from the point's
+// extensions, accepting the common vendor spellings regardless of namespace.
+func (p Point) Sensors() Sensors {
+	var s Sensors
+	var walk func(exts []Extension)
+	walk = func(exts []Extension) {
+		for _, e := range exts {
+			if len(e.Children) > 0 {
+				walk(e.Children)
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(e.Text), 64)
+			if err != nil {
+				continue
+			}
+			switch strings.ToLower(e.Name) {
+			case "hr", "heartrate", "heartratebpm":
+				s.HeartRate = &v
+			case "cad", "cadence", "runcadence":
+				s.Cadence = &v
+			case "atemp", "temp", "temperature":
+				s.Temperature = &v
+			case "power", "powerinwatts", "watts":
+				s.Power = &v
+			}
+		}
+	}
+	walk(p.Extensions)
+	return s
+}
@@ -0,0 +1,129 @@
+package track
+
+import (
+	"container/heap"
+	"math"
+)
+
+// SimplifyDouglasPeucker removes points that lie within tolerance meters of
+// the line joining their retained neighbours. The first and last points are
+// always kept.
+func SimplifyDouglasPeucker(line []Point, tolerance float64) []Point {
+	if len(line) <= 2 || tolerance <= 0 {
+		return line
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// Iterative to avoid deep recursion on long, nearly straight tracks.
+	stack := [][2]int{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+
+		maxDist, index := 0.0, -1
+		for i := first + 1; i < last; i++ {
+			if d := DistanceToSegment(line[i].Lat, line[i].Lon, line[first], line[last]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index >= 0 && maxDist > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	out := make([]Point, 0, len(line)/4+2)
+	for i, p := range line {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// SimplifyVisvalingam repeatedly drops the point forming the smallest
+// triangle with its neighbours until every remaining triangle is at least
+// minArea square meters. It tends to keep the overall shape of wiggly trails
+// better than Douglas–Peucker at the same point count.
+func SimplifyVisvalingam(line []Point, minArea float64) []Point {
+	if len(line) <= 2 || minArea <= 0 {
+		return line
+	}
+
+	n := len(line)
+	prev := make([]int, n)
+	next := make([]int, n)
+	removed := make([]bool, n)
+	for i := range line {
+		prev[i], next[i] = i-1, i+1
+	}
+
+	h := &areaHeap{}
+	version := make([]int, n)
+	for i := 1; i < n-1; i++ {
+		heap.Push(h, areaItem{index: i, area: triangleArea(line[i-1], line[i], line[i+1])})
+	}
+
+	// A point's effective area never drops below that of a point removed
+	// before it, so the result does not depend on removal order artifacts.
+	maxRemoved := 0.0
+	for h.Len() > 0 {
+		it := heap.Pop(h).(areaItem)
+		if removed[it.index] || it.version != version[it.index] {
+			continue
+		}
+		area := math.Max(it.area, maxRemoved)
+		if area >= minArea {
+			break
+		}
+		maxRemoved = area
+		removed[it.index] = true
+
+		p, nx := prev[it.index], next[it.index]
+		next[p], prev[nx] = nx, p
+		for _, j := range [2]int{p, nx} {
+			if j == 0 || j == n-1 {
+				continue
+			}
+			version[j]++
+			heap.Push(h, areaItem{index: j, version: version[j], area: triangleArea(line[prev[j]], line[j], line[next[j]])})
+		}
+	}
+
+	out := make([]Point, 0, n/4+2)
+	for i, p := range line {
+		if !removed[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// triangleArea is the planar area in square meters around b.
+func triangleArea(a, b, c Point) float64 {
+	ax, ay := project(b.Lat, b.Lon, a.Lat, a.Lon)
+	cx, cy := project(b.Lat, b.Lon, c.Lat, c.Lon)
+	return math.Abs(ax*cy-ay*cx) / 2
+}
+
+type areaItem struct {
+	index   int
+	version int
+	area    float64
+}
+
+type areaHeap []areaItem
+
+func (h areaHeap) Len() int           { return len(h) }
+func (h areaHeap) Less(i, j int) bool { return h[i].area < h[j].area }
+func (h areaHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *areaHeap) Push(x any)        { *h = append(*h, x.(areaItem)) }
+func (h *areaHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,119 @@
+package track
+
+import (
+	"math"
+	"testing"
+)
+
+// zigzag returns a northbound line whose points alternate offset meters east
+// and west, with a single large detour in the middle.
+func zigzag(n int, offset float64) []Point {
+	dLon := offset / (earthRadius * math.Cos(59*math.Pi/180)) * 180 / math.Pi
+	var line []Point
+	for i := 0; i < n; i++ {
+		lon := 24.0
+		if i%2 == 1 {
+			lon += dLon
+		} else {
+			lon -= dLon
+		}
+		if i == n/2 {
+			lon += 100 * dLon
+		}
+		line = append(line, Point{Lat: 59 + float64(i)*0.001, Lon: lon})
+	}
+	return line
+}
+
+func TestSimplifyDouglasPeucker(t *testing.T) {
+	line := zigzag(101, 2)
+
+	got := SimplifyDouglasPeucker(line, 10)
+	// Start, detour (with the points framing it) and end survive; the ±2 m
+	// jitter does not.
+	if len(got) > 7 {
+		t.Fatalf("expected at most 7 points, got %d", len(got))
+	}
+	if got[0].Lat != line[0].Lat || got[len(got)-1].Lat != line[100].Lat {
+		t.Error("endpoints must be kept")
+	}
+	foundDetour := false
+	for _, p := range got {
+		if p.Lat == line[50].Lat {
+			foundDetour = true
+		}
+	}
+	if !foundDetour {
+		t.Error("expected the detour point to be kept")
+	}
+
+	if got := SimplifyDouglasPeucker(line, 1); len(got) != len(line) {
+		t.Errorf("a tolerance below the jitter should keep every point, got %d", len(got))
+	}
+	if got := SimplifyDouglasPeucker(line, 0); len(got) != len(line) {
+		t.Error("zero tolerance should return the input")
+	}
+}
+
+func TestSimplifyVisvalingam(t *testing.T) {
+	line := zigzag(101, 2)
+
+	got := SimplifyVisvalingam(line, 1000)
+	if len(got) < 3 || len(got) > 5 {
+		t.Fatalf("expected the detour to survive with few points, got %d", len(got))
+	}
+	if got[0].Lat != line[0].Lat || got[len(got)-1].Lat != line[100].Lat {
+		t.Error("endpoints must be kept")
+	}
+	foundDetour := false
+	for _, p := range got {
+		if p.Lat == line[50].Lat {
+			foundDetour = true
+		}
+	}
+	if !foundDetour {
+		t.Error("expected the detour point to be kept")
+	}
+
+	if got := SimplifyVisvalingam(line[:2], 1000); len(got) != 2 {
+		t.Error("two-point lines are returned unchanged")
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	ele := 12.5
+	doc := &Document{
+		Waypoints: []Point{{Lat: 59.5, Lon: 24.5, Name: "Hut"}},
+		Routes:    []Route{{Name: "Plan", Points: []Point{{Lat: 59, Lon: 24}, {Lat: 59.1, Lon: 24.1}}}},
+		Tracks: []Track{{Name: "Ride", Segments: []Segment{
+			{Points: []Point{{Lat: 59, Lon: 24}, {Lat: 59.001, Lon: 24}, {Lat: 59.002, Lon: 24}, {Lat: 59.003, Lon: 24}}},
+			{Points: []Point{{Lat: 60, Lon: 25, Ele: &ele}, {Lat: 60.1, Lon: 25}}},
+		}}},
+	}
+
+	fc := doc.GeoJSON(func(p []Point) []Point { return SimplifyDouglasPeucker(p, 10) })
+
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 {
+		t.Fatalf("unexpected collection: %+v", fc)
+	}
+	route, trk, wpt := fc.Features[0], fc.Features[1], fc.Features[2]
+	if route.Geometry.Type != "LineString" || route.Properties["name"] != "Plan" {
+		t.Errorf("unexpected route feature: %+v", route)
+	}
+	lines := trk.Geometry.Coordinates.([][][]float64)
+	if trk.Geometry.Type != "MultiLineString" || len(lines) != 2 {
+		t.Fatalf("unexpected track geometry: %+v", trk.Geometry)
+	}
+	if len(lines[0]) != 2 {
+		t.Errorf("expected the straight segment simplified to 2 points, got %d", len(lines[0]))
+	}
+	if got := lines[1][0]; len(got) != 3 || got[0] != 25 || got[1] != 60 || got[2] != 12.5 {
+		t.Errorf("expected [lon, lat, ele], got %v", got)
+	}
+	if wpt.Geometry.Type != "Point" || wpt.Properties["name"] != "Hut" {
+		t.Errorf("unexpected waypoint feature: %+v", wpt)
+	}
+	if len(fc.BBox) != 4 || fc.BBox[0] != 24 || fc.BBox[3] != 60.1 {
+		t.Errorf("unexpected bbox: %v", fc.BBox)
+	}
+}
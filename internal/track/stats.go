@@ -0,0 +1,159 @@
+package track
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// maxPointInterval mirrors leaflet-gpx's max_point_interval: gaps longer
+	// than this between consecutive points count as stopped time.
+	maxPointInterval = 15 * time.Second
+
+	elevationWindow    = 5
+	elevationThreshold = 0.5
+)
+
+type Stats struct {
+	StartTime     *time.Time
+	EndTime       *time.Time
+	Distance      float64 // meters
+	TotalTime     time.Duration
+	MovingTime    time.Duration
+	ElevationGain float64 // meters
+	ElevationLoss float64 // meters
+	Bounds        *Bounds
+	PointCount    int
+}
+
+// Lines returns every polyline in the document in the order leaflet-gpx
+// renders them: routes first, then track segments.
+func (d *Document) Lines() [][]Point {
+	var lines [][]Point
+	for _, r := range d.Routes {
+		if len(r.Points) > 0 {
+			lines = append(lines, r.Points)
+		}
+	}
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			if len(s.Points) > 0 {
+				lines = append(lines, s.Points)
+			}
+		}
+	}
+	return lines
+}
+
+// Stats computes the same figures the info panel shows: distance, total and
+// moving time, and smoothed elevation gain/loss.
+func (d *Document) Stats() Stats {
+	var st Stats
+	var elevations []float64
+
+	for _, line := range d.Lines() {
+		var lastEle *float64
+		for i, p := range line {
+			st.PointCount++
+			if st.Bounds == nil {
+				st.Bounds = NewBounds(p.Lat, p.Lon)
+			} else {
+				st.Bounds.Extend(p.Lat, p.Lon)
+			}
+
+			// Points without elevation inherit the previous one, as leaflet-gpx does.
+			if p.Ele != nil {
+				lastEle = p.Ele
+			}
+			if lastEle != nil {
+				elevations = append(elevations, *lastEle)
+			}
+
+			if p.Time != nil {
+				if st.StartTime == nil || p.Time.Before(*st.StartTime) {
+					t := *p.Time
+					st.StartTime = &t
+				}
+				if st.EndTime == nil || p.Time.After(*st.EndTime) {
+					t := *p.Time
+					st.EndTime = &t
+				}
+			}
+
+			if i == 0 {
+				continue
+			}
+			prev := line[i-1]
+			st.Distance += Distance(prev, p)
+			if prev.Time != nil && p.Time != nil {
+				dt := p.Time.Sub(*prev.Time)
+				if dt < 0 {
+					dt = -dt
+				}
+				if dt < maxPointInterval {
+					st.MovingTime += dt
+				}
+			}
+		}
+	}
+
+	if st.StartTime != nil && st.EndTime != nil {
+		st.TotalTime = st.EndTime.Sub(*st.StartTime)
+	}
+	if st.StartTime == nil && d.Metadata != nil && d.Metadata.Time != nil {
+		t := *d.Metadata.Time
+		st.StartTime = &t
+	}
+	if st.Bounds == nil {
+		for _, w := range d.Waypoints {
+			if st.Bounds == nil {
+				st.Bounds = NewBounds(w.Lat, w.Lon)
+			} else {
+				st.Bounds.Extend(w.Lat, w.Lon)
+			}
+		}
+	}
+
+	st.ElevationGain, st.ElevationLoss = SmoothedElevation(elevations)
+	return st
+}
+
+// SmoothedElevation is a port of calculateSmoothedElevation in utils.js: a
+// centered moving average followed by a dead band that ignores micro-noise.
+func SmoothedElevation(elevations []float64) (gain, loss float64) {
+	smoothed := smoothElevations(elevations)
+	for i := 1; i < len(smoothed); i++ {
+		diff := smoothed[i] - smoothed[i-1]
+		if math.Abs(diff) > elevationThreshold {
+			if diff > 0 {
+				gain += diff
+			} else {
+				loss -= diff
+			}
+		}
+	}
+	return gain, loss
+}
+
+// smoothElevations applies the centered moving average of
+// SmoothedElevation.
+func smoothElevations(elevations []float64) []float64 {
+	half := elevationWindow / 2
+	smoothed := make([]float64, len(elevations))
+	for i := range elevations {
+		lo := i - half
+		if lo < 0 {
+			lo = 0
+		}
+		hi := i + half
+		if hi > len(elevations)-1 {
+			hi = len(elevations) - 1
+		}
+		sum := 0.0
+		for j := lo; j <= hi; j++ {
+			sum += elevations[j]
+		}
+		smoothed[i] = sum / float64(hi-lo+1)
+	}
+	return smoothed
+}
@@ -0,0 +1,99 @@
+package track
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSmoothedElevation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []float64
+		wantGain float64
+		wantLoss float64
+	}{
+		{"empty", nil, 0, 0},
+		{"flat", []float64{10, 10, 10, 10}, 0, 0},
+		{"noise below threshold", []float64{10, 10.4, 10, 10.4, 10, 10.4}, 0, 0},
+		{"steady climb", []float64{0, 10, 20, 30, 40, 50, 60}, 40, 0},
+		{"up and down", []float64{0, 10, 20, 30, 20, 10, 0}, 8, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gain, loss := SmoothedElevation(tt.input)
+			if math.Abs(gain-tt.wantGain) > 1e-9 || math.Abs(loss-tt.wantLoss) > 1e-9 {
+				t.Errorf("got gain=%v loss=%v, want gain=%v loss=%v", gain, loss, tt.wantGain, tt.wantLoss)
+			}
+		})
+	}
+}
+
+func TestDocumentStats(t *testing.T) {
+	const input = `<gpx version="1.1">
+	<metadata><time>2025-01-01T07:00:00Z</time></metadata>
+	<trk><trkseg>
+		<trkpt lat="59.0" lon="24.0"><ele>10</ele><time>2025-01-01T08:00:00Z</time></trkpt>
+		<trkpt lat="59.001" lon="24.0"><ele>10</ele><time>2025-01-01T08:00:10Z</time></trkpt>
+		<trkpt lat="59.002" lon="24.0"><time>2025-01-01T08:05:00Z</time></trkpt>
+	</trkseg><trkseg>
+		<trkpt lat="59.1" lon="24.1"><ele>20</ele><time>2025-01-01T09:00:00Z</time></trkpt>
+		<trkpt lat="59.1" lon="24.101"><ele>20</ele><time>2025-01-01T09:00:05Z</time></trkpt>
+	</trkseg></trk>
+</gpx>`
+
+	doc, err := ParseGPX(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := doc.Stats()
+
+	if st.PointCount != 5 {
+		t.Errorf("expected 5 points, got %d", st.PointCount)
+	}
+	// The gap between segments is not counted as distance.
+	wantDist := Haversine(59.0, 24.0, 59.001, 24.0) + Haversine(59.001, 24.0, 59.002, 24.0) + Haversine(59.1, 24.1, 59.1, 24.101)
+	if math.Abs(st.Distance-wantDist) > 0.01 {
+		t.Errorf("expected distance %.2f, got %.2f", wantDist, st.Distance)
+	}
+	// 10s + 5s moving; the 4m50s gap exceeds the moving threshold.
+	if st.MovingTime != 15*time.Second {
+		t.Errorf("expected 15s moving time, got %v", st.MovingTime)
+	}
+	if st.TotalTime != time.Hour+5*time.Second {
+		t.Errorf("expected total time 1h0m5s, got %v", st.TotalTime)
+	}
+	wantStart := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	if st.StartTime == nil || !st.StartTime.Equal(wantStart) {
+		t.Errorf("expected start time from first point, got %v", st.StartTime)
+	}
+	if st.Bounds == nil || st.Bounds.MinLat != 59.0 || st.Bounds.MaxLat != 59.1 || st.Bounds.MaxLon != 24.101 {
+		t.Errorf("unexpected bounds: %+v", st.Bounds)
+	}
+}
+
+func TestDocumentStats_FallsBackToMetadataTime(t *testing.T) {
+	const input = `<gpx version="1.1">
+	<metadata><time>2023-09-17T10:12:46Z</time></metadata>
+	<wpt lat="68.3" lon="27.8"/>
+	<rte><rtept lat="68.2" lon="28.0"/><rtept lat="68.21" lon="28.0"/></rte>
+</gpx>`
+
+	doc, err := ParseGPX(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := doc.Stats()
+
+	if st.StartTime == nil || !st.StartTime.Equal(time.Date(2023, 9, 17, 10, 12, 46, 0, time.UTC)) {
+		t.Errorf("expected metadata start time, got %v", st.StartTime)
+	}
+	if st.PointCount != 2 || st.Distance == 0 {
+		t.Errorf("expected route points to count towards stats, got %+v", st)
+	}
+	if st.MovingTime != 0 || st.TotalTime != 0 {
+		t.Errorf("expected zero durations without timestamps, got %+v", st)
+	}
+}
@@ -0,0 +1,238 @@
+package track
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseTCX decodes a Garmin Training Center (TCX v2) document. Each activity
+// becomes a track and each <Track> inside its laps a segment (devices start a
+// new one after a pause); courses become tracks too, with their course points
+// as waypoints. Trackpoints without a position (heart rate only, indoor
+// sessions) are skipped.
+func ParseTCX(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+
+	var raw tcxXML
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid tcx: %w", err)
+	}
+	if raw.XMLName.Local != "TrainingCenterDatabase" {
+		return nil, fmt.Errorf("invalid tcx: unexpected root element %q", raw.XMLName.Local)
+	}
+	return raw.toDocument(), nil
+}
+
+type tcxXML struct {
+	XMLName    xml.Name
+	Activities []tcxActivityXML `xml:"Activities>Activity"`
+	Courses    []tcxCourseXML   `xml:"Courses>Course"`
+	Author     *struct {
+		Name string `xml:"Name"`
+	} `xml:"Author"`
+}
+
+type tcxActivityXML struct {
+	Sport   string        `xml:"Sport,attr"`
+	ID      string        `xml:"Id"`
+	Notes   string        `xml:"Notes"`
+	Laps    []tcxLapXML   `xml:"Lap"`
+	Creator *tcxDeviceXML `xml:"Creator"`
+}
+
+type tcxCourseXML struct {
+	Name   string           `xml:"Name"`
+	Notes  string           `xml:"Notes"`
+	Tracks []tcxTrackXML    `xml:"Track"`
+	Points []tcxCoursePtXML `xml:"CoursePoint"`
+}
+
+type tcxLapXML struct {
+	StartTime     string        `xml:"StartTime,attr"`
+	TotalTime     string        `xml:"TotalTimeSeconds"`
+	Distance      string        `xml:"DistanceMeters"`
+	MaximumSpeed  string        `xml:"MaximumSpeed"`
+	Calories      string        `xml:"Calories"`
+	AvgHeartRate  string        `xml:"AverageHeartRateBpm>Value"`
+	MaxHeartRate  string        `xml:"MaximumHeartRateBpm>Value"`
+	Intensity     string        `xml:"Intensity"`
+	Cadence       string        `xml:"Cadence"`
+	TriggerMethod string        `xml:"TriggerMethod"`
+	Tracks        []tcxTrackXML `xml:"Track"`
+	AvgSpeed      string        `xml:"Extensions>LX>AvgSpeed"`
+	AvgRunCadence string        `xml:"Extensions>LX>AvgRunCadence"`
+}
+
+type tcxTrackXML struct {
+	Points []tcxPointXML `xml:"Trackpoint"`
+}
+
+type tcxPointXML struct {
+	Time       string `xml:"Time"`
+	Lat        string `xml:"Position>LatitudeDegrees"`
+	Lon        string `xml:"Position>LongitudeDegrees"`
+	Altitude   string `xml:"AltitudeMeters"`
+	HeartRate  string `xml:"HeartRateBpm>Value"`
+	Cadence    string `xml:"Cadence"`
+	Speed      string `xml:"Extensions>TPX>Speed"`
+	RunCadence string `xml:"Extensions>TPX>RunCadence"`
+	Watts      string `xml:"Extensions>TPX>Watts"`
+}
+
+type tcxCoursePtXML struct {
+	Name      string `xml:"Name"`
+	Time      string `xml:"Time"`
+	Lat       string `xml:"Position>LatitudeDegrees"`
+	Lon       string `xml:"Position>LongitudeDegrees"`
+	Altitude  string `xml:"AltitudeMeters"`
+	PointType string `xml:"PointType"`
+	Notes     string `xml:"Notes"`
+}
+
+type tcxDeviceXML struct {
+	Name         string `xml:"Name"`
+	UnitID       string `xml:"UnitId"`
+	VersionMajor string `xml:"Version>VersionMajor"`
+	VersionMinor string `xml:"Version>VersionMinor"`
+}
+
+// tcxSports maps TCX sport names onto the names used for FIT sessions, so the
+// track type does not depend on which format a device exported.
+var tcxSports = map[string]string{"Running": "running", "Biking": "cycling", "Other": "generic"}
+
+func (t *tcxXML) toDocument() *Document {
+	doc := &Document{Creator: "TCX"}
+	if t.Author != nil && strings.TrimSpace(t.Author.Name) != "" {
+		doc.Creator = strings.TrimSpace(t.Author.Name)
+	}
+
+	for _, a := range t.Activities {
+		trk := Track{Desc: strings.TrimSpace(a.Notes), Type: tcxSports[a.Sport]}
+		if trk.Type == "" {
+			trk.Type = strings.ToLower(a.Sport)
+		}
+		session := Session{Sport: trk.Type}
+		session.StartTime = parseOptTime(a.ID)
+		if doc.Metadata == nil && session.StartTime != nil {
+			doc.Metadata = &Metadata{Time: session.StartTime}
+		}
+
+		for _, l := range a.Laps {
+			lap := l.toLap()
+			doc.Laps = append(doc.Laps, lap)
+			session.ElapsedTime += lap.ElapsedTime
+			session.Distance += lap.Distance
+			if session.StartTime == nil {
+				session.StartTime = lap.StartTime
+			}
+			for _, tr := range l.Tracks {
+				if seg := tr.toSegment(); len(seg.Points) > 0 {
+					trk.Segments = append(trk.Segments, seg)
+				}
+			}
+		}
+		doc.Sessions = append(doc.Sessions, session)
+		if a.Creator != nil && strings.TrimSpace(a.Creator.Name) != "" {
+			dev := Device{Product: strings.TrimSpace(a.Creator.Name), SerialNumber: strings.TrimSpace(a.Creator.UnitID)}
+			if a.Creator.VersionMajor != "" {
+				dev.SoftwareVersion = strings.TrimSpace(a.Creator.VersionMajor) + "." + strings.TrimSpace(a.Creator.VersionMinor)
+			}
+			doc.Devices = append(doc.Devices, dev)
+			if doc.Creator == "TCX" {
+				doc.Creator = dev.Product
+			}
+		}
+		if len(trk.Segments) > 0 {
+			doc.Tracks = append(doc.Tracks, trk)
+		}
+	}
+
+	for _, c := range t.Courses {
+		trk := Track{Name: strings.TrimSpace(c.Name), Desc: strings.TrimSpace(c.Notes)}
+		for _, tr := range c.Tracks {
+			if seg := tr.toSegment(); len(seg.Points) > 0 {
+				trk.Segments = append(trk.Segments, seg)
+			}
+		}
+		if len(trk.Segments) > 0 {
+			doc.Tracks = append(doc.Tracks, trk)
+		}
+		for _, cp := range c.Points {
+			p, ok := tcxPosition(cp.Lat, cp.Lon)
+			if !ok {
+				continue
+			}
+			p.Name = strings.TrimSpace(cp.Name)
+			p.Desc = strings.TrimSpace(cp.Notes)
+			p.Type = strings.TrimSpace(cp.PointType)
+			p.Time = parseOptTime(cp.Time)
+			p.Ele = parseOptFloat(cp.Altitude)
+			doc.Waypoints = append(doc.Waypoints, p)
+		}
+	}
+	return doc
+}
+
+func (l *tcxLapXML) toLap() Lap {
+	lap := Lap{
+		Summary: Summary{
+			StartTime:    parseOptTime(l.StartTime),
+			Calories:     parseOptInt(l.Calories),
+			AvgHeartRate: parseOptFloat(l.AvgHeartRate),
+			MaxHeartRate: parseOptFloat(l.MaxHeartRate),
+			AvgCadence:   parseOptFloat(l.Cadence),
+			AvgSpeed:     parseOptFloat(l.AvgSpeed),
+			MaxSpeed:     parseOptFloat(l.MaximumSpeed),
+		},
+		Intensity: strings.ToLower(strings.TrimSpace(l.Intensity)),
+		Trigger:   strings.ToLower(strings.TrimSpace(l.TriggerMethod)),
+	}
+	if lap.Intensity == "resting" {
+		lap.Intensity = "rest"
+	}
+	if lap.AvgCadence == nil {
+		lap.AvgCadence = parseOptFloat(l.AvgRunCadence)
+	}
+	if v := parseOptFloat(l.TotalTime); v != nil {
+		lap.ElapsedTime = *v
+	}
+	if v := parseOptFloat(l.Distance); v != nil {
+		lap.Distance = *v
+	}
+	return lap
+}
+
+func (t *tcxTrackXML) toSegment() Segment {
+	var seg Segment
+	for _, tp := range t.Points {
+		p, ok := tcxPosition(tp.Lat, tp.Lon)
+		if !ok {
+			continue
+		}
+		p.Time = parseOptTime(tp.Time)
+		p.Ele = parseOptFloat(tp.Altitude)
+		p.Speed = parseOptFloat(tp.Speed)
+		cadence := parseOptFloat(tp.Cadence)
+		if cadence == nil {
+			cadence = parseOptFloat(tp.RunCadence)
+		}
+		p.Extensions = Sensors{
+			HeartRate: parseOptFloat(tp.HeartRate),
+			Cadence:   cadence,
+			Power:     parseOptFloat(tp.Watts),
+		}.Extensions()
+		seg.Points = append(seg.Points, p)
+	}
+	return seg
+}
+
+func tcxPosition(lat, lon string) (Point, bool) {
+	la, lo := parseOptFloat(lat), parseOptFloat(lon)
+	if la == nil || lo == nil || *la < -90 || *la > 90 || *lo < -180 || *lo > 180 {
+		return Point{}, false
+	}
+	return Point{Lat: *la, Lon: *lo}, true
+}
@@ -0,0 +1,152 @@
+package track
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Id>2025-06-01T08:00:00Z</Id>
+      <Lap StartTime="2025-06-01T08:00:00Z">
+        <TotalTimeSeconds>40.0</TotalTimeSeconds>
+        <DistanceMeters>1234.5</DistanceMeters>
+        <MaximumSpeed>9.5</MaximumSpeed>
+        <Calories>25</Calories>
+        <AverageHeartRateBpm><Value>121</Value></AverageHeartRateBpm>
+        <MaximumHeartRateBpm><Value>130</Value></MaximumHeartRateBpm>
+        <Intensity>Active</Intensity>
+        <Cadence>80</Cadence>
+        <TriggerMethod>Manual</TriggerMethod>
+        <Track>
+          <Trackpoint>
+            <Time>2025-06-01T08:00:00Z</Time>
+            <Position><LatitudeDegrees>59.0000</LatitudeDegrees><LongitudeDegrees>24.0</LongitudeDegrees></Position>
+            <AltitudeMeters>10</AltitudeMeters>
+            <HeartRateBpm><Value>120</Value></HeartRateBpm>
+            <Cadence>80</Cadence>
+            <Extensions><ns3:TPX><ns3:Speed>5.5</ns3:Speed><ns3:Watts>210</ns3:Watts></ns3:TPX></Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2025-06-01T08:00:01Z</Time>
+            <HeartRateBpm><Value>121</Value></HeartRateBpm>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2025-06-01T08:00:10Z</Time>
+            <Position><LatitudeDegrees>59.0005</LatitudeDegrees><LongitudeDegrees>24.0</LongitudeDegrees></Position>
+            <AltitudeMeters>12</AltitudeMeters>
+          </Trackpoint>
+        </Track>
+        <Track>
+          <Trackpoint>
+            <Time>2025-06-01T08:00:40Z</Time>
+            <Position><LatitudeDegrees>59.0010</LatitudeDegrees><LongitudeDegrees>24.0</LongitudeDegrees></Position>
+            <AltitudeMeters>15</AltitudeMeters>
+          </Trackpoint>
+        </Track>
+      </Lap>
+      <Creator><Name>Edge 530</Name><UnitId>3900123456</UnitId><Version><VersionMajor>9</VersionMajor><VersionMinor>75</VersionMinor></Version></Creator>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestParseTCX(t *testing.T) {
+	doc, err := ParseTCX(strings.NewReader(testTCX))
+	if err != nil {
+		t.Fatalf("ParseTCX failed: %v", err)
+	}
+
+	if doc.Creator != "Edge 530" || doc.Metadata == nil || !doc.Metadata.Time.Equal(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected header: %q %+v", doc.Creator, doc.Metadata)
+	}
+	if len(doc.Tracks) != 1 || doc.Tracks[0].Type != "cycling" {
+		t.Fatalf("unexpected tracks: %+v", doc.Tracks)
+	}
+	segs := doc.Tracks[0].Segments
+	if len(segs) != 2 || len(segs[0].Points) != 2 || len(segs[1].Points) != 1 {
+		t.Fatalf("expected 2+1 points (positionless point skipped), got %+v", segs)
+	}
+
+	p := segs[0].Points[0]
+	if p.Ele == nil || *p.Ele != 10 || p.Speed == nil || *p.Speed != 5.5 {
+		t.Errorf("unexpected point: %+v", p)
+	}
+	s := p.Sensors()
+	if s.HeartRate == nil || *s.HeartRate != 120 || s.Cadence == nil || *s.Cadence != 80 || s.Power == nil || *s.Power != 210 {
+		t.Errorf("unexpected sensors: %+v", s)
+	}
+
+	if len(doc.Laps) != 1 {
+		t.Fatalf("unexpected laps: %+v", doc.Laps)
+	}
+	lap := doc.Laps[0]
+	if lap.ElapsedTime != 40 || lap.Distance != 1234.5 || *lap.Calories != 25 || *lap.MaxHeartRate != 130 || lap.Intensity != "active" || lap.Trigger != "manual" {
+		t.Errorf("unexpected lap: %+v", lap)
+	}
+	if len(doc.Sessions) != 1 || doc.Sessions[0].Sport != "cycling" || doc.Sessions[0].Distance != 1234.5 {
+		t.Errorf("unexpected sessions: %+v", doc.Sessions)
+	}
+	if len(doc.Devices) != 1 || doc.Devices[0].SerialNumber != "3900123456" || doc.Devices[0].SoftwareVersion != "9.75" {
+		t.Errorf("unexpected devices: %+v", doc.Devices)
+	}
+}
+
+func TestParseTCX_StatsMatchGPX(t *testing.T) {
+	tcx, err := ParseTCX(strings.NewReader(testTCX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, tcx); err != nil {
+		t.Fatal(err)
+	}
+	gpx, err := ParseGPX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gpx.Stats(), tcx.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats differ after conversion:\n gpx %+v\n tcx %+v", got, want)
+	}
+}
+
+func TestParseTCX_Course(t *testing.T) {
+	course := `<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Courses><Course>
+    <Name>Loop</Name>
+    <Track>
+      <Trackpoint><Position><LatitudeDegrees>59</LatitudeDegrees><LongitudeDegrees>24</LongitudeDegrees></Position></Trackpoint>
+      <Trackpoint><Position><LatitudeDegrees>59.01</LatitudeDegrees><LongitudeDegrees>24</LongitudeDegrees></Position></Trackpoint>
+    </Track>
+    <CoursePoint><Name>Water</Name><Position><LatitudeDegrees>59.005</LatitudeDegrees><LongitudeDegrees>24</LongitudeDegrees></Position><PointType>Water</PointType></CoursePoint>
+  </Course></Courses>
+  <Author><Name>Route planner</Name></Author>
+</TrainingCenterDatabase>`
+	doc, err := ParseTCX(strings.NewReader(course))
+	if err != nil {
+		t.Fatalf("ParseTCX failed: %v", err)
+	}
+	if doc.Creator != "Route planner" || len(doc.Tracks) != 1 || doc.Tracks[0].Name != "Loop" || len(doc.Tracks[0].Segments[0].Points) != 2 {
+		t.Errorf("unexpected course track: %q %+v", doc.Creator, doc.Tracks)
+	}
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Water" || doc.Waypoints[0].Type != "Water" {
+		t.Errorf("unexpected course points: %+v", doc.Waypoints)
+	}
+}
+
+func TestParseTCX_Errors(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":     "",
+		"not xml":   "TrainingCenterDatabase",
+		"wrong doc": `<gpx version="1.1"></gpx>`,
+	} {
+		if _, err := ParseTCX(strings.NewReader(data)); err == nil || !strings.HasPrefix(err.Error(), "invalid tcx") {
+			t.Errorf("%s: expected invalid tcx error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package track holds the structured representation of a GPS track file and
+// the readers that produce it.
+package track
+
+import "time"
+
+type Document struct {
+	Version    string      `json:"version,omitempty"`
+	Creator    string      `json:"creator,omitempty"`
+	Metadata   *Metadata   `json:"metadata,omitempty"`
+	Waypoints  []Point     `json:"waypoints,omitempty"`
+	Routes     []Route     `json:"routes,omitempty"`
+	Tracks     []Track     `json:"tracks,omitempty"`
+	Extensions []Extension `json:"extensions,omitempty"`
+
+	// Laps, Sessions and Devices come from device formats (FIT, TCX); GPX
+	// has no place for them, so they are not written back out.
+	Laps     []Lap     `json:"laps,omitempty"`
+	Sessions []Session `json:"sessions,omitempty"`
+	Devices  []Device  `json:"devices,omitempty"`
+}
+
+type Metadata struct {
+	Name       string      `json:"name,omitempty"`
+	Desc       string      `json:"desc,omitempty"`
+	Author     *Person     `json:"author,omitempty"`
+	Copyright  *Copyright  `json:"copyright,omitempty"`
+	Links      []Link      `json:"links,omitempty"`
+	Time       *time.Time  `json:"time,omitempty"`
+	Keywords   string      `json:"keywords,omitempty"`
+	Bounds     *Bounds     `json:"bounds,omitempty"`
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+type Person struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Link  *Link  `json:"link,omitempty"`
+}
+
+type Copyright struct {
+	Author  string `json:"author,omitempty"`
+	Year    string `json:"year,omitempty"`
+	License string `json:"license,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+	Type string `json:"type,omitempty"`
+}
+
+type Bounds struct {
+	MinLat float64 `json:"minLat"`
+	MinLon float64 `json:"minLon"`
+	MaxLat float64 `json:"maxLat"`
+	MaxLon float64 `json:"maxLon"`
+}
+
+type Route struct {
+	Name       string      `json:"name,omitempty"`
+	Comment    string      `json:"cmt,omitempty"`
+	Desc       string      `json:"desc,omitempty"`
+	Source     string      `json:"src,omitempty"`
+	Links      []Link      `json:"links,omitempty"`
+	Number     *int        `json:"number,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Points     []Point     `json:"points"`
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+type Track struct {
+	Name       string      `json:"name,omitempty"`
+	Comment    string      `json:"cmt,omitempty"`
+	Desc       string      `json:"desc,omitempty"`
+	Source     string      `json:"src,omitempty"`
+	Links      []Link      `json:"links,omitempty"`
+	Number     *int        `json:"number,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Segments   []Segment   `json:"segments"`
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+type Segment struct {
+	Points     []Point     `json:"points"`
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+// Point is a GPX waypoint; track points, route points and standalone
+// waypoints all share this shape.
+type Point struct {
+	Lat           float64     `json:"lat"`
+	Lon           float64     `json:"lon"`
+	Ele           *float64    `json:"ele,omitempty"`
+	Time          *time.Time  `json:"time,omitempty"`
+	MagVar        *float64    `json:"magvar,omitempty"`
+	GeoidHeight   *float64    `json:"geoidHeight,omitempty"`
+	Name          string      `json:"name,omitempty"`
+	Comment       string      `json:"cmt,omitempty"`
+	Desc          string      `json:"desc,omitempty"`
+	Source        string      `json:"src,omitempty"`
+	Links         []Link      `json:"links,omitempty"`
+	Sym           string      `json:"sym,omitempty"`
+	Type          string      `json:"type,omitempty"`
+	Fix           string      `json:"fix,omitempty"`
+	Sat           *int        `json:"sat,omitempty"`
+	HDOP          *float64    `json:"hdop,omitempty"`
+	VDOP          *float64    `json:"vdop,omitempty"`
+	PDOP          *float64    `json:"pdop,omitempty"`
+	AgeOfDGPSData *float64    `json:"ageOfDgpsData,omitempty"`
+	DGPSID        *int        `json:"dgpsId,omitempty"`
+	Course        *float64    `json:"course,omitempty"` // GPX 1.0 only
+	Speed         *float64    `json:"speed,omitempty"`  // GPX 1.0 and device formats
+	Extensions    []Extension `json:"extensions,omitempty"`
+}
+
+// Extension is a generic XML element found inside an <extensions> block (or,
+// for GPX 1.0, any foreign-namespace element). Space holds the namespace URI.
+type Extension struct {
+	Space    string      `json:"space,omitempty"`
+	Name     string      `json:"name"`
+	Attrs    []Attr      `json:"attrs,omitempty"`
+	Text     string      `json:"text,omitempty"`
+	Children []Extension `json:"children,omitempty"`
+}
+
+type Attr struct {
+	Space string `json:"space,omitempty"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Summary holds the totals a device records for a lap or a session. Times
+// are in seconds, distances in meters, speeds in m/s.
+type Summary struct {
+	StartTime    *time.Time `json:"startTime,omitempty"`
+	ElapsedTime  float64    `json:"elapsedTime"`
+	TimerTime    float64    `json:"timerTime,omitempty"`
+	Distance     float64    `json:"distance"`
+	Calories     *int       `json:"calories,omitempty"`
+	AvgHeartRate *float64   `json:"avgHeartRate,omitempty"`
+	MaxHeartRate *float64   `json:"maxHeartRate,omitempty"`
+	AvgCadence   *float64   `json:"avgCadence,omitempty"`
+	AvgSpeed     *float64   `json:"avgSpeed,omitempty"`
+	MaxSpeed     *float64   `json:"maxSpeed,omitempty"`
+	Ascent       *float64   `json:"ascent,omitempty"`
+	Descent      *float64   `json:"descent,omitempty"`
+}
+
+type Lap struct {
+	Summary
+	Intensity string `json:"intensity,omitempty"` // active, rest, warmup, cooldown
+	Trigger   string `json:"trigger,omitempty"`   // manual, distance, time, ...
+}
+
+type Session struct {
+	Summary
+	Sport    string `json:"sport,omitempty"`
+	SubSport string `json:"subSport,omitempty"`
+}
+
+// Device describes a recording device or sensor listed in the file.
+type Device struct {
+	Index           *int   `json:"index,omitempty"`
+	Manufacturer    string `json:"manufacturer,omitempty"`
+	Product         string `json:"product,omitempty"`
+	SerialNumber    string `json:"serialNumber,omitempty"`
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+}
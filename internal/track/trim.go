@@ -0,0 +1,73 @@
+package track
+
+import "time"
+
+// FilterTrackPoints returns a copy of the document keeping only the track
+// points for which keep returns true. index counts track points across all
+// tracks and segments in document order, starting at 0. Segments and tracks
+// left without points are dropped; routes, waypoints, metadata and all
+// extensions are kept, and metadata bounds are recomputed when present.
+func (d *Document) FilterTrackPoints(keep func(index int, p Point) bool) *Document {
+	out := *d
+	out.Tracks = nil
+	index := 0
+	for _, t := range d.Tracks {
+		nt := t
+		nt.Segments = nil
+		for _, s := range t.Segments {
+			ns := s
+			ns.Points = nil
+			for _, p := range s.Points {
+				if keep(index, p) {
+					ns.Points = append(ns.Points, p)
+				}
+				index++
+			}
+			if len(ns.Points) > 0 {
+				nt.Segments = append(nt.Segments, ns)
+			}
+		}
+		if len(nt.Segments) > 0 {
+			out.Tracks = append(out.Tracks, nt)
+		}
+	}
+
+	if d.Metadata != nil && d.Metadata.Bounds != nil {
+		meta := *d.Metadata
+		meta.Bounds = out.Stats().Bounds
+		out.Metadata = &meta
+	}
+	return &out
+}
+
+// TrimIndex keeps the track points whose index (as in FilterTrackPoints) lies
+// within [from, to].
+func (d *Document) TrimIndex(from, to int) *Document {
+	return d.FilterTrackPoints(func(i int, _ Point) bool { return i >= from && i <= to })
+}
+
+// TrimTime keeps the track points recorded within [start, end]; a zero start
+// or end leaves that side open. A point without a timestamp follows the
+// decision made for the point before it, so gaps in the time data do not cut
+// holes into the kept range.
+func (d *Document) TrimTime(start, end time.Time) *Document {
+	keepLast := false
+	return d.FilterTrackPoints(func(_ int, p Point) bool {
+		if p.Time != nil {
+			keepLast = (start.IsZero() || !p.Time.Before(start)) && (end.IsZero() || !p.Time.After(end))
+		}
+		return keepLast
+	})
+}
+
+// TrackPointCount returns the number of track points (routes and waypoints
+// are not counted).
+func (d *Document) TrackPointCount() int {
+	n := 0
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			n += len(s.Points)
+		}
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package track
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const trimGPX = `<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1" xmlns:x="urn:x">
+<metadata><name>Hike</name><bounds minlat="59" minlon="24" maxlat="59.005" maxlon="24"/><extensions><x:keep>1</x:keep></extensions></metadata>
+<wpt lat="59" lon="24"><name>Start</name></wpt>
+<trk><name>Hike</name><extensions><x:color>red</x:color></extensions>
+<trkseg>
+  <trkpt lat="59.000" lon="24"><time>2025-06-01T08:00:00Z</time><extensions><x:hr>100</x:hr></extensions></trkpt>
+  <trkpt lat="59.001" lon="24"><time>2025-06-01T08:10:00Z</time></trkpt>
+  <trkpt lat="59.002" lon="24"></trkpt>
+  <trkpt lat="59.003" lon="24"><time>2025-06-01T08:30:00Z</time></trkpt>
+</trkseg>
+<trkseg>
+  <trkpt lat="59.004" lon="24"><time>2025-06-01T09:00:00Z</time></trkpt>
+  <trkpt lat="59.005" lon="24"><time>2025-06-01T09:30:00Z</time></trkpt>
+</trkseg>
+</trk>
+</gpx>`
+
+func TestTrimIndex(t *testing.T) {
+	doc, err := ParseGPX(strings.NewReader(trimGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trimmed := doc.TrimIndex(0, 2)
+	if trimmed.TrackPointCount() != 3 || len(trimmed.Tracks[0].Segments) != 1 {
+		t.Fatalf("expected the first 3 points in one segment, got %+v", trimmed.Tracks)
+	}
+	if len(trimmed.Tracks[0].Extensions) != 1 || len(trimmed.Tracks[0].Segments[0].Points[0].Extensions) != 1 {
+		t.Error("track and point extensions should be preserved")
+	}
+	if trimmed.Metadata.Name != "Hike" || len(trimmed.Metadata.Extensions) != 1 || len(trimmed.Waypoints) != 1 {
+		t.Errorf("metadata and waypoints should be preserved: %+v", trimmed.Metadata)
+	}
+	if b := trimmed.Metadata.Bounds; b == nil || b.MaxLat != 59.002 {
+		t.Errorf("expected recomputed bounds, got %+v", b)
+	}
+	if doc.TrackPointCount() != 6 || doc.Metadata.Bounds.MaxLat != 59.005 {
+		t.Error("the source document must not be modified")
+	}
+
+	if doc.TrimIndex(10, 20).TrackPointCount() != 0 {
+		t.Error("out of range indices should select nothing")
+	}
+}
+
+func TestTrimTime(t *testing.T) {
+	doc, err := ParseGPX(strings.NewReader(trimGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trimmed := doc.TrimTime(time.Date(2025, 6, 1, 8, 5, 0, 0, time.UTC), time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+	var lats []float64
+	for _, line := range trimmed.Lines() {
+		for _, p := range line {
+			lats = append(lats, p.Lat)
+		}
+	}
+	// The untimed point follows its predecessor and is kept.
+	want := []float64{59.001, 59.002, 59.003, 59.004}
+	if len(lats) != len(want) {
+		t.Fatalf("expected %v, got %v", want, lats)
+	}
+	for i := range want {
+		if lats[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, lats)
+		}
+	}
+
+	if n := doc.TrimTime(time.Time{}, time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)).TrackPointCount(); n != 1 {
+		t.Errorf("open start should keep the first point only, got %d", n)
+	}
+}
@@ -239,7 +239,7 @@ async function bootstrapApp(options = {}) {
                 json: () => Promise.resolve(tileConfig)
             });
         }
-        if (url === '/api/gpx') {
+        if (url === '/api/gpx?duplicates=hide') {
             return Promise.resolve({
                 json: () => Promise.resolve(gpxFiles)
             });
@@ -321,7 +321,7 @@ describe('Offline cache pre-warming', () => {
 
         global.fetch.mockImplementation((url, opts) => {
             if (url === '/api/tile-config') return Promise.resolve({ json: () => Promise.resolve({ initial: 'opentopomap', offline: false, providers: { opentopomap: { name: 'OpenTopoMap', isTMS: false, minZoom: 0, maxZoom: 0 } } }) });
-            if (url === '/api/gpx') return Promise.resolve({ json: () => Promise.resolve([]) });
+            if (url === '/api/gpx?duplicates=hide') return Promise.resolve({ json: () => Promise.resolve([]) });
             if (url === '/api/prewarm-view') return prewarmSpy(url, opts);
             return Promise.resolve({ ok: true, status: 200, json: () => Promise.resolve({}) });
         });
@@ -413,7 +413,7 @@ describe('Offline cache pre-warming', () => {
                     })
                 });
             }
-            if (url === '/api/gpx') return Promise.resolve({ json: () => Promise.resolve([]) });
+            if (url === '/api/gpx?duplicates=hide') return Promise.resolve({ json: () => Promise.resolve([]) });
             return neverResolvingFetch(url, opts);
         });
 
@@ -663,7 +663,7 @@ describe('App Logic', () => {
         });
 
         test('fetches and lists files', () => {
-            expect(global.fetch).toHaveBeenCalledWith('/api/gpx');
+            expect(global.fetch).toHaveBeenCalledWith('/api/gpx?duplicates=hide');
             const list = document.getElementById('file-list');
             // Check if 3 items are rendered (excluding separators)
             expect(list.querySelectorAll('li:not(.year-separator)').length).toBe(3);
@@ -1300,7 +1300,7 @@ describe('App edge cases', () => {
         const { app } = await bootstrapApp();
 
         global.fetch.mockImplementation((url) => {
-            if (url === '/api/gpx') return Promise.reject(new Error('Internal Server Error'));
+            if (url === '/api/gpx?duplicates=hide') return Promise.reject(new Error('Internal Server Error'));
             return Promise.resolve({ ok: true, json: () => Promise.resolve({}) });
         });
 
@@ -1575,6 +1575,7 @@ describe('App edge cases', () => {
             expect(app.deriveActivity(null)).toBe('Other');
             expect(app.deriveActivity('')).toBe('Other');
             expect(app.deriveActivity('Activities/')).toBe('Other');
+            expect(app.deriveActivity('Activities/loose.gpx')).toBe('loose.gpx');
             expect(app.deriveActivity('Plans/path/to/file.gpx')).toBe('Plans');
             expect(app.deriveActivity('some/random/path.gpx')).toBe('some');
         });
@@ -1605,3 +1606,63 @@ describe('App edge cases', () => {
         });
     });
 });
+
+describe('Live library updates', () => {
+    const findChipByLabel = (label) => {
+        const chips = Array.from(document.querySelectorAll('#activity-filters .filter-chip'));
+        return chips.find(btn => btn.textContent.includes(label));
+    };
+
+    test('added files are inserted in date order', async () => {
+        const { app } = await bootstrapApp();
+        app.applyLibraryEvent({
+            type: 'file-added',
+            relativePath: 'Activities/runs/2023-02-01_Tempo.gpx',
+            file: { name: '2023-02-01_Tempo.gpx', path: '/data/Activities/runs/2023-02-01_Tempo.gpx', relativePath: 'Activities/runs/2023-02-01_Tempo.gpx' }
+        });
+
+        const titles = Array.from(document.querySelectorAll('#file-list li:not(.year-separator) .track-title')).map(el => el.textContent);
+        expect(titles).toEqual(['Evil', 'Tempo', 'Walk', 'Run']);
+        expect(document.getElementById('file-count').textContent).toBe('(4)');
+    });
+
+    test('changed files replace the existing entry', async () => {
+        const { app } = await bootstrapApp();
+        app.applyLibraryEvent({
+            type: 'file-changed',
+            relativePath: 'Activities/runs/2023-01-01_Run.gpx',
+            file: { name: '2023-01-01_Run.gpx', path: '/data/Activities/runs/2023-01-01_Run.gpx', relativePath: 'Activities/runs/2023-01-01_Run.gpx', stats: { distance: 5000 } }
+        });
+
+        expect(document.getElementById('file-count').textContent).toBe('(3)');
+    });
+
+    test('files marked as duplicates are hidden', async () => {
+        const { app } = await bootstrapApp();
+        app.applyLibraryEvent({
+            type: 'file-added',
+            relativePath: 'Activities/runs/2023-01-01_Run (1).gpx',
+            file: { name: '2023-01-01_Run (1).gpx', path: '/data/Activities/runs/2023-01-01_Run (1).gpx', relativePath: 'Activities/runs/2023-01-01_Run (1).gpx', duplicateOf: 'Activities/runs/2023-01-01_Run.gpx' }
+        });
+        expect(document.getElementById('file-count').textContent).toBe('(3)');
+
+        app.applyLibraryEvent({
+            type: 'file-changed',
+            relativePath: 'Activities/runs/2023-01-01_Run (1).gpx',
+            file: { name: '2023-01-01_Run (1).gpx', path: '/data/Activities/runs/2023-01-01_Run (1).gpx', relativePath: 'Activities/runs/2023-01-01_Run (1).gpx' }
+        });
+        expect(document.getElementById('file-count').textContent).toBe('(4)');
+    });
+
+    test('removed files disappear and selected activities survive', async () => {
+        const { app } = await bootstrapApp();
+        findChipByLabel('runs').click();
+        expect(document.getElementById('file-count').textContent).toBe('(1)');
+
+        app.applyLibraryEvent({ type: 'file-removed', relativePath: 'Activities/walks/2023-01-02_Walk.gpx' });
+
+        expect(findChipByLabel('walks')).toBeUndefined();
+        expect(findChipByLabel('runs').classList.contains('active')).toBe(true);
+        expect(document.getElementById('file-count').textContent).toBe('(1)');
+    });
+});
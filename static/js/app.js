@@ -4,7 +4,7 @@
 import { state, ui, resetState, constants } from './state.js';
 import { initMap, setupThemeToggle, normalizeTheme, getCurrentTheme, setTheme } from './map.js';
 import { initMapLayer } from './tiles.js';
-import { fetchFiles, setView, applyFilters, renderFileList } from './files.js';
+import { fetchFiles, setView, applyFilters, renderFileList, subscribeToLibraryEvents, applyLibraryEvent } from './files.js';
 import { setupMultiTrackToggle, focusTrack, toggleTrackVisibility, addTrack, removeTrack, updateInfoPanel } from './tracks.js';
 import { setupDrawControl, updateExportButtonState, exportGPX } from './draw.js';
 import * as utils from './utils.js';
@@ -51,6 +51,9 @@ async function init() {
 
     // Initial data fetch
     await fetchFiles();
+
+    // Keep the list in sync with files added/changed/removed on the server
+    subscribeToLibraryEvents();
 }
 
 // Start the app
@@ -85,5 +88,6 @@ export {
     focusTrack,
     resetState,
     init,
-    exportGPX
+    exportGPX,
+    applyLibraryEvent
 };
@@ -143,29 +143,9 @@ export function applyFilters() {
 
 export async function fetchFiles() {
     try {
-        const response = await fetch('/api/gpx');
+        const response = await fetch('/api/gpx?duplicates=hide');
         const files = await response.json();
-        const filesWithActivity = utils.addActivityToFiles(files || []);
-        state.hasPlanFiles = filesWithActivity.some(f => (f.activity || '').toLowerCase() === 'plans');
-        const activities = new Set(filesWithActivity.filter(f => (f.activity || '').toLowerCase() !== 'plans').map(f => f.activity));
-
-        state.allFiles = filesWithActivity.sort((a, b) => {
-            const dateA = utils.parseDateFromFilename(a.name);
-            const dateB = utils.parseDateFromFilename(b.name);
-            if (dateA && dateB) {
-                const timeDiff = dateB - dateA;
-                if (timeDiff !== 0) return timeDiff;
-            } else if (dateA) return -1;
-            else if (dateB) return 1;
-
-            const aKey = (a.relativePath || a.name || '').toLowerCase();
-            const bKey = (b.relativePath || b.name || '').toLowerCase();
-            return bKey.localeCompare(aKey);
-        });
-
-        setupActivityFilters(activities);
-        updateViewToggleUiState();
-        updateActivityFilterVisibility();
+        setLibraryFiles(utils.addActivityToFiles(files || []));
         applyFilters();
     } catch (error) {
         console.error('Error fetching files:', error);
@@ -174,6 +154,89 @@ export async function fetchFiles() {
     }
 }
 
+function sortFiles(files) {
+    return files.sort((a, b) => {
+        const dateA = utils.parseDateFromFilename(a.name);
+        const dateB = utils.parseDateFromFilename(b.name);
+        if (dateA && dateB) {
+            const timeDiff = dateB - dateA;
+            if (timeDiff !== 0) return timeDiff;
+        } else if (dateA) return -1;
+        else if (dateB) return 1;
+
+        const aKey = (a.relativePath || a.name || '').toLowerCase();
+        const bKey = (b.relativePath || b.name || '').toLowerCase();
+        return bKey.localeCompare(aKey);
+    });
+}
+
+function setLibraryFiles(filesWithActivity) {
+    // Copies of another file (see /api/duplicates) would list the same activity twice.
+    filesWithActivity = filesWithActivity.filter(f => !f.duplicateOf);
+    state.hasPlanFiles = filesWithActivity.some(f => (f.activity || '').toLowerCase() === 'plans');
+    const activities = new Set(filesWithActivity.filter(f => (f.activity || '').toLowerCase() !== 'plans').map(f => f.activity));
+
+    state.allFiles = sortFiles(filesWithActivity);
+
+    setupActivityFilters(activities);
+    updateViewToggleUiState();
+    updateActivityFilterVisibility();
+}
+
+// --- Live updates (Server-Sent Events) ---
+
+const LIBRARY_EVENT_TYPES = ['file-added', 'file-changed', 'file-removed'];
+
+export function subscribeToLibraryEvents() {
+    if (typeof EventSource === 'undefined') return null;
+    if (state.libraryEvents) return state.libraryEvents;
+
+    const source = new EventSource('/api/events');
+    let connectedBefore = false;
+    source.addEventListener('open', () => {
+        // Events that happened while disconnected are lost; resync on reconnect.
+        if (connectedBefore) fetchFiles();
+        connectedBefore = true;
+    });
+    LIBRARY_EVENT_TYPES.forEach(type => {
+        source.addEventListener(type, (e) => {
+            try {
+                applyLibraryEvent(JSON.parse(e.data));
+            } catch (error) {
+                console.error('Invalid library event:', error);
+            }
+        });
+    });
+
+    state.libraryEvents = source;
+    return source;
+}
+
+export function applyLibraryEvent(event) {
+    if (!event || !event.relativePath) return;
+
+    const files = state.allFiles.filter(f => f.relativePath !== event.relativePath);
+    if (event.type !== 'file-removed' && event.file) {
+        files.push(...utils.addActivityToFiles([event.file]));
+    }
+
+    // Rebuilding the chips resets the selection; keep what is still valid.
+    const previousSelection = new Set(state.selectedActivities);
+    setLibraryFiles(files);
+    const available = new Set(state.activityKeyMap.values());
+    previousSelection.forEach(activity => {
+        if (available.has(activity)) state.selectedActivities.add(activity);
+    });
+    if (state.currentView === 'plans' && !state.hasPlanFiles) {
+        state.currentView = 'activities';
+        updateViewToggleUiState();
+        updateActivityFilterVisibility();
+    }
+
+    updateActivityFilterStyles();
+    applyFilters();
+}
+
 function renderListMessage(text, className = '') {
     const li = document.createElement('li');
     if (className) li.className = className;
@@ -244,6 +307,10 @@ function createFileListItem(file) {
         if (state.focusedTrackPath === file.path) li.classList.add('active');
     }
 
+    if (file.relativePath && file.stats) {
+        li.appendChild(createTrackThumbnail(file));
+    }
+
     const infoDiv = createTrackInfo(file);
     li.appendChild(infoDiv);
     li.title = file.relativePath || file.name;
@@ -251,11 +318,25 @@ function createFileListItem(file) {
     return li;
 }
 
+// Thumbnails are rendered and cached server-side; lazy loading keeps long
+// lists from requesting more than is on screen.
+function createTrackThumbnail(file) {
+    const img = document.createElement('img');
+    img.className = 'track-thumb';
+    img.alt = '';
+    img.loading = 'lazy';
+    img.width = constants.THUMBNAIL_SIZE;
+    img.height = constants.THUMBNAIL_SIZE;
+    const encodedPath = file.relativePath.split('/').map(encodeURIComponent).join('/');
+    img.src = `/api/gpx/${encodedPath}/thumbnail.svg?size=${constants.THUMBNAIL_SIZE}`;
+    return img;
+}
+
 function createTrackInfo(file) {
     const infoDiv = document.createElement('div');
     infoDiv.className = 'track-info';
 
-    const rawName = (file.name || '').replace(/\.gpx$/i, '');
+    const rawName = (file.name || '').replace(/\.(gpx|fit|tcx|kml|kmz)$/i, '');
     const dateMatch = rawName.match(/^(\d{4}[-\d]*)(?:[\s_]+)(.*)/);
     const activity = file.activity || 'Other';
     const folder = utils.getDisplayFolder(file.relativePath, activity);
@@ -26,6 +26,9 @@ export const state = {
 
     // Leaflet Draw bits
     drawnItems: null,
+
+    // Live library updates
+    libraryEvents: null,
 };
 
 // Use getters for UI elements to avoid stale references in tests and ensure they are found when needed
@@ -79,6 +82,8 @@ export function resetState() {
     state.prewarmInProgress = false;
     state.prewarmStatusText = null;
     state.drawnItems = null;
+    if (state.libraryEvents) state.libraryEvents.close();
+    state.libraryEvents = null;
 }
 
 export const constants = {
@@ -95,6 +100,7 @@ export const constants = {
         '#FF8000'  // Orange
     ],
     LAYER_CONTROL_POSITION: 'bottomleft',
+    THUMBNAIL_SIZE: 48,
     ACTIVITY_ICON_MAP: {
         backpacking: 'fa-mountain',
         'speed hiking': 'fa-person-hiking',
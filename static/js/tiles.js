@@ -53,7 +53,19 @@ export async function initMapLayer() {
         if (state.layerControl) {
             state.map.removeControl(state.layerControl);
         }
-        state.layerControl = L.control.layers(baseLayers, null, { position: constants.LAYER_CONTROL_POSITION }).addTo(state.map);
+        // Overlays are rendered by the server (e.g. the activity heatmap) and
+        // sit on top of whichever base layer is active.
+        const overlays = {};
+        Object.keys(config.overlays || {}).forEach(key => {
+            const overlay = config.overlays[key];
+            overlays[overlay.name] = L.tileLayer(`/tiles/${key}/{z}/{x}/{y}.png`, {
+                maxZoom: overlay.maxZoom || 18,
+                minZoom: overlay.minZoom || 0,
+                attribution: overlay.attribution
+            });
+        });
+
+        state.layerControl = L.control.layers(baseLayers, overlays, { position: constants.LAYER_CONTROL_POSITION }).addTo(state.map);
         ensureDownloadCurrentViewOverlay();
 
         state.map.on('baselayerchange', (e) => {